@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"os"
 	"os/signal"
 	"syscall"
 
@@ -13,7 +16,19 @@ import (
 	"github.com/niksmo/runlytics/internal/server/config"
 )
 
+// commands are server subcommands, the server is run without one.
+var commands = map[string]func(args []string, out io.Writer) error{
+	migrateCmd: runMigrate,
+	tokenCmd:   runToken,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			runCommand(cmd, os.Args[2:])
+		}
+	}
+
 	buildinfo.Print()
 	cfg := config.Load()
 	logger.Init(cfg.Log.Level)
@@ -29,9 +44,21 @@ func main() {
 	go application.HTTPServer.MustRun()
 	go application.GRPCServer.MustRun()
 	go application.Storage.MustRun()
+	go application.Expirer.MustRun()
 
 	<-stopCtx.Done()
 	application.HTTPServer.Stop()
 	application.GRPCServer.Stop()
+	application.Expirer.Stop()
 	application.Storage.Stop()
 }
+
+// runCommand runs subcommand with args and exits with its status,
+// the output of subcommand is not prefixed by build info.
+func runCommand(cmd func(args []string, out io.Writer) error, args []string) {
+	if err := cmd(args, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	psqlstorage "github.com/niksmo/runlytics/internal/server/storage/postgresql"
+)
+
+const (
+	migrateCmd     = "migrate"
+	migrateTimeout = 5 * time.Minute
+	migrateUsage   = `Usage: %s migrate [-d dsn] status|up
+
+Commands:
+  status  show applied and pending database migrations
+  up      apply pending database migrations
+
+Flags:
+`
+)
+
+var errMigrateUsage = errors.New("migrate command is required: status or up")
+
+// runMigrate runs database migrations subcommand, args follow "migrate".
+// DSN is read from flag "-d" or enviroment variable DATABASE_DSN.
+func runMigrate(args []string, out io.Writer) error {
+	flagSet := flag.NewFlagSet(migrateCmd, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), migrateUsage, os.Args[0])
+		flagSet.PrintDefaults()
+	}
+	dsn := flagSet.String(
+		"d", os.Getenv("DATABASE_DSN"), "Data source, enviroment DATABASE_DSN",
+	)
+	flagSet.Parse(args)
+
+	if *dsn == "" {
+		return errors.New("database dsn is not set")
+	}
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return errMigrateUsage
+	}
+
+	logger.Init("info")
+	storage := psqlstorage.New(*dsn, 0, 0)
+	defer storage.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	switch flagSet.Arg(0) {
+	case "status":
+		return printMigrationStatus(ctx, storage, out)
+	case "up":
+		applied, err := storage.Migrate(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations\n", len(applied))
+		return nil
+	default:
+		flagSet.Usage()
+		return errMigrateUsage
+	}
+}
+
+func printMigrationStatus(
+	ctx context.Context, storage *psqlstorage.PSQLStorage, out io.Writer,
+) error {
+	status, err := storage.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range status {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Unknown {
+			state = "unknown"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	psqlstorage "github.com/niksmo/runlytics/internal/server/storage/postgresql"
+	"github.com/niksmo/runlytics/pkg/token"
+)
+
+const (
+	tokenCmd     = "token"
+	tokenTimeout = time.Minute
+	tokenUsage   = `Usage: %s token [-d dsn] [-id id] [-scope scopes] issue|revoke|list
+
+Commands:
+  issue   issue token, print the token and its config spec if dsn is not set
+  revoke  revoke database token with id, tokens of TOKENS config are
+          revoked by removing their specs from the config
+  list    show database tokens
+
+Flags:
+`
+)
+
+var (
+	errTokenUsage = errors.New("token command is required: issue, revoke or list")
+	errTokenDSN   = errors.New(
+		"database dsn is not set, tokens of TOKENS config are managed " +
+			"by the config only",
+	)
+)
+
+// runToken runs API tokens subcommand, args follow "token".
+// DSN is read from flag "-d" or enviroment variable DATABASE_DSN,
+// without DSN only issue command is available.
+func runToken(args []string, out io.Writer) error {
+	flagSet := flag.NewFlagSet(tokenCmd, flag.ExitOnError)
+	flagSet.Usage = func() {
+		fmt.Fprintf(flagSet.Output(), tokenUsage, os.Args[0])
+		flagSet.PrintDefaults()
+	}
+	dsn := flagSet.String(
+		"d", os.Getenv("DATABASE_DSN"), "Data source, enviroment DATABASE_DSN",
+	)
+	id := flagSet.String("id", "", "Token id, random if not set")
+	scopes := flagSet.String(
+		"scope", string(token.ScopeRead), "Token scopes, e.g. 'read+write' or 'admin'",
+	)
+	flagSet.Parse(args)
+
+	if flagSet.NArg() != 1 {
+		flagSet.Usage()
+		return errTokenUsage
+	}
+	cmd := flagSet.Arg(0)
+	switch cmd {
+	case "issue", "revoke", "list":
+	default:
+		flagSet.Usage()
+		return errTokenUsage
+	}
+	if cmd == "issue" && *dsn == "" {
+		return issueConfigToken(*id, *scopes, out)
+	}
+	if *dsn == "" {
+		return fmt.Errorf("%s: %w", cmd, errTokenDSN)
+	}
+
+	logger.Init("info")
+	storage := psqlstorage.New(*dsn, 0, 0)
+	defer storage.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout)
+	defer cancel()
+
+	switch cmd {
+	case "issue":
+		return issueDBToken(ctx, storage, *id, *scopes, out)
+	case "revoke":
+		if *id == "" {
+			return errors.New("token id is not set")
+		}
+		if err := storage.RevokeToken(ctx, *id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "revoked token '%s'\n", *id)
+		return nil
+	default:
+		return printTokens(ctx, storage, out)
+	}
+}
+
+func issueConfigToken(id, scopesValue string, out io.Writer) error {
+	raw, t, err := newToken(id, scopesValue)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "token: %s\nspec:  %s\n", raw, t.Spec())
+	return nil
+}
+
+func issueDBToken(
+	ctx context.Context,
+	storage *psqlstorage.PSQLStorage,
+	id, scopesValue string,
+	out io.Writer,
+) error {
+	raw, t, err := newToken(id, scopesValue)
+	if err != nil {
+		return err
+	}
+	if err := storage.AddToken(ctx, t); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "token: %s\n", raw)
+	return nil
+}
+
+func newToken(id, scopesValue string) (string, token.Token, error) {
+	scopes, err := token.ParseScopes(scopesValue)
+	if err != nil {
+		return "", token.Token{}, err
+	}
+	return token.New(id, scopes)
+}
+
+func printTokens(
+	ctx context.Context, storage *psqlstorage.PSQLStorage, out io.Writer,
+) error {
+	tokens, err := storage.Tokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCOPES\tSTATE\tCREATED AT")
+	for _, t := range tokens {
+		state := "active"
+		if t.Revoked {
+			state = "revoked"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\n",
+			t.ID, t.Scopes, state, t.CreatedAt.Format(time.RFC3339),
+		)
+	}
+	return w.Flush()
+}
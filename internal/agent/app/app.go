@@ -20,7 +20,7 @@ type App struct {
 }
 
 func New(cfg *config.AgentConfig) *App {
-	provider := provider.New(cfg.Metrics.Poll)
+	provider := provider.New(cfg.Metrics.Poll, cfg.Labels.Labels)
 	reportGen := reportgen.New(provider, cfg.Metrics.Report)
 	encrypter, err := cipher.NewEncrypterX509(cfg.Crypto.Data)
 	if err != nil {
@@ -30,14 +30,39 @@ func New(cfg *config.AgentConfig) *App {
 		Encrypter:  encrypter,
 		HashKey:    cfg.HashKey.Key,
 		OutboundIP: cfg.GetOutboundIP(),
+		AgentID:    cfg.AgentID.ID,
 	}
 
 	var wf di.SendMetricsFunc
 	if cfg.Server.GRPCAddr != nil {
-		wf = grpcworker.SendMetrics
 		wo.URL = cfg.Server.GRPCAddr.String()
+		client, err := grpcworker.NewClient(wo.URL, grpcworker.ClientOpts{
+			DialTimeout: cfg.GRPC.DialTimeout,
+			Timeout:     cfg.GRPC.Timeout,
+			Keepalive:   cfg.GRPC.Keepalive,
+			TLSConfig:   cfg.TLS.Config,
+			HashKeyID:   cfg.HashKey.ID,
+			CryptoKeyID: cfg.Crypto.ID,
+			SignKey:     cfg.SignKey.Key,
+			Token:       cfg.Token.Token,
+		})
+		if err != nil {
+			logger.Log.Fatal("failed to init gRPC client", zap.Error(err))
+		}
+		wf, wo.Closer = client.SendMetrics, client
+		if cfg.Server.GRPCStream {
+			sender := grpcworker.NewStreamSender(client)
+			wf, wo.Closer = sender.SendMetrics, sender
+		}
 	} else {
-		wf = httpworker.SendMetrics
+		client := httpworker.NewClient(httpworker.ClientOpts{
+			TLSConfig:   cfg.TLS.Config,
+			HashKeyID:   cfg.HashKey.ID,
+			CryptoKeyID: cfg.Crypto.ID,
+			SignKey:     cfg.SignKey.Key,
+			Token:       cfg.Token.Token,
+		})
+		wf = client.SendMetrics
 		wo.URL = cfg.Server.URL()
 	}
 	wPool := workerpool.New(
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// AgentIDConfig holds stable agent identity attached to every batch,
+// server applies batch of the agent with the same sequence once.
+// Subject common name of TLS client certificate is default agent id.
+type AgentIDConfig struct {
+	ID string
+}
+
+func NewAgentIDConfig(p ConfigParams, tc TLSConfig) (ac AgentIDConfig) {
+	resolveID := func(value, src, name string) {
+		if value == "" {
+			p.ErrStream <- fmt.Errorf(
+				"empty agent id, source '%s' name '%s'", src, name,
+			)
+			return
+		}
+		err := metrics.BatchID{AgentID: value}.Verify()
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid agent id '%s', source '%s' name '%s': %w",
+				value, src, name, err,
+			)
+			return
+		}
+		ac.ID = value
+	}
+
+	switch {
+	case p.EnvSet.IsSet(agentIDEnvName):
+		resolveID(*p.EnvValues.agentID, srcEnv, agentIDEnvName)
+	case p.FlagSet.IsSet(agentIDFlagName):
+		resolveID(*p.FlagValues.agentID, srcFlag, "-"+agentIDFlagName)
+	case p.Settings.AgentID != nil:
+		resolveID(*p.Settings.AgentID, srcSettings, agentIDSettingsName)
+	case tc.Identity() != "":
+		resolveID(tc.Identity(), "default", "certificate subject")
+	default:
+		hostname, err := os.Hostname()
+		if err != nil {
+			p.ErrStream <- fmt.Errorf("failed to get default agent id: %w", err)
+			return
+		}
+		resolveID(hostname, "default", "hostname")
+	}
+	return
+}
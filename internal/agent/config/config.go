@@ -33,6 +33,45 @@ const (
 	grpcDefault      = "localhost:8081"
 	grpcUsage        = "TCP address for metrics emitting, e.g. '192.168.1.101:8080'"
 
+	grpcStreamFlagName     = "grpc-stream"
+	grpcStreamEnvName      = "GRPC_STREAM"
+	grpcStreamSettingsName = "grpc_stream"
+	grpcStreamDefault      = false
+	grpcStreamUsage        = "Send batches over one long-lived gRPC stream instead of call per batch"
+
+	grpcDialTimeoutFlagName     = "grpc-dial-timeout"
+	grpcDialTimeoutEnvName      = "GRPC_DIAL_TIMEOUT"
+	grpcDialTimeoutSettingsName = "grpc_dial_timeout"
+	grpcDialTimeoutDefault      = 5
+	grpcDialTimeoutUsage        = "gRPC connection establishing timeout in sec, e.g. '5' (min '1')"
+
+	grpcTimeoutFlagName     = "grpc-timeout"
+	grpcTimeoutEnvName      = "GRPC_TIMEOUT"
+	grpcTimeoutSettingsName = "grpc_timeout"
+	grpcTimeoutDefault      = 10
+	grpcTimeoutUsage        = "gRPC batch send timeout in sec, e.g. '10' (min '1')"
+
+	grpcKeepaliveFlagName     = "grpc-keepalive"
+	grpcKeepaliveEnvName      = "GRPC_KEEPALIVE"
+	grpcKeepaliveSettingsName = "grpc_keepalive"
+	grpcKeepaliveDefault      = 30
+	grpcKeepaliveUsage        = "gRPC connection keepalive ping interval in sec, e.g. '30' (min '10')"
+
+	tlsCAFlagName = "tls-ca"
+	tlsCAEnvName  = "TLS_CA"
+	tlsCADefault  = ""
+	tlsCAUsage    = "CA certificates path for server certificate verification, e.g. '/folder/ca.pem' (optional)"
+
+	tlsCertFlagName = "tls-cert"
+	tlsCertEnvName  = "TLS_CERT"
+	tlsCertDefault  = ""
+	tlsCertUsage    = "Agent TLS certificate path, subject common name is default agent id, e.g. '/folder/cert.pem' (optional)"
+
+	tlsKeyFlagName = "tls-key"
+	tlsKeyEnvName  = "TLS_KEY"
+	tlsKeyDefault  = ""
+	tlsKeyUsage    = "Agent TLS private key path, e.g. '/folder/key.pem' (optional)"
+
 	logFlagName     = "log"
 	logEnvName      = "LOG_LVL"
 	logSettingsName = "log"
@@ -63,12 +102,46 @@ const (
 	rateLimitSettingsName = "rate_limit"
 	rateLimitUsage        = "Emitting rate limit, e.g. 8 (min 1)"
 
+	hashKeyIDFlagName = "key-id"
+	hashKeyIDEnvName  = "KEY_ID"
+	hashKeyIDDefault  = ""
+	hashKeyIDUsage    = "Hash key id for server key rotation, e.g. 'k2' (optional)"
+
 	cryptoKeyFlagName     = "crypto-key"
 	cryptoKeyEnvName      = "CRYPTO_KEY"
 	cryptoKeySettingsName = "crypto_key"
 	cryptoKeyDefault      = ""
 	cryptoKeyUsage        = "Cert path, e.g. '/folder/cert.pem' (required)"
 
+	cryptoKeyIDFlagName = "crypto-key-id"
+	cryptoKeyIDEnvName  = "CRYPTO_KEY_ID"
+	cryptoKeyIDDefault  = ""
+	cryptoKeyIDUsage    = "Server private key id of cert for server key rotation, e.g. 'k2' (optional)"
+
+	signKeyFlagName     = "sign-key"
+	signKeyEnvName      = "SIGN_KEY"
+	signKeySettingsName = "sign_key"
+	signKeyDefault      = ""
+	signKeyUsage        = "Agent Ed25519 private key path for batch signatures, e.g. '/folder/agent.pem' (optional)"
+
+	tokenFlagName     = "token"
+	tokenEnvName      = "TOKEN"
+	tokenSettingsName = "token"
+	tokenDefault      = ""
+	tokenUsage        = "Bearer token of server API, e.g. 'ci.<secret>' (optional)"
+
+	labelsFlagName     = "labels"
+	labelsEnvName      = "LABELS"
+	labelsSettingsName = "labels"
+	labelsDefault      = ""
+	labelsUsage        = "Labels attached to every metric, e.g. 'host=a,env=prod' (optional)"
+
+	agentIDFlagName     = "id"
+	agentIDEnvName      = "AGENT_ID"
+	agentIDSettingsName = "agent_id"
+	agentIDDefault      = ""
+	agentIDUsage        = "Stable agent id for batches deduplication, e.g. 'host-a' (default hostname)"
+
 	configFileFlagName = "config"
 	configFileEnvName  = "CONFIG"
 	configFileDefault  = ""
@@ -78,26 +151,52 @@ const (
 var rateLimitDefault = runtime.NumCPU()
 
 type values struct {
-	addr       *string
-	grpc       *string
-	log        *string
-	poll       *int
-	report     *int
-	hashKey    *string
-	rateLimit  *int
-	cryptoKey  *string
-	configFile *string
+	addr        *string
+	grpc        *string
+	grpcStream  *bool
+	grpcDial    *int
+	grpcRPC     *int
+	grpcPing    *int
+	tlsCA       *string
+	tlsCert     *string
+	tlsKey      *string
+	log         *string
+	poll        *int
+	report      *int
+	hashKey     *string
+	rateLimit   *int
+	hashKeyID   *string
+	cryptoKey   *string
+	cryptoKeyID *string
+	signKey     *string
+	token       *string
+	labels      *string
+	agentID     *string
+	configFile  *string
 }
 
 type settings struct {
-	Address   *string `json:"address"`
-	GRPC      *string `json:"grpc_address"`
-	Log       *string `json:"log"`
-	Poll      *int    `json:"poll_interval"`
-	Report    *int    `json:"report_interval"`
-	HashKey   *string `json:"hash_key"`
-	RateLimit *int    `json:"rate_limit"`
-	CryptoKey *string `json:"crypto_key"`
+	Address     *string `json:"address"`
+	GRPC        *string `json:"grpc_address"`
+	GRPCStream  *bool   `json:"grpc_stream"`
+	GRPCDial    *int    `json:"grpc_dial_timeout"`
+	GRPCRPC     *int    `json:"grpc_timeout"`
+	GRPCPing    *int    `json:"grpc_keepalive"`
+	TLSCA       *string `json:"tls_ca"`
+	TLSCert     *string `json:"tls_cert"`
+	TLSKey      *string `json:"tls_key"`
+	Log         *string `json:"log"`
+	Poll        *int    `json:"poll_interval"`
+	Report      *int    `json:"report_interval"`
+	HashKey     *string `json:"hash_key"`
+	RateLimit   *int    `json:"rate_limit"`
+	HashKeyID   *string `json:"key_id"`
+	CryptoKey   *string `json:"crypto_key"`
+	CryptoKeyID *string `json:"crypto_key_id"`
+	SignKey     *string `json:"sign_key"`
+	Token       *string `json:"token"`
+	Labels      *string `json:"labels"`
+	AgentID     *string `json:"agent_id"`
 }
 
 func newSettings(path string) (settings, error) {
@@ -124,10 +223,16 @@ type ConfigParams struct {
 
 type AgentConfig struct {
 	Server  ServerConfig
+	GRPC    GRPCConfig
+	TLS     TLSConfig
 	Log     LogConfig
 	Metrics MetricsConfig
 	HashKey HashKeyConfig
 	Crypto  CryptoConfig
+	SignKey SignKeyConfig
+	Token   TokenConfig
+	Labels  LabelsConfig
+	AgentID AgentIDConfig
 }
 
 func Load() *AgentConfig {
@@ -158,18 +263,30 @@ func Load() *AgentConfig {
 		ErrStream:  errStream,
 	}
 
-	serverConfig := NewServerConfig(params)
+	tlsConfig := NewTLSConfig(params)
+	serverConfig := NewServerConfig(params, tlsConfig)
+	grpcConfig := NewGRPCConfig(params)
 	logConfig := NewLogConfig(params)
 	metricsConfig := NewMetricsConfig(params)
 	hashKeyConfig := NewHashKeyConfig(params)
 	cryptoConfig := NewCryptoConfig(params)
+	signKeyConfig := NewSignKeyConfig(params)
+	tokenConfig := NewTokenConfig(params)
+	labelsConfig := NewLabelsConfig(params)
+	agentIDConfig := NewAgentIDConfig(params, tlsConfig)
 
 	return &AgentConfig{
 		Server:  serverConfig,
+		GRPC:    grpcConfig,
+		TLS:     tlsConfig,
 		Log:     logConfig,
 		Metrics: metricsConfig,
 		HashKey: hashKeyConfig,
 		Crypto:  cryptoConfig,
+		SignKey: signKeyConfig,
+		Token:   tokenConfig,
+		Labels:  labelsConfig,
+		AgentID: agentIDConfig,
 	}
 
 }
@@ -190,12 +307,25 @@ func (c *AgentConfig) PrintConfig(logger *zap.Logger) {
 		"Start agent with flags",
 		zap.String("-"+addrFlagName, c.Server.URL()),
 		zap.String("-"+grpcFlagName, c.Server.GRPCAddr.String()),
+		zap.Bool("-"+grpcStreamFlagName, c.Server.GRPCStream),
+		zap.String("-"+grpcDialTimeoutFlagName, c.GRPC.DialTimeout.String()),
+		zap.String("-"+grpcTimeoutFlagName, c.GRPC.Timeout.String()),
+		zap.String("-"+grpcKeepaliveFlagName, c.GRPC.Keepalive.String()),
+		zap.String("-"+tlsCAFlagName, c.TLS.CAFile),
+		zap.String("-"+tlsCertFlagName, c.TLS.CertFile),
+		zap.String("-"+tlsKeyFlagName, c.TLS.KeyFile),
 		zap.String("-"+logFlagName, c.Log.Level),
 		zap.String("-"+pollFlagName, c.Metrics.Poll.String()),
 		zap.String("-"+reportFlagName, c.Metrics.Report.String()),
 		zap.String("-"+hashKeyFlagName, c.HashKey.Key),
 		zap.Int("-"+rateLimitFlagName, c.Metrics.RateLimit),
+		zap.String("-"+hashKeyIDFlagName, c.HashKey.ID),
 		zap.String("-"+cryptoKeyFlagName, c.Crypto.Path()),
+		zap.String("-"+cryptoKeyIDFlagName, c.Crypto.ID),
+		zap.String("-"+signKeyFlagName, c.SignKey.Path),
+		zap.String("-"+tokenFlagName, c.Token.ID),
+		zap.String("-"+labelsFlagName, c.Labels.Labels.String()),
+		zap.String("-"+agentIDFlagName, c.AgentID.ID),
 		zap.String("outboundIP", c.GetOutboundIP()),
 	)
 }
@@ -205,6 +335,21 @@ func setupFlagValues(flagSet *flag.FlagSet) values {
 
 	fv.addr = flagSet.String(addrFlagName, addrDefault, addrUsage)
 	fv.grpc = flagSet.String(grpcFlagName, grpcDefault, grpcUsage)
+	fv.grpcStream = flagSet.Bool(
+		grpcStreamFlagName, grpcStreamDefault, grpcStreamUsage,
+	)
+	fv.grpcDial = flagSet.Int(
+		grpcDialTimeoutFlagName, grpcDialTimeoutDefault, grpcDialTimeoutUsage,
+	)
+	fv.grpcRPC = flagSet.Int(
+		grpcTimeoutFlagName, grpcTimeoutDefault, grpcTimeoutUsage,
+	)
+	fv.grpcPing = flagSet.Int(
+		grpcKeepaliveFlagName, grpcKeepaliveDefault, grpcKeepaliveUsage,
+	)
+	fv.tlsCA = flagSet.String(tlsCAFlagName, tlsCADefault, tlsCAUsage)
+	fv.tlsCert = flagSet.String(tlsCertFlagName, tlsCertDefault, tlsCertUsage)
+	fv.tlsKey = flagSet.String(tlsKeyFlagName, tlsKeyDefault, tlsKeyUsage)
 	fv.log = flagSet.String(logFlagName, logDefault, logUsage)
 	fv.poll = flagSet.Int(pollFlagName, pollDefault, pollUsage)
 	fv.report = flagSet.Int(reportFlagName, reportDefault, reportUsage)
@@ -213,9 +358,21 @@ func setupFlagValues(flagSet *flag.FlagSet) values {
 	fv.rateLimit = flagSet.Int(
 		rateLimitFlagName, rateLimitDefault, rateLimitUsage,
 	)
+	fv.hashKeyID = flagSet.String(
+		hashKeyIDFlagName, hashKeyIDDefault, hashKeyIDUsage,
+	)
 	fv.cryptoKey = flagSet.String(
 		cryptoKeyFlagName, cryptoKeyDefault, cryptoKeyUsage,
 	)
+	fv.cryptoKeyID = flagSet.String(
+		cryptoKeyIDFlagName, cryptoKeyIDDefault, cryptoKeyIDUsage,
+	)
+	fv.signKey = flagSet.String(
+		signKeyFlagName, signKeyDefault, signKeyUsage,
+	)
+	fv.token = flagSet.String(tokenFlagName, tokenDefault, tokenUsage)
+	fv.labels = flagSet.String(labelsFlagName, labelsDefault, labelsUsage)
+	fv.agentID = flagSet.String(agentIDFlagName, agentIDDefault, agentIDUsage)
 	fv.configFile = flagSet.String(
 		configFileFlagName, configFileDefault, configFileUsage,
 	)
@@ -227,12 +384,25 @@ func setupEnvValues(envSet *env.EnvSet) values {
 	var ev values
 	ev.addr = envSet.String(addrEnvName)
 	ev.grpc = envSet.String(grpcEnvName)
+	ev.grpcStream = envSet.Bool(grpcStreamEnvName)
+	ev.grpcDial = envSet.Int(grpcDialTimeoutEnvName)
+	ev.grpcRPC = envSet.Int(grpcTimeoutEnvName)
+	ev.grpcPing = envSet.Int(grpcKeepaliveEnvName)
+	ev.tlsCA = envSet.String(tlsCAEnvName)
+	ev.tlsCert = envSet.String(tlsCertEnvName)
+	ev.tlsKey = envSet.String(tlsKeyEnvName)
 	ev.log = envSet.String(logEnvName)
 	ev.poll = envSet.Int(pollEnvName)
 	ev.report = envSet.Int(reportEnvName)
 	ev.hashKey = envSet.String(hashKeyEnvName)
 	ev.rateLimit = envSet.Int(rateLimitEnvName)
+	ev.hashKeyID = envSet.String(hashKeyIDEnvName)
 	ev.cryptoKey = envSet.String(cryptoKeyEnvName)
+	ev.cryptoKeyID = envSet.String(cryptoKeyIDEnvName)
+	ev.signKey = envSet.String(signKeyEnvName)
+	ev.token = envSet.String(tokenEnvName)
+	ev.labels = envSet.String(labelsEnvName)
+	ev.agentID = envSet.String(agentIDEnvName)
 	ev.configFile = envSet.String(configFileEnvName)
 	return ev
 }
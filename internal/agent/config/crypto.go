@@ -7,17 +7,33 @@ import (
 	"os"
 )
 
+// CryptoConfig describes server certificate. ID is sent with message,
+// so server picks the private key during rotation, empty ID is server
+// default key.
 type CryptoConfig struct {
 	Data []byte
+	ID   string
 	f    *os.File
 }
 
 func NewCryptoConfig(p ConfigParams) (cc CryptoConfig) {
 	cc.initFile(p)
 	cc.initData(p.ErrStream)
+	cc.initID(p)
 	return
 }
 
+func (cc *CryptoConfig) initID(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(cryptoKeyIDEnvName):
+		cc.ID = *p.EnvValues.cryptoKeyID
+	case p.FlagSet.IsSet(cryptoKeyIDFlagName):
+		cc.ID = *p.FlagValues.cryptoKeyID
+	case p.Settings.CryptoKeyID != nil:
+		cc.ID = *p.Settings.CryptoKeyID
+	}
+}
+
 func (cc *CryptoConfig) Path() string {
 	if cc.f != nil {
 		return cc.f.Name()
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	minGRPCDialTimeout = 1
+	minGRPCTimeout     = 1
+	minGRPCKeepalive   = 10 // server rejects more frequent pings
+)
+
+// GRPCConfig describes agent gRPC connection parameters.
+type GRPCConfig struct {
+	DialTimeout, Timeout, Keepalive time.Duration
+}
+
+func NewGRPCConfig(p ConfigParams) (gc GRPCConfig) {
+	gc.initDialTimeout(p)
+	gc.initTimeout(p)
+	gc.initKeepalive(p)
+	return
+}
+
+func (gc *GRPCConfig) initDialTimeout(p ConfigParams) {
+	resolveDialTimeout := func(value int, src, name string) {
+		if value < minGRPCDialTimeout {
+			p.ErrStream <- fmt.Errorf(
+				"gRPC dial timeout '%d' less '%d', source '%s' name '%s'",
+				value, minGRPCDialTimeout, src, name,
+			)
+		}
+		gc.DialTimeout = time.Duration(value) * time.Second
+	}
+
+	switch {
+	case p.EnvSet.IsSet(grpcDialTimeoutEnvName):
+		resolveDialTimeout(
+			*p.EnvValues.grpcDial, srcEnv, grpcDialTimeoutEnvName,
+		)
+	case p.FlagSet.IsSet(grpcDialTimeoutFlagName):
+		resolveDialTimeout(
+			*p.FlagValues.grpcDial, srcFlag, "-"+grpcDialTimeoutFlagName,
+		)
+	case p.Settings.GRPCDial != nil:
+		resolveDialTimeout(
+			*p.Settings.GRPCDial, srcSettings, grpcDialTimeoutSettingsName,
+		)
+	default:
+		gc.DialTimeout = time.Duration(grpcDialTimeoutDefault) * time.Second
+	}
+}
+
+func (gc *GRPCConfig) initTimeout(p ConfigParams) {
+	resolveTimeout := func(value int, src, name string) {
+		if value < minGRPCTimeout {
+			p.ErrStream <- fmt.Errorf(
+				"gRPC timeout '%d' less '%d', source '%s' name '%s'",
+				value, minGRPCTimeout, src, name,
+			)
+		}
+		gc.Timeout = time.Duration(value) * time.Second
+	}
+
+	switch {
+	case p.EnvSet.IsSet(grpcTimeoutEnvName):
+		resolveTimeout(*p.EnvValues.grpcRPC, srcEnv, grpcTimeoutEnvName)
+	case p.FlagSet.IsSet(grpcTimeoutFlagName):
+		resolveTimeout(*p.FlagValues.grpcRPC, srcFlag, "-"+grpcTimeoutFlagName)
+	case p.Settings.GRPCRPC != nil:
+		resolveTimeout(*p.Settings.GRPCRPC, srcSettings, grpcTimeoutSettingsName)
+	default:
+		gc.Timeout = time.Duration(grpcTimeoutDefault) * time.Second
+	}
+}
+
+func (gc *GRPCConfig) initKeepalive(p ConfigParams) {
+	resolveKeepalive := func(value int, src, name string) {
+		if value < minGRPCKeepalive {
+			p.ErrStream <- fmt.Errorf(
+				"gRPC keepalive '%d' less '%d', source '%s' name '%s'",
+				value, minGRPCKeepalive, src, name,
+			)
+		}
+		gc.Keepalive = time.Duration(value) * time.Second
+	}
+
+	switch {
+	case p.EnvSet.IsSet(grpcKeepaliveEnvName):
+		resolveKeepalive(*p.EnvValues.grpcPing, srcEnv, grpcKeepaliveEnvName)
+	case p.FlagSet.IsSet(grpcKeepaliveFlagName):
+		resolveKeepalive(
+			*p.FlagValues.grpcPing, srcFlag, "-"+grpcKeepaliveFlagName,
+		)
+	case p.Settings.GRPCPing != nil:
+		resolveKeepalive(
+			*p.Settings.GRPCPing, srcSettings, grpcKeepaliveSettingsName,
+		)
+	default:
+		gc.Keepalive = time.Duration(grpcKeepaliveDefault) * time.Second
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
+// HashKeyConfig describes HMAC key. ID is sent with hash,
+// so server picks the key during rotation, empty ID is server default key.
 type HashKeyConfig struct {
-	Key string
+	Key, ID string
 }
 
 func NewHashKeyConfig(p ConfigParams) (hc HashKeyConfig) {
@@ -13,5 +15,14 @@ func NewHashKeyConfig(p ConfigParams) (hc HashKeyConfig) {
 	case p.Settings.HashKey != nil:
 		hc.Key = *p.Settings.HashKey
 	}
+
+	switch {
+	case p.EnvSet.IsSet(hashKeyIDEnvName):
+		hc.ID = *p.EnvValues.hashKeyID
+	case p.FlagSet.IsSet(hashKeyIDFlagName):
+		hc.ID = *p.FlagValues.hashKeyID
+	case p.Settings.HashKeyID != nil:
+		hc.ID = *p.Settings.HashKeyID
+	}
 	return
 }
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+type LabelsConfig struct {
+	Labels metrics.Labels
+}
+
+func NewLabelsConfig(p ConfigParams) (lc LabelsConfig) {
+	resolveLabels := func(value, src, name string) {
+		labels, err := metrics.ParseLabels(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid labels '%s', source '%s' name '%s': %w",
+				value, src, name, err,
+			)
+			return
+		}
+		lc.Labels = labels
+	}
+
+	switch {
+	case p.EnvSet.IsSet(labelsEnvName):
+		resolveLabels(*p.EnvValues.labels, srcEnv, labelsEnvName)
+	case p.FlagSet.IsSet(labelsFlagName):
+		resolveLabels(*p.FlagValues.labels, srcFlag, "-"+labelsFlagName)
+	case p.Settings.Labels != nil:
+		resolveLabels(*p.Settings.Labels, srcSettings, labelsSettingsName)
+	}
+	return
+}
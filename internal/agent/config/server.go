@@ -7,20 +7,26 @@ import (
 )
 
 const (
-	scheme = "http"
-	path   = "updates"
+	scheme    = "http"
+	schemeTLS = "https"
+	path      = "updates"
 )
 
 type ServerConfig struct {
 	HTTPAddr, GRPCAddr *net.TCPAddr
 	Scheme, Path       string
+	GRPCStream         bool
 }
 
-func NewServerConfig(p ConfigParams) (sc ServerConfig) {
+func NewServerConfig(p ConfigParams, tc TLSConfig) (sc ServerConfig) {
 	sc.Scheme = scheme
+	if tc.IsSet() {
+		sc.Scheme = schemeTLS
+	}
 	sc.Path = path
 	sc.initHTTPAddr(p)
 	sc.initGRPCAddr(p)
+	sc.initGRPCStream(p)
 	return
 }
 
@@ -82,3 +88,16 @@ func (sc *ServerConfig) initGRPCAddr(p ConfigParams) {
 		sc.GRPCAddr = resolveGPRCAddr(grpcDefault, "", "")
 	}
 }
+
+func (sc *ServerConfig) initGRPCStream(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(grpcStreamEnvName):
+		sc.GRPCStream = *p.EnvValues.grpcStream
+	case p.FlagSet.IsSet(grpcStreamFlagName):
+		sc.GRPCStream = *p.FlagValues.grpcStream
+	case p.Settings.GRPCStream != nil:
+		sc.GRPCStream = *p.Settings.GRPCStream
+	default:
+		sc.GRPCStream = grpcStreamDefault
+	}
+}
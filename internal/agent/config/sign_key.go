@@ -0,0 +1,48 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"github.com/niksmo/runlytics/pkg/sign"
+)
+
+// SignKeyConfig describes agent Ed25519 private key, batches are signed
+// with agent id if the key is set.
+type SignKeyConfig struct {
+	Path string
+	Key  ed25519.PrivateKey
+}
+
+func NewSignKeyConfig(p ConfigParams) (sc SignKeyConfig) {
+	resolveKey := func(path, src, name string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"failed to read sign key '%s', source '%s' name '%s': %w",
+				path, src, name, err,
+			)
+			return
+		}
+		key, err := sign.ParsePrivateKey(data)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid sign key '%s', source '%s' name '%s': %w",
+				path, src, name, err,
+			)
+			return
+		}
+		sc.Path, sc.Key = path, key
+	}
+
+	switch {
+	case p.EnvSet.IsSet(signKeyEnvName):
+		resolveKey(*p.EnvValues.signKey, srcEnv, signKeyEnvName)
+	case p.FlagSet.IsSet(signKeyFlagName):
+		resolveKey(*p.FlagValues.signKey, srcFlag, "-"+signKeyFlagName)
+	case p.Settings.SignKey != nil:
+		resolveKey(*p.Settings.SignKey, srcSettings, signKeySettingsName)
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/tlsconf"
+)
+
+// TLSConfig describes TLS of HTTP and gRPC connections to server.
+// Agent connects in cleartext if no CA or client certificate is set.
+type TLSConfig struct {
+	CAFile, CertFile, KeyFile string
+	Config                    *tls.Config
+}
+
+func NewTLSConfig(p ConfigParams) (tc TLSConfig) {
+	tc.initCAFile(p)
+	tc.initCertFile(p)
+	tc.initKeyFile(p)
+	tc.initConfig(p.ErrStream)
+	return
+}
+
+func (tc *TLSConfig) initCAFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsCAEnvName):
+		tc.CAFile = *p.EnvValues.tlsCA
+	case p.FlagSet.IsSet(tlsCAFlagName):
+		tc.CAFile = *p.FlagValues.tlsCA
+	case p.Settings.TLSCA != nil:
+		tc.CAFile = *p.Settings.TLSCA
+	}
+}
+
+func (tc *TLSConfig) initCertFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsCertEnvName):
+		tc.CertFile = *p.EnvValues.tlsCert
+	case p.FlagSet.IsSet(tlsCertFlagName):
+		tc.CertFile = *p.FlagValues.tlsCert
+	case p.Settings.TLSCert != nil:
+		tc.CertFile = *p.Settings.TLSCert
+	}
+}
+
+func (tc *TLSConfig) initKeyFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsKeyEnvName):
+		tc.KeyFile = *p.EnvValues.tlsKey
+	case p.FlagSet.IsSet(tlsKeyFlagName):
+		tc.KeyFile = *p.FlagValues.tlsKey
+	case p.Settings.TLSKey != nil:
+		tc.KeyFile = *p.Settings.TLSKey
+	}
+}
+
+func (tc *TLSConfig) initConfig(errStream chan<- error) {
+	if tc.CAFile == "" && tc.CertFile == "" && tc.KeyFile == "" {
+		return
+	}
+
+	cfg, err := tlsconf.NewClient(tlsconf.ClientOpts{
+		CAFile:   tc.CAFile,
+		CertFile: tc.CertFile,
+		KeyFile:  tc.KeyFile,
+	})
+	if err != nil {
+		errStream <- fmt.Errorf("failed to init TLS: %w", err)
+		return
+	}
+	tc.Config = cfg
+}
+
+func (tc *TLSConfig) IsSet() bool {
+	return tc.Config != nil
+}
+
+// Identity returns subject common name of client certificate.
+func (tc *TLSConfig) Identity() string {
+	return tlsconf.Identity(tc.Config)
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/token"
+)
+
+// TokenConfig describes bearer token of server API, requests are sent
+// without token if it is not set. ID is the token id, it is safe to log.
+type TokenConfig struct {
+	Token, ID string
+}
+
+func NewTokenConfig(p ConfigParams) (tc TokenConfig) {
+	resolveToken := func(value, src, name string) {
+		id, err := token.ParseID(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid token, source '%s' name '%s': %w", src, name, err,
+			)
+			return
+		}
+		tc.Token, tc.ID = value, id
+	}
+
+	switch {
+	case p.EnvSet.IsSet(tokenEnvName):
+		resolveToken(*p.EnvValues.token, srcEnv, tokenEnvName)
+	case p.FlagSet.IsSet(tokenFlagName):
+		resolveToken(*p.FlagValues.token, srcFlag, "-"+tokenFlagName)
+	case p.Settings.Token != nil:
+		resolveToken(*p.Settings.Token, srcSettings, tokenSettingsName)
+	}
+	return
+}
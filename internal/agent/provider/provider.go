@@ -21,11 +21,15 @@ func newMetricsData() metricsData {
 
 type StatProvider struct {
 	poll      time.Duration
+	labels    metrics.Labels
 	providers []di.MetricsProvider
 }
 
-func New(poll time.Duration) *StatProvider {
-	p := &StatProvider{poll: poll}
+// New returns StatProvider pointer.
+//
+// Passed labels are attached to every provided metric.
+func New(poll time.Duration, labels metrics.Labels) *StatProvider {
+	p := &StatProvider{poll: poll, labels: labels}
 	p.providers = append(
 		p.providers,
 		newManualStat(poll), newPSUtilStat(poll), newRuntimeStat(poll),
@@ -50,5 +54,10 @@ func (p *StatProvider) GetMetrics() metrics.MetricsList {
 	for _, p := range p.providers {
 		m = append(m, p.GetMetrics()...)
 	}
+	if len(p.labels) != 0 {
+		for idx := range m {
+			m[idx].Labels = p.labels
+		}
+	}
 	return m
 }
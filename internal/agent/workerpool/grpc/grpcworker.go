@@ -1,9 +1,9 @@
 package grpcworker
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"crypto/ed25519"
+	"crypto/tls"
 	"fmt"
 	"time"
 
@@ -11,78 +11,211 @@ import (
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
 	pb "github.com/niksmo/runlytics/proto"
 	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-func SendMetrics(
+// Key id metadata, server default key is used if not set.
+const (
+	xHashKeyID   = "X-Hash-Key-ID"
+	xCryptoKeyID = "X-Crypto-Key-ID"
+)
+
+const authorization = "authorization"
+
+// ClientOpts describes shared connection parameters.
+type ClientOpts struct {
+	DialTimeout time.Duration // connection establishing timeout
+	Timeout     time.Duration // batch send timeout
+	Keepalive   time.Duration // ping interval of idle connection
+	TLSConfig   *tls.Config   // connects in cleartext if nil
+	HashKeyID   string        // server HMAC key id, optional
+	CryptoKeyID string        // server private key id, optional
+	Token       string        // bearer token of calls, optional
+
+	// SignKey signs batches with agent id, batches are not signed if nil.
+	SignKey ed25519.PrivateKey
+}
+
+// Client sends batches over one long-lived connection shared by workers.
+//
+// The connection is established lazily and reestablished with backoff
+// after failure, keepalive pings detect broken connection between reports.
+type Client struct {
+	conn    *grpc.ClientConn
+	client  pb.RunlyticsClient
+	timeout time.Duration
+	callMD  metadata.MD // key ids and token of every call
+	signKey ed25519.PrivateKey
+}
+
+// NewClient returns Client pointer with connection to addr.
+// Client should be closed after use.
+func NewClient(addr string, opts ClientOpts) (*Client, error) {
+	const op = "grpcworker.NewClient"
+	creds := insecure.NewCredentials()
+	if opts.TLSConfig != nil {
+		creds = credentials.NewTLS(opts.TLSConfig)
+	}
+	conn, err := grpc.NewClient(
+		addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: opts.DialTimeout,
+		}),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                opts.Keepalive,
+			Timeout:             opts.DialTimeout,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	callMD := metadata.MD{}
+	if opts.HashKeyID != "" {
+		callMD.Set(xHashKeyID, opts.HashKeyID)
+	}
+	if opts.CryptoKeyID != "" {
+		callMD.Set(xCryptoKeyID, opts.CryptoKeyID)
+	}
+	if opts.Token != "" {
+		callMD.Set(authorization, "Bearer "+opts.Token)
+	}
+	return &Client{
+		conn:    conn,
+		client:  pb.NewRunlyticsClient(conn),
+		timeout: opts.Timeout,
+		callMD:  callMD,
+		signKey: opts.SignKey,
+	}, nil
+}
+
+// SendMetrics sends batch and returns error, if batch is not applied.
+// If server rejects batch as invalid, error wraps [workerpool.ErrRejected],
+// if server limits calls, error wraps [workerpool.ThrottledError].
+//
+// The method is [di.SendMetricsFunc], addr is used only for logging.
+func (c *Client) SendMetrics(
 	ctx context.Context,
+	id metrics.BatchID,
 	m metrics.MetricsList,
 	enc di.Encrypter,
 	addr, hk, ip string,
 ) error {
-	const op = "grpcworker.SendMetrics"
+	const op = "grpcworker.Client.SendMetrics"
 	log := logger.Log.With(
 		zap.String("op", op), zap.String("addr", addr), zap.String("ip", ip),
 	)
-	c, err := getClient(addr)
-	if err != nil {
-		log.Fatal("failed to get grpc client", zap.Error(err))
-	}
 
 	data, err := serialize(m)
 	if err != nil {
 		log.Fatal("failed serialize payload", zap.Error(err))
 	}
 
-	md, err := newMetadata(data, hk, ip)
+	stamp := replay.NewStamp()
+	md, err := newMetadata(stamp.Material(data), hk, ip)
 	if err != nil {
 		log.Fatal("failed set metadata", zap.Error(err))
 	}
-	ctx = metadata.NewOutgoingContext(ctx, md)
+	md.Append(replay.XTimestamp, stamp.Timestamp())
+	md.Append(replay.XNonce, stamp.Nonce)
 
 	encrypted, err := encrypt(enc, data)
 	if err != nil {
 		log.Fatal("failed encrypt payload", zap.Error(err))
 	}
-	req := newRequest(encrypted)
+	req := newRequest(encrypted, id)
+	req.Signature = c.sign(id, stamp.Material(data))
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Join(md, c.callMD))
 
 	reqStart := time.Now()
-	res, err := c.BatchUpdate(ctx, req)
+	res, err := c.client.BatchUpdate(ctx, req)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return sendError(op, err)
 	}
 
 	log.Info(
 		"got response",
 		zap.Duration("resTime", time.Since(reqStart)),
 		zap.Uint32("updatedCount", res.GetUpdatedCount()),
+		zap.Stringer("batch", id),
+		zap.Bool("duplicate", res.GetDuplicate()),
 	)
 
 	return nil
 }
 
-func getClient(addr string) (pb.RunlyticsClient, error) {
-	const op = "grpcworker.getClient"
-	conn, err := grpc.NewClient(
-		addr, grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+// Close closes the connection.
+func (c *Client) Close() error {
+	const op = "grpcworker.Client.Close"
+	if err := c.conn.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// sign returns signature of stamped serialized batch,
+// or nil if sign key is not set.
+func (c *Client) sign(id metrics.BatchID, material []byte) []byte {
+	if c.signKey == nil {
+		return nil
+	}
+	return sign.Sign(c.signKey, id.AgentID, id.Seq, material)
+}
+
+// sendError wraps [workerpool.ErrRejected], if server rejects batch,
+// or [workerpool.ThrottledError], if server limits calls.
+func sendError(op string, err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.PermissionDenied, codes.Unauthenticated:
+		return fmt.Errorf("%s: %w: %w", op, workerpool.ErrRejected, err)
+	case codes.ResourceExhausted:
+		return fmt.Errorf(
+			"%s: %w: %w",
+			op, &workerpool.ThrottledError{RetryAfter: retryAfter(err)}, err,
+		)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// retryAfter returns retry delay of RetryInfo status detail
+// or [workerpool.DefaultRetryAfter].
+func retryAfter(err error) time.Duration {
+	st, _ := status.FromError(err)
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			if d := info.GetRetryDelay().AsDuration(); d > 0 {
+				return d
+			}
+		}
 	}
-	return pb.NewRunlyticsClient(conn), nil
+	return workerpool.DefaultRetryAfter
 }
 
 func serialize(m metrics.MetricsList) ([]byte, error) {
 	const op = "grpcworker.serialize"
-	var b bytes.Buffer
-	if err := gob.NewEncoder(&b).Encode(m); err != nil {
+	data, err := proto.Marshal(pb.NewMetricsBatch(m))
+	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return b.Bytes(), nil
+	return data, nil
 }
 
 func encrypt(encoder di.Encrypter, data []byte) ([]byte, error) {
@@ -94,8 +227,10 @@ func encrypt(encoder di.Encrypter, data []byte) ([]byte, error) {
 	return data, err
 }
 
-func newRequest(data []byte) *pb.BatchUpdateRequest {
-	return &pb.BatchUpdateRequest{Metrics: data}
+func newRequest(data []byte, id metrics.BatchID) *pb.BatchUpdateRequest {
+	return &pb.BatchUpdateRequest{
+		Batch: data, AgentId: id.AgentID, Seq: id.Seq,
+	}
 }
 
 func newMetadata(out []byte, key, ip string) (metadata.MD, error) {
@@ -0,0 +1,338 @@
+package grpcworker
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/agent/workerpool"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/api/grpcapi"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/decrypt"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/hashcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/limitcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/replaycheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/signcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/tokencheck"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"github.com/niksmo/runlytics/pkg/token"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func newDecrypters(t *testing.T, ids ...string) *keyring.Keyring[di.Decrypter] {
+	t.Helper()
+	decrypters := keyring.New[di.Decrypter]()
+	for _, id := range ids {
+		require.NoError(t, decrypters.Add(id, plainCipher{}, time.Time{}))
+	}
+	return decrypters
+}
+
+func newHashKeys(t *testing.T, keys map[string]string) *keyring.Keyring[string] {
+	t.Helper()
+	hashKeys := keyring.New[string]()
+	for id, key := range keys {
+		require.NoError(t, hashKeys.Add(id, key, time.Time{}))
+	}
+	return hashKeys
+}
+
+var testClientOpts = ClientOpts{
+	DialTimeout: time.Second,
+	Timeout:     time.Second,
+	Keepalive:   10 * time.Second,
+}
+
+// countListener counts accepted connections.
+type countListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestClient(t *testing.T) {
+	logger.Init("fatal")
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	lis := &countListener{Listener: tcp}
+
+	service := &batchService{
+		applied: make(map[metrics.BatchID]metrics.MetricsList),
+	}
+	s := grpc.NewServer()
+	grpcapi.Register(s, grpcapi.RegisterServices{IBatchUpdateService: service})
+	go s.Serve(lis)
+	defer func() { s.Stop() }()
+
+	addr := lis.Addr().String()
+	ml := metrics.MetricsList{
+		{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+	}
+	send := func(c *Client, seq uint64) error {
+		id := metrics.BatchID{AgentID: "host-a", Seq: seq}
+		return c.SendMetrics(
+			context.Background(), id, ml, plainCipher{}, addr, "", "",
+		)
+	}
+
+	t.Run("Should reuse connection on repeated sends", func(t *testing.T) {
+		client, err := NewClient(addr, testClientOpts)
+		require.NoError(t, err)
+
+		// first send establishes the connection
+		require.NoError(t, send(client, 1))
+		baseline := runtime.NumGoroutine()
+
+		for seq := range uint64(100) {
+			require.NoError(t, send(client, seq+2))
+		}
+		assert.Equal(t, int32(1), lis.accepted.Load())
+		assert.LessOrEqual(t, runtime.NumGoroutine(), baseline)
+
+		require.NoError(t, client.Close())
+	})
+
+	t.Run("Should release goroutines on close", func(t *testing.T) {
+		baseline := runtime.NumGoroutine()
+
+		client, err := NewClient(addr, testClientOpts)
+		require.NoError(t, err)
+		require.NoError(t, send(client, 200))
+		require.NoError(t, client.Close())
+
+		assert.Eventually(t, func() bool {
+			return runtime.NumGoroutine() <= baseline
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Should reconnect after server restart", func(t *testing.T) {
+		client, err := NewClient(addr, testClientOpts)
+		require.NoError(t, err)
+		defer client.Close()
+		require.NoError(t, send(client, 300))
+
+		s.Stop()
+		assert.Error(t, send(client, 301))
+
+		tcp, err := net.Listen("tcp", addr)
+		require.NoError(t, err)
+		s = grpc.NewServer()
+		grpcapi.Register(
+			s, grpcapi.RegisterServices{IBatchUpdateService: service},
+		)
+		go s.Serve(tcp)
+
+		assert.Eventually(t, func() bool {
+			return send(client, 302) == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+// agentKeys is agent keys registry, nil key is revoked.
+type agentKeys map[string]ed25519.PublicKey
+
+func (k agentKeys) AgentKey(
+	_ context.Context, agentID string,
+) (ed25519.PublicKey, error) {
+	key, ok := k[agentID]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%w '%s'", sign.ErrUnknownAgent, agentID)
+	case key == nil:
+		return nil, fmt.Errorf("%w '%s'", sign.ErrRevokedAgent, agentID)
+	}
+	return key, nil
+}
+
+// startServer serves batches applied by the returned service
+// until the test ends.
+func startServer(
+	t *testing.T, opts ...grpc.ServerOption,
+) (string, *batchService) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	service := &batchService{
+		applied: make(map[metrics.BatchID]metrics.MetricsList),
+	}
+	s := grpc.NewServer(opts...)
+	grpcapi.Register(s, grpcapi.RegisterServices{IBatchUpdateService: service})
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	return lis.Addr().String(), service
+}
+
+// TestClientSecured checks that batches of unary and stream senders
+// pass server checks, the checks are tested by interceptor packages.
+func TestClientSecured(t *testing.T) {
+	logger.Init("fatal")
+
+	hashKeys := newHashKeys(t, map[string]string{"k2": "secret2"})
+	require.NoError(t, hashKeys.Add("k1", "secret1", time.Now()))
+	decrypters := newDecrypters(t, "c2")
+	publicA, privateA, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, privateB, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keys := agentKeys{"host-a": publicA}
+	guard := replay.NewGuard(time.Minute, 100)
+
+	// every case is client of own token, the limit allows one batch
+	// per method and token, so batch of spent token is throttled
+	raw := make(map[string]string)
+	var issued []token.Token
+	for id, scope := range map[string]token.Scope{
+		"accepted": token.ScopeWrite, "expired": token.ScopeWrite,
+		"other-key": token.ScopeWrite, "read": token.ScopeRead,
+	} {
+		r, tok, err := token.New(id, token.Scopes{scope})
+		require.NoError(t, err)
+		raw[id], issued = r, append(issued, tok)
+	}
+	tokens, err := token.NewRegistry(issued)
+	require.NoError(t, err)
+	limit := ratelimit.Limit{Rate: 0.1, Burst: 1}
+	limits := limitcheck.Limits{
+		pb.Runlytics_BatchUpdate_FullMethodName:   ratelimit.New(limit, 0),
+		pb.Runlytics_StreamUpdates_FullMethodName: ratelimit.New(limit, 0),
+	}
+
+	addr, service := startServer(
+		t,
+		grpc.ChainUnaryInterceptor(
+			tokencheck.New(tokens),
+			limitcheck.New(limits),
+			decrypt.New(decrypters),
+			hashcheck.New(hashKeys),
+			signcheck.New(keys),
+			replaycheck.New(guard),
+		),
+		grpc.ChainStreamInterceptor(
+			tokencheck.NewStream(tokens),
+			limitcheck.NewStream(limits),
+			decrypt.NewStream(decrypters),
+			hashcheck.NewStream(hashKeys),
+			signcheck.NewStream(keys),
+			replaycheck.NewStream(guard),
+		),
+	)
+	ml := metrics.MetricsList{
+		{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+	}
+
+	tests := []struct {
+		name    string
+		opts    ClientOpts
+		key     string
+		wantErr error
+	}{
+		{
+			name: "Should accept batch",
+			opts: ClientOpts{
+				HashKeyID: "k2", CryptoKeyID: "c2", SignKey: privateA,
+				Token: raw["accepted"],
+			},
+			key: "secret2",
+		},
+		{
+			name: "Should reject batch of expired hash key",
+			opts: ClientOpts{
+				HashKeyID: "k1", CryptoKeyID: "c2", SignKey: privateA,
+				Token: raw["expired"],
+			},
+			key:     "secret1",
+			wantErr: workerpool.ErrRejected,
+		},
+		{
+			name: "Should reject batch signed by other key",
+			opts: ClientOpts{
+				HashKeyID: "k2", CryptoKeyID: "c2", SignKey: privateB,
+				Token: raw["other-key"],
+			},
+			key:     "secret2",
+			wantErr: workerpool.ErrRejected,
+		},
+		{
+			name: "Should reject batch of read token",
+			opts: ClientOpts{
+				HashKeyID: "k2", CryptoKeyID: "c2", SignKey: privateA,
+				Token: raw["read"],
+			},
+			key:     "secret2",
+			wantErr: workerpool.ErrRejected,
+		},
+		{
+			name: "Should throttle batch over the limit",
+			opts: ClientOpts{
+				HashKeyID: "k2", CryptoKeyID: "c2", SignKey: privateA,
+				Token: raw["accepted"],
+			},
+			key:     "secret2",
+			wantErr: workerpool.ErrThrottled,
+		},
+	}
+
+	var seq uint64
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.DialTimeout, opts.Timeout, opts.Keepalive =
+				testClientOpts.DialTimeout,
+				testClientOpts.Timeout,
+				testClientOpts.Keepalive
+			client, err := NewClient(addr, opts)
+			require.NoError(t, err)
+			defer client.Close()
+			sender := NewStreamSender(client)
+			defer sender.Close()
+
+			for _, send := range []di.SendMetricsFunc{
+				client.SendMetrics, sender.SendMetrics,
+			} {
+				seq++
+				id := metrics.BatchID{AgentID: "host-a", Seq: seq}
+				err := send(
+					context.Background(),
+					id, ml, plainCipher{}, addr, test.key, "",
+				)
+				if test.wantErr != nil {
+					assert.ErrorIs(t, err, test.wantErr)
+					assert.NotContains(t, service.applied, id)
+					var throttled *workerpool.ThrottledError
+					if errors.As(err, &throttled) {
+						assert.InDelta(
+							t, 10*time.Second, throttled.RetryAfter,
+							float64(time.Second),
+						)
+					}
+					continue
+				}
+				require.NoError(t, err)
+				assert.Equal(t, ml, service.applied[id])
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package grpcworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/agent/workerpool"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// StreamSender sends batches over one long-lived StreamUpdates stream
+// instead of unary call per batch.
+//
+// Batches are sent one by one, every batch waits for its ack.
+// The stream is opened by the first batch and reopened
+// by the next batch after failure over the shared client connection.
+type StreamSender struct {
+	mu     sync.Mutex
+	client *Client
+	stream pb.Runlytics_StreamUpdatesClient
+	cancel context.CancelFunc
+}
+
+// NewStreamSender returns StreamSender pointer.
+// Closing StreamSender closes the client.
+func NewStreamSender(client *Client) *StreamSender {
+	return &StreamSender{client: client}
+}
+
+// SendMetrics sends batch to stream and returns error, if batch is not
+// acknowledged as applied. If server rejects batch as invalid, error
+// wraps [workerpool.ErrRejected], if server limits batches, error wraps
+// [workerpool.ThrottledError].
+//
+// The method is [di.SendMetricsFunc].
+func (s *StreamSender) SendMetrics(
+	ctx context.Context,
+	id metrics.BatchID,
+	m metrics.MetricsList,
+	enc di.Encrypter,
+	addr, hk, ip string,
+) error {
+	const op = "grpcworker.StreamSender.SendMetrics"
+	log := logger.Log.With(
+		zap.String("op", op), zap.String("addr", addr), zap.String("ip", ip),
+	)
+
+	data, err := serialize(m)
+	if err != nil {
+		log.Fatal("failed serialize payload", zap.Error(err))
+	}
+
+	encrypted, err := encrypt(enc, data)
+	if err != nil {
+		log.Fatal("failed encrypt payload", zap.Error(err))
+	}
+	stamp := replay.NewStamp()
+	req := newRequest(encrypted, id)
+	req.Timestamp, req.Nonce = stamp.Time.UnixMilli(), stamp.Nonce
+	req.Signature = s.client.sign(id, stamp.Material(data))
+
+	if hk != "" {
+		req.Hash, err = workerpool.GetHashString(stamp.Material(data), hk)
+		if err != nil {
+			log.Fatal("failed hash payload", zap.Error(err))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stream, err := s.open(ip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// canceled or expired ctx breaks the stream, the next batch reopens it
+	ctx, cancel := context.WithTimeout(ctx, s.client.timeout)
+	defer cancel()
+	stop := context.AfterFunc(ctx, s.cancel)
+	defer stop()
+
+	reqStart := time.Now()
+	ack, err := roundTrip(stream, req)
+	if err != nil {
+		s.reset()
+		return sendError(op, err)
+	}
+	if code := codes.Code(ack.GetCode()); code != codes.OK {
+		return sendError(op, status.Error(code, ack.GetMessage()))
+	}
+
+	log.Info(
+		"got ack",
+		zap.Duration("resTime", time.Since(reqStart)),
+		zap.Uint32("updatedCount", ack.GetResponse().GetUpdatedCount()),
+		zap.Stringer("batch", id),
+		zap.Bool("duplicate", ack.GetResponse().GetDuplicate()),
+	)
+	return nil
+}
+
+// Close closes the stream and the client.
+func (s *StreamSender) Close() error {
+	const op = "grpcworker.StreamSender.Close"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stream != nil {
+		s.stream.CloseSend()
+	}
+	s.reset()
+
+	if err := s.client.Close(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// open returns opened stream or opens new one.
+func (s *StreamSender) open(
+	ip string,
+) (pb.Runlytics_StreamUpdatesClient, error) {
+	const op = "grpcworker.StreamSender.open"
+	if s.stream != nil {
+		return s.stream, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = metadata.NewOutgoingContext(
+		ctx, metadata.Join(metadata.Pairs("X-Real-IP", ip), s.client.callMD),
+	)
+	stream, err := s.client.client.StreamUpdates(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s.stream, s.cancel = stream, cancel
+	return stream, nil
+}
+
+// reset drops broken stream.
+func (s *StreamSender) reset() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.stream, s.cancel = nil, nil
+}
+
+// roundTrip sends batch and returns its ack.
+func roundTrip(
+	stream pb.Runlytics_StreamUpdatesClient, req *pb.BatchUpdateRequest,
+) (*pb.BatchUpdateAck, error) {
+	if err := stream.Send(req); err != nil {
+		if !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		// status of broken stream is returned by Recv
+		if _, err = stream.Recv(); err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	return stream.Recv()
+}
@@ -0,0 +1,129 @@
+package grpcworker
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/agent/workerpool"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/decrypt"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/hashcheck"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+type plainCipher struct{}
+
+func (plainCipher) EncryptMsg(b []byte) ([]byte, error) { return b, nil }
+func (plainCipher) DecryptMsg(b []byte) ([]byte, error) { return b, nil }
+
+// batchService applies batches once.
+type batchService struct {
+	mu      sync.Mutex
+	applied map[metrics.BatchID]metrics.MetricsList
+}
+
+func (s *batchService) BatchUpdate(
+	ctx context.Context, ml metrics.MetricsList,
+) error {
+	_, err := s.BatchUpdateOnce(ctx, metrics.BatchID{}, ml)
+	return err
+}
+
+func (s *batchService) BatchUpdateOnce(
+	_ context.Context, id metrics.BatchID, ml metrics.MetricsList,
+) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.applied[id]; ok {
+		return true, nil
+	}
+	s.applied[id] = ml
+	return false, nil
+}
+
+func (s *batchService) BatchUpdatePartial(
+	ctx context.Context,
+	id metrics.BatchID,
+	ml metrics.MetricsList,
+	_ ...metrics.VerifyOp,
+) (metrics.BatchResult, error) {
+	duplicate, err := s.BatchUpdateOnce(ctx, id, ml)
+	return metrics.BatchResult{Duplicate: duplicate}, err
+}
+
+func TestStreamSender(t *testing.T) {
+	logger.Init("fatal")
+	const key = "secret"
+
+	var streams int
+	var streamsMu sync.Mutex
+	countStreams := func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		streamsMu.Lock()
+		streams++
+		streamsMu.Unlock()
+		return handler(srv, ss)
+	}
+
+	addr, service := startServer(t, grpc.ChainStreamInterceptor(
+		countStreams,
+		decrypt.NewStream(newDecrypters(t, "")),
+		hashcheck.NewStream(newHashKeys(t, map[string]string{"": key})),
+	))
+	ml := metrics.MetricsList{
+		{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+	}
+
+	client, err := NewClient(addr, testClientOpts)
+	require.NoError(t, err)
+	sender := NewStreamSender(client)
+	defer sender.Close()
+
+	t.Run("Should send batches over one stream", func(t *testing.T) {
+		for seq := range uint64(3) {
+			id := metrics.BatchID{AgentID: "host-a", Seq: seq + 1}
+			err := sender.SendMetrics(
+				context.Background(), id, ml, plainCipher{}, addr, key, "",
+			)
+			require.NoError(t, err)
+			assert.Equal(t, ml, service.applied[id])
+		}
+		assert.Equal(t, 1, streams)
+	})
+
+	t.Run("Should reject invalid batch and keep stream", func(t *testing.T) {
+		invalid := metrics.MetricsList{{ID: "", MType: metrics.MTypeGauge}}
+		err := sender.SendMetrics(
+			context.Background(),
+			metrics.BatchID{AgentID: "host-a", Seq: 10},
+			invalid, plainCipher{}, addr, key, "",
+		)
+		assert.ErrorIs(t, err, workerpool.ErrRejected)
+		assert.Equal(t, 1, streams)
+	})
+
+	t.Run("Should reopen stream after missing hash", func(t *testing.T) {
+		err := sender.SendMetrics(
+			context.Background(),
+			metrics.BatchID{AgentID: "host-a", Seq: 11},
+			ml, plainCipher{}, addr, "", "",
+		)
+		assert.ErrorIs(t, err, workerpool.ErrRejected)
+
+		id := metrics.BatchID{AgentID: "host-a", Seq: 12}
+		err = sender.SendMetrics(
+			context.Background(), id, ml, plainCipher{}, addr, key, "",
+		)
+		require.NoError(t, err)
+		assert.Equal(t, ml, service.applied[id])
+		assert.Equal(t, 2, streams)
+	})
+}
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,23 +19,84 @@ import (
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
 	"go.uber.org/zap"
 )
 
-const headerHashKey = "HashSHA256"
+const (
+	headerHashKey        = "HashSHA256"
+	headerAgentID        = "X-Agent-ID"
+	headerBatchSeq       = "X-Batch-Seq"
+	headerBatchDuplicate = "X-Batch-Duplicate"
+	headerHashKeyID      = "X-Hash-Key-ID"
+	headerCryptoKeyID    = "X-Crypto-Key-ID"
+	headerSignature      = "X-Signature"
+	headerAuthorization  = "Authorization"
+	headerRetryAfter     = "Retry-After"
+)
 
 var (
 	bufferPool     = sync.Pool{}
 	gzipWriterPool = sync.Pool{}
 )
 
-func SendMetrics(
+// ClientOpts describes Client parameters.
+type ClientOpts struct {
+	TLSConfig   *tls.Config // posts over HTTPS, if set
+	HashKeyID   string      // server HMAC key id, optional
+	CryptoKeyID string      // server private key id, optional
+	Token       string      // bearer token of requests, optional
+
+	// SignKey signs batches with agent id, batches are not signed if nil.
+	SignKey ed25519.PrivateKey
+}
+
+// Client posts batches over HTTP or HTTPS.
+type Client struct {
+	client  *http.Client
+	header  http.Header // key ids and token of every request
+	signKey ed25519.PrivateKey
+}
+
+// NewClient returns Client pointer.
+func NewClient(opts ClientOpts) *Client {
+	c := &Client{
+		client:  http.DefaultClient,
+		header:  http.Header{},
+		signKey: opts.SignKey,
+	}
+	if opts.HashKeyID != "" {
+		c.header.Set(headerHashKeyID, opts.HashKeyID)
+	}
+	if opts.CryptoKeyID != "" {
+		c.header.Set(headerCryptoKeyID, opts.CryptoKeyID)
+	}
+	if opts.Token != "" {
+		c.header.Set(headerAuthorization, "Bearer "+opts.Token)
+	}
+	if opts.TLSConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = opts.TLSConfig
+		c.client = &http.Client{Transport: transport}
+	}
+	return c
+}
+
+// SendMetrics posts batch and returns error, if batch is not applied.
+// If server rejects batch with 4xx status, error wraps [workerpool.ErrRejected].
+// If server limits requests with 429 status, error wraps
+// [workerpool.ThrottledError] of Retry-After header.
+//
+// The method is [di.SendMetricsFunc].
+func (c *Client) SendMetrics(
 	ctx context.Context,
+	id metrics.BatchID,
 	m metrics.MetricsList,
 	enc di.Encrypter,
 	url, hk, ip string,
 ) error {
-	const op = "httpworker.SendMetrics"
+	const op = "httpworker.Client.SendMetrics"
 	log := logger.Log.With(
 		zap.String("op", op), zap.String("url", url), zap.String("ip", ip),
 	)
@@ -43,18 +108,31 @@ func SendMetrics(
 	}
 	defer bufferPool.Put(buf)
 
-	var sha256 string
-	if err := makeReqData(buf, &sha256, m, hk, enc); err != nil {
+	var sha256, signature string
+	stamp := replay.NewStamp()
+	err := makeReqData(
+		buf, &sha256, &signature, m, hk, c.signKey, id, stamp, enc,
+	)
+	if err != nil {
 		log.Fatal("failed to make request data", zap.Error(err))
 	}
 
-	req, err := newRequest(url, buf, sha256, ip)
+	req, err := newRequest(url, buf, sha256, ip, id)
 	if err != nil {
 		log.Fatal("failed to create request", zap.Error(err))
 	}
 
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	if signature != "" {
+		req.Header.Set(headerSignature, signature)
+	}
+	req.Header.Set(replay.XTimestamp, stamp.Timestamp())
+	req.Header.Set(replay.XNonce, stamp.Nonce)
+
 	reqStart := time.Now()
-	res, err := http.DefaultClient.Do(req)
+	res, err := c.client.Do(req)
 	if err != nil {
 		log.Warn(
 			"failed to do request",
@@ -67,21 +145,41 @@ func SendMetrics(
 		"got response",
 		zap.String("status", res.Status),
 		zap.Duration("resTime", time.Since(reqStart)),
+		zap.Stringer("batch", id),
+		zap.Bool("duplicate", res.Header.Get(headerBatchDuplicate) == "true"),
 	)
 
-	if _, err := readResData(res); err != nil {
+	data, err := readResData(res)
+	if err != nil {
 		log.Warn("failed to read response data", zap.Error(err))
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	switch {
+	case res.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%s: %s: %s", op, res.Status, data)
+	case res.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf(
+			"%s: %w: %s: %s",
+			op, &workerpool.ThrottledError{RetryAfter: retryAfter(res)},
+			res.Status, data,
+		)
+	case res.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf(
+			"%s: %w: %s: %s", op, workerpool.ErrRejected, res.Status, data,
+		)
+	}
 	return nil
 }
 
 func makeReqData(
 	buf *bytes.Buffer,
-	sha256 *string,
+	sha256, signature *string,
 	metrics []metrics.Metrics,
 	key string,
+	signKey ed25519.PrivateKey,
+	id metrics.BatchID,
+	stamp replay.Stamp,
 	encrypter di.Encrypter,
 ) error {
 	const op = "httpworker.makeReqData"
@@ -92,13 +190,19 @@ func makeReqData(
 	}
 
 	if key != "" {
-		*sha256, err = workerpool.GetHashString(jsonData, key)
+		*sha256, err = workerpool.GetHashString(stamp.Material(jsonData), key)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
 	}
 
+	if signKey != nil {
+		*signature = base64.StdEncoding.EncodeToString(
+			sign.Sign(signKey, id.AgentID, id.Seq, stamp.Material(jsonData)),
+		)
+	}
+
 	gzipWriter, ok := gzipWriterPool.Get().(*gzip.Writer)
 	if !ok {
 		gzipWriter = gzip.NewWriter(buf)
@@ -125,7 +229,11 @@ func makeReqData(
 }
 
 func newRequest(
-	URL string, body *bytes.Buffer, sha256 string, outboundIP string,
+	URL string,
+	body *bytes.Buffer,
+	sha256 string,
+	outboundIP string,
+	id metrics.BatchID,
 ) (*http.Request, error) {
 	const op = "httpworker.newRequest"
 
@@ -142,6 +250,10 @@ func newRequest(
 	if outboundIP != "" {
 		request.Header.Set("X-Real-IP", outboundIP)
 	}
+	if !id.IsZero() {
+		request.Header.Set(headerAgentID, id.AgentID)
+		request.Header.Set(headerBatchSeq, strconv.FormatUint(id.Seq, 10))
+	}
 	return request, nil
 }
 
@@ -162,3 +274,13 @@ func readResData(res *http.Response) ([]byte, error) {
 	}
 	return data, nil
 }
+
+// retryAfter returns delay of Retry-After seconds header
+// or [workerpool.DefaultRetryAfter].
+func retryAfter(res *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(res.Header.Get(headerRetryAfter))
+	if err != nil || seconds <= 0 {
+		return workerpool.DefaultRetryAfter
+	}
+	return time.Duration(seconds) * time.Second
+}
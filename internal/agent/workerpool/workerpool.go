@@ -2,7 +2,10 @@ package workerpool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
@@ -11,30 +14,80 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// maxPending is number of failed batches kept for resend,
+// the oldest batches are dropped on overflow.
+const maxPending = 64
+
+// DefaultRetryAfter is throttling time, if server does not hint it.
+const DefaultRetryAfter = time.Second
+
+// ErrRejected is returned by [di.SendMetricsFunc], when server rejects
+// the batch as invalid, so resending the same batch is useless.
+var ErrRejected = errors.New("batch rejected by server")
+
+// ErrThrottled is returned by [di.SendMetricsFunc], when server limits
+// requests of the agent, see [ThrottledError].
+var ErrThrottled = errors.New("batch throttled by server")
+
+// ThrottledError is [ErrThrottled] with time after which server
+// accepts batches again. Batches are not sent until then,
+// they are kept pending.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrThrottled, e.RetryAfter)
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
 type WorkerOpts struct {
 	URL        string
 	HashKey    string
 	Encrypter  di.Encrypter
 	OutboundIP string
+	AgentID    string
+	Closer     di.Closer // closes sender on Stop, optional
 }
 
+// A batch is metrics list with its id.
+type batch struct {
+	id metrics.BatchID
+	ml metrics.MetricsList
+}
+
+// WorkerPool sends metrics lists divided into batches.
+//
+// Every new batch gets next sequence, failed batch is resent
+// with the same sequence on the next report, so server applies it once.
+// Sequence starts from start time, so it keeps growing after restart.
 type WorkerPool struct {
 	n         int
 	in        <-chan metrics.MetricsList
-	pollCount [2]int64
+	pollCount int64
+	seq       uint64
+	pending   []batch
 	wf        di.SendMetricsFunc
 	wo        WorkerOpts
 	grp       *errgroup.Group
+	mu        sync.Mutex
+	resumeAt  time.Time // batches are not sent before
+	now       func() time.Time
 }
 
 func New(
 	n int, in <-chan metrics.MetricsList, wf di.SendMetricsFunc, wo WorkerOpts,
 ) *WorkerPool {
 	return &WorkerPool{
-		n:  n,
-		in: in,
-		wf: wf,
-		wo: wo,
+		n:   n,
+		in:  in,
+		seq: uint64(time.Now().UnixNano()),
+		wf:  wf,
+		wo:  wo,
+		now: time.Now,
 	}
 }
 
@@ -47,19 +100,30 @@ func (p *WorkerPool) Run() {
 			p.handlePollCount(pci, m)
 		}
 
-		output := p.divideInput(m)
+		batches := append(p.pending, p.newBatches(p.divideInput(m))...)
+		p.pending = p.doWork(batches)
 
-		if err := p.doWork(output); err != nil {
+		if len(p.pending) != 0 {
 			logger.Log.Warn(
-				"failed to do work", zap.String("op", op), zap.Error(err),
+				"failed to send batches, resend on next report",
+				zap.String("op", op), zap.Int("pending", len(p.pending)),
 			)
-			p.rollbackPollCount()
 		}
 	}
 }
 
 func (p *WorkerPool) Stop() {
-	p.grp.Wait()
+	const op = "workerpool.Stop"
+	if p.grp != nil {
+		p.grp.Wait()
+	}
+	if p.wo.Closer != nil {
+		if err := p.wo.Closer.Close(); err != nil {
+			logger.Log.Warn(
+				"failed to close sender", zap.String("op", op), zap.Error(err),
+			)
+		}
+	}
 }
 
 func (p *WorkerPool) findPollCountIdx(m metrics.MetricsList) (int, bool) {
@@ -71,22 +135,14 @@ func (p *WorkerPool) findPollCountIdx(m metrics.MetricsList) (int, bool) {
 	return -1, false
 }
 
+// handlePollCount replaces total PollCount with delta since previous report.
+// Delta of failed batch is not lost, the batch is resent.
 func (p *WorkerPool) handlePollCount(idx int, m metrics.MetricsList) {
-	p.pollCount[0] = p.pollCount[1]
-	d := m[idx].Delta - p.pollCount[1]
-	p.pollCount[1] = m[idx].Delta
+	d := m[idx].Delta - p.pollCount
+	p.pollCount = m[idx].Delta
 	m[idx].Delta = d
 }
 
-func (p *WorkerPool) rollbackPollCount() {
-	const op = "workerpool.rollbackPollCount"
-	p.pollCount[1] = p.pollCount[0]
-	logger.Log.Debug(
-		"rollback",
-		zap.String("op", op), zap.Int64s("pollCount", p.pollCount[:]),
-	)
-}
-
 func (p *WorkerPool) divideInput(m metrics.MetricsList) []metrics.MetricsList {
 	var output []metrics.MetricsList
 	if len(m) > p.n {
@@ -108,27 +164,112 @@ func (p *WorkerPool) divideInput(m metrics.MetricsList) []metrics.MetricsList {
 	return output
 }
 
-func (p *WorkerPool) doWork(output []metrics.MetricsList) error {
+// newBatches assigns next sequence to every metrics list.
+func (p *WorkerPool) newBatches(output []metrics.MetricsList) []batch {
+	batches := make([]batch, 0, len(output))
+	for _, ml := range output {
+		p.seq++
+		batches = append(batches, batch{
+			id: metrics.BatchID{AgentID: p.wo.AgentID, Seq: p.seq},
+			ml: ml,
+		})
+	}
+	return batches
+}
+
+// doWork sends batches by n workers and returns failed batches to resend.
+func (p *WorkerPool) doWork(batches []batch) []batch {
 	const op = "workerpool.doWork"
-	grp, ctx := errgroup.WithContext(context.Background())
+	grp := new(errgroup.Group)
+	grp.SetLimit(p.n)
 	p.grp = grp
 
-	for _, m := range output {
-		ml := m
+	errs := make([]error, len(batches))
+	for idx, b := range batches {
 		grp.Go(func() error {
-			return p.wf(
-				ctx,
-				ml,
+			if wait, ok := p.throttled(); ok {
+				errs[idx] = &ThrottledError{RetryAfter: wait}
+				return nil
+			}
+			errs[idx] = p.wf(
+				context.Background(),
+				b.id,
+				b.ml,
 				p.wo.Encrypter,
 				p.wo.URL,
 				p.wo.HashKey,
 				p.wo.OutboundIP,
 			)
+			var throttled *ThrottledError
+			if errors.As(errs[idx], &throttled) {
+				p.throttle(throttled.RetryAfter)
+			}
+			return nil
 		})
 	}
+	grp.Wait()
+
+	var pending []batch
+	for idx, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrRejected):
+			logger.Log.Warn(
+				"batch dropped",
+				zap.String("op", op),
+				zap.Stringer("batch", batches[idx].id),
+				zap.Error(err),
+			)
+		default:
+			if b, ok := batches[idx].resend(); ok {
+				pending = append(pending, b)
+			}
+		}
+	}
+
+	if overflow := len(pending) - maxPending; overflow > 0 {
+		logger.Log.Warn(
+			"pending batches overflow, the oldest are dropped",
+			zap.String("op", op), zap.Int("dropped", overflow),
+		)
+		pending = pending[overflow:]
+	}
+	return pending
+}
+
+// throttled reports whether sending is throttled
+// and returns time left until server accepts batches.
+func (p *WorkerPool) throttled() (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	wait := p.resumeAt.Sub(p.now())
+	return wait, wait > 0
+}
+
+// throttle stops sending for d, the latest resume time wins.
+func (p *WorkerPool) throttle(d time.Duration) {
+	const op = "workerpool.throttle"
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	resumeAt := p.now().Add(d)
+	if resumeAt.After(p.resumeAt) {
+		p.resumeAt = resumeAt
+		logger.Log.Warn(
+			"sending throttled by server",
+			zap.String("op", op), zap.Duration("retryAfter", d),
+		)
+	}
+}
 
-	if err := grp.Wait(); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+// resend returns batch to resend with the same id. Gauges are dropped,
+// the next report has fresh values, which should not be overwritten
+// by stale ones. It reports false if nothing is left to resend.
+func (b batch) resend() (batch, bool) {
+	ml := make(metrics.MetricsList, 0, len(b.ml))
+	for _, m := range b.ml {
+		if m.MType != metrics.MTypeGauge {
+			ml = append(ml, m)
+		}
 	}
-	return nil
+	return batch{id: b.id, ml: ml}, len(ml) != 0
 }
@@ -0,0 +1,166 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer applies batches once and fails while errs are not empty
+// or the server is down.
+type fakeServer struct {
+	mu      sync.Mutex
+	down    bool
+	errs    []error
+	applied map[metrics.BatchID]metrics.MetricsList
+	sent    []metrics.BatchID
+}
+
+func (s *fakeServer) send(
+	_ context.Context,
+	id metrics.BatchID,
+	ml metrics.MetricsList,
+	_ di.Encrypter,
+	_, _, _ string,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, id)
+	if s.down {
+		return errors.New("unavailable")
+	}
+	if len(s.errs) != 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return err
+	}
+	if _, ok := s.applied[id]; !ok {
+		s.applied[id] = ml
+	}
+	return nil
+}
+
+func (s *fakeServer) pollCount() (total int64) {
+	for _, ml := range s.applied {
+		for _, m := range ml {
+			if m.ID == "PollCount" {
+				total += m.Delta
+			}
+		}
+	}
+	return total
+}
+
+func TestWorkerPool(t *testing.T) {
+	logger.Init("fatal")
+
+	run := func(s *fakeServer, reports ...int64) *WorkerPool {
+		in := make(chan metrics.MetricsList)
+		p := New(1, in, s.send, WorkerOpts{AgentID: "host-a"})
+		done := make(chan struct{})
+		go func() {
+			p.Run()
+			close(done)
+		}()
+		for _, pollCount := range reports {
+			in <- metrics.MetricsList{
+				{ID: "PollCount", MType: metrics.MTypeCounter, Delta: pollCount},
+				{ID: "Alloc", MType: metrics.MTypeGauge, Value: float64(pollCount)},
+			}
+		}
+		close(in)
+		<-done
+		return p
+	}
+
+	t.Run("Should resend failed batch with the same seq", func(t *testing.T) {
+		s := &fakeServer{
+			errs:    []error{errors.New("unavailable")},
+			applied: make(map[metrics.BatchID]metrics.MetricsList),
+		}
+		p := run(s, 5, 12)
+
+		require.Len(t, s.sent, 3)
+		assert.Equal(t, s.sent[0], s.sent[1], "failed batch goes first")
+		assert.Less(t, s.sent[1].Seq, s.sent[2].Seq)
+		assert.Equal(t, int64(12), s.pollCount())
+		assert.Empty(t, p.pending)
+
+		resent := s.applied[s.sent[1]]
+		require.Len(t, resent, 1, "stale gauge is not resent")
+		assert.Equal(t, metrics.MTypeCounter, resent[0].MType)
+	})
+
+	t.Run("Should drop rejected batch", func(t *testing.T) {
+		s := &fakeServer{
+			errs:    []error{fmt.Errorf("bad request: %w", ErrRejected)},
+			applied: make(map[metrics.BatchID]metrics.MetricsList),
+		}
+		p := run(s, 5, 12)
+
+		require.Len(t, s.sent, 2)
+		assert.NotEqual(t, s.sent[0], s.sent[1])
+		assert.Equal(t, int64(7), s.pollCount())
+		assert.Empty(t, p.pending)
+	})
+
+	t.Run("Should not send until throttling ends", func(t *testing.T) {
+		s := &fakeServer{
+			errs:    []error{&ThrottledError{RetryAfter: time.Minute}},
+			applied: make(map[metrics.BatchID]metrics.MetricsList),
+		}
+		p := New(1, nil, s.send, WorkerOpts{AgentID: "host-a"})
+		now := time.Now()
+		p.now = func() time.Time { return now }
+		report := func(pollCount int64) {
+			batches := p.newBatches([]metrics.MetricsList{{
+				{ID: "PollCount", MType: metrics.MTypeCounter, Delta: pollCount},
+			}})
+			p.pending = p.doWork(append(p.pending, batches...))
+		}
+
+		report(5)
+		report(7)
+		require.Len(t, s.sent, 1, "throttled batches are not sent")
+		require.Len(t, p.pending, 2)
+
+		now = now.Add(time.Minute)
+		report(8)
+		require.Len(t, s.sent, 4, "pending batches are sent after throttling")
+		assert.Equal(t, s.sent[0], s.sent[1], "throttled batch is resent")
+		assert.Equal(t, int64(20), s.pollCount())
+		assert.Empty(t, p.pending)
+	})
+
+	t.Run("Should keep limited pending batches", func(t *testing.T) {
+		s := &fakeServer{
+			down:    true,
+			applied: make(map[metrics.BatchID]metrics.MetricsList),
+		}
+		reports := make([]int64, maxPending+10)
+		for idx := range reports {
+			reports[idx] = int64(idx + 1)
+		}
+		p := run(s, reports...)
+		require.Len(t, p.pending, maxPending)
+		assert.Equal(t, int64(maxPending), sumPending(p), "every report adds one")
+	})
+}
+
+func sumPending(p *WorkerPool) (total int64) {
+	for _, b := range p.pending {
+		for _, m := range b.ml {
+			total += m.Delta
+		}
+	}
+	return total
+}
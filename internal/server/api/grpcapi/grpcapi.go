@@ -4,54 +4,355 @@ import (
 	"bytes"
 	"context"
 	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"time"
 
+	"github.com/niksmo/runlytics/internal/server"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
 	pb "github.com/niksmo/runlytics/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// batchVerifyOps are checks of every metrics list item.
+var batchVerifyOps = []metrics.VerifyOp{
+	metrics.VerifyID,
+	metrics.VerifyType,
+	metrics.VerifyLabels,
+	metrics.VerifyHistogram,
+}
+
+var itemStatus = map[string]pb.ItemStatus{
+	metrics.ItemApplied: pb.ItemStatus_ITEM_STATUS_APPLIED,
+	metrics.ItemInvalid: pb.ItemStatus_ITEM_STATUS_INVALID,
+	metrics.ItemFailed:  pb.ItemStatus_ITEM_STATUS_FAILED,
+}
+
 type serverAPI struct {
 	pb.UnimplementedRunlyticsServer
 	batchUpdateService di.IBatchUpdateService
+	updateService      di.IUpdateService
+	readService        di.IReadService
+	listService        di.IListService
+	healthCheckService di.IHealthCheckService
+	rangeService       di.IRangeService
+	deleteService      di.IDeleteService
+	legacyGob          bool
+}
+
+type RegisterServices struct {
+	di.IBatchUpdateService
+	di.IUpdateService
+	di.IReadService
+	di.IListService
+	di.IHealthCheckService
+	di.IRangeService
+	di.IDeleteService
+
+	// LegacyGob accepts deprecated gob encoded metrics of batch update.
+	LegacyGob bool
 }
 
-func Register(
-	gRPCServer *grpc.Server,
-	batchUpdateService di.IBatchUpdateService,
-) {
+// Register registers Runlytics service and standard health service,
+// both backed by s.
+func Register(gRPCServer *grpc.Server, s RegisterServices) {
 	pb.RegisterRunlyticsServer(
-		gRPCServer, &serverAPI{batchUpdateService: batchUpdateService},
+		gRPCServer,
+		&serverAPI{
+			batchUpdateService: s.IBatchUpdateService,
+			updateService:      s.IUpdateService,
+			readService:        s.IReadService,
+			listService:        s.IListService,
+			healthCheckService: s.IHealthCheckService,
+			rangeService:       s.IRangeService,
+			deleteService:      s.IDeleteService,
+			legacyGob:          s.LegacyGob,
+		},
+	)
+	healthpb.RegisterHealthServer(
+		gRPCServer, &healthServer{service: s.IHealthCheckService},
 	)
 }
 
 func (s *serverAPI) BatchUpdate(
 	ctx context.Context, in *pb.BatchUpdateRequest,
 ) (*pb.BatchUpdateResponse, error) {
-	b := bytes.NewReader(in.GetMetrics())
+	ml, err := s.decodeBatch(in)
+	if err != nil {
+		return nil, err
+	}
+
+	id := metrics.BatchID{AgentID: in.GetAgentId(), Seq: in.GetSeq()}
+	if err = id.Verify(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 
-	var ml metrics.MetricsList
-	err := gob.NewDecoder(b).Decode(&ml)
+	if in.GetPartial() {
+		return s.batchUpdatePartial(ctx, id, ml)
+	}
+
+	var batchErrs metrics.BatchErrors
+	err = ml.Verify(batchVerifyOps...)
+	if errors.As(err, &batchErrs) {
+		return nil, batchErrorsStatus(batchErrs)
+	}
+
+	duplicate, err := s.batchUpdateService.BatchUpdateOnce(ctx, id, ml)
+	if errors.As(err, &batchErrs) {
+		return nil, batchErrorsStatus(batchErrs)
+	}
 	if err != nil {
+		return nil, status.Error(
+			codes.Internal, "failed to update",
+		)
+	}
+
+	if duplicate {
+		return &pb.BatchUpdateResponse{Duplicate: true}, nil
+	}
+	return &pb.BatchUpdateResponse{UpdatedCount: uint32(len(ml))}, nil
+}
+
+// StreamUpdates applies every received batch like BatchUpdate
+// and acknowledges it with the batch status, so failed batch
+// does not break the stream.
+func (s *serverAPI) StreamUpdates(stream pb.Runlytics_StreamUpdatesServer) error {
+	for {
+		in, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &pb.BatchUpdateAck{Seq: in.GetSeq()}
+		res, err := s.BatchUpdate(stream.Context(), in)
+		if err != nil {
+			st := status.Convert(err)
+			ack.Code = uint32(st.Code())
+			ack.Message = st.Message()
+		} else {
+			ack.Response = res
+		}
+
+		if err = stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeBatch returns metrics list of protobuf batch or, if batch is empty,
+// of legacy gob metrics, when it is enabled.
+func (s *serverAPI) decodeBatch(
+	in *pb.BatchUpdateRequest,
+) (metrics.MetricsList, error) {
+	legacy := in.GetMetrics()
+	if len(in.GetBatch()) == 0 && len(legacy) != 0 {
+		if !s.legacyGob {
+			return nil, status.Error(
+				codes.InvalidArgument, "gob encoded metrics are not accepted",
+			)
+		}
+		var ml metrics.MetricsList
+		if err := gob.NewDecoder(bytes.NewReader(legacy)).Decode(&ml); err != nil {
+			return nil, status.Error(codes.InvalidArgument, "failed to decode")
+		}
+		return ml, nil
+	}
+
+	var batch pb.MetricsBatch
+	if err := proto.Unmarshal(in.GetBatch(), &batch); err != nil {
 		return nil, status.Error(codes.InvalidArgument, "failed to decode")
 	}
+	return batch.MetricsList(), nil
+}
+
+func (s *serverAPI) batchUpdatePartial(
+	ctx context.Context, id metrics.BatchID, ml metrics.MetricsList,
+) (*pb.BatchUpdateResponse, error) {
+	res, err := s.batchUpdateService.BatchUpdatePartial(
+		ctx, id, ml, batchVerifyOps...,
+	)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to update")
+	}
+
+	out := &pb.BatchUpdateResponse{
+		UpdatedCount: uint32(res.Applied),
+		Duplicate:    res.Duplicate,
+		Items:        make([]*pb.ItemResult, len(res.Items)),
+	}
+	for idx, item := range res.Items {
+		out.Items[idx] = &pb.ItemResult{
+			Index:  uint32(item.Index),
+			Status: itemStatus[item.Status],
+			Error:  item.Error,
+		}
+	}
+	return out, nil
+}
 
-	err = ml.Verify(
+func (s *serverAPI) Update(
+	ctx context.Context, in *pb.UpdateRequest,
+) (*pb.UpdateResponse, error) {
+	if in.GetMetric() == nil {
+		return nil, status.Error(codes.InvalidArgument, "metric is required")
+	}
+	m := in.GetMetric().Metrics()
+	err := m.Verify(
 		metrics.VerifyID,
 		metrics.VerifyType,
+		metrics.VerifyLabels,
+		metrics.VerifyHistogram,
 	)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	err = s.batchUpdateService.BatchUpdate(ctx, ml)
+	err = s.updateService.Update(ctx, &m)
+	if errors.Is(err, metrics.ErrHistogramMismatch) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
-		return nil, status.Error(
-			codes.Internal, "failed to update",
+		return nil, status.Error(codes.Internal, "failed to update")
+	}
+	return &pb.UpdateResponse{Metric: pb.NewMetric(m)}, nil
+}
+
+func (s *serverAPI) Value(
+	ctx context.Context, in *pb.ValueRequest,
+) (*pb.ValueResponse, error) {
+	m := metrics.Metrics{
+		ID:     in.GetId(),
+		MType:  in.GetType(),
+		Labels: in.GetLabels(),
+	}
+	err := m.Verify(metrics.VerifyID, metrics.VerifyType, metrics.VerifyLabels)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.readService.Read(ctx, &m)
+	if errors.Is(err, server.ErrNotExists) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to read")
+	}
+	return &pb.ValueResponse{Metric: pb.NewMetric(m)}, nil
+}
+
+func (s *serverAPI) List(
+	ctx context.Context, in *pb.ListRequest,
+) (*pb.ListResponse, error) {
+	ml, err := s.listService.List(ctx, in.GetLabels())
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to read list")
+	}
+	return &pb.ListResponse{Metrics: pb.NewMetricList(ml)}, nil
+}
+
+func (s *serverAPI) Ping(
+	ctx context.Context, in *pb.PingRequest,
+) (*pb.PingResponse, error) {
+	if err := s.healthCheckService.Check(ctx); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &pb.PingResponse{}, nil
+}
+
+func (s *serverAPI) Range(
+	ctx context.Context, in *pb.RangeRequest,
+) (*pb.RangeResponse, error) {
+	q := metrics.RangeQuery{
+		ID:     in.GetId(),
+		MType:  in.GetType(),
+		Labels: in.GetLabels(),
+		Func:   in.GetFunc(),
+	}
+	if in.GetFrom() != nil {
+		q.From = in.GetFrom().AsTime()
+	}
+	if in.GetTo() != nil {
+		q.To = in.GetTo().AsTime()
+	}
+	if in.GetStep() != nil {
+		q.Step = in.GetStep().AsDuration()
+	}
+	q.SetDefaults(time.Now())
+
+	if err := q.Verify(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	points, err := s.rangeService.Range(ctx, q)
+	if errors.Is(err, server.ErrNotSupported) {
+		return nil, status.Error(codes.Unimplemented, err.Error())
+	}
+	if errors.Is(err, server.ErrRangeStep) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to read range")
+	}
+
+	res := &pb.RangeResponse{Points: make([]*pb.Point, 0, len(points))}
+	for _, p := range points {
+		res.Points = append(
+			res.Points,
+			&pb.Point{Ts: timestamppb.New(p.Time), Value: p.Value},
 		)
 	}
+	return res, nil
+}
 
-	return &pb.BatchUpdateResponse{UpdatedCount: uint32(len(ml))}, nil
+func (s *serverAPI) Delete(
+	ctx context.Context, in *pb.DeleteRequest,
+) (*pb.DeleteResponse, error) {
+	m := metrics.Metrics{
+		ID:     in.GetId(),
+		MType:  in.GetType(),
+		Labels: in.GetLabels(),
+	}
+	err := m.Verify(metrics.VerifyID, metrics.VerifyType, metrics.VerifyLabels)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = s.deleteService.Delete(ctx, &m)
+	if errors.Is(err, server.ErrNotExists) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to delete")
+	}
+	return &pb.DeleteResponse{}, nil
+}
+
+// batchErrorsStatus returns InvalidArgument status of not applied list,
+// every failed item is "metrics[index]" field violation of details.
+func batchErrorsStatus(errs metrics.BatchErrors) error {
+	st := status.New(codes.InvalidArgument, "metrics list is not applied")
+	details := &errdetails.BadRequest{
+		FieldViolations: make([]*errdetails.BadRequest_FieldViolation, len(errs)),
+	}
+	for idx, e := range errs {
+		details.FieldViolations[idx] = &errdetails.BadRequest_FieldViolation{
+			Field:       fmt.Sprintf("metrics[%d]", e.Index),
+			Description: e.Err.Error(),
+		}
+	}
+	if withDetails, err := st.WithDetails(details); err == nil {
+		st = withDetails
+	}
+	return st.Err()
 }
@@ -0,0 +1,214 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecodeBatch(t *testing.T) {
+	ml := metrics.MetricsList{
+		{ID: "Alloc", MType: metrics.MTypeGauge, Value: 123.45},
+		{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+	}
+
+	var legacy bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&legacy).Encode(ml))
+
+	t.Run("Protobuf batch", func(t *testing.T) {
+		data, err := proto.Marshal(pb.NewMetricsBatch(ml))
+		require.NoError(t, err)
+
+		s := &serverAPI{}
+		actual, err := s.decodeBatch(&pb.BatchUpdateRequest{Batch: data})
+		require.NoError(t, err)
+		assert.Equal(t, ml, actual)
+	})
+
+	t.Run("Legacy gob enabled", func(t *testing.T) {
+		s := &serverAPI{legacyGob: true}
+		actual, err := s.decodeBatch(
+			&pb.BatchUpdateRequest{Metrics: legacy.Bytes()},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, ml, actual)
+	})
+
+	t.Run("Legacy gob disabled", func(t *testing.T) {
+		s := &serverAPI{}
+		_, err := s.decodeBatch(
+			&pb.BatchUpdateRequest{Metrics: legacy.Bytes()},
+		)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Malformed batch", func(t *testing.T) {
+		s := &serverAPI{}
+		_, err := s.decodeBatch(
+			&pb.BatchUpdateRequest{Batch: []byte{0xff, 0xff}},
+		)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// fakeStorage serves update, read, list and health check services.
+type fakeStorage struct {
+	gauge map[string]float64
+	down  bool
+}
+
+func (s *fakeStorage) Update(_ context.Context, m *metrics.Metrics) error {
+	s.gauge[metrics.MakeKey(m.ID, m.Labels)] = m.Value
+	return nil
+}
+
+func (s *fakeStorage) Read(_ context.Context, m *metrics.Metrics) error {
+	v, ok := s.gauge[metrics.MakeKey(m.ID, m.Labels)]
+	if !ok {
+		return server.ErrNotExists
+	}
+	m.Value = v
+	return nil
+}
+
+func (s *fakeStorage) List(
+	_ context.Context, filter metrics.Labels,
+) (metrics.MetricsList, error) {
+	var ml metrics.MetricsList
+	for k, v := range s.gauge {
+		id, labels, _ := metrics.ParseKey(k)
+		if labels.Match(filter) {
+			ml = append(ml, metrics.Metrics{
+				ID: id, MType: metrics.MTypeGauge, Value: v, Labels: labels,
+			})
+		}
+	}
+	return ml, nil
+}
+
+func (s *fakeStorage) Check(context.Context) error {
+	if s.down {
+		return errors.New("storage: down")
+	}
+	return nil
+}
+
+func TestServerAPI(t *testing.T) {
+	storage := &fakeStorage{gauge: make(map[string]float64)}
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	Register(s, RegisterServices{
+		IUpdateService:      storage,
+		IReadService:        storage,
+		IListService:        storage,
+		IHealthCheckService: storage,
+	})
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := pb.NewRunlyticsClient(conn)
+	health := healthpb.NewHealthClient(conn)
+	ctx := context.Background()
+
+	t.Run("Update and value", func(t *testing.T) {
+		m := metrics.Metrics{
+			ID:     "Alloc",
+			MType:  metrics.MTypeGauge,
+			Value:  1.5,
+			Labels: metrics.Labels{"host": "a"},
+		}
+		updated, err := client.Update(
+			ctx, &pb.UpdateRequest{Metric: pb.NewMetric(m)},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, m, updated.GetMetric().Metrics())
+
+		res, err := client.Value(ctx, &pb.ValueRequest{
+			Id: m.ID, Type: m.MType, Labels: m.Labels,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, m, res.GetMetric().Metrics())
+	})
+
+	t.Run("Invalid update", func(t *testing.T) {
+		_, err := client.Update(ctx, &pb.UpdateRequest{
+			Metric: &pb.Metric{Id: "Alloc", Type: "unknown"},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Value not found", func(t *testing.T) {
+		_, err := client.Value(
+			ctx, &pb.ValueRequest{Id: "Missing", Type: metrics.MTypeGauge},
+		)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("List", func(t *testing.T) {
+		res, err := client.List(
+			ctx, &pb.ListRequest{Labels: map[string]string{"host": "a"}},
+		)
+		require.NoError(t, err)
+		require.Len(t, res.GetMetrics(), 1)
+		assert.Equal(t, "Alloc", res.GetMetrics()[0].GetId())
+
+		res, err = client.List(
+			ctx, &pb.ListRequest{Labels: map[string]string{"host": "b"}},
+		)
+		require.NoError(t, err)
+		assert.Empty(t, res.GetMetrics())
+	})
+
+	t.Run("Ping and health", func(t *testing.T) {
+		_, err := client.Ping(ctx, &pb.PingRequest{})
+		require.NoError(t, err)
+
+		res, err := health.Check(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, res.GetStatus())
+
+		_, err = health.Check(
+			ctx, &healthpb.HealthCheckRequest{Service: "unknown"},
+		)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		storage.down = true
+		defer func() { storage.down = false }()
+
+		_, err = client.Ping(ctx, &pb.PingRequest{})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		res, err = health.Check(ctx, &healthpb.HealthCheckRequest{
+			Service: pb.Runlytics_ServiceDesc.ServiceName,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, res.GetStatus())
+	})
+}
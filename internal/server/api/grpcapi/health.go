@@ -0,0 +1,89 @@
+package grpcapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/di"
+	pb "github.com/niksmo/runlytics/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthWatchInterval is how often Watch checks the status.
+const healthWatchInterval = 5 * time.Second
+
+// healthServer is standard grpc.health.v1 service backed by health check
+// service. Overall server health "" and Runlytics service are known.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	service di.IHealthCheckService
+}
+
+func (h *healthServer) Check(
+	ctx context.Context, in *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	if !knownService(in.GetService()) {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: h.status(ctx)}, nil
+}
+
+func (h *healthServer) List(
+	ctx context.Context, in *healthpb.HealthListRequest,
+) (*healthpb.HealthListResponse, error) {
+	st := &healthpb.HealthCheckResponse{Status: h.status(ctx)}
+	return &healthpb.HealthListResponse{
+		Statuses: map[string]*healthpb.HealthCheckResponse{
+			"":                                   st,
+			pb.Runlytics_ServiceDesc.ServiceName: st,
+		},
+	}, nil
+}
+
+// Watch sends the status on start and on every change until the stream
+// is done. Unknown service is watched as SERVICE_UNKNOWN.
+func (h *healthServer) Watch(
+	in *healthpb.HealthCheckRequest,
+	stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse],
+) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		st := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		if knownService(in.GetService()) {
+			st = h.status(ctx)
+		}
+		if st != last {
+			err := stream.Send(&healthpb.HealthCheckResponse{Status: st})
+			if err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *healthServer) status(
+	ctx context.Context,
+) healthpb.HealthCheckResponse_ServingStatus {
+	if err := h.service.Check(ctx); err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+func knownService(name string) bool {
+	return name == "" || name == pb.Runlytics_ServiceDesc.ServiceName
+}
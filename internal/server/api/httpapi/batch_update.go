@@ -1,15 +1,27 @@
 package httpapi
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/internal/server"
 	"github.com/niksmo/runlytics/internal/server/app/http/middleware"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
+	"go.uber.org/zap"
 )
 
+// batchVerifyOps are checks of every metrics list item.
+var batchVerifyOps = []metrics.VerifyOp{
+	metrics.VerifyID,
+	metrics.VerifyType,
+	metrics.VerifyLabels,
+	metrics.VerifyHistogram,
+}
+
 // BatchUpdateHandler working with service and provides BatchUpdate method.
 type BatchUpdateHandler struct {
 	service di.IBatchUpdateService
@@ -17,41 +29,146 @@ type BatchUpdateHandler struct {
 
 // SetBatchUpdateHandler sets BatchUpdate handler to "/updates" path.
 //
-// Allows only JSON media type.
-func SetBatchUpdateHandler(mux *chi.Mux, service di.IBatchUpdateService) {
+// Allows only JSON media type. Guards, e.g. signature check,
+// are applied to batch requests only.
+func SetBatchUpdateHandler(
+	mux chi.Router,
+	service di.IBatchUpdateService,
+	guards ...func(http.Handler) http.Handler,
+) {
 	path := "/updates"
 	handler := &BatchUpdateHandler{service}
 	mux.Route(path, func(r chi.Router) {
 		batchUpdate := "/"
-		r.With(middleware.AllowJSON).Post(batchUpdate, handler.BatchUpdate())
+		r.With(middleware.AllowJSON).
+			With(guards...).
+			Post(batchUpdate, handler.BatchUpdate())
 		debugLogRegister(path + batchUpdate)
 	})
 }
 
 // BatchUpdate reads metrics list from request for update.
+//
+// The list is applied atomically. If some items are invalid
+// or can't be applied, nothing is applied and every failed item
+// is returned in JSON body with 400 status code.
+//
+// Batch identified by [XAgentID] and [XBatchSeq] headers is applied once,
+// duplicate is acknowledged with [XBatchDuplicate] header.
+//
+// With [PartialQuery] set to "true" only valid items are applied
+// and outcome of every item is returned in JSON body with 200 status code,
+// see [metrics.BatchResult].
 func (h *BatchUpdateHandler) BatchUpdate() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var ml metrics.MetricsList
-		if err := ReadJSONRequest(r, &ml); err != nil {
+		id, err := metrics.ParseBatchID(
+			r.Header.Get(XAgentID), r.Header.Get(XBatchSeq),
+		)
+		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		err := ml.Verify(
-			metrics.VerifyID,
-			metrics.VerifyType,
-		)
+		partial, err := parsePartial(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		err = h.service.BatchUpdate(r.Context(), ml)
+		var ml metrics.MetricsList
+		if err := ReadJSONRequest(r, &ml); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if partial {
+			h.batchUpdatePartial(w, r, id, ml)
+			return
+		}
+
+		var batchErrs metrics.BatchErrors
+		err = ml.Verify(batchVerifyOps...)
+		if errors.As(err, &batchErrs) {
+			writeBatchErrors(w, batchErrs)
+			return
+		}
+
+		duplicate, err := h.service.BatchUpdateOnce(r.Context(), id, ml)
+		if errors.As(err, &batchErrs) {
+			writeBatchErrors(w, batchErrs)
+			return
+		}
 		if err != nil {
 			http.Error(w, server.ErrInternal.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if duplicate {
+			w.Header().Set(XBatchDuplicate, "true")
+		}
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func (h *BatchUpdateHandler) batchUpdatePartial(
+	w http.ResponseWriter,
+	r *http.Request,
+	id metrics.BatchID,
+	ml metrics.MetricsList,
+) {
+	res, err := h.service.BatchUpdatePartial(
+		r.Context(), id, ml, batchVerifyOps...,
+	)
+	if err != nil {
+		http.Error(w, server.ErrInternal.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if res.Duplicate {
+		w.Header().Set(XBatchDuplicate, "true")
+	}
+	if err = WriteJSONResponse(w, http.StatusOK, res); err != nil {
+		logger.Log.Error("error on write response", zap.Error(err))
+	}
+}
+
+// parsePartial returns [PartialQuery] value, false if not set.
+func parsePartial(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get(PartialQuery)
+	if raw == "" {
+		return false, nil
+	}
+	partial, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("'partial': boolean required")
+	}
+	return partial, nil
+}
+
+// batchErrorsResponse is response scheme of not applied list.
+type batchErrorsResponse struct {
+	Error string              `json:"error"`
+	Items []itemErrorResponse `json:"items"`
+}
+
+type itemErrorResponse struct {
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	MType string `json:"type"`
+	Error string `json:"error"`
+}
+
+func writeBatchErrors(w http.ResponseWriter, errs metrics.BatchErrors) {
+	res := batchErrorsResponse{
+		Error: "metrics list is not applied",
+		Items: make([]itemErrorResponse, len(errs)),
+	}
+	for idx, e := range errs {
+		res.Items[idx] = itemErrorResponse{
+			Index: e.Index, ID: e.ID, MType: e.MType, Error: e.Err.Error(),
+		}
+	}
+	if err := WriteJSONResponse(w, http.StatusBadRequest, res); err != nil {
+		logger.Log.Error("error on write response", zap.Error(err))
+	}
+}
@@ -26,6 +26,23 @@ func (s *ExampleBatchUpdateService) BatchUpdate(
 	return retArgs.Error(0)
 }
 
+func (s *ExampleBatchUpdateService) BatchUpdateOnce(
+	ctx context.Context, id metrics.BatchID, ml metrics.MetricsList,
+) (bool, error) {
+	retArgs := s.Called(context.Background(), id, ml)
+	return retArgs.Bool(0), retArgs.Error(1)
+}
+
+func (s *ExampleBatchUpdateService) BatchUpdatePartial(
+	ctx context.Context,
+	id metrics.BatchID,
+	ml metrics.MetricsList,
+	ops ...metrics.VerifyOp,
+) (metrics.BatchResult, error) {
+	retArgs := s.Called(context.Background(), id, ml)
+	return retArgs.Get(0).(metrics.BatchResult), retArgs.Error(1)
+}
+
 func ExampleSetBatchUpdateHandler() {
 	m0value := 123.45
 	m1delta := int64(12345)
@@ -37,8 +54,8 @@ func ExampleSetBatchUpdateHandler() {
 
 	batchUpdateService := new(ExampleBatchUpdateService)
 	batchUpdateService.On(
-		"BatchUpdate", context.Background(), metricsList,
-	).Return(nil)
+		"BatchUpdateOnce", context.Background(), metrics.BatchID{}, metricsList,
+	).Return(false, nil)
 
 	mux := chi.NewRouter()
 	httpapi.SetBatchUpdateHandler(mux, batchUpdateService)
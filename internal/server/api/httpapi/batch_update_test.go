@@ -0,0 +1,202 @@
+package httpapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchUpdateHandlerBatchID(t *testing.T) {
+	ml := metrics.MetricsList{
+		{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+	}
+
+	doUpdate := func(t *testing.T, url, agentID, seq string) *http.Response {
+		body, err := json.Marshal(ml)
+		require.NoError(t, err)
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodPost, url+"/updates/",
+			bytes.NewReader(body),
+		)
+		require.NoError(t, err)
+		req.Header.Set(httpapi.ContentType, httpapi.JSON)
+		req.Header.Set(httpapi.XAgentID, agentID)
+		req.Header.Set(httpapi.XBatchSeq, seq)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	newServer := func(service *ExampleBatchUpdateService) *httptest.Server {
+		mux := chi.NewRouter()
+		httpapi.SetBatchUpdateHandler(mux, service)
+		return httptest.NewServer(mux)
+	}
+
+	t.Run("Should acknowledge duplicate", func(t *testing.T) {
+		id := metrics.BatchID{AgentID: "host-a", Seq: 7}
+		service := new(ExampleBatchUpdateService)
+		service.On("BatchUpdateOnce", context.Background(), id, ml).
+			Return(false, nil).Once()
+		service.On("BatchUpdateOnce", context.Background(), id, ml).
+			Return(true, nil).Once()
+		s := newServer(service)
+		defer s.Close()
+
+		res := doUpdate(t, s.URL, "host-a", "7")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Empty(t, res.Header.Get(httpapi.XBatchDuplicate))
+
+		res = doUpdate(t, s.URL, "host-a", "7")
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "true", res.Header.Get(httpapi.XBatchDuplicate))
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Should not call service on bad sequence", func(t *testing.T) {
+		service := new(ExampleBatchUpdateService)
+		s := newServer(service)
+		defer s.Close()
+
+		res := doUpdate(t, s.URL, "host-a", "-1")
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		service.AssertNotCalled(t, "BatchUpdateOnce")
+	})
+
+	t.Run("Should return failed items", func(t *testing.T) {
+		service := new(ExampleBatchUpdateService)
+		service.On("BatchUpdateOnce", context.Background(), metrics.BatchID{}, ml).
+			Return(false, metrics.BatchErrors{
+				metrics.NewItemError(0, ml[0], metrics.ErrHistogramMismatch),
+			})
+		s := newServer(service)
+		defer s.Close()
+
+		body, err := json.Marshal(ml)
+		require.NoError(t, err)
+		res, err := http.Post(
+			s.URL+"/updates/", httpapi.JSON, bytes.NewReader(body),
+		)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		var resBody struct {
+			Items []struct {
+				Index int    `json:"index"`
+				ID    string `json:"id"`
+				Error string `json:"error"`
+			} `json:"items"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&resBody))
+		require.Len(t, resBody.Items, 1)
+		assert.Equal(t, "PollCount", resBody.Items[0].ID)
+		assert.Equal(t, metrics.ErrHistogramMismatch.Error(), resBody.Items[0].Error)
+	})
+
+	t.Run("Should return invalid items without update", func(t *testing.T) {
+		service := new(ExampleBatchUpdateService)
+		s := newServer(service)
+		defer s.Close()
+
+		body := `[{"id":"Alloc","type":"gauge","value":1},{"id":"","type":"gauge"}]`
+		res, err := http.Post(
+			s.URL+"/updates/", httpapi.JSON, bytes.NewReader([]byte(body)),
+		)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		var resBody struct {
+			Items []struct {
+				Index int `json:"index"`
+			} `json:"items"`
+		}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&resBody))
+		require.Len(t, resBody.Items, 1)
+		assert.Equal(t, 1, resBody.Items[0].Index)
+		service.AssertNotCalled(t, "BatchUpdateOnce")
+	})
+}
+
+func TestBatchUpdateHandlerPartial(t *testing.T) {
+	ml := metrics.MetricsList{
+		{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1},
+		{ID: "", MType: metrics.MTypeGauge},
+	}
+
+	doUpdate := func(t *testing.T, url, query string) *http.Response {
+		body, err := json.Marshal(ml)
+		require.NoError(t, err)
+		res, err := http.Post(
+			url+"/updates/"+query, httpapi.JSON, bytes.NewReader(body),
+		)
+		require.NoError(t, err)
+		return res
+	}
+
+	newServer := func(service *ExampleBatchUpdateService) *httptest.Server {
+		mux := chi.NewRouter()
+		httpapi.SetBatchUpdateHandler(mux, service)
+		return httptest.NewServer(mux)
+	}
+
+	t.Run("Should return every item result", func(t *testing.T) {
+		expected := metrics.BatchResult{
+			Applied: 1,
+			Items: []metrics.ItemResult{
+				{Index: 0, Status: metrics.ItemApplied},
+				{Index: 1, Status: metrics.ItemInvalid, Error: "'id': required"},
+			},
+		}
+		service := new(ExampleBatchUpdateService)
+		service.On("BatchUpdatePartial", context.Background(), metrics.BatchID{}, ml).
+			Return(expected, nil)
+		s := newServer(service)
+		defer s.Close()
+
+		res := doUpdate(t, s.URL, "?partial=true")
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, httpapi.JSON, res.Header.Get(httpapi.ContentType))
+
+		var actual metrics.BatchResult
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&actual))
+		assert.Equal(t, expected, actual)
+		service.AssertExpectations(t)
+	})
+
+	t.Run("Should return internal error", func(t *testing.T) {
+		service := new(ExampleBatchUpdateService)
+		service.On("BatchUpdatePartial", context.Background(), metrics.BatchID{}, ml).
+			Return(metrics.BatchResult{}, errors.New("storage error"))
+		s := newServer(service)
+		defer s.Close()
+
+		res := doUpdate(t, s.URL, "?partial=true")
+		res.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	})
+
+	t.Run("Should not call service on bad partial", func(t *testing.T) {
+		service := new(ExampleBatchUpdateService)
+		s := newServer(service)
+		defer s.Close()
+
+		res := doUpdate(t, s.URL, "?partial=maybe")
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+		service.AssertNotCalled(t, "BatchUpdatePartial")
+	})
+}
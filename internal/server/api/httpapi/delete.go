@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// DeleteHandler works with service and provides DeleteByURLParams method.
+type DeleteHandler struct {
+	service di.IDeleteService
+}
+
+// SetDeleteHandler sets DeleteHandler to "/value/{type}/{name}" path
+// with DELETE method, labels are passed by query.
+//
+// Guards restrict callers, e.g. to trusted network.
+func SetDeleteHandler(
+	mux chi.Router,
+	service di.IDeleteService,
+	guards ...func(http.Handler) http.Handler,
+) {
+	path := "/value/{type}/{name}"
+	handler := &DeleteHandler{service}
+	mux.With(guards...).Delete(path, handler.DeleteByURLParams())
+	debugLogRegister(path)
+}
+
+// DeleteByURLParams deletes metrics by URL params and labels query.
+func (h *DeleteHandler) DeleteByURLParams() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m := metrics.Metrics{
+			ID:    chi.URLParam(r, "name"),
+			MType: chi.URLParam(r, "type"),
+		}
+		labels, err := ReadLabelsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.Labels = labels
+
+		err = m.Verify(metrics.VerifyID, metrics.VerifyType, metrics.VerifyLabels)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err = h.service.Delete(r.Context(), &m)
+		if errors.Is(err, server.ErrNotExists) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(
+				w, server.ErrInternal.Error(), http.StatusInternalServerError,
+			)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,146 @@
+package httpapi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/internal/server/app/http/middleware"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDeleteService struct {
+	mock.Mock
+}
+
+func (service *MockDeleteService) Delete(
+	ctx context.Context, m *metrics.Metrics,
+) error {
+	retArgs := service.Called(context.Background(), m)
+	return retArgs.Error(0)
+}
+
+func TestDeleteHandler(t *testing.T) {
+	trustedNet, err := netacl.ParseACL("192.168.1.0/24,fd00::/8")
+	require.NoError(t, err)
+	proxies, err := netacl.ParseACL("127.0.0.1,::1")
+	require.NoError(t, err)
+
+	newServer := func(
+		service *MockDeleteService, acl, proxies *netacl.ACL,
+	) *httptest.Server {
+		mux := chi.NewRouter()
+		mux.Use(middleware.RealIP(proxies))
+		guard := middleware.TrustedNet(netacl.Policy{Default: acl})
+		httpapi.SetDeleteHandler(mux, service, guard(netacl.RouteAdmin))
+		return httptest.NewServer(mux)
+	}
+
+	doDelete := func(t *testing.T, url, ip string) int {
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodDelete, url, nil,
+		)
+		require.NoError(t, err)
+		req.Header.Set(httpapi.XRealIP, ip)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	t.Run("Should delete from trusted subnet", func(t *testing.T) {
+		m := &metrics.Metrics{
+			ID:     "Alloc",
+			MType:  metrics.MTypeGauge,
+			Labels: metrics.Labels{"host": "a"},
+		}
+		mockService := new(MockDeleteService)
+		mockService.On("Delete", context.Background(), m).Return(nil)
+		s := newServer(mockService, trustedNet, proxies)
+		defer s.Close()
+
+		code := doDelete(t, s.URL+"/value/gauge/Alloc?labels=host=a", "192.168.1.10")
+		assert.Equal(t, http.StatusOK, code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Should forbid not trusted caller", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			trustedNet *netacl.ACL
+			proxies    *netacl.ACL
+			ip         string
+		}{
+			{name: "Outside subnet", trustedNet: trustedNet, proxies: proxies, ip: "10.0.0.1"},
+			{name: "Outside IPv6 subnet", trustedNet: trustedNet, proxies: proxies, ip: "fe80::1"},
+			{name: "Subnet is not set", trustedNet: new(netacl.ACL), proxies: proxies, ip: "192.168.1.10"},
+			{name: "Not trusted proxy", trustedNet: trustedNet, proxies: nil, ip: "192.168.1.10"},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				mockService := new(MockDeleteService)
+				s := newServer(mockService, test.trustedNet, test.proxies)
+				defer s.Close()
+
+				code := doDelete(t, s.URL+"/value/gauge/Alloc", test.ip)
+				assert.Equal(t, http.StatusForbidden, code)
+				mockService.AssertNotCalled(t, "Delete")
+			})
+		}
+	})
+
+	t.Run("Should return service errors", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			err        error
+			statusCode int
+		}{
+			{
+				name:       "Not exists",
+				err:        fmt.Errorf("metric 'Alloc' is %w", server.ErrNotExists),
+				statusCode: http.StatusNotFound,
+			},
+			{
+				name:       "Internal",
+				err:        server.ErrInternal,
+				statusCode: http.StatusInternalServerError,
+			},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				mockService := new(MockDeleteService)
+				mockService.On("Delete", context.Background(), mock.Anything).
+					Return(test.err)
+				s := newServer(mockService, trustedNet, proxies)
+				defer s.Close()
+
+				code := doDelete(t, s.URL+"/value/gauge/Alloc", "192.168.1.10")
+				assert.Equal(t, test.statusCode, code)
+			})
+		}
+	})
+
+	t.Run("Should not call service on bad request", func(t *testing.T) {
+		mockService := new(MockDeleteService)
+		s := newServer(mockService, trustedNet, proxies)
+		defer s.Close()
+
+		for _, path := range []string{
+			"/value/unknown/Alloc",
+			"/value/gauge/Alloc?labels=bad",
+		} {
+			code := doDelete(t, s.URL+path, "192.168.1.10")
+			assert.Equal(t, http.StatusBadRequest, code, path)
+		}
+		mockService.AssertNotCalled(t, "Delete")
+	})
+}
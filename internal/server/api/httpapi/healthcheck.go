@@ -13,7 +13,7 @@ type HealthCheckHandler struct {
 }
 
 // SetHealthCheckHandler sets Ping handler to "/ping" path.
-func SetHealthCheckHandler(mux *chi.Mux, service di.IHealthCheckService) {
+func SetHealthCheckHandler(mux chi.Router, service di.IHealthCheckService) {
 	path := "/ping"
 	handler := &HealthCheckHandler{service}
 	mux.Get(path, handler.Ping())
@@ -14,7 +14,7 @@ type HTMLHandler struct {
 }
 
 // SetHTMLHandler sets MetricsHTML handler to "/" path.
-func SetHTMLHandler(mux *chi.Mux, service di.IHTMLService) {
+func SetHTMLHandler(mux chi.Router, service di.IHTMLService) {
 	path := "/"
 	handler := &HTMLHandler{service}
 	mux.Route(path, func(r chi.Router) {
@@ -24,10 +24,18 @@ func SetHTMLHandler(mux *chi.Mux, service di.IHTMLService) {
 }
 
 // MetricsHTML renders metrics list.
+//
+// Metrics could be filtered by labels query, e.g. "/?labels=host=a".
 func (handler *HTMLHandler) MetricsHTML() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := ReadLabelsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		var buf bytes.Buffer
-		err := handler.service.RenderMetricsList(r.Context(), &buf)
+		err = handler.service.RenderMetricsList(r.Context(), filter, &buf)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
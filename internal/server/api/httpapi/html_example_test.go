@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/pkg/metrics"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -21,8 +22,10 @@ type ExampleHTMLService struct {
 }
 
 // RenderMetricsList writes html page to buffer.
-func (s *ExampleHTMLService) RenderMetricsList(ctx context.Context, buf *bytes.Buffer) error {
-	getArgs := s.Called(context.Background(), buf)
+func (s *ExampleHTMLService) RenderMetricsList(
+	ctx context.Context, filter metrics.Labels, buf *bytes.Buffer,
+) error {
+	getArgs := s.Called(context.Background(), filter, buf)
 	buf.WriteString(`<!DOCTYPE html>
 <html lang="en">
 <head>
@@ -41,7 +44,10 @@ func (s *ExampleHTMLService) RenderMetricsList(ctx context.Context, buf *bytes.B
 func ExampleSetHTMLHandler() {
 	buf := new(bytes.Buffer)
 	HTMLService := new(ExampleHTMLService)
-	HTMLService.On("RenderMetricsList", context.Background(), buf).Return(nil)
+	var filter metrics.Labels
+	HTMLService.On(
+		"RenderMetricsList", context.Background(), filter, buf,
+	).Return(nil)
 
 	mux := chi.NewRouter()
 	httpapi.SetHTMLHandler(mux, HTMLService)
@@ -12,20 +12,25 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/niksmo/runlytics/internal/server"
 	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/pkg/metrics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 type mockHTMLService struct {
-	err  error
-	data string
+	err    error
+	data   string
+	filter metrics.Labels
 }
 
-func (service *mockHTMLService) RenderMetricsList(ctx context.Context, buf *bytes.Buffer) error {
+func (service *mockHTMLService) RenderMetricsList(
+	ctx context.Context, filter metrics.Labels, buf *bytes.Buffer,
+) error {
 	if service.err != nil {
 		return service.err
 	}
 
+	service.filter = filter
 	buf.WriteString(service.data)
 	return nil
 }
@@ -85,6 +90,42 @@ func TestHTMLHandler(t *testing.T) {
 		assert.Equal(t, expectedData, string(data))
 	})
 
+	t.Run("Should pass labels filter", func(t *testing.T) {
+		service := &mockHTMLService{data: "test"}
+		mux := chi.NewRouter()
+		httpapi.SetHTMLHandler(mux, service)
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequest(
+			http.MethodGet, makeURL(s.URL)+"?labels=host=a,env=prod", http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, metrics.Labels{"host": "a", "env": "prod"}, service.filter)
+	})
+
+	t.Run("Should return bad request on invalid labels", func(t *testing.T) {
+		mux := chi.NewRouter()
+		httpapi.SetHTMLHandler(mux, &mockHTMLService{data: "test"})
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequest(
+			http.MethodGet, makeURL(s.URL)+"?labels=host", http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
 	t.Run("Should return internal error", func(t *testing.T) {
 		mux := chi.NewRouter()
 		httpapi.SetHTMLHandler(mux, &mockHTMLService{err: server.ErrInternal, data: ""})
@@ -3,12 +3,19 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/token"
 	"go.uber.org/zap"
 )
 
@@ -19,6 +26,10 @@ const (
 	AcceptEncoding  = "Accept-Encoding"
 
 	XRealIP = "X-Real-IP"
+
+	XAgentID        = "X-Agent-ID"        // batch agent id, see [metrics.BatchID]
+	XBatchSeq       = "X-Batch-Seq"       // batch sequence, decimal
+	XBatchDuplicate = "X-Batch-Duplicate" // "true" if batch is already applied
 )
 
 // Content types
@@ -28,20 +39,78 @@ const (
 	TEXT = "text/plain"
 )
 
+// Query params
+const (
+	LabelsQuery    = "labels"    // e.g. "?labels=env=prod,host=a"
+	QuantilesQuery = "quantiles" // e.g. "?quantiles=0.5,0.99", only for histogram
+	FromQuery      = "from"      // RFC 3339 time or unix seconds
+	ToQuery        = "to"        // RFC 3339 time or unix seconds
+	StepQuery      = "step"      // duration, e.g. "30s", "5m", or seconds
+	FuncQuery      = "func"      // e.g. "avg", see [metrics.AggAvg]
+	PartialQuery   = "partial"   // "true" applies valid items of batch only
+)
+
+var ErrQuantilesType = errors.New("quantiles are allowed only for histogram type")
+
 type RegisterServices struct {
 	di.IHTMLService
 	di.IUpdateService
 	di.IBatchUpdateService
 	di.IReadService
+	di.IDeleteService
 	di.IHealthCheckService
+	di.IRangeService
+
+	// TrustedNet returns middleware allowing callers of route class
+	// network, see [netacl.Policy].
+	TrustedNet func(route string) func(http.Handler) http.Handler
+
+	// Signed is middleware verifying batch signatures.
+	Signed func(http.Handler) http.Handler
+
+	// Unsigned is middleware of write routes without signature,
+	// it rejects requests while signatures are required.
+	Unsigned func(http.Handler) http.Handler
+
+	// Replay is middleware rejecting replayed write requests, read
+	// requests are not stamped. It runs after hash and signature checks,
+	// so nonces of unauthenticated requests are not remembered.
+	Replay func(http.Handler) http.Handler
+
+	// UpdateLimit and BatchLimit are middlewares limiting requests
+	// of "/update" and "/updates" paths.
+	UpdateLimit func(http.Handler) http.Handler
+	BatchLimit  func(http.Handler) http.Handler
+
+	// Authorize returns middleware allowing callers of token scope.
+	// Read handlers require read scope, update handlers write scope
+	// and delete handler admin scope, ping is not authorized.
+	Authorize func(token.Scope) func(http.Handler) http.Handler
 }
 
 func Register(mux *chi.Mux, s RegisterServices) {
-	SetHTMLHandler(mux, s.IHTMLService)
-	SetUpdateHandler(mux, s.IUpdateService)
-	SetBatchUpdateHandler(mux, s.IBatchUpdateService)
-	SetValueHandler(mux, s.IReadService)
-	SetHealthCheckHandler(mux, s.IHealthCheckService)
+	ping := mux.With(s.TrustedNet(netacl.RoutePing))
+	read := mux.With(
+		s.TrustedNet(netacl.RouteRead), s.Authorize(token.ScopeRead),
+	)
+	write := mux.With(
+		s.TrustedNet(netacl.RouteWrite), s.Authorize(token.ScopeWrite),
+	)
+	admin := mux.With(
+		s.TrustedNet(netacl.RouteAdmin), s.Authorize(token.ScopeAdmin),
+	)
+
+	SetHTMLHandler(read, s.IHTMLService)
+	SetUpdateHandler(
+		write, s.IUpdateService, s.UpdateLimit, s.Unsigned, s.Replay,
+	)
+	SetBatchUpdateHandler(
+		write, s.IBatchUpdateService, s.BatchLimit, s.Signed, s.Replay,
+	)
+	SetValueHandler(read, s.IReadService)
+	SetDeleteHandler(admin, s.IDeleteService)
+	SetHealthCheckHandler(ping, s.IHealthCheckService)
+	SetRangeHandler(read, s.IRangeService)
 }
 
 // ReadRequest decode request body from JSON objects.
@@ -62,6 +131,80 @@ func WriteJSONResponse(w http.ResponseWriter, statusCode int, scheme any) error
 	return nil
 }
 
+// ReadLabelsQuery parses labels from request query.
+func ReadLabelsQuery(r *http.Request) (metrics.Labels, error) {
+	labels, err := metrics.ParseLabels(r.URL.Query().Get(LabelsQuery))
+	if err != nil {
+		return nil, fmt.Errorf("parse labels query error: %w", err)
+	}
+	return labels, nil
+}
+
+// ReadQuantilesQuery parses comma separated quantiles from request query.
+func ReadQuantilesQuery(r *http.Request) ([]float64, error) {
+	raw := r.URL.Query().Get(QuantilesQuery)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var quantiles []float64
+	for _, item := range strings.Split(raw, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(item), 64)
+		if err != nil || q < 0 || q > 1 {
+			return nil, fmt.Errorf(
+				"parse quantiles query error: %w", metrics.ErrInvalidQuantile,
+			)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, nil
+}
+
+// ReadRangeQuery parses range query params from request query.
+// Omitted params are left zero, see [metrics.RangeQuery.SetDefaults].
+func ReadRangeQuery(r *http.Request) (metrics.RangeQuery, error) {
+	var (
+		q   metrics.RangeQuery
+		err error
+	)
+	query := r.URL.Query()
+
+	if q.Labels, err = ReadLabelsQuery(r); err != nil {
+		return q, err
+	}
+	if q.From, err = parseTimeQuery(query.Get(FromQuery)); err != nil {
+		return q, fmt.Errorf("parse from query error: %w", err)
+	}
+	if q.To, err = parseTimeQuery(query.Get(ToQuery)); err != nil {
+		return q, fmt.Errorf("parse to query error: %w", err)
+	}
+	if q.Step, err = parseDurationQuery(query.Get(StepQuery)); err != nil {
+		return q, fmt.Errorf("parse step query error: %w", err)
+	}
+	q.Func = query.Get(FuncQuery)
+	return q, nil
+}
+
+func parseTimeQuery(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func parseDurationQuery(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	if sec, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+	return time.ParseDuration(raw)
+}
+
 func debugLogRegister(endpoint string) {
 	logger.Log.Debug("Register", zap.String("endpoint", endpoint))
 }
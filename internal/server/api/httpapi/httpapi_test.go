@@ -0,0 +1,370 @@
+package httpapi_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/internal/server/app/http/middleware"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"github.com/niksmo/runlytics/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterAuthorize(t *testing.T) {
+	logger.Init("fatal")
+
+	tokens := make(map[token.Scope]string)
+	var stored []token.Token
+	for _, scope := range []token.Scope{
+		token.ScopeRead, token.ScopeWrite, token.ScopeAdmin,
+	} {
+		raw, tk, err := token.New(string(scope), token.Scopes{scope})
+		require.NoError(t, err)
+		tokens[scope] = raw
+		stored = append(stored, tk)
+	}
+	revoked, tk, err := token.New("revoked", token.Scopes{token.ScopeAdmin})
+	require.NoError(t, err)
+	tk.Revoked = true
+	registry, err := token.NewRegistry(append(stored, tk))
+	require.NoError(t, err)
+
+	healthCheckService := &ExampleHealthCheckService{}
+	healthCheckService.On("Check", mock.Anything).Return(nil)
+	updateService := &UpdateByURLService{}
+	updateService.On("Update", mock.Anything, mock.Anything).Return(nil)
+	deleteService := &MockDeleteService{}
+	deleteService.On("Delete", mock.Anything, mock.Anything).Return(nil)
+
+	mux := chi.NewRouter()
+	httpapi.Register(mux, httpapi.RegisterServices{
+		IHTMLService:        &mockHTMLService{data: "metrics"},
+		IUpdateService:      updateService,
+		IDeleteService:      deleteService,
+		IHealthCheckService: healthCheckService,
+		TrustedNet:          middleware.TrustedNet(netacl.Policy{}),
+		Signed:              func(next http.Handler) http.Handler { return next },
+		Unsigned:            middleware.RejectUnsigned(nil),
+		Replay:              middleware.RejectReplay(nil),
+		UpdateLimit:         middleware.RateLimit(nil),
+		BatchLimit:          middleware.RateLimit(nil),
+		Authorize:           middleware.Authorize(registry),
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	do := func(t *testing.T, method, path, raw string) int {
+		req, err := http.NewRequestWithContext(
+			context.Background(), method, s.URL+path, strings.NewReader(""),
+		)
+		require.NoError(t, err)
+		if raw != "" {
+			req.Header.Set(middleware.Authorization, "Bearer "+raw)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	const (
+		read   = http.MethodGet + " /"
+		update = http.MethodPost + " /update/gauge/Alloc/1.5"
+		remove = http.MethodDelete + " /value/gauge/Alloc"
+	)
+
+	tests := []struct {
+		name     string
+		route    string
+		raw      string
+		wantCode int
+	}{
+		{"Should allow ping without token", "GET /ping", "", http.StatusOK},
+		{"Should require token", read, "", http.StatusUnauthorized},
+		{"Should reject unknown token", read, "other.secret", http.StatusUnauthorized},
+		{"Should reject changed secret", read, tokens[token.ScopeRead] + "x", http.StatusUnauthorized},
+		{"Should reject revoked token", read, revoked, http.StatusUnauthorized},
+		{"Should allow read scope", read, tokens[token.ScopeRead], http.StatusOK},
+		{"Should deny read by write scope", read, tokens[token.ScopeWrite], http.StatusForbidden},
+		{"Should deny update by read scope", update, tokens[token.ScopeRead], http.StatusForbidden},
+		{"Should allow write scope", update, tokens[token.ScopeWrite], http.StatusOK},
+		{"Should deny delete by write scope", remove, tokens[token.ScopeWrite], http.StatusForbidden},
+		{"Should allow admin scope", remove, tokens[token.ScopeAdmin], http.StatusOK},
+		{"Should allow read by admin scope", read, tokens[token.ScopeAdmin], http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method, path, _ := strings.Cut(test.route, " ")
+			assert.Equal(t, test.wantCode, do(t, method, path, test.raw))
+		})
+	}
+}
+
+func TestRegisterTrustedNet(t *testing.T) {
+	logger.Init("fatal")
+
+	trusted, err := netacl.ParseACL("192.168.1.0/24")
+	require.NoError(t, err)
+	proxies, err := netacl.ParseACL("127.0.0.1")
+	require.NoError(t, err)
+	routes, err := netacl.ParseRoutes("read=any")
+	require.NoError(t, err)
+
+	healthCheckService := &ExampleHealthCheckService{}
+	healthCheckService.On("Check", mock.Anything).Return(nil)
+	updateService := &UpdateByURLService{}
+	updateService.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	mux := chi.NewRouter()
+	mux.Use(middleware.RealIP(proxies))
+	httpapi.Register(mux, httpapi.RegisterServices{
+		IHTMLService:        &mockHTMLService{data: "metrics"},
+		IUpdateService:      updateService,
+		IHealthCheckService: healthCheckService,
+		TrustedNet: middleware.TrustedNet(
+			netacl.Policy{Default: trusted, Routes: routes},
+		),
+		Signed:      func(next http.Handler) http.Handler { return next },
+		Unsigned:    middleware.RejectUnsigned(nil),
+		Replay:      middleware.RejectReplay(nil),
+		UpdateLimit: middleware.RateLimit(nil),
+		BatchLimit:  middleware.RateLimit(nil),
+		Authorize:   middleware.Authorize(nil),
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	tests := []struct {
+		name     string
+		route    string
+		ip       string
+		wantCode int
+	}{
+		{"Should allow read from any address", "GET /", "10.0.0.1", http.StatusOK},
+		{"Should forbid write outside subnet", "POST /update/gauge/Alloc/1.5", "10.0.0.1", http.StatusForbidden},
+		{"Should allow write from subnet", "POST /update/gauge/Alloc/1.5", "192.168.1.5", http.StatusOK},
+		{"Should forbid ping outside subnet", "GET /ping", "10.0.0.1", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method, path, _ := strings.Cut(test.route, " ")
+			req, err := http.NewRequestWithContext(
+				context.Background(), method, s.URL+path, http.NoBody,
+			)
+			require.NoError(t, err)
+			req.Header.Set(middleware.XRealIP, test.ip)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, test.wantCode, res.StatusCode)
+		})
+	}
+}
+
+func TestRegisterRateLimit(t *testing.T) {
+	logger.Init("fatal")
+
+	tokenA, tkA, err := token.New("host-a", token.Scopes{token.ScopeWrite})
+	require.NoError(t, err)
+	tokenB, tkB, err := token.New("host-b", token.Scopes{token.ScopeWrite})
+	require.NoError(t, err)
+	registry, err := token.NewRegistry([]token.Token{tkA, tkB})
+	require.NoError(t, err)
+
+	updateService := &UpdateByURLService{}
+	updateService.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	mux := chi.NewRouter()
+	httpapi.Register(mux, httpapi.RegisterServices{
+		IUpdateService: updateService,
+		TrustedNet:     middleware.TrustedNet(netacl.Policy{}),
+		Signed:         func(next http.Handler) http.Handler { return next },
+		Unsigned:       middleware.RejectUnsigned(nil),
+		Replay:         middleware.RejectReplay(nil),
+		UpdateLimit: middleware.RateLimit(
+			ratelimit.New(ratelimit.Limit{Rate: 0.1, Burst: 1}, 0),
+		),
+		BatchLimit: middleware.RateLimit(nil),
+		Authorize:  middleware.Authorize(registry),
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	update := func(t *testing.T, raw, agentID string) *http.Response {
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			s.URL+"/update/gauge/Alloc/1.5",
+			http.NoBody,
+		)
+		require.NoError(t, err)
+		req.Header.Set(middleware.Authorization, "Bearer "+raw)
+		req.Header.Set(middleware.XAgentID, agentID)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+
+	res := update(t, tokenA, "host-a")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = update(t, tokenA, "host-a")
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, "10", res.Header.Get(middleware.RetryAfter))
+
+	res = update(t, tokenA, "other")
+	assert.Equal(
+		t, http.StatusTooManyRequests, res.StatusCode,
+		"agent id header does not change the client",
+	)
+
+	res = update(t, tokenB, "host-a")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "other token has own limit")
+}
+
+func TestRegisterReplay(t *testing.T) {
+	logger.Init("fatal")
+
+	batchUpdateService := &ExampleBatchUpdateService{}
+	batchUpdateService.On(
+		"BatchUpdateOnce", mock.Anything, mock.Anything, mock.Anything,
+	).Return(false, nil)
+	valueService := &MockValueService{}
+	valueService.On("Read", mock.Anything, mock.Anything).Return(nil)
+
+	keys := keyring.New[string]()
+	require.NoError(t, keys.Add("", "secret", time.Time{}))
+	guard := replay.NewGuard(time.Minute, 2)
+	mux := chi.NewRouter()
+	mux.Use(middleware.VerifyAndWriteSHA256(keys, http.MethodPost))
+	httpapi.Register(mux, httpapi.RegisterServices{
+		IBatchUpdateService: batchUpdateService,
+		IReadService:        valueService,
+		TrustedNet:          middleware.TrustedNet(netacl.Policy{}),
+		Signed:              func(next http.Handler) http.Handler { return next },
+		Unsigned:            middleware.RejectUnsigned(nil),
+		Replay:              middleware.RejectReplay(guard),
+		UpdateLimit:         middleware.RateLimit(nil),
+		BatchLimit:          middleware.RateLimit(nil),
+		Authorize:           middleware.Authorize(nil),
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	send := func(t *testing.T, stamp replay.Stamp, key string) int {
+		body := []byte(`[{"id":"PollCount","type":"counter","delta":5}]`)
+		h := hmac.New(sha256.New, []byte(key))
+		h.Write(stamp.Material(body))
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			s.URL+"/updates/",
+			bytes.NewReader(body),
+		)
+		require.NoError(t, err)
+		req.Header.Set(httpapi.ContentType, httpapi.JSON)
+		req.Header.Set(replay.XTimestamp, stamp.Timestamp())
+		req.Header.Set(replay.XNonce, stamp.Nonce)
+		req.Header.Set("HashSHA256", hex.EncodeToString(h.Sum(nil)))
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	for range 5 {
+		forged := replay.NewStamp()
+		forged.Time = forged.Time.Add(55 * time.Second)
+		assert.Equal(t, http.StatusBadRequest, send(t, forged, "wrong"))
+	}
+
+	stamp := replay.NewStamp()
+	assert.Equal(
+		t, http.StatusOK, send(t, stamp, "secret"),
+		"requests of wrong hash do not move the floor",
+	)
+	assert.Equal(
+		t, http.StatusUnauthorized, send(t, stamp, "secret"),
+		"authenticated request is accepted once",
+	)
+
+	req, err := http.NewRequestWithContext(
+		context.Background(),
+		http.MethodPost,
+		s.URL+"/value/",
+		strings.NewReader(`{"id":"PollCount","type":"counter"}`),
+	)
+	require.NoError(t, err)
+	req.Header.Set(httpapi.ContentType, httpapi.JSON)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(
+		t, http.StatusOK, res.StatusCode, "read request is not stamped",
+	)
+}
+
+// agentKeys is agent key storage without keys.
+type agentKeys struct{}
+
+func (agentKeys) AgentKey(context.Context, string) (ed25519.PublicKey, error) {
+	return nil, sign.ErrUnknownAgent
+}
+
+func TestRegisterUnsigned(t *testing.T) {
+	logger.Init("fatal")
+
+	updateService := &UpdateByURLService{}
+	updateService.On("Update", mock.Anything, mock.Anything).Return(nil)
+
+	mux := chi.NewRouter()
+	httpapi.Register(mux, httpapi.RegisterServices{
+		IUpdateService: updateService,
+		TrustedNet:     middleware.TrustedNet(netacl.Policy{}),
+		Signed:         middleware.VerifySignature(agentKeys{}),
+		Unsigned:       middleware.RejectUnsigned(agentKeys{}),
+		Replay:         middleware.RejectReplay(nil),
+		UpdateLimit:    middleware.RateLimit(nil),
+		BatchLimit:     middleware.RateLimit(nil),
+		Authorize:      middleware.Authorize(nil),
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	for _, path := range []string{"/update/gauge/Alloc/1.5", "/update/"} {
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			s.URL+path,
+			strings.NewReader(`{"id":"Alloc","type":"gauge","value":1.5}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set(httpapi.ContentType, httpapi.JSON)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, path)
+	}
+	updateService.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,100 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// RangeHandler works with service and provides ReadRange method.
+type RangeHandler struct {
+	service di.IRangeService
+}
+
+// SetRangeHandler sets RangeHandler to "/range/{type}/{name}" path.
+//
+// Range params are passed by query, e.g.
+// "/range/gauge/HeapAlloc?from=2025-01-01T10:00:00Z&to=2025-01-01T11:00:00Z&step=1m&func=avg".
+func SetRangeHandler(mux chi.Router, service di.IRangeService) {
+	path := "/range/{type}/{name}"
+	handler := &RangeHandler{service}
+	mux.Get(path, handler.ReadRange())
+	debugLogRegister(path)
+}
+
+// rangeResponse is aggregated metrics history.
+type rangeResponse struct {
+	ID     string          `json:"id"`
+	MType  string          `json:"type"`
+	Labels metrics.Labels  `json:"labels,omitempty"`
+	Func   string          `json:"func"`
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Step   int64           `json:"step"` // seconds
+	Points []metrics.Point `json:"points"`
+}
+
+// ReadRange reads metrics from URL params and range from query,
+// then writes aggregated points in JSON.
+func (h *RangeHandler) ReadRange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := ReadRangeQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q.ID = chi.URLParam(r, "name")
+		q.MType = chi.URLParam(r, "type")
+		q.SetDefaults(time.Now())
+
+		if err = q.Verify(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		points, err := h.service.Range(r.Context(), q)
+		if errors.Is(err, server.ErrNotSupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+			return
+		}
+		if errors.Is(err, server.ErrRangeStep) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(
+				w,
+				server.ErrInternal.Error(),
+				http.StatusInternalServerError,
+			)
+			return
+		}
+
+		res := rangeResponse{
+			ID:     q.ID,
+			MType:  q.MType,
+			Labels: q.Labels,
+			Func:   q.Func,
+			From:   q.From.UTC(),
+			To:     q.To.UTC(),
+			Step:   int64(q.Step / time.Second),
+			Points: points,
+		}
+		err = WriteJSONResponse(w, http.StatusOK, res)
+		if err != nil {
+			logger.Log.Error("error on write response", zap.Error(err))
+			http.Error(
+				w,
+				err.Error(),
+				http.StatusInternalServerError,
+			)
+		}
+	}
+}
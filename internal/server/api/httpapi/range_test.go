@@ -0,0 +1,157 @@
+package httpapi_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/internal/server/api/httpapi"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockRangeService struct {
+	mock.Mock
+}
+
+func (service *MockRangeService) Range(
+	ctx context.Context, q metrics.RangeQuery,
+) ([]metrics.Point, error) {
+	retArgs := service.Called(q)
+	points, _ := retArgs.Get(0).([]metrics.Point)
+	return points, retArgs.Error(1)
+}
+
+func TestRangeHandler(t *testing.T) {
+	from := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Minute)
+
+	request := func(t *testing.T, s *httptest.Server, target string) *http.Response {
+		req, err := http.NewRequestWithContext(
+			context.Background(), http.MethodGet, s.URL+target, http.NoBody,
+		)
+		require.NoError(t, err)
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("Regular response", func(t *testing.T) {
+		expectedQuery := metrics.RangeQuery{
+			ID:     "PollCount",
+			MType:  metrics.MTypeCounter,
+			Labels: metrics.Labels{"host": "a"},
+			From:   from,
+			To:     to,
+			Step:   time.Minute,
+			Func:   metrics.AggRate,
+		}
+		mockService := new(MockRangeService)
+		mockService.On("Range", mock.MatchedBy(func(q metrics.RangeQuery) bool {
+			return q.ID == expectedQuery.ID &&
+				q.MType == expectedQuery.MType &&
+				q.Labels.String() == expectedQuery.Labels.String() &&
+				q.From.Equal(expectedQuery.From) &&
+				q.To.Equal(expectedQuery.To) &&
+				q.Step == expectedQuery.Step &&
+				q.Func == expectedQuery.Func
+		})).Return(
+			[]metrics.Point{{Time: from.Add(time.Minute), Value: 0.5}}, nil,
+		)
+
+		mux := chi.NewRouter()
+		httpapi.SetRangeHandler(mux, mockService)
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		res := request(
+			t, s,
+			"/range/counter/PollCount?labels=host=a&func=rate&step=1m"+
+				"&from=2025-01-01T10:00:00Z&to=1735725780",
+		)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, httpapi.JSON, res.Header.Get(httpapi.ContentType))
+
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.JSONEq(
+			t,
+			`{
+				"id": "PollCount",
+				"type": "counter",
+				"labels": {"host": "a"},
+				"func": "rate",
+				"from": "2025-01-01T10:00:00Z",
+				"to": "2025-01-01T10:03:00Z",
+				"step": 60,
+				"points": [{"ts": "2025-01-01T10:01:00Z", "value": 0.5}]
+			}`,
+			string(data),
+		)
+		mockService.AssertNumberOfCalls(t, "Range", 1)
+	})
+
+	t.Run("Bad request", func(t *testing.T) {
+		targets := []string{
+			"/range/histogram/Latency",
+			"/range/gauge/Alloc?func=rate",
+			"/range/gauge/Alloc?func=median",
+			"/range/gauge/Alloc?step=1.5s",
+			"/range/gauge/Alloc?from=yesterday",
+			"/range/gauge/Alloc?from=1735725780&to=1735725600",
+			"/range/gauge/Alloc?labels=host",
+		}
+
+		mockService := new(MockRangeService)
+		mux := chi.NewRouter()
+		httpapi.SetRangeHandler(mux, mockService)
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		for _, target := range targets {
+			res := request(t, s, target)
+			res.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, res.StatusCode, target)
+		}
+		mockService.AssertNumberOfCalls(t, "Range", 0)
+	})
+
+	t.Run("Not supported by storage", func(t *testing.T) {
+		mockService := new(MockRangeService)
+		mockService.On("Range", mock.Anything).Return(nil, server.ErrNotSupported)
+
+		mux := chi.NewRouter()
+		httpapi.SetRangeHandler(mux, mockService)
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		res := request(t, s, "/range/gauge/Alloc")
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotImplemented, res.StatusCode)
+	})
+
+	t.Run("Step finer than history resolution", func(t *testing.T) {
+		mockService := new(MockRangeService)
+		mockService.On("Range", mock.Anything).Return(
+			nil, fmt.Errorf("%w, at least 1h", server.ErrRangeStep),
+		)
+
+		mux := chi.NewRouter()
+		httpapi.SetRangeHandler(mux, mockService)
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		res := request(t, s, "/range/gauge/Alloc")
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+}
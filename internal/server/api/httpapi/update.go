@@ -1,6 +1,7 @@
 package httpapi
 
 import (
+	"errors"
 	"io"
 	"net/http"
 
@@ -22,11 +23,19 @@ type UpdateHandler struct {
 // SetUpdateHandler sets UpdateHandler to "/update" path.
 //
 //   - "/update/" to UpdateByJSON method, only JSON media type is allowed
-//   - "/update/{type}/{name}/{value}" to UpdateByURLParams method
-func SetUpdateHandler(mux *chi.Mux, service di.IUpdateService) {
+//   - "/update/{type}/{name}/{value}" to UpdateByURLParams method, labels are passed by query
+//
+// Guards, e.g. rate limit, are applied to both paths.
+func SetUpdateHandler(
+	mux chi.Router,
+	service di.IUpdateService,
+	guards ...func(http.Handler) http.Handler,
+) {
 	path := "/update"
 	handler := &UpdateHandler{service}
 	mux.Route(path, func(r chi.Router) {
+		r.Use(guards...)
+
 		byJSONPath := "/"
 		r.With(middleware.AllowJSON).Post(byJSONPath, handler.UpdateByJSON())
 		debugLogRegister(path + byJSONPath)
@@ -53,6 +62,10 @@ func (h *UpdateHandler) UpdateByJSON() http.HandlerFunc {
 		}
 
 		err = h.service.Update(r.Context(), &m)
+		if errors.Is(err, metrics.ErrHistogramMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(
 				w, server.ErrInternal.Error(), http.StatusInternalServerError,
@@ -68,7 +81,7 @@ func (h *UpdateHandler) UpdateByJSON() http.HandlerFunc {
 	}
 }
 
-// UpdataByURLParams reads data from URL params.
+// UpdataByURLParams reads data from URL params and labels query.
 func (h *UpdateHandler) UpdataByURLParams() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		m := metrics.NewFromStrArgs(
@@ -76,14 +89,24 @@ func (h *UpdateHandler) UpdataByURLParams() http.HandlerFunc {
 			chi.URLParam(r, "type"),
 			chi.URLParam(r, "value"),
 		)
+		labels, err := ReadLabelsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.Labels = labels
 
-		err := checkMetricsForUpdate(m)
+		err = checkMetricsForUpdate(m)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		err = h.service.Update(r.Context(), &m)
+		if errors.Is(err, metrics.ErrHistogramMismatch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(
 				w, server.ErrInternal.Error(), http.StatusInternalServerError,
@@ -102,5 +125,7 @@ func checkMetricsForUpdate(m metrics.Metrics) error {
 	return m.Verify(
 		metrics.VerifyID,
 		metrics.VerifyType,
+		metrics.VerifyLabels,
+		metrics.VerifyHistogram,
 	)
 }
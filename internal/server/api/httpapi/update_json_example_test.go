@@ -72,5 +72,5 @@ func ExampleSetUpdateHandler_updateByJSON() {
 
 	// Output:
 	// 200
-	// {"value":123.45,"id":"0","type":"gauge"}
+	// {"id":"0","type":"gauge","value":123.45}
 }
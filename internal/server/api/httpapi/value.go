@@ -4,6 +4,8 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/niksmo/runlytics/internal/logger"
@@ -23,22 +25,30 @@ type ValueHandler struct {
 // SetValueHandler sets ValueHandler to "/value" path.
 //
 //   - "/value/" to ReadByJSON method, only JSON media type is allowed
-//   - "/value/{type}/{name}" to ReadByURLParams method
-func SetValueHandler(mux *chi.Mux, service di.IReadService) {
+//   - "/value/{type}/{name}" to ReadByURLParams method, labels are passed by query
+func SetValueHandler(mux chi.Router, service di.IReadService) {
 	path := "/value"
 	handler := &ValueHandler{service}
-	mux.Route(path, func(r chi.Router) {
-		byJSONPath := "/"
-		r.With(middleware.AllowJSON).Post(byJSONPath, handler.ReadByJSON())
-		debugLogRegister(path + byJSONPath)
-
-		byURLParamsPath := "/{type}/{name}"
-		r.Get(byURLParamsPath, handler.ReadByURLParams())
-		debugLogRegister(path + byURLParamsPath)
-	})
+
+	byJSONPath := path + "/"
+	mux.With(middleware.AllowJSON).Post(byJSONPath, handler.ReadByJSON())
+	debugLogRegister(byJSONPath)
+
+	byURLParamsPath := path + "/{type}/{name}"
+	mux.Get(byURLParamsPath, handler.ReadByURLParams())
+	debugLogRegister(byURLParamsPath)
+}
+
+// valueResponse is metrics object with histogram quantile estimates.
+type valueResponse struct {
+	metrics.Metrics
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
 }
 
 // ReadByJSON reads JSON data from request body.
+//
+// For histogram type quantile estimates could be requested by query,
+// e.g. "/value/?quantiles=0.5,0.99".
 func (h *ValueHandler) ReadByJSON() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var m metrics.Metrics
@@ -54,6 +64,12 @@ func (h *ValueHandler) ReadByJSON() http.HandlerFunc {
 			return
 		}
 
+		quantiles, err := readQuantiles(r, m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		err = h.service.Read(r.Context(), &m)
 		if errors.Is(err, server.ErrNotExists) {
 			http.Error(w, err.Error(), http.StatusNotFound)
@@ -68,7 +84,16 @@ func (h *ValueHandler) ReadByJSON() http.HandlerFunc {
 			return
 		}
 
-		err = WriteJSONResponse(w, http.StatusOK, m)
+		res := valueResponse{Metrics: m}
+		if len(quantiles) != 0 {
+			res.Quantiles = make(map[string]float64, len(quantiles))
+			for _, q := range quantiles {
+				v, _ := m.Histogram.Quantile(q)
+				res.Quantiles[strconv.FormatFloat(q, 'f', -1, 64)] = v
+			}
+		}
+
+		err = WriteJSONResponse(w, http.StatusOK, res)
 		if err != nil {
 			logger.Log.Error("error on write response", zap.Error(err))
 			http.Error(
@@ -80,7 +105,11 @@ func (h *ValueHandler) ReadByJSON() http.HandlerFunc {
 	}
 }
 
-// ReadByURLParams reads data from URL params.
+// ReadByURLParams reads data from URL params and labels query.
+//
+// For histogram type quantile estimates could be requested by query,
+// e.g. "/value/histogram/Latency?quantiles=0.5,0.99".
+// Estimates are written in requested order, separated by a newline.
 func (h *ValueHandler) ReadByURLParams() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		m := metrics.NewFromStrArgs(
@@ -88,7 +117,20 @@ func (h *ValueHandler) ReadByURLParams() http.HandlerFunc {
 			chi.URLParam(r, "type"),
 			"",
 		)
-		err := checkMetricsForRead(m)
+		labels, err := ReadLabelsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.Labels = labels
+
+		err = checkMetricsForRead(m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		quantiles, err := readQuantiles(r, m)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -108,8 +150,18 @@ func (h *ValueHandler) ReadByURLParams() http.HandlerFunc {
 			return
 		}
 
+		value := m.GetValue()
+		if len(quantiles) != 0 {
+			estimates := make([]string, 0, len(quantiles))
+			for _, q := range quantiles {
+				v, _ := m.Histogram.Quantile(q)
+				estimates = append(estimates, strconv.FormatFloat(v, 'f', -1, 64))
+			}
+			value = strings.Join(estimates, "\n")
+		}
+
 		w.WriteHeader(http.StatusOK)
-		if _, err = io.WriteString(w, m.GetValue()); err != nil {
+		if _, err = io.WriteString(w, value); err != nil {
 			http.Error(
 				w,
 				err.Error(),
@@ -119,9 +171,21 @@ func (h *ValueHandler) ReadByURLParams() http.HandlerFunc {
 	}
 }
 
+func readQuantiles(r *http.Request, m metrics.Metrics) ([]float64, error) {
+	quantiles, err := ReadQuantilesQuery(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(quantiles) != 0 && m.MType != metrics.MTypeHistogram {
+		return nil, ErrQuantilesType
+	}
+	return quantiles, nil
+}
+
 func checkMetricsForRead(m metrics.Metrics) error {
 	return m.Verify(
 		metrics.VerifyID,
 		metrics.VerifyType,
+		metrics.VerifyLabels,
 	)
 }
@@ -37,6 +37,13 @@ func (service *MockValueService) Read(
 			m.Value = 123.45
 		case metrics.MTypeCounter:
 			m.Delta = 12345
+		case metrics.MTypeHistogram:
+			m.Histogram = &metrics.Histogram{
+				Bounds: []float64{1, 2},
+				Counts: []uint64{10, 10, 0},
+				Sum:    25,
+				Count:  20,
+			}
 		}
 	}
 	return retArgs.Error(0)
@@ -301,6 +308,50 @@ func TestReadByJSONHandler(t *testing.T) {
 		mockService.AssertNumberOfCalls(t, "Read", 1)
 	})
 
+	t.Run("Histogram quantiles", func(t *testing.T) {
+		var schemeReq metrics.Metrics
+		schemeReq.ID = "0"
+		schemeReq.MType = metrics.MTypeHistogram
+
+		mockService := new(MockValueService)
+		mockService.On("Read", context.Background(), &schemeReq).Return(nil)
+
+		mux := chi.NewRouter()
+		httpapi.SetValueHandler(mux, mockService)
+
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodPost,
+			makeURL(s.URL)+"?quantiles=0.5",
+			strings.NewReader(`{"id": "0", "type": "histogram"}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set(httpapi.ContentType, httpapi.JSON)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.JSONEq(
+			t,
+			`{
+				"id": "0",
+				"type": "histogram",
+				"histogram": {"bounds": [1, 2], "counts": [10, 10, 0], "sum": 25, "count": 20},
+				"quantiles": {"0.5": 1}
+			}`,
+			string(data),
+		)
+
+		mockService.AssertNumberOfCalls(t, "Read", 1)
+	})
+
 	t.Run("Encoding", func(t *testing.T) {
 		t.Run("Allow only gzip", func(t *testing.T) {
 			mockService := new(MockValueService)
@@ -625,4 +676,124 @@ func TestReadByURLParamsHandler(t *testing.T) {
 		mockService.AssertNumberOfCalls(t, "Read", 1)
 	})
 
+	t.Run("Labels query", func(t *testing.T) {
+		id := "0"
+		mType := metrics.MTypeGauge
+
+		var schemeReq metrics.Metrics
+		schemeReq.ID = id
+		schemeReq.MType = mType
+		schemeReq.Labels = metrics.Labels{"host": "a", "env": "prod"}
+
+		mockService := new(MockValueService)
+		mockService.On("Read", context.Background(), &schemeReq).Return(nil)
+
+		mux := chi.NewRouter()
+		httpapi.SetValueHandler(mux, mockService)
+
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			makeURL(s.URL, mType, id)+"?labels=host=a,env=prod",
+			http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+
+		mockService.AssertNumberOfCalls(t, "Read", 1)
+	})
+
+	t.Run("Histogram quantiles", func(t *testing.T) {
+		id := "0"
+		mType := metrics.MTypeHistogram
+
+		var schemeReq metrics.Metrics
+		schemeReq.ID = id
+		schemeReq.MType = mType
+
+		mockService := new(MockValueService)
+		mockService.On("Read", context.Background(), &schemeReq).Return(nil)
+
+		mux := chi.NewRouter()
+		httpapi.SetValueHandler(mux, mockService)
+
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			makeURL(s.URL, mType, id)+"?quantiles=0.5,0.75",
+			http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, "1\n1.5", string(data))
+
+		mockService.AssertNumberOfCalls(t, "Read", 1)
+	})
+
+	t.Run("Quantiles for non histogram type", func(t *testing.T) {
+		mockService := new(MockValueService)
+
+		mux := chi.NewRouter()
+		httpapi.SetValueHandler(mux, mockService)
+
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			makeURL(s.URL, metrics.MTypeGauge, "0")+"?quantiles=0.5",
+			http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		mockService.AssertNumberOfCalls(t, "Read", 0)
+	})
+
+	t.Run("Invalid labels query", func(t *testing.T) {
+		mockService := new(MockValueService)
+
+		mux := chi.NewRouter()
+		httpapi.SetValueHandler(mux, mockService)
+
+		s := httptest.NewServer(mux)
+		defer s.Close()
+
+		req, err := http.NewRequestWithContext(
+			context.Background(),
+			http.MethodGet,
+			makeURL(s.URL, metrics.MTypeGauge, "0")+"?labels=1host=a",
+			http.NoBody,
+		)
+		require.NoError(t, err)
+
+		res, err := s.Client().Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+		mockService.AssertNumberOfCalls(t, "Read", 0)
+	})
 }
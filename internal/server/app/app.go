@@ -1,15 +1,24 @@
 package app
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/niksmo/runlytics/internal/logger"
 	grpcapp "github.com/niksmo/runlytics/internal/server/app/grpc"
 	httpapp "github.com/niksmo/runlytics/internal/server/app/http"
 	"github.com/niksmo/runlytics/internal/server/config"
 	"github.com/niksmo/runlytics/internal/server/service"
 	"github.com/niksmo/runlytics/internal/server/storage"
+	"github.com/niksmo/runlytics/internal/server/storage/agentkeys"
 	"github.com/niksmo/runlytics/pkg/cipher"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/fileoperator"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/token"
 	"go.uber.org/zap"
 )
 
@@ -17,35 +26,79 @@ type App struct {
 	GRPCServer di.MustRunStopper
 	HTTPServer di.MustRunStopper
 	Storage    di.MustRunStopper
+	Expirer    di.MustRunStopper
 }
 
 func New(cfg *config.ServerConfig) *App {
-	decrypter, err := cipher.NewDecrypterX509(cfg.Crypto.Data)
+	decrypters, err := newDecrypters(cfg.Crypto)
 	if err != nil {
 		logger.Log.Fatal("failed to init decrypter", zap.Error(err))
 	}
 
-	fileOperator := fileoperator.New(cfg.FileStorage.File)
+	fileOperator := fileoperator.New(cfg.FileStorage.File, cfg.FileStorage.Keep)
 	storage := storage.New(
 		fileOperator,
 		cfg.DB.DSN,
 		cfg.FileStorage.SaveInterval,
 		cfg.FileStorage.Restore,
+		cfg.FileStorage.WALPath(),
+		cfg.FileStorage.Keep,
+		cfg.FileStorage.Shards,
+		cfg.History.Retention,
+		cfg.Batch.TTL,
 	)
 
+	agentKeys, err := newAgentKeys(cfg.AgentKeys, storage)
+	if err != nil {
+		logger.Log.Fatal("failed to init agent keys", zap.Error(err))
+	}
+
+	tokens, err := newTokens(cfg.Tokens, storage)
+	if err != nil {
+		logger.Log.Fatal("failed to init tokens", zap.Error(err))
+	}
+
+	var replayGuard *replay.Guard
+	if cfg.Replay.IsSet() {
+		replayGuard = replay.NewGuard(cfg.Replay.Window, cfg.Replay.CacheSize)
+	}
+
+	limits := cfg.RateLimit
+	updateLimiter := ratelimit.New(limits.Update, limits.Concurrency)
+	batchLimiter := ratelimit.New(limits.Updates, limits.Concurrency)
+	grpcBatchLimiter := ratelimit.New(limits.GRPCBatch, limits.Concurrency)
+
 	htmlS := service.NewHTMLService(storage)
 	updateS := service.NewUpdateService(storage)
 	readS := service.NewReadService(storage)
+	listS := service.NewListService(storage)
 	healthCheckS := service.NewHealthCheckService(storage)
 	batchUpdateS := service.NewBatchUpdateService(storage)
+	rangeS := service.NewRangeService(storage)
+	deleteS := service.NewDeleteService(storage)
+	expireS := service.NewExpireService(storage, cfg.TTL.TTL)
 
 	gRPCApp := grpcapp.New(
 		grpcapp.AppParams{
 			BatchUpdateService: batchUpdateS,
+			UpdateService:      updateS,
+			ReadService:        readS,
+			ListService:        listS,
+			HealthCheckService: healthCheckS,
+			RangeService:       rangeS,
+			DeleteService:      deleteS,
 			Addr:               cfg.GRPCAddr.TCPAddr,
-			Decrypter:          decrypter,
-			HashKey:            cfg.HashKey.Key,
-			TrustedNet:         cfg.TrustedNet.IPNet,
+			Decrypters:         decrypters,
+			HashKeys:           cfg.HashKey.Keyring,
+			AgentKeys:          agentKeys,
+			ReplayGuard:        replayGuard,
+			Tokens:             tokens,
+			TrustedNet:         cfg.TrustedNet.Policy(),
+			TrustedProxies:     cfg.TrustedNet.Proxies,
+			UpdateLimiter:      updateLimiter,
+			BatchLimiter:       grpcBatchLimiter,
+			LegacyGob:          cfg.GRPCGob.Enabled,
+			TLSConfig:          cfg.TLS.Config,
 		},
 	)
 
@@ -54,12 +107,21 @@ func New(cfg *config.ServerConfig) *App {
 			HTMLService:        htmlS,
 			UpdateService:      updateS,
 			ReadService:        readS,
+			DeleteService:      deleteS,
 			HealthCheckService: healthCheckS,
 			BatchUpdateService: batchUpdateS,
+			RangeService:       rangeS,
 			Addr:               cfg.HTTPAddr.TCPAddr,
-			Decrypter:          decrypter,
-			HashKey:            cfg.HashKey.Key,
-			TrustedNet:         cfg.TrustedNet.IPNet,
+			Decrypters:         decrypters,
+			HashKeys:           cfg.HashKey.Keyring,
+			AgentKeys:          agentKeys,
+			ReplayGuard:        replayGuard,
+			Tokens:             tokens,
+			TrustedNet:         cfg.TrustedNet.Policy(),
+			TrustedProxies:     cfg.TrustedNet.Proxies,
+			UpdateLimiter:      updateLimiter,
+			BatchLimiter:       batchLimiter,
+			TLSConfig:          cfg.TLS.Config,
 		},
 	)
 
@@ -67,5 +129,67 @@ func New(cfg *config.ServerConfig) *App {
 		GRPCServer: gRPCApp,
 		HTTPServer: httpApp,
 		Storage:    storage,
+		Expirer:    expireS,
+	}
+}
+
+// newDecrypters returns decrypters of the default key and rotated keys.
+func newDecrypters(
+	cfg config.CryptoConfig,
+) (*keyring.Keyring[di.Decrypter], error) {
+	decrypters := keyring.New[di.Decrypter]()
+	if cfg.Data != nil {
+		decrypter, err := cipher.NewDecrypterX509(cfg.Data)
+		if err != nil {
+			return nil, err
+		}
+		decrypters.Add("", decrypter, time.Time{})
+	}
+
+	for _, key := range cfg.Keys {
+		decrypter, err := cipher.NewDecrypterX509(key.Data)
+		if err != nil {
+			return nil, fmt.Errorf("key '%s': %w", key.ID, err)
+		}
+		if err := decrypters.Add(key.ID, decrypter, key.Expires); err != nil {
+			return nil, err
+		}
+	}
+	return decrypters, nil
+}
+
+// newAgentKeys returns registry of agent public keys,
+// or nil if signatures are not verified.
+func newAgentKeys(
+	cfg config.AgentKeysConfig, storage di.IStorage,
+) (di.IAgentKeyStorage, error) {
+	switch {
+	case cfg.File != "":
+		return agentkeys.NewFileRegistry(cfg.File)
+	case cfg.DB:
+		keys, ok := storage.(di.IAgentKeyStorage)
+		if !ok {
+			return nil, errors.New("storage has no agent keys")
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+// newTokens returns registry of API tokens,
+// or nil if requests are not authorized.
+func newTokens(
+	cfg config.TokensConfig, storage di.IStorage,
+) (di.ITokenStorage, error) {
+	switch {
+	case len(cfg.Tokens) != 0:
+		return token.NewRegistry(cfg.Tokens)
+	case cfg.DB:
+		tokens, ok := storage.(di.ITokenStorage)
+		if !ok {
+			return nil, errors.New("storage has no tokens")
+		}
+		return tokens, nil
 	}
+	return nil, nil
 }
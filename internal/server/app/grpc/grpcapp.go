@@ -1,20 +1,40 @@
 package grpcapp
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/internal/server/api/grpcapi"
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/decrypt"
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/hashcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/limitcheck"
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/netcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/peerid"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/replaycheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/signcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/tokencheck"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
+// keepaliveMinTime is the minimum ping interval permitted to agents,
+// more frequent pings close the connection.
+const keepaliveMinTime = 10 * time.Second
+
 type App struct {
 	gRPCServer *grpc.Server
 	addr       *net.TCPAddr
@@ -22,23 +42,81 @@ type App struct {
 
 type AppParams struct {
 	BatchUpdateService di.IBatchUpdateService
+	UpdateService      di.IUpdateService
+	ReadService        di.IReadService
+	ListService        di.IListService
+	HealthCheckService di.IHealthCheckService
+	RangeService       di.IRangeService
+	DeleteService      di.IDeleteService
 	Addr               *net.TCPAddr
-	HashKey            string
-	Decrypter          di.Decrypter
-	TrustedNet         *net.IPNet
+	HashKeys           *keyring.Keyring[string]
+	Decrypters         *keyring.Keyring[di.Decrypter]
+	AgentKeys          di.IAgentKeyStorage // signatures are not verified if nil
+	ReplayGuard        *replay.Guard       // replays are not rejected if nil
+	Tokens             di.ITokenStorage    // calls are not authorized if nil
+	TrustedNet         netacl.Policy
+	TrustedProxies     *netacl.ACL        // X-Real-IP is not honored if nil
+	UpdateLimiter      *ratelimit.Limiter // Update is not limited if nil
+	BatchLimiter       *ratelimit.Limiter // batches are not limited if nil
+	LegacyGob          bool
+	TLSConfig          *tls.Config // serves cleartext if nil
 }
 
 func New(p AppParams) *App {
+	limits := limitcheck.Limits{
+		pb.Runlytics_Update_FullMethodName:        p.UpdateLimiter,
+		pb.Runlytics_BatchUpdate_FullMethodName:   p.BatchLimiter,
+		pb.Runlytics_StreamUpdates_FullMethodName: p.BatchLimiter,
+	}
+	creds := insecure.NewCredentials()
+	if p.TLSConfig != nil {
+		creds = credentials.NewTLS(p.TLSConfig)
+	}
 	gRPCServer := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             keepaliveMinTime,
+			PermitWithoutStream: true,
+		}),
 		grpc.ChainUnaryInterceptor(
 			interceptor.WithRecovery(),
 			interceptor.WithLog(),
-			decrypt.New(p.Decrypter),
-			netcheck.New(p.TrustedNet),
-			hashcheck.New(p.HashKey),
+			netcheck.New(p.TrustedNet, p.TrustedProxies),
+			tokencheck.New(p.Tokens),
+			limitcheck.New(limits),
+			decrypt.New(p.Decrypters),
+			hashcheck.New(p.HashKeys),
+			signcheck.New(p.AgentKeys),
+			replaycheck.New(p.ReplayGuard),
+			peerid.New(),
 		),
+		grpc.ChainStreamInterceptor(
+			interceptor.WithStreamRecovery(),
+			interceptor.WithStreamLog(),
+			netcheck.NewStream(p.TrustedNet, p.TrustedProxies),
+			tokencheck.NewStream(p.Tokens),
+			limitcheck.NewStream(limits),
+			decrypt.NewStream(p.Decrypters),
+			hashcheck.NewStream(p.HashKeys),
+			signcheck.NewStream(p.AgentKeys),
+			replaycheck.NewStream(p.ReplayGuard),
+			peerid.NewStream(),
+		),
+	)
+	grpcapi.Register(
+		gRPCServer,
+		grpcapi.RegisterServices{
+			IBatchUpdateService: p.BatchUpdateService,
+			IUpdateService:      p.UpdateService,
+			IReadService:        p.ReadService,
+			IListService:        p.ListService,
+			IHealthCheckService: p.HealthCheckService,
+			IRangeService:       p.RangeService,
+			IDeleteService:      p.DeleteService,
+			LegacyGob:           p.LegacyGob,
+		},
 	)
-	grpcapi.Register(gRPCServer, p.BatchUpdateService)
+	reflection.Register(gRPCServer)
 	return &App{gRPCServer: gRPCServer, addr: p.Addr}
 }
 
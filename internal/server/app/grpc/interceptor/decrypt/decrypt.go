@@ -2,36 +2,94 @@ package decrypt
 
 import (
 	"context"
+	"errors"
 
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/cipher"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
 	"github.com/niksmo/runlytics/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-var ErrInvalidPayload = status.Error(
-	codes.InvalidArgument, "invalid message payload",
+var (
+	ErrInvalidPayload = status.Error(
+		codes.InvalidArgument, "invalid message payload",
+	)
+	ErrPayloadVersion = status.Error(
+		codes.InvalidArgument, "unsupported message payload version",
+	)
+	ErrUnknownKey = status.Error(
+		codes.InvalidArgument, "unknown or expired crypto key",
+	)
 )
 
-func New(decrypter di.Decrypter) grpc.UnaryServerInterceptor {
+// New decrypts batch with decrypter picked from decrypters by
+// [interceptor.XCryptoKeyID] metadata, batch without the metadata
+// is decrypted by the default decrypter.
+func New(decrypters *keyring.Keyring[di.Decrypter]) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		switch r := req.(type) {
-		case *proto.BatchUpdateRequest:
-			data, err := decrypter.DecryptMsg(r.GetMetrics())
-			if err != nil {
-				return nil, ErrInvalidPayload
-			}
-			r.Metrics = data
-			return handler(ctx, r)
-		default:
-			return handler(ctx, req)
+		if err := decrypt(ctx, decrypters, req); err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream decrypts every batch received by stream,
+// decrypter is picked by stream metadata.
+func NewStream(
+	decrypters *keyring.Keyring[di.Decrypter],
+) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(m any) error {
+				return decrypt(ss.Context(), decrypters, m)
+			},
+		})
+	}
+}
 
+func decrypt(
+	ctx context.Context, decrypters *keyring.Keyring[di.Decrypter], req any,
+) error {
+	r, ok := req.(*proto.BatchUpdateRequest)
+	if !ok {
+		return nil
+	}
+
+	decrypter, err := decrypters.Get(
+		interceptor.MetadataValue(ctx, interceptor.XCryptoKeyID),
+	)
+	if err != nil {
+		return ErrUnknownKey
+	}
+
+	// legacy gob metrics are encrypted as well
+	payload := &r.Batch
+	if len(r.GetBatch()) == 0 && len(r.GetMetrics()) != 0 {
+		payload = &r.Metrics
+	}
+	data, err := decrypter.DecryptMsg(*payload)
+	if errors.Is(err, cipher.ErrMsgVersion) {
+		return ErrPayloadVersion
+	}
+	if err != nil {
+		return ErrInvalidPayload
 	}
+	*payload = data
+	return nil
 }
@@ -0,0 +1,149 @@
+package decrypt_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/decrypt"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/pkg/cipher"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixDecrypter decrypts messages sealed by the prefix.
+type prefixDecrypter string
+
+func (d prefixDecrypter) DecryptMsg(b []byte) ([]byte, error) {
+	if bytes.HasPrefix(b, []byte("v2:")) {
+		return nil, cipher.ErrMsgVersion
+	}
+	data, ok := bytes.CutPrefix(b, []byte(d))
+	if !ok {
+		return nil, errors.New("decryption error")
+	}
+	return data, nil
+}
+
+func newDecrypters(t *testing.T) *keyring.Keyring[di.Decrypter] {
+	t.Helper()
+	decrypters := keyring.New[di.Decrypter]()
+	require.NoError(t, decrypters.Add("", prefixDecrypter("c0:"), time.Time{}))
+	require.NoError(t, decrypters.Add("c2", prefixDecrypter("c2:"), time.Time{}))
+	return decrypters
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyID   string
+		req     *pb.BatchUpdateRequest
+		want    *pb.BatchUpdateRequest
+		wantErr error
+	}{
+		{
+			name: "Should decrypt by default key",
+			req:  &pb.BatchUpdateRequest{Batch: []byte("c0:batch")},
+			want: &pb.BatchUpdateRequest{Batch: []byte("batch")},
+		},
+		{
+			name:  "Should decrypt by key id",
+			keyID: "c2",
+			req:   &pb.BatchUpdateRequest{Batch: []byte("c2:batch")},
+			want:  &pb.BatchUpdateRequest{Batch: []byte("batch")},
+		},
+		{
+			name: "Should decrypt legacy metrics",
+			req:  &pb.BatchUpdateRequest{Metrics: []byte("c0:gob")},
+			want: &pb.BatchUpdateRequest{Metrics: []byte("gob")},
+		},
+		{
+			name:    "Should reject unknown key",
+			keyID:   "c1",
+			req:     &pb.BatchUpdateRequest{Batch: []byte("c2:batch")},
+			wantErr: decrypt.ErrUnknownKey,
+		},
+		{
+			name:    "Should reject payload of other key",
+			req:     &pb.BatchUpdateRequest{Batch: []byte("c2:batch")},
+			wantErr: decrypt.ErrInvalidPayload,
+		},
+		{
+			name:    "Should reject unsupported payload version",
+			req:     &pb.BatchUpdateRequest{Batch: []byte("v2:batch")},
+			wantErr: decrypt.ErrPayloadVersion,
+		},
+	}
+
+	check := decrypt.New(newDecrypters(t))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h interceptortest.Handler
+			_, err := check(
+				interceptortest.Context(interceptor.XCryptoKeyID, test.keyID),
+				test.req,
+				interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			got := h.Req.(*pb.BatchUpdateRequest)
+			assert.Equal(t, test.want.GetBatch(), got.GetBatch())
+			assert.Equal(t, test.want.GetMetrics(), got.GetMetrics())
+		})
+	}
+
+	t.Run("Should pass other requests", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := check(
+			interceptortest.Context(),
+			&pb.PingRequest{},
+			interceptortest.Info(pb.Runlytics_Ping_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	check := decrypt.NewStream(newDecrypters(t))
+	info := interceptortest.StreamInfo(pb.Runlytics_StreamUpdates_FullMethodName)
+
+	t.Run("Should decrypt every batch by stream key", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx: interceptortest.Context(interceptor.XCryptoKeyID, "c2"),
+			Msgs: []*pb.BatchUpdateRequest{
+				{Batch: []byte("c2:first")}, {Batch: []byte("c2:second")},
+			},
+		}
+		var h interceptortest.StreamHandler
+		require.NoError(t, check(nil, ss, info, h.Handle))
+		require.Len(t, h.Received, 2)
+		assert.Equal(t, []byte("first"), h.Received[0].GetBatch())
+		assert.Equal(t, []byte("second"), h.Received[1].GetBatch())
+	})
+
+	t.Run("Should fail stream on invalid batch", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx: interceptortest.Context(interceptor.XCryptoKeyID, "c2"),
+			Msgs: []*pb.BatchUpdateRequest{
+				{Batch: []byte("c2:first")}, {Batch: []byte("c0:second")},
+			},
+		}
+		var h interceptortest.StreamHandler
+		err := check(nil, ss, info, h.Handle)
+		assert.ErrorIs(t, err, decrypt.ErrInvalidPayload)
+		assert.Len(t, h.Received, 1)
+	})
+}
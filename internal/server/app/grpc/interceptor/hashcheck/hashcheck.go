@@ -5,10 +5,9 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
-	"hash"
-	"sync"
 
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/keyring"
 	pb "github.com/niksmo/runlytics/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -18,21 +17,35 @@ import (
 
 const hashSHA256 = "HashSHA256"
 
-var ErrInvalidHash = status.Error(codes.InvalidArgument, "invalid hash")
-
-var hashPool = sync.Pool{}
+var (
+	ErrInvalidHash = status.Error(codes.InvalidArgument, "invalid hash")
+	ErrUnknownKey  = status.Error(
+		codes.InvalidArgument, "unknown or expired hash key",
+	)
+)
 
-func New(key string) grpc.UnaryServerInterceptor {
+// New checks batch hash with the key picked from keys by
+// [interceptor.XHashKeyID] metadata, batch without the metadata
+// is checked with the default key. Hash of stamped batch covers
+// the stamp, see package replay.
+func New(keys *keyring.Keyring[string]) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		if key == "" || validMethod(info.FullMethod) {
+		if keys.Len() == 0 || validMethod(info.FullMethod) {
 			return handler(ctx, req)
 		}
 
+		key, err := keys.Get(
+			interceptor.MetadataValue(ctx, interceptor.XHashKeyID),
+		)
+		if err != nil {
+			return nil, ErrUnknownKey
+		}
+
 		md, ok := metadata.FromIncomingContext(ctx)
 		if !ok {
 			return nil, interceptor.ErrMissingMetadata
@@ -47,8 +60,13 @@ func New(key string) grpc.UnaryServerInterceptor {
 			return nil, interceptor.ErrInvalidRequestMessage
 		}
 
+		stamp, err := interceptor.MetadataStamp(ctx)
+		if err != nil {
+			return nil, interceptor.ErrInvalidStamp
+		}
+
 		hash := md.Get(hashSHA256)[0]
-		if !validHash(key, hash, typedReq.GetMetrics()) {
+		if !validHash(key, hash, stamp.Material(payload(typedReq))) {
 			return nil, ErrInvalidHash
 		}
 
@@ -56,10 +74,64 @@ func New(key string) grpc.UnaryServerInterceptor {
 	}
 }
 
+// NewStream checks hash of every batch received by StreamUpdates.
+// Stream metadata is shared by batches, so hash is passed
+// in request hash field, the key is picked by stream metadata.
+func NewStream(keys *keyring.Keyring[string]) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if keys.Len() == 0 || validStreamMethod(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		key, err := keys.Get(
+			interceptor.MetadataValue(ss.Context(), interceptor.XHashKeyID),
+		)
+		if err != nil {
+			return ErrUnknownKey
+		}
+
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(m any) error {
+				typedReq, ok := m.(*pb.BatchUpdateRequest)
+				if !ok {
+					return interceptor.ErrInvalidRequestMessage
+				}
+				stamp, err := interceptor.RequestStamp(typedReq)
+				if err != nil {
+					return interceptor.ErrInvalidStamp
+				}
+				material := stamp.Material(payload(typedReq))
+				if !validHash(key, typedReq.GetHash(), material) {
+					return ErrInvalidHash
+				}
+				return nil
+			},
+		})
+	}
+}
+
+// payload returns serialized batch or legacy gob metrics, if batch is empty.
+func payload(req *pb.BatchUpdateRequest) []byte {
+	if len(req.GetBatch()) == 0 {
+		return req.GetMetrics()
+	}
+	return req.GetBatch()
+}
+
 func validMethod(method string) bool {
 	return method != pb.Runlytics_BatchUpdate_FullMethodName
 }
 
+func validStreamMethod(method string) bool {
+	return method != pb.Runlytics_StreamUpdates_FullMethodName
+}
+
 func validMD(hash []string) bool {
 	return len(hash) >= 1
 }
@@ -70,14 +142,7 @@ func validHash(key, hashString string, in []byte) bool {
 		return false
 	}
 
-	h, ok := hashPool.Get().(hash.Hash)
-	if !ok {
-		h = hmac.New(sha256.New, []byte(key))
-	} else {
-		h.Reset()
-	}
-	defer hashPool.Put(h)
-
+	h := hmac.New(sha256.New, []byte(key))
 	if _, err := h.Write(in); err != nil {
 		return false
 	}
@@ -0,0 +1,200 @@
+package hashcheck_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/hashcheck"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hash(key string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newKeys(t *testing.T) *keyring.Keyring[string] {
+	t.Helper()
+	keys := keyring.New[string]()
+	require.NoError(t, keys.Add("", "default", time.Time{}))
+	require.NoError(t, keys.Add("k2", "secret2", time.Time{}))
+	require.NoError(t, keys.Add("k1", "secret1", time.Now().Add(-time.Minute)))
+	return keys
+}
+
+func TestNew(t *testing.T) {
+	data := []byte("batch")
+	stamp := replay.NewStamp()
+
+	tests := []struct {
+		name    string
+		method  string
+		md      []string
+		wantErr error
+	}{
+		{
+			name:   "Should pass other methods",
+			method: pb.Runlytics_Update_FullMethodName,
+		},
+		{
+			name: "Should accept hash of default key",
+			md:   []string{"HashSHA256", hash("default", data)},
+		},
+		{
+			name: "Should accept hash of key id",
+			md: []string{
+				"HashSHA256", hash("secret2", data), interceptor.XHashKeyID, "k2",
+			},
+		},
+		{
+			name: "Should accept hash covering stamp",
+			md: []string{
+				"HashSHA256", hash("default", stamp.Material(data)),
+				replay.XTimestamp, stamp.Timestamp(),
+				replay.XNonce, stamp.Nonce,
+			},
+		},
+		{
+			name: "Should reject hash without stamp of stamped batch",
+			md: []string{
+				"HashSHA256", hash("default", data),
+				replay.XTimestamp, stamp.Timestamp(),
+				replay.XNonce, stamp.Nonce,
+			},
+			wantErr: hashcheck.ErrInvalidHash,
+		},
+		{
+			name:    "Should reject hash of other key",
+			md:      []string{"HashSHA256", hash("secret2", data)},
+			wantErr: hashcheck.ErrInvalidHash,
+		},
+		{
+			name: "Should reject expired key",
+			md: []string{
+				"HashSHA256", hash("secret1", data), interceptor.XHashKeyID, "k1",
+			},
+			wantErr: hashcheck.ErrUnknownKey,
+		},
+		{
+			name: "Should reject unknown key",
+			md: []string{
+				"HashSHA256", hash("secret2", data), interceptor.XHashKeyID, "k3",
+			},
+			wantErr: hashcheck.ErrUnknownKey,
+		},
+		{
+			name:    "Should reject batch without hash",
+			wantErr: interceptor.ErrMissingMetadata,
+		},
+	}
+
+	check := hashcheck.New(newKeys(t))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = pb.Runlytics_BatchUpdate_FullMethodName
+			}
+			var h interceptortest.Handler
+			_, err := check(
+				interceptortest.Context(test.md...),
+				&pb.BatchUpdateRequest{Batch: data},
+				interceptortest.Info(method),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, h.Calls)
+		})
+	}
+
+	t.Run("Should pass every batch without keys", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := hashcheck.New(keyring.New[string]())(
+			context.Background(),
+			&pb.BatchUpdateRequest{Batch: data},
+			interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	data := []byte("batch")
+	stamp := replay.NewStamp()
+	valid := &pb.BatchUpdateRequest{
+		Batch:     data,
+		Hash:      hash("secret2", stamp.Material(data)),
+		Timestamp: stamp.Time.UnixMilli(),
+		Nonce:     stamp.Nonce,
+	}
+
+	tests := []struct {
+		name    string
+		keyID   string
+		msgs    []*pb.BatchUpdateRequest
+		wantErr error
+	}{
+		{
+			name:  "Should accept batches of stream key",
+			keyID: "k2",
+			msgs:  []*pb.BatchUpdateRequest{valid, valid},
+		},
+		{
+			name:  "Should fail stream on invalid hash",
+			keyID: "k2",
+			msgs: []*pb.BatchUpdateRequest{
+				valid, {Batch: data, Hash: hash("secret2", []byte("other"))},
+			},
+			wantErr: hashcheck.ErrInvalidHash,
+		},
+		{
+			name:    "Should reject stream of unknown key",
+			keyID:   "k3",
+			msgs:    []*pb.BatchUpdateRequest{valid},
+			wantErr: hashcheck.ErrUnknownKey,
+		},
+	}
+
+	check := hashcheck.NewStream(newKeys(t))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ss := &interceptortest.ServerStream{
+				Ctx:  interceptortest.Context(interceptor.XHashKeyID, test.keyID),
+				Msgs: test.msgs,
+			}
+			var h interceptortest.StreamHandler
+			err := check(
+				nil,
+				ss,
+				interceptortest.StreamInfo(
+					pb.Runlytics_StreamUpdates_FullMethodName,
+				),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, h.Received, len(test.msgs))
+		})
+	}
+}
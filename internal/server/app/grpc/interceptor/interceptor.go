@@ -1,10 +1,22 @@
 package interceptor
 
 import (
+	"context"
+	"strconv"
+
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// Key id metadata, default key is used if not set.
+const (
+	XHashKeyID   = "X-Hash-Key-ID"   // HMAC key id
+	XCryptoKeyID = "X-Crypto-Key-ID" // private key id
+)
+
 var (
 	ErrMissingMetadata = status.Error(
 		codes.InvalidArgument, "missing metadata",
@@ -13,4 +25,35 @@ var (
 	ErrInvalidRequestMessage = status.Error(
 		codes.InvalidArgument, "invalid request message type",
 	)
+
+	ErrInvalidStamp = status.Error(
+		codes.Unauthenticated, replay.ErrInvalidStamp.Error(),
+	)
 )
+
+// MetadataValue returns the first value of incoming metadata name,
+// or empty string.
+func MetadataValue(ctx context.Context, name string) string {
+	values := metadata.ValueFromIncomingContext(ctx, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// MetadataStamp returns replay stamp of BatchUpdate incoming metadata.
+func MetadataStamp(ctx context.Context) (replay.Stamp, error) {
+	return replay.ParseStamp(
+		MetadataValue(ctx, replay.XTimestamp), MetadataValue(ctx, replay.XNonce),
+	)
+}
+
+// RequestStamp returns replay stamp of StreamUpdates batch, where
+// metadata is per stream.
+func RequestStamp(req *pb.BatchUpdateRequest) (replay.Stamp, error) {
+	var timestamp string
+	if req.GetTimestamp() != 0 {
+		timestamp = strconv.FormatInt(req.GetTimestamp(), 10)
+	}
+	return replay.ParseStamp(timestamp, req.GetNonce())
+}
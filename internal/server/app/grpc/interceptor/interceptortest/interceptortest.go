@@ -0,0 +1,86 @@
+// Package interceptortest provides utilities for interceptor testing.
+package interceptortest
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	pb "github.com/niksmo/runlytics/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// Context returns context with incoming metadata of key-value pairs.
+func Context(kv ...string) context.Context {
+	return metadata.NewIncomingContext(
+		context.Background(), metadata.Pairs(kv...),
+	)
+}
+
+// Handler is unary handler recording the last call.
+type Handler struct {
+	Calls int
+	Ctx   context.Context
+	Req   any
+}
+
+func (h *Handler) Handle(ctx context.Context, req any) (any, error) {
+	h.Calls++
+	h.Ctx, h.Req = ctx, req
+	return req, nil
+}
+
+// Info returns unary server info of method.
+func Info(method string) *grpc.UnaryServerInfo {
+	return &grpc.UnaryServerInfo{FullMethod: method}
+}
+
+// StreamInfo returns stream server info of method.
+func StreamInfo(method string) *grpc.StreamServerInfo {
+	return &grpc.StreamServerInfo{FullMethod: method}
+}
+
+// ServerStream is server stream of context receiving Msgs one by one.
+type ServerStream struct {
+	grpc.ServerStream
+	Ctx  context.Context
+	Msgs []*pb.BatchUpdateRequest
+}
+
+func (s *ServerStream) Context() context.Context {
+	return s.Ctx
+}
+
+func (s *ServerStream) RecvMsg(m any) error {
+	if len(s.Msgs) == 0 {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.Msgs[0])
+	s.Msgs = s.Msgs[1:]
+	return nil
+}
+
+// StreamHandler is stream handler receiving every message of stream.
+type StreamHandler struct {
+	Ctx      context.Context
+	Received []*pb.BatchUpdateRequest
+}
+
+// Handle receives messages until the end of stream, end of stream is
+// not an error.
+func (h *StreamHandler) Handle(_ any, ss grpc.ServerStream) error {
+	h.Ctx = ss.Context()
+	for {
+		m := new(pb.BatchUpdateRequest)
+		err := ss.RecvMsg(m)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		h.Received = append(h.Received, m)
+	}
+}
@@ -0,0 +1,122 @@
+// Package limitcheck limits calls of every client, see [ratelimit.Limiter].
+package limitcheck
+
+import (
+	"context"
+	"errors"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/peerid"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/token"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Limits are limiters of methods, nil limiter does not limit calls.
+type Limits map[string]*ratelimit.Limiter
+
+// New limits calls of methods, call over the limit fails with
+// ResourceExhausted status with RetryInfo detail.
+//
+// Client is agent of verified certificate or verified bearer token,
+// or client address of netcheck otherwise. Agent id of batch is not
+// trusted, it is verified after the limit.
+func New(limits Limits) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		limiter := limits[info.FullMethod]
+		if limiter == nil {
+			return handler(ctx, req)
+		}
+		release, err := acquire(ctx, limiter)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// NewStream is [New] for streams, every received batch takes call of
+// the stream method limit. Calls in flight are not limited for streams,
+// where batches are applied one by one.
+func NewStream(limits Limits) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		limiter := limits[info.FullMethod]
+		if limiter == nil {
+			return handler(srv, ss)
+		}
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(any) error {
+				release, err := acquire(ss.Context(), limiter)
+				if err != nil {
+					return err
+				}
+				release()
+				return nil
+			},
+		})
+	}
+}
+
+func acquire(
+	ctx context.Context, limiter *ratelimit.Limiter,
+) (func(), error) {
+	const op = "limitcheck.acquire"
+	key := clientKey(ctx)
+	release, err := limiter.Acquire(key)
+	var limited *ratelimit.LimitedError
+	if !errors.As(err, &limited) {
+		return release, nil
+	}
+
+	logger.Log.Info(
+		"call is rate limited",
+		zap.String("op", op),
+		zap.String("client", key),
+		zap.Duration("retryAfter", limited.RetryAfter),
+	)
+	st, detailsErr := status.New(codes.ResourceExhausted, limited.Error()).
+		WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(limited.RetryAfter),
+		})
+	if detailsErr != nil {
+		return nil, status.Error(codes.ResourceExhausted, limited.Error())
+	}
+	return nil, st.Err()
+}
+
+// clientKey returns rate limit key of call client.
+func clientKey(ctx context.Context) string {
+	if id := peerid.Identity(ctx); id != "" {
+		return "agent:" + id
+	}
+	if t, ok := token.FromContext(ctx); ok {
+		return "token:" + t.ID
+	}
+	addr, ok := netacl.FromContext(ctx)
+	if !ok {
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			addr = netacl.ParseAddr(p.Addr.String())
+		}
+	}
+	return "ip:" + addr.String()
+}
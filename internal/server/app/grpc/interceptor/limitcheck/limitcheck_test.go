@@ -0,0 +1,209 @@
+package limitcheck_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/limitcheck"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/token"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// client describes call client, empty fields are not set.
+type client struct {
+	addr    string
+	tokenID string
+	agent   string
+	agentID string
+}
+
+// context returns call context of client, agent is common name of
+// verified certificate, agentID is X-Agent-ID metadata.
+func (c client) context() context.Context {
+	ctx := interceptortest.Context()
+	if c.agentID != "" {
+		ctx = interceptortest.Context("X-Agent-ID", c.agentID)
+	}
+	if c.addr != "" {
+		ctx = netacl.NewContext(ctx, netip.MustParseAddr(c.addr))
+	}
+	if c.tokenID != "" {
+		ctx = token.NewContext(ctx, token.Token{ID: c.tokenID})
+	}
+	if c.agent != "" {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: c.agent}}
+		ctx = peer.NewContext(ctx, &peer.Peer{
+			AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{cert}},
+			}},
+		})
+	}
+	return ctx
+}
+
+func newLimits() limitcheck.Limits {
+	limit := ratelimit.Limit{Rate: 0.1, Burst: 1}
+	return limitcheck.Limits{
+		pb.Runlytics_BatchUpdate_FullMethodName:   ratelimit.New(limit, 0),
+		pb.Runlytics_StreamUpdates_FullMethodName: ratelimit.New(limit, 0),
+	}
+}
+
+func assertLimited(t *testing.T, err error) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	require.Len(t, st.Details(), 1)
+	info, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	assert.InDelta(
+		t, 10*time.Second, info.GetRetryDelay().AsDuration(),
+		float64(time.Second),
+	)
+}
+
+func TestNew(t *testing.T) {
+	logger.Init("fatal")
+
+	tests := []struct {
+		name        string
+		method      string
+		first       client
+		second      client
+		wantLimited bool
+	}{
+		{
+			name:        "Should limit calls of same address",
+			first:       client{addr: "10.0.0.5"},
+			second:      client{addr: "10.0.0.5"},
+			wantLimited: true,
+		},
+		{
+			name:   "Should not limit calls of other address",
+			first:  client{addr: "10.0.0.5"},
+			second: client{addr: "10.0.0.6"},
+		},
+		{
+			name:        "Should not trust agent id metadata",
+			first:       client{addr: "10.0.0.5", agentID: "host-a"},
+			second:      client{addr: "10.0.0.5", agentID: "host-b"},
+			wantLimited: true,
+		},
+		{
+			name:        "Should limit calls of same token",
+			first:       client{addr: "10.0.0.5", tokenID: "agent"},
+			second:      client{addr: "10.0.0.6", tokenID: "agent"},
+			wantLimited: true,
+		},
+		{
+			name:   "Should not limit calls of other token",
+			first:  client{addr: "10.0.0.5", tokenID: "agent"},
+			second: client{addr: "10.0.0.5", tokenID: "other"},
+		},
+		{
+			name: "Should limit calls of same certificate",
+			first: client{
+				addr: "10.0.0.5", tokenID: "agent", agent: "host-a",
+			},
+			second: client{
+				addr: "10.0.0.6", tokenID: "other", agent: "host-a",
+			},
+			wantLimited: true,
+		},
+		{
+			name:   "Should not limit calls of other certificate",
+			first:  client{addr: "10.0.0.5", agent: "host-a"},
+			second: client{addr: "10.0.0.5", agent: "host-b"},
+		},
+		{
+			name:   "Should not limit method without limiter",
+			method: pb.Runlytics_Update_FullMethodName,
+			first:  client{addr: "10.0.0.5"},
+			second: client{addr: "10.0.0.5"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = pb.Runlytics_BatchUpdate_FullMethodName
+			}
+			check := limitcheck.New(newLimits())
+			var h interceptortest.Handler
+			for _, c := range []client{test.first, test.second} {
+				_, err := check(
+					c.context(),
+					&pb.BatchUpdateRequest{},
+					interceptortest.Info(method),
+					h.Handle,
+				)
+				if err != nil {
+					assertLimited(t, err)
+				}
+			}
+			if test.wantLimited {
+				assert.Equal(t, 1, h.Calls)
+				return
+			}
+			assert.Equal(t, 2, h.Calls)
+		})
+	}
+
+	t.Run("Should release call when it is done", func(t *testing.T) {
+		check := limitcheck.New(limitcheck.Limits{
+			pb.Runlytics_BatchUpdate_FullMethodName: ratelimit.New(
+				ratelimit.Limit{}, 1,
+			),
+		})
+		var h interceptortest.Handler
+		for range 2 {
+			_, err := check(
+				client{addr: "10.0.0.5"}.context(),
+				&pb.BatchUpdateRequest{},
+				interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+				h.Handle,
+			)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	logger.Init("fatal")
+
+	check := limitcheck.NewStream(newLimits())
+	ss := &interceptortest.ServerStream{
+		Ctx: client{addr: "10.0.0.5"}.context(),
+		Msgs: []*pb.BatchUpdateRequest{
+			{Batch: []byte("first")}, {Batch: []byte("second")},
+		},
+	}
+	var h interceptortest.StreamHandler
+	err := check(
+		nil,
+		ss,
+		interceptortest.StreamInfo(pb.Runlytics_StreamUpdates_FullMethodName),
+		h.Handle,
+	)
+	assertLimited(t, err)
+	assert.Len(t, h.Received, 1)
+}
@@ -14,6 +14,10 @@ func WithLog() grpc.UnaryServerInterceptor {
 	return logging.UnaryServerInterceptor(InterceptorLogger(logger.Log))
 }
 
+func WithStreamLog() grpc.StreamServerInterceptor {
+	return logging.StreamServerInterceptor(InterceptorLogger(logger.Log))
+}
+
 func InterceptorLogger(l *zap.Logger) logging.Logger {
 	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, fields ...any) {
 		logger.Log.Sugar().Logw(zapcore.Level(lvl), msg, fields...)
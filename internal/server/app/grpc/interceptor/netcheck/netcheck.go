@@ -1,57 +1,122 @@
+// Package netcheck allows calls by client network, see [netacl.Policy].
 package netcheck
 
 import (
 	"context"
-	"net"
+	"net/netip"
 
 	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	pb "github.com/niksmo/runlytics/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 )
 
-const xRealIP = "X-Real-IP"
+const (
+	xRealIP       = "X-Real-IP"
+	xForwardedFor = "X-Forwarded-For"
+)
 
 var ErrNotAllowedIP = status.Error(
 	codes.Unauthenticated, "not allowed zone",
 )
 
-func New(ipNet *net.IPNet) grpc.UnaryServerInterceptor {
+var ErrTrustedNetNotSet = status.Error(
+	codes.PermissionDenied, "trusted subnet is not configured",
+)
+
+// methodRoutes are route classes of methods, methods out of the list,
+// e.g. reflection, use default ACL of policy.
+var methodRoutes = map[string]string{
+	pb.Runlytics_BatchUpdate_FullMethodName:   netacl.RouteWrite,
+	pb.Runlytics_StreamUpdates_FullMethodName: netacl.RouteWrite,
+	pb.Runlytics_Update_FullMethodName:        netacl.RouteWrite,
+	pb.Runlytics_Value_FullMethodName:         netacl.RouteRead,
+	pb.Runlytics_List_FullMethodName:          netacl.RouteRead,
+	pb.Runlytics_Range_FullMethodName:         netacl.RouteRead,
+	pb.Runlytics_Delete_FullMethodName:        netacl.RouteAdmin,
+	pb.Runlytics_Ping_FullMethodName:          netacl.RoutePing,
+	healthpb.Health_Check_FullMethodName:      netacl.RoutePing,
+	healthpb.Health_Watch_FullMethodName:      netacl.RoutePing,
+	healthpb.Health_List_FullMethodName:       netacl.RoutePing,
+}
+
+// New allows call from client address of the method route ACL and
+// passes the address to handler context, see [netacl.FromContext].
+//
+// Client address is the peer address, X-Real-IP and X-Forwarded-For
+// metadata are honored only for peers from proxies.
+func New(policy netacl.Policy, proxies *netacl.ACL) grpc.UnaryServerInterceptor {
 	return func(
 		ctx context.Context,
 		req any,
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (any, error) {
-		if ipNet == nil {
-			return handler(ctx, req)
-		}
-
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, interceptor.ErrMissingMetadata
-		}
-
-		if !validMD(md.Get(xRealIP)) {
-			return nil, interceptor.ErrMissingMetadata
+		ctx, err := check(ctx, policy, proxies, info.FullMethod)
+		if err != nil {
+			return nil, err
 		}
-
-		clientIP := md.Get(xRealIP)[0]
-		if !validIPNet(clientIP, ipNet) {
-			return nil, ErrNotAllowedIP
-		}
-
 		return handler(ctx, req)
 	}
 }
 
-func validMD(ip []string) bool {
-	return len(ip) >= 1
+// NewStream is [New] for streams, client address is checked on stream
+// start.
+func NewStream(
+	policy netacl.Policy, proxies *netacl.ACL,
+) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := check(ss.Context(), policy, proxies, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(
+			srv, interceptor.ContextStream{ServerStream: ss, Ctx: ctx},
+		)
+	}
 }
 
-func validIPNet(cIP string, ipNet *net.IPNet) bool {
-	ip := net.ParseIP(cIP)
-	return ipNet.Contains(ip)
+// check returns context with client address or error, if the address
+// is not allowed. Empty ACL denies any address.
+func check(
+	ctx context.Context,
+	policy netacl.Policy,
+	proxies *netacl.ACL,
+	method string,
+) (context.Context, error) {
+	var peerAddr netip.Addr
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = netacl.ParseAddr(p.Addr.String())
+	}
+	addr := netacl.ClientAddr(
+		peerAddr,
+		proxies,
+		interceptor.MetadataValue(ctx, xRealIP),
+		interceptor.MetadataValue(ctx, xForwardedFor),
+	)
+	ctx = netacl.NewContext(ctx, addr)
 
+	route, ok := methodRoutes[method]
+	acl := policy.Default
+	if ok {
+		acl = policy.ACL(route)
+	}
+	switch {
+	case acl == nil:
+		return ctx, nil
+	case acl.Len() == 0:
+		return nil, ErrTrustedNetNotSet
+	case !addr.IsValid() || !acl.Contains(addr):
+		return nil, ErrNotAllowedIP
+	}
+	return ctx, nil
 }
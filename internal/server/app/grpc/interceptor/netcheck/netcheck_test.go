@@ -0,0 +1,183 @@
+package netcheck_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/netcheck"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+func parseACL(t *testing.T, s string) *netacl.ACL {
+	t.Helper()
+	acl, err := netacl.ParseACL(s)
+	require.NoError(t, err)
+	return acl
+}
+
+// peerContext returns context of peer address with metadata of
+// key-value pairs.
+func peerContext(addr string, kv ...string) context.Context {
+	return peer.NewContext(
+		interceptortest.Context(kv...),
+		&peer.Peer{Addr: net.TCPAddrFromAddrPort(
+			netip.AddrPortFrom(netip.MustParseAddr(addr), 40000),
+		)},
+	)
+}
+
+func TestNew(t *testing.T) {
+	policy := netacl.Policy{
+		Default: parseACL(t, "10.0.0.0/8"),
+		Routes: map[string]*netacl.ACL{
+			netacl.RoutePing:  nil,
+			netacl.RouteRead:  parseACL(t, "192.168.0.0/16"),
+			netacl.RouteAdmin: {},
+		},
+	}
+	proxies := parseACL(t, "172.16.0.1")
+
+	tests := []struct {
+		name     string
+		method   string
+		ctx      context.Context
+		wantAddr string
+		wantErr  error
+	}{
+		{
+			name:     "Should allow address of route ACL",
+			method:   pb.Runlytics_Value_FullMethodName,
+			ctx:      peerContext("192.168.1.5"),
+			wantAddr: "192.168.1.5",
+		},
+		{
+			name:    "Should deny address out of route ACL",
+			method:  pb.Runlytics_Value_FullMethodName,
+			ctx:     peerContext("10.0.0.5"),
+			wantErr: netcheck.ErrNotAllowedIP,
+		},
+		{
+			name:     "Should use default ACL of route without own ACL",
+			method:   pb.Runlytics_BatchUpdate_FullMethodName,
+			ctx:      peerContext("10.0.0.5"),
+			wantAddr: "10.0.0.5",
+		},
+		{
+			name:     "Should use default ACL of unknown method",
+			method:   "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			ctx:      peerContext("10.0.0.5"),
+			wantAddr: "10.0.0.5",
+		},
+		{
+			name:    "Should deny unknown method out of default ACL",
+			method:  "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+			ctx:     peerContext("192.168.1.5"),
+			wantErr: netcheck.ErrNotAllowedIP,
+		},
+		{
+			name:     "Should allow any address of nil ACL",
+			method:   pb.Runlytics_Ping_FullMethodName,
+			ctx:      peerContext("8.8.8.8"),
+			wantAddr: "8.8.8.8",
+		},
+		{
+			name:     "Should allow health list by ping ACL",
+			method:   healthpb.Health_List_FullMethodName,
+			ctx:      peerContext("8.8.8.8"),
+			wantAddr: "8.8.8.8",
+		},
+		{
+			name:    "Should deny any address of empty ACL",
+			method:  pb.Runlytics_Delete_FullMethodName,
+			ctx:     peerContext("10.0.0.5"),
+			wantErr: netcheck.ErrTrustedNetNotSet,
+		},
+		{
+			name:     "Should honor X-Real-IP of proxy",
+			method:   pb.Runlytics_Update_FullMethodName,
+			ctx:      peerContext("172.16.0.1", "X-Real-IP", "10.0.0.7"),
+			wantAddr: "10.0.0.7",
+		},
+		{
+			name:   "Should honor X-Forwarded-For of proxy",
+			method: pb.Runlytics_Update_FullMethodName,
+			ctx: peerContext(
+				"172.16.0.1", "X-Forwarded-For", "8.8.8.8, 10.0.0.7",
+			),
+			wantAddr: "10.0.0.7",
+		},
+		{
+			name:    "Should ignore X-Real-IP of other peer",
+			method:  pb.Runlytics_Update_FullMethodName,
+			ctx:     peerContext("192.168.1.5", "X-Real-IP", "10.0.0.7"),
+			wantErr: netcheck.ErrNotAllowedIP,
+		},
+		{
+			name:    "Should deny call without peer",
+			method:  pb.Runlytics_Update_FullMethodName,
+			ctx:     interceptortest.Context(),
+			wantErr: netcheck.ErrNotAllowedIP,
+		},
+	}
+
+	check := netcheck.New(policy, proxies)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h interceptortest.Handler
+			_, err := check(
+				test.ctx,
+				&pb.BatchUpdateRequest{},
+				interceptortest.Info(test.method),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1, h.Calls)
+			addr, ok := netacl.FromContext(h.Ctx)
+			require.True(t, ok)
+			assert.Equal(t, netip.MustParseAddr(test.wantAddr), addr)
+		})
+	}
+}
+
+func TestNewStream(t *testing.T) {
+	policy := netacl.Policy{Default: parseACL(t, "10.0.0.0/8")}
+	check := netcheck.NewStream(policy, nil)
+	info := interceptortest.StreamInfo(pb.Runlytics_StreamUpdates_FullMethodName)
+
+	t.Run("Should allow stream of allowed address", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx:  peerContext("10.0.0.5"),
+			Msgs: []*pb.BatchUpdateRequest{{Batch: []byte("batch")}},
+		}
+		var h interceptortest.StreamHandler
+		require.NoError(t, check(nil, ss, info, h.Handle))
+		assert.Len(t, h.Received, 1)
+		addr, ok := netacl.FromContext(h.Ctx)
+		require.True(t, ok)
+		assert.Equal(t, netip.MustParseAddr("10.0.0.5"), addr)
+	})
+
+	t.Run("Should deny stream of other address", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx:  peerContext("192.168.1.5"),
+			Msgs: []*pb.BatchUpdateRequest{{Batch: []byte("batch")}},
+		}
+		var h interceptortest.StreamHandler
+		err := check(nil, ss, info, h.Handle)
+		assert.ErrorIs(t, err, netcheck.ErrNotAllowedIP)
+		assert.Empty(t, h.Received)
+	})
+}
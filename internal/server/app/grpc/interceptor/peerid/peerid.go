@@ -0,0 +1,79 @@
+// Package peerid binds batch agent id to verified client certificate.
+package peerid
+
+import (
+	"context"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/tlsconf"
+	"github.com/niksmo/runlytics/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var ErrAgentIDMismatch = status.Error(
+	codes.PermissionDenied, "agent id does not match client certificate",
+)
+
+// New rejects batch with agent id other than subject common name of
+// verified client certificate. Without verified certificate batch is
+// passed as is.
+func New() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if err := check(ctx, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream is [New] for streams, every received batch is checked.
+func NewStream() grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(m any) error {
+				return check(ss.Context(), m)
+			},
+		})
+	}
+}
+
+func check(ctx context.Context, req any) error {
+	r, ok := req.(*proto.BatchUpdateRequest)
+	if !ok {
+		return nil
+	}
+	id, agentID := Identity(ctx), r.GetAgentId()
+	if id != "" && agentID != "" && agentID != id {
+		return ErrAgentIDMismatch
+	}
+	return nil
+}
+
+// Identity returns subject common name of verified client certificate
+// of ctx peer, or empty string.
+func Identity(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ""
+	}
+	return tlsconf.PeerIdentity(&info.State)
+}
@@ -0,0 +1,116 @@
+package peerid_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/peerid"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// peerContext returns context of peer with client certificate of
+// common name, the certificate is verified if verified is true.
+func peerContext(commonName string, verified bool) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+	if verified {
+		state.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		req     any
+		wantErr error
+	}{
+		{
+			name: "Should accept agent id of certificate",
+			ctx:  peerContext("host-a", true),
+			req:  &pb.BatchUpdateRequest{AgentId: "host-a"},
+		},
+		{
+			name:    "Should reject agent id of other certificate",
+			ctx:     peerContext("host-b", true),
+			req:     &pb.BatchUpdateRequest{AgentId: "host-a"},
+			wantErr: peerid.ErrAgentIDMismatch,
+		},
+		{
+			name: "Should accept batch without agent id",
+			ctx:  peerContext("host-b", true),
+			req:  &pb.BatchUpdateRequest{},
+		},
+		{
+			name: "Should pass batch of not verified certificate",
+			ctx:  peerContext("host-b", false),
+			req:  &pb.BatchUpdateRequest{AgentId: "host-a"},
+		},
+		{
+			name: "Should pass batch without peer",
+			ctx:  context.Background(),
+			req:  &pb.BatchUpdateRequest{AgentId: "host-a"},
+		},
+		{
+			name: "Should pass other requests",
+			ctx:  peerContext("host-b", true),
+			req:  &pb.PingRequest{},
+		},
+	}
+
+	check := peerid.New()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h interceptortest.Handler
+			_, err := check(
+				test.ctx,
+				test.req,
+				interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, h.Calls)
+		})
+	}
+}
+
+func TestNewStream(t *testing.T) {
+	check := peerid.NewStream()
+	ss := &interceptortest.ServerStream{
+		Ctx: peerContext("host-a", true),
+		Msgs: []*pb.BatchUpdateRequest{
+			{AgentId: "host-a"}, {AgentId: "host-b"},
+		},
+	}
+	var h interceptortest.StreamHandler
+	err := check(
+		nil,
+		ss,
+		interceptortest.StreamInfo(pb.Runlytics_StreamUpdates_FullMethodName),
+		h.Handle,
+	)
+	assert.ErrorIs(t, err, peerid.ErrAgentIDMismatch)
+	assert.Len(t, h.Received, 1)
+}
+
+func TestIdentity(t *testing.T) {
+	assert.Equal(t, "host-a", peerid.Identity(peerContext("host-a", true)))
+	assert.Empty(t, peerid.Identity(peerContext("host-a", false)))
+	assert.Empty(t, peerid.Identity(context.Background()))
+}
@@ -10,9 +10,15 @@ import (
 )
 
 func WithRecovery() grpc.UnaryServerInterceptor {
-	return recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(func(p any) (err error) {
-		logger.Log.Error("Recovered from panic", zap.Any("panic", p))
+	return recovery.UnaryServerInterceptor(recovery.WithRecoveryHandler(recoveryHandler))
+}
+
+func WithStreamRecovery() grpc.StreamServerInterceptor {
+	return recovery.StreamServerInterceptor(recovery.WithRecoveryHandler(recoveryHandler))
+}
+
+func recoveryHandler(p any) (err error) {
+	logger.Log.Error("Recovered from panic", zap.Any("panic", p))
 
-		return status.Errorf(codes.Internal, "internal error")
-	}))
+	return status.Errorf(codes.Internal, "internal error")
 }
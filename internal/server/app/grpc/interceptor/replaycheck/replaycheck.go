@@ -0,0 +1,89 @@
+// Package replaycheck rejects replayed batches.
+package replaycheck
+
+import (
+	"context"
+	"errors"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrStaleStamp = status.Error(
+		codes.Unauthenticated, replay.ErrStaleStamp.Error(),
+	)
+	ErrReplayed = status.Error(codes.Unauthenticated, replay.ErrReplayed.Error())
+)
+
+// New accepts BatchUpdate batch with stamp of [replay.XTimestamp] and
+// [replay.XNonce] metadata once within guard window.
+// Batches are not checked if guard is nil.
+func New(guard *replay.Guard) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if guard == nil ||
+			info.FullMethod != pb.Runlytics_BatchUpdate_FullMethodName {
+			return handler(ctx, req)
+		}
+		stamp, err := interceptor.MetadataStamp(ctx)
+		if err != nil {
+			return nil, interceptor.ErrInvalidStamp
+		}
+		if err := check(guard, stamp); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream accepts every batch received by StreamUpdates with
+// stamp of request fields once within guard window.
+func NewStream(guard *replay.Guard) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if guard == nil ||
+			info.FullMethod != pb.Runlytics_StreamUpdates_FullMethodName {
+			return handler(srv, ss)
+		}
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(m any) error {
+				typedReq, ok := m.(*pb.BatchUpdateRequest)
+				if !ok {
+					return interceptor.ErrInvalidRequestMessage
+				}
+				stamp, err := interceptor.RequestStamp(typedReq)
+				if err != nil {
+					return interceptor.ErrInvalidStamp
+				}
+				return check(guard, stamp)
+			},
+		})
+	}
+}
+
+func check(guard *replay.Guard, stamp replay.Stamp) error {
+	err := guard.Check(stamp)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, replay.ErrReplayed):
+		return ErrReplayed
+	case errors.Is(err, replay.ErrStaleStamp):
+		return ErrStaleStamp
+	}
+	return interceptor.ErrInvalidStamp
+}
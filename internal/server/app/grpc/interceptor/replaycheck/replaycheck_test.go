@@ -0,0 +1,167 @@
+package replaycheck_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/replaycheck"
+	"github.com/niksmo/runlytics/pkg/replay"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stampContext(stamp replay.Stamp) context.Context {
+	return interceptortest.Context(
+		replay.XTimestamp, stamp.Timestamp(), replay.XNonce, stamp.Nonce,
+	)
+}
+
+func stamped(stamp replay.Stamp) *pb.BatchUpdateRequest {
+	return &pb.BatchUpdateRequest{
+		Batch:     []byte("batch"),
+		Timestamp: stamp.Time.UnixMilli(),
+		Nonce:     stamp.Nonce,
+	}
+}
+
+func TestNew(t *testing.T) {
+	used := replay.NewStamp()
+	stale := replay.NewStamp()
+	stale.Time = stale.Time.Add(-2 * time.Minute)
+
+	tests := []struct {
+		name    string
+		method  string
+		ctx     context.Context
+		wantErr error
+	}{
+		{
+			name: "Should accept stamped batch",
+			ctx:  stampContext(replay.NewStamp()),
+		},
+		{
+			name:    "Should reject replayed batch",
+			ctx:     stampContext(used),
+			wantErr: replaycheck.ErrReplayed,
+		},
+		{
+			name:    "Should reject stale batch",
+			ctx:     stampContext(stale),
+			wantErr: replaycheck.ErrStaleStamp,
+		},
+		{
+			name:    "Should reject not stamped batch",
+			ctx:     interceptortest.Context(),
+			wantErr: interceptor.ErrInvalidStamp,
+		},
+		{
+			name:    "Should reject invalid stamp",
+			ctx:     interceptortest.Context(replay.XTimestamp, "now"),
+			wantErr: interceptor.ErrInvalidStamp,
+		},
+		{
+			name:   "Should pass other methods",
+			method: pb.Runlytics_Update_FullMethodName,
+			ctx:    interceptortest.Context(),
+		},
+	}
+
+	guard := replay.NewGuard(time.Minute, 100)
+	require.NoError(t, guard.Check(used))
+	check := replaycheck.New(guard)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			method := test.method
+			if method == "" {
+				method = pb.Runlytics_BatchUpdate_FullMethodName
+			}
+			var h interceptortest.Handler
+			_, err := check(
+				test.ctx,
+				&pb.BatchUpdateRequest{Batch: []byte("batch")},
+				interceptortest.Info(method),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, h.Calls)
+		})
+	}
+
+	t.Run("Should pass every batch without guard", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := replaycheck.New(nil)(
+			interceptortest.Context(),
+			&pb.BatchUpdateRequest{Batch: []byte("batch")},
+			interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	first, second := replay.NewStamp(), replay.NewStamp()
+
+	tests := []struct {
+		name     string
+		msgs     []*pb.BatchUpdateRequest
+		wantErr  error
+		received int
+	}{
+		{
+			name:     "Should accept batches of different stamps",
+			msgs:     []*pb.BatchUpdateRequest{stamped(first), stamped(second)},
+			received: 2,
+		},
+		{
+			name: "Should fail stream on replayed batch",
+			msgs: []*pb.BatchUpdateRequest{
+				stamped(replay.NewStamp()), stamped(first),
+			},
+			wantErr:  replaycheck.ErrReplayed,
+			received: 1,
+		},
+		{
+			name: "Should fail stream on not stamped batch",
+			msgs: []*pb.BatchUpdateRequest{
+				{Batch: []byte("batch")},
+			},
+			wantErr: interceptor.ErrInvalidStamp,
+		},
+	}
+
+	check := replaycheck.NewStream(replay.NewGuard(time.Minute, 100))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ss := &interceptortest.ServerStream{
+				Ctx:  context.Background(),
+				Msgs: test.msgs,
+			}
+			var h interceptortest.StreamHandler
+			err := check(
+				nil,
+				ss,
+				interceptortest.StreamInfo(
+					pb.Runlytics_StreamUpdates_FullMethodName,
+				),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Len(t, h.Received, test.received)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package signcheck verifies per-agent Ed25519 batch signatures.
+package signcheck
+
+import (
+	"context"
+	"errors"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
+	pb "github.com/niksmo/runlytics/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	ErrMissingSignature = status.Error(
+		codes.Unauthenticated, "missing agent id or signature",
+	)
+	ErrInvalidSignature = status.Error(
+		codes.Unauthenticated, "invalid signature",
+	)
+	ErrAgentNotAllowed = status.Error(
+		codes.PermissionDenied, "unknown or revoked agent key",
+	)
+	ErrAgentKey = status.Error(codes.Internal, "failed to get agent key")
+	ErrUnsigned = status.Error(
+		codes.PermissionDenied, "signed batch is required",
+	)
+)
+
+// New verifies signature of BatchUpdate batch with public key of
+// batch agent. Update request can not be signed, so it is rejected
+// while signatures are required. Verification is disabled if keys is nil.
+func New(keys di.IAgentKeyStorage) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		if keys == nil {
+			return handler(ctx, req)
+		}
+		switch info.FullMethod {
+		case pb.Runlytics_BatchUpdate_FullMethodName:
+		case pb.Runlytics_Update_FullMethodName:
+			return nil, ErrUnsigned
+		default:
+			return handler(ctx, req)
+		}
+		stamp, err := interceptor.MetadataStamp(ctx)
+		if err != nil {
+			return nil, interceptor.ErrInvalidStamp
+		}
+		if err := verify(ctx, keys, req, stamp); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream verifies signature of every batch received by StreamUpdates.
+func NewStream(keys di.IAgentKeyStorage) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if keys == nil ||
+			info.FullMethod != pb.Runlytics_StreamUpdates_FullMethodName {
+			return handler(srv, ss)
+		}
+		return handler(srv, &interceptor.RecvStream{
+			ServerStream: ss,
+			OnRecv: func(m any) error {
+				typedReq, ok := m.(*pb.BatchUpdateRequest)
+				if !ok {
+					return interceptor.ErrInvalidRequestMessage
+				}
+				stamp, err := interceptor.RequestStamp(typedReq)
+				if err != nil {
+					return interceptor.ErrInvalidStamp
+				}
+				return verify(ss.Context(), keys, typedReq, stamp)
+			},
+		})
+	}
+}
+
+// verify verifies signature of batch payload, stamp is signed
+// with the payload, see [replay.Stamp.Material].
+func verify(
+	ctx context.Context,
+	keys di.IAgentKeyStorage,
+	req any,
+	stamp replay.Stamp,
+) error {
+	const op = "signcheck.verify"
+
+	r, ok := req.(*pb.BatchUpdateRequest)
+	if !ok {
+		return interceptor.ErrInvalidRequestMessage
+	}
+	if r.GetAgentId() == "" || len(r.GetSignature()) == 0 {
+		return ErrMissingSignature
+	}
+
+	key, err := keys.AgentKey(ctx, r.GetAgentId())
+	if err != nil {
+		if errors.Is(err, sign.ErrUnknownAgent) ||
+			errors.Is(err, sign.ErrRevokedAgent) {
+			return ErrAgentNotAllowed
+		}
+		logger.Log.Error(
+			"failed to get agent key",
+			zap.String("op", op),
+			zap.String("agentID", r.GetAgentId()),
+			zap.Error(err),
+		)
+		return ErrAgentKey
+	}
+
+	err = sign.Verify(
+		key,
+		r.GetAgentId(),
+		r.GetSeq(),
+		stamp.Material(payload(r)),
+		r.GetSignature(),
+	)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// payload returns serialized batch or legacy gob metrics, if batch is empty.
+func payload(req *pb.BatchUpdateRequest) []byte {
+	if len(req.GetBatch()) == 0 {
+		return req.GetMetrics()
+	}
+	return req.GetBatch()
+}
@@ -0,0 +1,234 @@
+package signcheck_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/signcheck"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"github.com/niksmo/runlytics/pkg/sign"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// agentKeys is agent keys registry, nil key is revoked,
+// "broken" agent fails the storage.
+type agentKeys map[string]ed25519.PublicKey
+
+func (k agentKeys) AgentKey(
+	_ context.Context, agentID string,
+) (ed25519.PublicKey, error) {
+	key, ok := k[agentID]
+	switch {
+	case agentID == "broken":
+		return nil, errors.New("storage is unavailable")
+	case !ok:
+		return nil, fmt.Errorf("%w '%s'", sign.ErrUnknownAgent, agentID)
+	case key == nil:
+		return nil, fmt.Errorf("%w '%s'", sign.ErrRevokedAgent, agentID)
+	}
+	return key, nil
+}
+
+func signed(
+	key ed25519.PrivateKey, agentID string, stamp replay.Stamp,
+) *pb.BatchUpdateRequest {
+	req := &pb.BatchUpdateRequest{
+		Batch: []byte("batch"), AgentId: agentID, Seq: 7,
+	}
+	req.Signature = sign.Sign(key, agentID, req.Seq, stamp.Material(req.Batch))
+	return req
+}
+
+func TestNew(t *testing.T) {
+	logger.Init("fatal")
+
+	publicA, privateA, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, privateB, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keys := agentKeys{"host-a": publicA, "host-b": nil}
+	stamp := replay.NewStamp()
+
+	changedSeq := signed(privateA, "host-a", replay.Stamp{})
+	changedSeq.Seq++
+
+	tests := []struct {
+		name    string
+		req     *pb.BatchUpdateRequest
+		stamp   replay.Stamp
+		wantErr error
+	}{
+		{
+			name: "Should accept batch signed by agent key",
+			req:  signed(privateA, "host-a", replay.Stamp{}),
+		},
+		{
+			name:  "Should accept signature covering stamp",
+			req:   signed(privateA, "host-a", stamp),
+			stamp: stamp,
+		},
+		{
+			name:    "Should reject signature without stamp of stamped batch",
+			req:     signed(privateA, "host-a", replay.Stamp{}),
+			stamp:   stamp,
+			wantErr: signcheck.ErrInvalidSignature,
+		},
+		{
+			name:    "Should reject batch signed by other key",
+			req:     signed(privateB, "host-a", replay.Stamp{}),
+			wantErr: signcheck.ErrInvalidSignature,
+		},
+		{
+			name:    "Should reject signature of other seq",
+			req:     changedSeq,
+			wantErr: signcheck.ErrInvalidSignature,
+		},
+		{
+			name: "Should reject not signed batch",
+			req: &pb.BatchUpdateRequest{
+				Batch: []byte("batch"), AgentId: "host-a",
+			},
+			wantErr: signcheck.ErrMissingSignature,
+		},
+		{
+			name:    "Should reject batch of revoked agent",
+			req:     signed(privateB, "host-b", replay.Stamp{}),
+			wantErr: signcheck.ErrAgentNotAllowed,
+		},
+		{
+			name:    "Should reject batch of unknown agent",
+			req:     signed(privateB, "host-c", replay.Stamp{}),
+			wantErr: signcheck.ErrAgentNotAllowed,
+		},
+		{
+			name:    "Should fail on storage error",
+			req:     signed(privateB, "broken", replay.Stamp{}),
+			wantErr: signcheck.ErrAgentKey,
+		},
+	}
+
+	check := signcheck.New(keys)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var md []string
+			if !test.stamp.IsZero() {
+				md = []string{
+					replay.XTimestamp, test.stamp.Timestamp(),
+					replay.XNonce, test.stamp.Nonce,
+				}
+			}
+			var h interceptortest.Handler
+			_, err := check(
+				interceptortest.Context(md...),
+				test.req,
+				interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, h.Calls)
+		})
+	}
+
+	t.Run("Should reject invalid stamp", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := check(
+			interceptortest.Context(replay.XTimestamp, "now"),
+			signed(privateA, "host-a", replay.Stamp{}),
+			interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+			h.Handle,
+		)
+		assert.ErrorIs(t, err, interceptor.ErrInvalidStamp)
+	})
+
+	t.Run("Should reject single metric update", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := check(
+			interceptortest.Context(),
+			&pb.UpdateRequest{},
+			interceptortest.Info(pb.Runlytics_Update_FullMethodName),
+			h.Handle,
+		)
+		assert.ErrorIs(t, err, signcheck.ErrUnsigned)
+		assert.Zero(t, h.Calls)
+	})
+
+	t.Run("Should pass other methods", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := check(
+			interceptortest.Context(),
+			&pb.ValueRequest{},
+			interceptortest.Info(pb.Runlytics_Value_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+
+	t.Run("Should pass update without keys", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := signcheck.New(nil)(
+			interceptortest.Context(),
+			&pb.UpdateRequest{},
+			interceptortest.Info(pb.Runlytics_Update_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	logger.Init("fatal")
+
+	publicA, privateA, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	_, privateB, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	check := signcheck.NewStream(agentKeys{"host-a": publicA})
+	info := interceptortest.StreamInfo(pb.Runlytics_StreamUpdates_FullMethodName)
+
+	stampedReq := func(key ed25519.PrivateKey) *pb.BatchUpdateRequest {
+		stamp := replay.NewStamp()
+		req := signed(key, "host-a", stamp)
+		req.Timestamp, req.Nonce = stamp.Time.UnixMilli(), stamp.Nonce
+		return req
+	}
+
+	t.Run("Should accept signed batches", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx: context.Background(),
+			Msgs: []*pb.BatchUpdateRequest{
+				stampedReq(privateA), stampedReq(privateA),
+			},
+		}
+		var h interceptortest.StreamHandler
+		require.NoError(t, check(nil, ss, info, h.Handle))
+		assert.Len(t, h.Received, 2)
+	})
+
+	t.Run("Should fail stream on invalid signature", func(t *testing.T) {
+		ss := &interceptortest.ServerStream{
+			Ctx: context.Background(),
+			Msgs: []*pb.BatchUpdateRequest{
+				stampedReq(privateA), stampedReq(privateB),
+			},
+		}
+		var h interceptortest.StreamHandler
+		err := check(nil, ss, info, h.Handle)
+		assert.ErrorIs(t, err, signcheck.ErrInvalidSignature)
+		assert.Len(t, h.Received, 1)
+	})
+}
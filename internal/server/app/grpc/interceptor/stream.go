@@ -0,0 +1,31 @@
+package interceptor
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// RecvStream wraps server stream and passes every received message
+// to OnRecv, the stream fails with OnRecv error.
+type RecvStream struct {
+	grpc.ServerStream
+	OnRecv func(m any) error
+}
+
+func (s *RecvStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.OnRecv(m)
+}
+
+// ContextStream is server stream with context of interceptor.
+type ContextStream struct {
+	grpc.ServerStream
+	Ctx context.Context
+}
+
+func (s ContextStream) Context() context.Context {
+	return s.Ctx
+}
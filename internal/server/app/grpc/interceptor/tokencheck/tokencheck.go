@@ -0,0 +1,133 @@
+// Package tokencheck authorizes calls by scoped bearer tokens.
+package tokencheck
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/token"
+	pb "github.com/niksmo/runlytics/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorization = "authorization"
+	bearerPrefix  = "Bearer "
+)
+
+var (
+	ErrMissingToken = status.Error(codes.Unauthenticated, "require bearer token")
+	ErrInvalidToken = status.Error(codes.Unauthenticated, "invalid token")
+	ErrScope        = status.Error(
+		codes.PermissionDenied, "insufficient token scope",
+	)
+	ErrTokenStorage = status.Error(codes.Internal, "failed to get token")
+)
+
+// publicMethods are health checks, which are not authorized.
+var publicMethods = map[string]bool{
+	pb.Runlytics_Ping_FullMethodName:     true,
+	healthpb.Health_Check_FullMethodName: true,
+	healthpb.Health_Watch_FullMethodName: true,
+	healthpb.Health_List_FullMethodName:  true,
+}
+
+// methodScopes are token scopes of methods, methods neither in the list
+// nor in publicMethods, e.g. reflection, require admin scope.
+var methodScopes = map[string]token.Scope{
+	pb.Runlytics_BatchUpdate_FullMethodName:   token.ScopeWrite,
+	pb.Runlytics_StreamUpdates_FullMethodName: token.ScopeWrite,
+	pb.Runlytics_Update_FullMethodName:        token.ScopeWrite,
+	pb.Runlytics_Value_FullMethodName:         token.ScopeRead,
+	pb.Runlytics_List_FullMethodName:          token.ScopeRead,
+	pb.Runlytics_Range_FullMethodName:         token.ScopeRead,
+	pb.Runlytics_Delete_FullMethodName:        token.ScopeAdmin,
+}
+
+// New allows call with authorization metadata bearer token granting
+// scope of the method. Calls are not authorized if tokens is nil.
+func New(tokens di.ITokenStorage) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req any,
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (any, error) {
+		ctx, err := authorize(ctx, tokens, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream is [New] for streams, the token is checked once per stream.
+func NewStream(tokens di.ITokenStorage) grpc.StreamServerInterceptor {
+	return func(
+		srv any,
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authorize(ss.Context(), tokens, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(
+			srv, interceptor.ContextStream{ServerStream: ss, Ctx: ctx},
+		)
+	}
+}
+
+// authorize returns context with verified token, see [token.FromContext].
+func authorize(
+	ctx context.Context, tokens di.ITokenStorage, method string,
+) (context.Context, error) {
+	const op = "tokencheck.authorize"
+	if tokens == nil || publicMethods[method] {
+		return ctx, nil
+	}
+	scope, ok := methodScopes[method]
+	if !ok {
+		scope = token.ScopeAdmin
+	}
+
+	raw, ok := strings.CutPrefix(
+		interceptor.MetadataValue(ctx, authorization), bearerPrefix,
+	)
+	if !ok {
+		return nil, ErrMissingToken
+	}
+
+	id, err := token.ParseID(raw)
+	var t token.Token
+	if err == nil {
+		t, err = tokens.Token(ctx, id)
+	}
+	if err == nil {
+		err = t.Verify(raw, scope)
+	}
+
+	switch {
+	case err == nil:
+		return token.NewContext(ctx, t), nil
+	case errors.Is(err, token.ErrScope):
+		return nil, ErrScope
+	case errors.Is(err, token.ErrInvalidToken),
+		errors.Is(err, token.ErrUnknownToken),
+		errors.Is(err, token.ErrRevokedToken):
+		return nil, ErrInvalidToken
+	}
+	logger.Log.Error(
+		"failed to get token", zap.String("op", op), zap.Error(err),
+	)
+	return nil, ErrTokenStorage
+}
@@ -0,0 +1,242 @@
+package tokencheck_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/interceptortest"
+	"github.com/niksmo/runlytics/internal/server/app/grpc/interceptor/tokencheck"
+	"github.com/niksmo/runlytics/pkg/token"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const reflectionMethod = "/grpc.reflection.v1.ServerReflection/" +
+	"ServerReflectionInfo"
+
+// brokenTokens fails the storage.
+type brokenTokens struct{}
+
+func (brokenTokens) Token(context.Context, string) (token.Token, error) {
+	return token.Token{}, errors.New("storage is unavailable")
+}
+
+func newToken(
+	t *testing.T, id string, scopes ...token.Scope,
+) (string, token.Token) {
+	t.Helper()
+	raw, tok, err := token.New(id, scopes)
+	require.NoError(t, err)
+	return raw, tok
+}
+
+func bearer(raw string) []string {
+	return []string{"authorization", "Bearer " + raw}
+}
+
+func TestNew(t *testing.T) {
+	logger.Init("fatal")
+
+	writeRaw, writeT := newToken(t, "agent", token.ScopeWrite)
+	readRaw, readT := newToken(t, "grafana", token.ScopeRead)
+	adminRaw, adminT := newToken(t, "ops", token.ScopeAdmin)
+	unknownRaw, _ := newToken(t, "unknown", token.ScopeWrite)
+	tokens, err := token.NewRegistry([]token.Token{writeT, readT, adminT})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		method  string
+		md      []string
+		wantID  string
+		wantErr error
+	}{
+		{
+			name:   "Should allow token of method scope",
+			method: pb.Runlytics_BatchUpdate_FullMethodName,
+			md:     bearer(writeRaw),
+			wantID: "agent",
+		},
+		{
+			name:   "Should allow read token to read",
+			method: pb.Runlytics_Value_FullMethodName,
+			md:     bearer(readRaw),
+			wantID: "grafana",
+		},
+		{
+			name:    "Should deny token of other scope",
+			method:  pb.Runlytics_BatchUpdate_FullMethodName,
+			md:      bearer(readRaw),
+			wantErr: tokencheck.ErrScope,
+		},
+		{
+			name:    "Should deny write token to delete",
+			method:  pb.Runlytics_Delete_FullMethodName,
+			md:      bearer(writeRaw),
+			wantErr: tokencheck.ErrScope,
+		},
+		{
+			name:    "Should require token",
+			method:  pb.Runlytics_Update_FullMethodName,
+			wantErr: tokencheck.ErrMissingToken,
+		},
+		{
+			name:    "Should require bearer token",
+			method:  pb.Runlytics_Update_FullMethodName,
+			md:      []string{"authorization", writeRaw},
+			wantErr: tokencheck.ErrMissingToken,
+		},
+		{
+			name:    "Should reject unknown token",
+			method:  pb.Runlytics_Update_FullMethodName,
+			md:      bearer(unknownRaw),
+			wantErr: tokencheck.ErrInvalidToken,
+		},
+		{
+			name:    "Should reject malformed token",
+			method:  pb.Runlytics_Update_FullMethodName,
+			md:      bearer("token"),
+			wantErr: tokencheck.ErrInvalidToken,
+		},
+		{
+			name:   "Should pass ping without token",
+			method: pb.Runlytics_Ping_FullMethodName,
+		},
+		{
+			name:   "Should pass health check without token",
+			method: healthpb.Health_Check_FullMethodName,
+		},
+		{
+			name:   "Should pass health list without token",
+			method: healthpb.Health_List_FullMethodName,
+		},
+		{
+			name:    "Should require token of unknown method",
+			method:  reflectionMethod,
+			wantErr: tokencheck.ErrMissingToken,
+		},
+		{
+			name:    "Should deny unknown method to write token",
+			method:  reflectionMethod,
+			md:      bearer(writeRaw),
+			wantErr: tokencheck.ErrScope,
+		},
+		{
+			name:   "Should allow unknown method to admin token",
+			method: reflectionMethod,
+			md:     bearer(adminRaw),
+			wantID: "ops",
+		},
+	}
+
+	check := tokencheck.New(tokens)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var h interceptortest.Handler
+			_, err := check(
+				interceptortest.Context(test.md...),
+				&pb.BatchUpdateRequest{},
+				interceptortest.Info(test.method),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Zero(t, h.Calls)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 1, h.Calls)
+			tok, ok := token.FromContext(h.Ctx)
+			assert.Equal(t, test.wantID != "", ok)
+			assert.Equal(t, test.wantID, tok.ID)
+		})
+	}
+
+	t.Run("Should fail on storage error", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := tokencheck.New(brokenTokens{})(
+			interceptortest.Context(bearer(writeRaw)...),
+			&pb.BatchUpdateRequest{},
+			interceptortest.Info(pb.Runlytics_BatchUpdate_FullMethodName),
+			h.Handle,
+		)
+		assert.ErrorIs(t, err, tokencheck.ErrTokenStorage)
+		assert.Zero(t, h.Calls)
+	})
+
+	t.Run("Should pass every call without tokens", func(t *testing.T) {
+		var h interceptortest.Handler
+		_, err := tokencheck.New(nil)(
+			interceptortest.Context(),
+			&pb.BatchUpdateRequest{},
+			interceptortest.Info(pb.Runlytics_Delete_FullMethodName),
+			h.Handle,
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 1, h.Calls)
+	})
+}
+
+func TestNewStream(t *testing.T) {
+	logger.Init("fatal")
+
+	writeRaw, writeT := newToken(t, "agent", token.ScopeWrite)
+	readRaw, readT := newToken(t, "grafana", token.ScopeRead)
+	tokens, err := token.NewRegistry([]token.Token{writeT, readT})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		md      []string
+		wantErr error
+	}{
+		{
+			name: "Should allow stream of write token",
+			md:   bearer(writeRaw),
+		},
+		{
+			name:    "Should deny stream of read token",
+			md:      bearer(readRaw),
+			wantErr: tokencheck.ErrScope,
+		},
+		{
+			name:    "Should require token",
+			wantErr: tokencheck.ErrMissingToken,
+		},
+	}
+
+	check := tokencheck.NewStream(tokens)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ss := &interceptortest.ServerStream{
+				Ctx: interceptortest.Context(test.md...),
+				Msgs: []*pb.BatchUpdateRequest{
+					{Batch: []byte("first")}, {Batch: []byte("second")},
+				},
+			}
+			var h interceptortest.StreamHandler
+			err := check(
+				nil,
+				ss,
+				interceptortest.StreamInfo(
+					pb.Runlytics_StreamUpdates_FullMethodName,
+				),
+				h.Handle,
+			)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				assert.Empty(t, h.Received)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, h.Received, 2)
+			tok, ok := token.FromContext(h.Ctx)
+			require.True(t, ok)
+			assert.Equal(t, "agent", tok.ID)
+		})
+	}
+}
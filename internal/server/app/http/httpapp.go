@@ -2,6 +2,7 @@ package httpapp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
@@ -13,45 +14,57 @@ import (
 	"github.com/niksmo/runlytics/internal/server/api/httpapi"
 	"github.com/niksmo/runlytics/internal/server/app/http/middleware"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/replay"
 	"go.uber.org/zap"
 )
 
 type App struct {
-	mux  *chi.Mux
-	addr *net.TCPAddr
-	s    *http.Server
+	mux       *chi.Mux
+	addr      *net.TCPAddr
+	tlsConfig *tls.Config
+	s         *http.Server
 }
 
 type AppParams struct {
 	HTMLService        di.IHTMLService
 	UpdateService      di.IUpdateService
 	ReadService        di.IReadService
+	DeleteService      di.IDeleteService
 	HealthCheckService di.IHealthCheckService
 	BatchUpdateService di.IBatchUpdateService
+	RangeService       di.IRangeService
 	Addr               *net.TCPAddr
-	HashKey            string
-	Decrypter          di.Decrypter
-	TrustedNet         *net.IPNet
+	HashKeys           *keyring.Keyring[string]
+	Decrypters         *keyring.Keyring[di.Decrypter]
+	AgentKeys          di.IAgentKeyStorage // signatures are not verified if nil
+	ReplayGuard        *replay.Guard       // replays are not rejected if nil
+	Tokens             di.ITokenStorage    // requests are not authorized if nil
+	TrustedNet         netacl.Policy
+	TrustedProxies     *netacl.ACL        // X-Real-IP is not honored if nil
+	UpdateLimiter      *ratelimit.Limiter // "/update" is not limited if nil
+	BatchLimiter       *ratelimit.Limiter // "/updates" is not limited if nil
+	TLSConfig          *tls.Config        // serves cleartext if nil
 }
 
 func New(p AppParams) *App {
 	mux := chi.NewRouter()
 
 	mux.Use(middleware.Logger)
-	mux.Use(middleware.Decrypt(p.Decrypter))
+	mux.Use(middleware.RealIP(p.TrustedProxies))
+	mux.Use(middleware.PeerIdentity)
+	mux.Use(middleware.Decrypt(p.Decrypters))
 	mux.Use(middleware.AllowContentEncoding("gzip"))
 	mux.Use(middleware.Gzip)
 
-	if p.HashKey != "" {
+	if p.HashKeys.Len() != 0 {
 		mux.Use(
-			middleware.VerifyAndWriteSHA256(p.HashKey, http.MethodPost),
+			middleware.VerifyAndWriteSHA256(p.HashKeys, http.MethodPost),
 		)
 	}
 
-	if p.TrustedNet != nil {
-		mux.Use(middleware.TrustedNet(p.TrustedNet))
-	}
-
 	httpapi.Register(
 		mux,
 		httpapi.RegisterServices{
@@ -59,11 +72,20 @@ func New(p AppParams) *App {
 			IUpdateService:      p.UpdateService,
 			IBatchUpdateService: p.BatchUpdateService,
 			IReadService:        p.ReadService,
+			IDeleteService:      p.DeleteService,
 			IHealthCheckService: p.HealthCheckService,
+			IRangeService:       p.RangeService,
+			TrustedNet:          middleware.TrustedNet(p.TrustedNet),
+			Signed:              middleware.VerifySignature(p.AgentKeys),
+			Unsigned:            middleware.RejectUnsigned(p.AgentKeys),
+			Replay:              middleware.RejectReplay(p.ReplayGuard),
+			UpdateLimit:         middleware.RateLimit(p.UpdateLimiter),
+			BatchLimit:          middleware.RateLimit(p.BatchLimiter),
+			Authorize:           middleware.Authorize(p.Tokens),
 		},
 	)
 
-	return &App{mux: mux, addr: p.Addr}
+	return &App{mux: mux, addr: p.Addr, tlsConfig: p.TLSConfig}
 }
 
 func (a *App) MustRun() {
@@ -80,11 +102,19 @@ func (a *App) Run() error {
 		zap.String("addr", a.addr.String()),
 	)
 
-	a.s = &http.Server{Addr: a.addr.String(), Handler: a.mux}
+	a.s = &http.Server{
+		Addr: a.addr.String(), Handler: a.mux, TLSConfig: a.tlsConfig,
+	}
 
-	log.Info("http server started")
+	log.Info("http server started", zap.Bool("tls", a.tlsConfig != nil))
 
-	err := a.s.ListenAndServe()
+	var err error
+	if a.tlsConfig != nil {
+		// certificates are set in TLSConfig
+		err = a.s.ListenAndServeTLS("", "")
+	} else {
+		err = a.s.ListenAndServe()
+	}
 	if errors.Is(err, http.ErrServerClosed) {
 		return nil
 	}
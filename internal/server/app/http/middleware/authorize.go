@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/token"
+	"go.uber.org/zap"
+)
+
+const bearerPrefix = "Bearer "
+
+// Authorize returns middleware of scope, the middleware allows request
+// with [Authorization] bearer token granting the scope. Request without
+// valid token gets 401 status code, request with token of other scope
+// gets 403 status code. Requests are passed as is, if tokens is nil.
+// Verified token is passed in request context, see [token.FromContext].
+func Authorize(
+	tokens di.ITokenStorage,
+) func(scope token.Scope) func(http.Handler) http.Handler {
+	return func(scope token.Scope) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			if tokens == nil {
+				return next
+			}
+			return &authorizeHandler{tokens: tokens, scope: scope, n: next}
+		}
+	}
+}
+
+type authorizeHandler struct {
+	tokens di.ITokenStorage
+	scope  token.Scope
+	n      http.Handler
+}
+
+func (h *authorizeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, ok := strings.CutPrefix(r.Header.Get(Authorization), bearerPrefix)
+	if !ok {
+		w.Header().Set(WWWAuthenticate, "Bearer")
+		http.Error(w, "Require bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := token.ParseID(raw)
+	var t token.Token
+	if err == nil {
+		t, err = h.tokens.Token(r.Context(), id)
+	}
+	if err == nil {
+		err = t.Verify(raw, h.scope)
+	}
+
+	switch {
+	case err == nil:
+		h.n.ServeHTTP(w, r.WithContext(token.NewContext(r.Context(), t)))
+	case errors.Is(err, token.ErrScope):
+		logger.Log.Info("token scope is denied", zap.Error(err))
+		http.Error(w, "Insufficient token scope", http.StatusForbidden)
+	case errors.Is(err, token.ErrInvalidToken),
+		errors.Is(err, token.ErrUnknownToken),
+		errors.Is(err, token.ErrRevokedToken):
+		logger.Log.Info("token is rejected", zap.String("id", id), zap.Error(err))
+		w.Header().Set(WWWAuthenticate, `Bearer error="invalid_token"`)
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	default:
+		logger.Log.Error("failed to get token", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -7,17 +7,24 @@ import (
 
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/keyring"
 	"go.uber.org/zap"
 )
 
-func Decrypt(decrypter di.Decrypter) func(http.Handler) http.Handler {
+// Decrypt replaces request body with data decrypted by decrypter
+// picked from decrypters by [XCryptoKeyID] header, request without
+// the header is decrypted by the default decrypter.
+// Request with body that fails to decrypt gets 400 status code.
+func Decrypt(
+	decrypters *keyring.Keyring[di.Decrypter],
+) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return &decryptHandler{d: decrypter, n: next}
+		return &decryptHandler{d: decrypters, n: next}
 	}
 }
 
 type decryptHandler struct {
-	d di.Decrypter
+	d *keyring.Keyring[di.Decrypter]
 	n http.Handler
 }
 
@@ -27,6 +34,13 @@ func (h *decryptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	decrypter, err := h.d.Get(r.Header.Get(XCryptoKeyID))
+	if err != nil {
+		logger.Log.Info("failed to pick decryption key", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	encryptedData, err := io.ReadAll(r.Body)
 	if err != nil {
 		logger.Log.Error(
@@ -36,28 +50,31 @@ func (h *decryptHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.d.DecryptMsg(encryptedData)
+	data, err := decrypter.DecryptMsg(encryptedData)
 	if err != nil {
 		logger.Log.Info(
-			"failed to decrypt request data", zap.Error(err),
+			"failed to decrypt request data",
+			zap.Int("size", len(encryptedData)),
+			zap.Error(err),
 		)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	readCloser := &decryptReadCloser{
+	readCloser := &bodyReadCloser{
 		Reader: bytes.NewReader(data),
 		rc:     r.Body,
 	}
 	r.Body = readCloser
+	r.ContentLength = int64(len(data))
 	h.n.ServeHTTP(w, r)
 }
 
-type decryptReadCloser struct {
+type bodyReadCloser struct {
 	*bytes.Reader
 	rc io.ReadCloser
 }
 
-func (drc *decryptReadCloser) Close() error {
+func (drc *bodyReadCloser) Close() error {
 	return drc.rc.Close()
 }
@@ -5,8 +5,16 @@ const (
 	ContentType     = "Content-Type"
 	ContentEncoding = "Content-Encoding"
 	AcceptEncoding  = "Accept-Encoding"
+	Authorization   = "Authorization"
+	WWWAuthenticate = "WWW-Authenticate"
 
-	XRealIP = "X-Real-IP"
+	XRealIP       = "X-Real-IP"
+	XForwardedFor = "X-Forwarded-For"
+	XAgentID      = "X-Agent-ID"
+	XBatchSeq     = "X-Batch-Seq"
+	XSignature    = "X-Signature"     // base64 Ed25519 batch signature
+	XHashKeyID    = "X-Hash-Key-ID"   // HMAC key id, default key if not set
+	XCryptoKeyID  = "X-Crypto-Key-ID" // private key id, default key if not set
 )
 
 // Content types
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/niksmo/runlytics/pkg/tlsconf"
+)
+
+// PeerIdentity forbids request with agent id other than subject common
+// name of verified client certificate. Request without verified
+// certificate is passed as is.
+func PeerIdentity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := tlsconf.PeerIdentity(r.TLS)
+		agentID := r.Header.Get(XAgentID)
+		if id != "" && agentID != "" && agentID != id {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/netacl"
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+	"github.com/niksmo/runlytics/pkg/tlsconf"
+	"github.com/niksmo/runlytics/pkg/token"
+	"go.uber.org/zap"
+)
+
+// RetryAfter is header of seconds after which limited request may be
+// retried.
+const RetryAfter = "Retry-After"
+
+// RateLimit returns middleware limiting requests of every client,
+// request over the limit gets 429 status code with [RetryAfter] header.
+// Requests are passed as is, if limiter is nil.
+//
+// Client is agent of verified certificate or verified bearer token,
+// or client address of [RealIP] otherwise. Client supplied ids,
+// e.g. [XAgentID] header, are not trusted.
+func RateLimit(limiter *ratelimit.Limiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := clientKey(r)
+			release, err := limiter.Acquire(key)
+			var limited *ratelimit.LimitedError
+			if errors.As(err, &limited) {
+				logger.Log.Info(
+					"request is rate limited",
+					zap.String("client", key),
+					zap.String("path", r.URL.Path),
+					zap.Duration("retryAfter", limited.RetryAfter),
+				)
+				w.Header().Set(
+					RetryAfter,
+					strconv.Itoa(ratelimit.RetryAfterSeconds(limited.RetryAfter)),
+				)
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+			defer release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey returns rate limit key of request client.
+func clientKey(r *http.Request) string {
+	if id := tlsconf.PeerIdentity(r.TLS); id != "" {
+		return "agent:" + id
+	}
+	if t, ok := token.FromContext(r.Context()); ok {
+		return "token:" + t.ID
+	}
+	addr, ok := netacl.FromContext(r.Context())
+	if !ok {
+		addr = netacl.ParseAddr(r.RemoteAddr)
+	}
+	return "ip:" + addr.String()
+}
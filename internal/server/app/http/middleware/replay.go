@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/replay"
+	"go.uber.org/zap"
+)
+
+// RejectReplay accepts POST request with stamp of [replay.XTimestamp]
+// and [replay.XNonce] headers once within guard window, request without
+// valid stamp, stale or replayed request gets 401 status code.
+// Requests are passed as is, if guard is nil.
+func RejectReplay(guard *replay.Guard) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if guard == nil {
+			return next
+		}
+		return &replayHandler{guard: guard, n: next}
+	}
+}
+
+type replayHandler struct {
+	guard *replay.Guard
+	n     http.Handler
+}
+
+func (h *replayHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.n.ServeHTTP(w, r)
+		return
+	}
+
+	stamp, err := parseStamp(r)
+	if err == nil {
+		err = h.guard.Check(stamp)
+	}
+	if err != nil {
+		logger.Log.Info(
+			"request is rejected by replay guard",
+			zap.String("agentID", r.Header.Get(XAgentID)), zap.Error(err),
+		)
+		http.Error(w, stampErrorText(err), http.StatusUnauthorized)
+		return
+	}
+	h.n.ServeHTTP(w, r)
+}
+
+// parseStamp returns replay stamp of request headers.
+func parseStamp(r *http.Request) (replay.Stamp, error) {
+	return replay.ParseStamp(
+		r.Header.Get(replay.XTimestamp), r.Header.Get(replay.XNonce),
+	)
+}
+
+func stampErrorText(err error) string {
+	for _, target := range []error{replay.ErrStaleStamp, replay.ErrReplayed} {
+		if errors.Is(err, target) {
+			return target.Error()
+		}
+	}
+	return replay.ErrInvalidStamp.Error()
+}
@@ -9,21 +9,27 @@ import (
 	"hash"
 	"io"
 	"net/http"
-	"sync"
 
 	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/keyring"
 	"go.uber.org/zap"
 )
 
 const headerHashKey = "HashSHA256"
 
-var hashPool = sync.Pool{}
-
 var (
 	ErrNotEqualHash = errors.New("invalid sha256 hash sum")
 )
 
-func VerifyAndWriteSHA256(key string, method ...string) func(http.Handler) http.Handler {
+// VerifyAndWriteSHA256 verifies request hash and writes response hash
+// with the key picked from keys by [XHashKeyID] header,
+// request without the header is verified with the default key.
+// Hash of stamped request covers the stamp, see [replay.Stamp.Material].
+// The body is read and verified before next handler is called, request
+// of wrong hash gets 400 status code.
+func VerifyAndWriteSHA256(
+	keys *keyring.Keyring[string], method ...string,
+) func(http.Handler) http.Handler {
 	verifyMethods := map[string]struct{}{}
 	for _, m := range method {
 		verifyMethods[m] = struct{}{}
@@ -31,7 +37,7 @@ func VerifyAndWriteSHA256(key string, method ...string) func(http.Handler) http.
 
 	return func(next http.Handler) http.Handler {
 		mdw := func(w http.ResponseWriter, r *http.Request) {
-			if key == "" || r.Header.Get(headerHashKey) == "" {
+			if keys.Len() == 0 || r.Header.Get(headerHashKey) == "" {
 				logger.Log.Debug("Key is not using, skip header check")
 				next.ServeHTTP(w, r)
 				return
@@ -43,6 +49,15 @@ func VerifyAndWriteSHA256(key string, method ...string) func(http.Handler) http.
 				return
 			}
 
+			key, err := keys.Get(r.Header.Get(XHashKeyID))
+			if err != nil {
+				logger.Log.Debug("Pick hash key", zap.Error(err))
+				http.Error(
+					w, "Unknown or expired hash key", http.StatusBadRequest,
+				)
+				return
+			}
+
 			reqSHA256Hex := r.Header.Get(headerHashKey)
 
 			reqSHA256, err := hex.DecodeString(reqSHA256Hex)
@@ -56,7 +71,25 @@ func VerifyAndWriteSHA256(key string, method ...string) func(http.Handler) http.
 				return
 			}
 
-			r.Body = newHashReader(r.Body, key, reqSHA256)
+			stamp, err := parseStamp(r)
+			if err != nil {
+				http.Error(w, stampErrorText(err), http.StatusUnauthorized)
+				return
+			}
+
+			data, err := io.ReadAll(r.Body)
+			if err != nil {
+				logger.Log.Error("failed to read request body", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !verifyHash(key, reqSHA256, stamp.Material(data)) {
+				logger.Log.Debug("Hash is not equal")
+				http.Error(w, ErrNotEqualHash.Error(), http.StatusBadRequest)
+				return
+			}
+
+			r.Body = &bodyReadCloser{Reader: bytes.NewReader(data), rc: r.Body}
 			w = newHashWriter(w, key)
 			next.ServeHTTP(w, r)
 		}
@@ -65,50 +98,11 @@ func VerifyAndWriteSHA256(key string, method ...string) func(http.Handler) http.
 	}
 }
 
-type hashReader struct {
-	io.ReadCloser
-	key     []byte
-	compare []byte
-	buf     bytes.Buffer
-}
-
-func newHashReader(
-	wrapped io.ReadCloser, key string, comparedHash []byte,
-) *hashReader {
-	return &hashReader{
-		ReadCloser: wrapped,
-		key:        []byte(key),
-		compare:    comparedHash,
-	}
-}
-
-func (hashReader *hashReader) Read(p []byte) (int, error) {
-	n, err := hashReader.ReadCloser.Read(p)
-	if err == nil {
-		hashReader.buf.Write(p[:n])
-	}
-
-	if errors.Is(err, io.EOF) {
-		hashReader.buf.Write(p[:n])
-
-		h, ok := hashPool.Get().(hash.Hash)
-		if !ok {
-			h = hmac.New(sha256.New, hashReader.key)
-		}
-		defer hashPool.Put(h)
-		h.Reset()
-
-		if _, hErr := h.Write(hashReader.buf.Bytes()); hErr != nil {
-			return 0, hErr
-		}
-
-		if !hmac.Equal(h.Sum(nil), hashReader.compare) {
-			logger.Log.Debug("Hash is not equal")
-			return 0, ErrNotEqualHash
-		}
-	}
-
-	return n, err
+// verifyHash reports whether hash is HMAC-SHA256 of data with key.
+func verifyHash(key string, hash, data []byte) bool {
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write(data)
+	return hmac.Equal(h.Sum(nil), hash)
 }
 
 type hashWriter struct {
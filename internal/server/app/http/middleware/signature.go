@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"go.uber.org/zap"
+)
+
+// VerifySignature verifies [XSignature] of request body with public key
+// of [XAgentID] agent, the body is signed with [XBatchSeq] sequence
+// and replay stamp, if set.
+// Request without agent id or valid signature gets 401 status code,
+// request of unknown or revoked agent gets 403 status code.
+// Requests are passed as is, if keys is nil.
+func VerifySignature(keys di.IAgentKeyStorage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if keys == nil {
+			return next
+		}
+		return &signatureHandler{keys: keys, n: next}
+	}
+}
+
+// RejectUnsigned rejects requests of routes without signature
+// with 403 status code, if keys is set: signatures are required,
+// but the route requests can not be signed.
+// Requests are passed as is, if keys is nil.
+func RejectUnsigned(keys di.IAgentKeyStorage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if keys == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Signed batch is required", http.StatusForbidden)
+		})
+	}
+}
+
+type signatureHandler struct {
+	keys di.IAgentKeyStorage
+	n    http.Handler
+}
+
+func (h *signatureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	agentID := r.Header.Get(XAgentID)
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(XSignature))
+	if agentID == "" || len(signature) == 0 || err != nil {
+		http.Error(w, "Require agent id and signature", http.StatusUnauthorized)
+		return
+	}
+	seq, err := strconv.ParseUint(r.Header.Get(XBatchSeq), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid batch sequence", http.StatusBadRequest)
+		return
+	}
+	stamp, err := parseStamp(r)
+	if err != nil {
+		http.Error(w, stampErrorText(err), http.StatusUnauthorized)
+		return
+	}
+
+	key, err := h.keys.AgentKey(r.Context(), agentID)
+	if err != nil {
+		if errors.Is(err, sign.ErrUnknownAgent) ||
+			errors.Is(err, sign.ErrRevokedAgent) {
+			logger.Log.Info(
+				"agent is not allowed",
+				zap.String("agentID", agentID), zap.Error(err),
+			)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		logger.Log.Error(
+			"failed to get agent key",
+			zap.String("agentID", agentID), zap.Error(err),
+		)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		logger.Log.Error("failed to read request body", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	err = sign.Verify(key, agentID, seq, stamp.Material(data), signature)
+	if err != nil {
+		logger.Log.Info(
+			"invalid batch signature", zap.String("agentID", agentID),
+		)
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = &bodyReadCloser{Reader: bytes.NewReader(data), rc: r.Body}
+	h.n.ServeHTTP(w, r)
+}
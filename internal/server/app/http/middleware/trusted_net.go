@@ -1,24 +1,55 @@
 package middleware
 
 import (
-	"net"
 	"net/http"
+
+	"github.com/niksmo/runlytics/pkg/netacl"
 )
 
-func TrustedNet(ipNet *net.IPNet) func(http.Handler) http.Handler {
+// RealIP stores client address of request in the request context,
+// [XRealIP] and [XForwardedFor] headers are honored only for peers
+// from proxies, see [netacl.ClientAddr].
+func RealIP(proxies *netacl.ACL) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return trustedNetHandler{ipNet: ipNet, n: next}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr := netacl.ClientAddr(
+				netacl.ParseAddr(r.RemoteAddr),
+				proxies,
+				r.Header.Get(XRealIP),
+				r.Header.Get(XForwardedFor),
+			)
+			next.ServeHTTP(w, r.WithContext(netacl.NewContext(r.Context(), addr)))
+		})
+	}
+}
+
+// TrustedNet returns middleware of route class, the middleware allows
+// requests from client address of the route ACL, other requests get
+// 403 status code. Client address is resolved by [RealIP], TCP peer
+// address is used without it.
+func TrustedNet(policy netacl.Policy) func(route string) func(http.Handler) http.Handler {
+	return func(route string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			acl := policy.ACL(route)
+			if acl == nil {
+				return next
+			}
+			return trustedNetHandler{acl: acl, n: next}
+		}
 	}
 }
 
 type trustedNetHandler struct {
-	ipNet *net.IPNet
-	n     http.Handler
+	acl *netacl.ACL
+	n   http.Handler
 }
 
 func (h trustedNetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	clientIP := net.ParseIP(r.Header.Get(XRealIP))
-	if clientIP == nil || !h.ipNet.Contains(clientIP) {
+	addr, ok := netacl.FromContext(r.Context())
+	if !ok {
+		addr = netacl.ParseAddr(r.RemoteAddr)
+	}
+	if !addr.IsValid() || !h.acl.Contains(addr) {
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
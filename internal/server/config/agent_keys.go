@@ -0,0 +1,54 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AgentKeysConfig describes registry of agent public keys for batch
+// signatures verification. Signatures are not verified if the registry
+// is not set.
+type AgentKeysConfig struct {
+	File string // registry file path
+	DB   bool   // agent_key table of database
+}
+
+func NewAgentKeysConfig(p ConfigParams, db DBConfig) (ac AgentKeysConfig) {
+	switch {
+	case p.EnvSet.IsSet(agentKeysEnvName):
+		ac.File = *p.EnvValues.agentKeys
+	case p.FlagSet.IsSet(agentKeysFlagName):
+		ac.File = *p.FlagValues.agentKeys
+	case p.Settings.AgentKeys != nil:
+		ac.File = *p.Settings.AgentKeys
+	}
+
+	switch {
+	case p.EnvSet.IsSet(agentKeysDBEnvName):
+		ac.DB = *p.EnvValues.agentKeysDB
+	case p.FlagSet.IsSet(agentKeysDBFlagName):
+		ac.DB = *p.FlagValues.agentKeysDB
+	case p.Settings.AgentKeysDB != nil:
+		ac.DB = *p.Settings.AgentKeysDB
+	default:
+		ac.DB = agentKeysDBDefault
+	}
+
+	if ac.DB && !db.IsSet() {
+		p.ErrStream <- fmt.Errorf(
+			"'-%s' requires database, set '-%s'",
+			agentKeysDBFlagName, dsnFlagName,
+		)
+	}
+	if ac.DB && ac.File != "" {
+		p.ErrStream <- errors.New(
+			"agent keys file and database are mutually exclusive",
+		)
+	}
+	return
+}
+
+// IsSet reports whether batch signatures are verified.
+func (ac *AgentKeysConfig) IsSet() bool {
+	return ac.File != "" || ac.DB
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// BatchConfig describes database applied batches parameters.
+type BatchConfig struct {
+	TTL time.Duration
+}
+
+func NewBatchConfig(p ConfigParams) (bc BatchConfig) {
+	resolveTTL := func(value int, src, name string) {
+		ttl := time.Second * time.Duration(value)
+		if ttl < metrics.BatchWindow {
+			p.ErrStream <- fmt.Errorf(
+				"batch ttl '%d' should not be less than agent batch window '%.0f', source '%s' name '%s'",
+				value, metrics.BatchWindow.Seconds(), src, name,
+			)
+			return
+		}
+		bc.TTL = ttl
+	}
+
+	switch {
+	case p.EnvSet.IsSet(batchTTLEnvName):
+		resolveTTL(*p.EnvValues.batchTTL, srcEnv, batchTTLEnvName)
+	case p.FlagSet.IsSet(batchTTLFlagName):
+		resolveTTL(*p.FlagValues.batchTTL, srcFlag, "-"+batchTTLFlagName)
+	case p.Settings.BatchTTL != nil:
+		resolveTTL(*p.Settings.BatchTTL, srcSettings, batchTTLSettingsName)
+	default:
+		resolveTTL(batchTTLDefault, "", "")
+	}
+	return
+}
@@ -5,15 +5,30 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/keyring"
 )
 
+// CryptoConfig describes RSA private keys. Path is the default key
+// of messages without key id, Keys are rotated keys with key id.
+// At least one key is required.
 type CryptoConfig struct {
 	Path string
 	Data []byte
+	Keys []CryptoKey
 	f    *os.File
 }
 
+// CryptoKey is private key with key id.
+type CryptoKey struct {
+	ID, Path string
+	Data     []byte
+	Expires  time.Time // zero is no expiry
+}
+
 func NewCryptoConfig(p ConfigParams) (cc CryptoConfig) {
+	cc.initKeys(p)
 	cc.initFile(p)
 	cc.initData(p.ErrStream)
 	return
@@ -39,6 +54,7 @@ func (cc *CryptoConfig) initFile(p ConfigParams) {
 		resolveFile(*p.FlagValues.cryptoKey, srcFlag, cryptoKeyFlagName)
 	case p.Settings.CryptoKey != nil:
 		resolveFile(*p.Settings.CryptoKey, srcSettings, cryptoKeySettingsName)
+	case len(cc.Keys) != 0:
 	default:
 		p.ErrStream <- errors.New("failed to open key file, flag is required")
 	}
@@ -56,3 +72,48 @@ func (cc *CryptoConfig) initData(errStream chan<- error) {
 	}
 	cc.Data = pemData
 }
+
+func (cc *CryptoConfig) initKeys(p ConfigParams) {
+	resolveKeys := func(value, src, name string) {
+		specs, err := keyring.ParseSpecs(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid crypto keys, source '%s' name '%s': %w", src, name, err,
+			)
+			return
+		}
+		for _, spec := range specs {
+			data, err := os.ReadFile(spec.Value)
+			if err != nil {
+				p.ErrStream <- fmt.Errorf(
+					"failed to read key '%s' path '%s', source '%s' name '%s': %w",
+					spec.ID, spec.Value, src, name, err,
+				)
+				return
+			}
+			cc.Keys = append(cc.Keys, CryptoKey{
+				ID: spec.ID, Path: spec.Value, Data: data, Expires: spec.Expires,
+			})
+		}
+	}
+
+	switch {
+	case p.EnvSet.IsSet(cryptoKeysEnvName):
+		resolveKeys(*p.EnvValues.cryptoKeys, srcEnv, cryptoKeysEnvName)
+	case p.FlagSet.IsSet(cryptoKeysFlagName):
+		resolveKeys(*p.FlagValues.cryptoKeys, srcFlag, "-"+cryptoKeysFlagName)
+	case p.Settings.CryptoKeys != nil:
+		resolveKeys(
+			*p.Settings.CryptoKeys, srcSettings, cryptoKeysSettingsName,
+		)
+	}
+}
+
+// KeyIDs returns key ids of Keys.
+func (cc CryptoConfig) KeyIDs() []string {
+	ids := make([]string, len(cc.Keys))
+	for idx, key := range cc.Keys {
+		ids[idx] = key.ID
+	}
+	return ids
+}
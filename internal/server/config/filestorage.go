@@ -10,12 +10,18 @@ type FileStorageConfig struct {
 	File         *os.File
 	SaveInterval time.Duration
 	Restore      bool
+	WAL          bool
+	Keep         int
+	Shards       int
 }
 
 func NewFileStorageConfig(p ConfigParams) (fc FileStorageConfig) {
 	fc.initFile(p)
 	fc.initSaveInterval(p)
 	fc.initRestore(p)
+	fc.initWAL(p)
+	fc.initKeep(p)
+	fc.initShards(p)
 	return
 }
 
@@ -26,6 +32,15 @@ func (fc *FileStorageConfig) FileName() string {
 	return ""
 }
 
+// WALPath returns write-ahead log path next to storage file,
+// or empty string if WAL mode is disabled.
+func (fc *FileStorageConfig) WALPath() string {
+	if !fc.WAL || fc.File == nil {
+		return ""
+	}
+	return fc.File.Name() + ".wal"
+}
+
 func (fc *FileStorageConfig) initFile(p ConfigParams) {
 	resolveFile := func(path, src, name string) {
 		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
@@ -94,3 +109,66 @@ func (fc *FileStorageConfig) initRestore(p ConfigParams) {
 		fc.Restore = *p.Settings.Restore
 	}
 }
+
+func (fc *FileStorageConfig) initWAL(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(storeWALEnvName):
+		fc.WAL = *p.EnvValues.storeWAL
+	case p.FlagSet.IsSet(storeWALFlagName):
+		fc.WAL = *p.FlagValues.storeWAL
+	case p.Settings.WAL != nil:
+		fc.WAL = *p.Settings.WAL
+	}
+}
+
+func (fc *FileStorageConfig) initKeep(p ConfigParams) {
+	resolveKeep := func(value int, src, name string) {
+		if value < 0 {
+			p.ErrStream <- fmt.Errorf(
+				"store keep '%d' less zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		fc.Keep = value
+	}
+
+	switch {
+	case p.EnvSet.IsSet(storeKeepEnvName):
+		resolveKeep(*p.EnvValues.storeKeep, srcEnv, storeKeepEnvName)
+	case p.FlagSet.IsSet(storeKeepFlagName):
+		resolveKeep(*p.FlagValues.storeKeep, srcFlag, "-"+storeKeepFlagName)
+	case p.Settings.StoreKeep != nil:
+		resolveKeep(*p.Settings.StoreKeep, srcSettings, storeKeepSettingsName)
+	default:
+		resolveKeep(storeKeepDefault, "", "")
+	}
+}
+
+func (fc *FileStorageConfig) initShards(p ConfigParams) {
+	resolveShards := func(value int, src, name string) {
+		if value < 1 {
+			p.ErrStream <- fmt.Errorf(
+				"store shards '%d' less one, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		fc.Shards = value
+	}
+
+	switch {
+	case p.EnvSet.IsSet(storeShardsEnvName):
+		resolveShards(*p.EnvValues.storeShards, srcEnv, storeShardsEnvName)
+	case p.FlagSet.IsSet(storeShardsFlagName):
+		resolveShards(
+			*p.FlagValues.storeShards, srcFlag, "-"+storeShardsFlagName,
+		)
+	case p.Settings.StoreShards != nil:
+		resolveShards(
+			*p.Settings.StoreShards, srcSettings, storeShardsSettingsName,
+		)
+	default:
+		resolveShards(storeShardsDefault, "", "")
+	}
+}
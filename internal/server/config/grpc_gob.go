@@ -0,0 +1,21 @@
+package config
+
+// GRPCGobConfig describes acceptance of legacy gob encoded gRPC batch.
+// Agents send protobuf batch, the config is removed in the next release.
+type GRPCGobConfig struct {
+	Enabled bool
+}
+
+func NewGRPCGobConfig(p ConfigParams) (gc GRPCGobConfig) {
+	switch {
+	case p.EnvSet.IsSet(grpcGobEnvName):
+		gc.Enabled = *p.EnvValues.grpcGob
+	case p.FlagSet.IsSet(grpcGobFlagName):
+		gc.Enabled = *p.FlagValues.grpcGob
+	case p.Settings.GRPCGob != nil:
+		gc.Enabled = *p.Settings.GRPCGob
+	default:
+		gc.Enabled = grpcGobDefault
+	}
+	return
+}
@@ -1,10 +1,28 @@
 package config
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/keyring"
+)
+
+// HashKeyConfig describes HMAC keys. Key is the default key of requests
+// without key id, Keys are rotated keys with key id.
 type HashKeyConfig struct {
-	Key string
+	Key     string
+	Keys    []keyring.Spec
+	Keyring *keyring.Keyring[string]
 }
 
 func NewHashKeyConfig(p ConfigParams) (hc HashKeyConfig) {
+	hc.initKey(p)
+	hc.initKeys(p)
+	hc.initKeyring(p.ErrStream)
+	return
+}
+
+func (hc *HashKeyConfig) initKey(p ConfigParams) {
 	switch {
 	case p.EnvSet.IsSet(hashKeyEnvName):
 		hc.Key = *p.EnvValues.hashKey
@@ -13,9 +31,51 @@ func NewHashKeyConfig(p ConfigParams) (hc HashKeyConfig) {
 	case p.Settings.HashKey != nil:
 		hc.Key = *p.Settings.HashKey
 	}
-	return
+}
+
+func (hc *HashKeyConfig) initKeys(p ConfigParams) {
+	resolveKeys := func(value, src, name string) {
+		specs, err := keyring.ParseSpecs(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid hash keys, source '%s' name '%s': %w", src, name, err,
+			)
+			return
+		}
+		hc.Keys = specs
+	}
+
+	switch {
+	case p.EnvSet.IsSet(hashKeysEnvName):
+		resolveKeys(*p.EnvValues.hashKeys, srcEnv, hashKeysEnvName)
+	case p.FlagSet.IsSet(hashKeysFlagName):
+		resolveKeys(*p.FlagValues.hashKeys, srcFlag, "-"+hashKeysFlagName)
+	case p.Settings.HashKeys != nil:
+		resolveKeys(*p.Settings.HashKeys, srcSettings, hashKeysSettingsName)
+	}
+}
+
+func (hc *HashKeyConfig) initKeyring(errStream chan<- error) {
+	hc.Keyring = keyring.New[string]()
+	if hc.Key != "" {
+		hc.Keyring.Add("", hc.Key, time.Time{})
+	}
+	for _, spec := range hc.Keys {
+		if err := hc.Keyring.Add(spec.ID, spec.Value, spec.Expires); err != nil {
+			errStream <- fmt.Errorf("invalid hash keys: %w", err)
+		}
+	}
 }
 
 func (hc HashKeyConfig) IsSet() bool {
-	return hc.Key != ""
+	return hc.Keyring.Len() != 0
+}
+
+// KeyIDs returns key ids of Keys.
+func (hc HashKeyConfig) KeyIDs() []string {
+	ids := make([]string, len(hc.Keys))
+	for idx, spec := range hc.Keys {
+		ids[idx] = spec.ID
+	}
+	return ids
 }
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistoryConfig describes database history samples parameters.
+type HistoryConfig struct {
+	Retention time.Duration
+}
+
+func NewHistoryConfig(p ConfigParams) (hc HistoryConfig) {
+	resolveRetention := func(value int, src, name string) {
+		if value <= 0 {
+			p.ErrStream <- fmt.Errorf(
+				"history retention '%d' should be greater zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		hc.Retention = time.Second * time.Duration(value)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(historyRetentionEnvName):
+		resolveRetention(
+			*p.EnvValues.historyRetention, srcEnv, historyRetentionEnvName,
+		)
+	case p.FlagSet.IsSet(historyRetentionFlagName):
+		resolveRetention(
+			*p.FlagValues.historyRetention,
+			srcFlag,
+			"-"+historyRetentionFlagName,
+		)
+	case p.Settings.HistoryRetention != nil:
+		resolveRetention(
+			*p.Settings.HistoryRetention,
+			srcSettings,
+			historyRetentionSettingsName,
+		)
+	default:
+		resolveRetention(historyRetentionDefault, "", "")
+	}
+	return
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/ratelimit"
+)
+
+// RateLimitConfig describes per client limits of ingestion requests.
+// Update limits "/update" and gRPC Update, Updates limits "/updates",
+// GRPCBatch limits gRPC BatchUpdate and StreamUpdates batches.
+// Concurrency is limit of client requests in flight of every route,
+// zero limit is disabled.
+type RateLimitConfig struct {
+	Update      ratelimit.Limit
+	Updates     ratelimit.Limit
+	GRPCBatch   ratelimit.Limit
+	Concurrency int
+}
+
+func NewRateLimitConfig(p ConfigParams) (rc RateLimitConfig) {
+	resolveLimit := func(limit *ratelimit.Limit, value, src, name string) {
+		parsed, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid rate limit, source '%s' name '%s': %w", src, name, err,
+			)
+			return
+		}
+		*limit = parsed
+	}
+
+	resolveConcurrency := func(value int, src, name string) {
+		if value < 0 {
+			p.ErrStream <- fmt.Errorf(
+				"ingest concurrency '%d' less zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		rc.Concurrency = value
+	}
+
+	switch {
+	case p.EnvSet.IsSet(rateLimitUpdateEnvName):
+		resolveLimit(
+			&rc.Update, *p.EnvValues.rateLimitUpdate,
+			srcEnv, rateLimitUpdateEnvName,
+		)
+	case p.FlagSet.IsSet(rateLimitUpdateFlagName):
+		resolveLimit(
+			&rc.Update, *p.FlagValues.rateLimitUpdate,
+			srcFlag, "-"+rateLimitUpdateFlagName,
+		)
+	case p.Settings.RateLimitUpdate != nil:
+		resolveLimit(
+			&rc.Update, *p.Settings.RateLimitUpdate,
+			srcSettings, rateLimitUpdateSettingsName,
+		)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(rateLimitUpdatesEnvName):
+		resolveLimit(
+			&rc.Updates, *p.EnvValues.rateLimitUpdates,
+			srcEnv, rateLimitUpdatesEnvName,
+		)
+	case p.FlagSet.IsSet(rateLimitUpdatesFlagName):
+		resolveLimit(
+			&rc.Updates, *p.FlagValues.rateLimitUpdates,
+			srcFlag, "-"+rateLimitUpdatesFlagName,
+		)
+	case p.Settings.RateLimitUpdates != nil:
+		resolveLimit(
+			&rc.Updates, *p.Settings.RateLimitUpdates,
+			srcSettings, rateLimitUpdatesSettingsName,
+		)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(rateLimitGRPCBatchEnvName):
+		resolveLimit(
+			&rc.GRPCBatch, *p.EnvValues.rateLimitGRPCBatch,
+			srcEnv, rateLimitGRPCBatchEnvName,
+		)
+	case p.FlagSet.IsSet(rateLimitGRPCBatchFlagName):
+		resolveLimit(
+			&rc.GRPCBatch, *p.FlagValues.rateLimitGRPCBatch,
+			srcFlag, "-"+rateLimitGRPCBatchFlagName,
+		)
+	case p.Settings.RateLimitGRPCBatch != nil:
+		resolveLimit(
+			&rc.GRPCBatch, *p.Settings.RateLimitGRPCBatch,
+			srcSettings, rateLimitGRPCBatchSettingsName,
+		)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(ingestConcurrencyEnvName):
+		resolveConcurrency(
+			*p.EnvValues.ingestConcurrency, srcEnv, ingestConcurrencyEnvName,
+		)
+	case p.FlagSet.IsSet(ingestConcurrencyFlagName):
+		resolveConcurrency(
+			*p.FlagValues.ingestConcurrency, srcFlag, "-"+ingestConcurrencyFlagName,
+		)
+	case p.Settings.IngestConcurrency != nil:
+		resolveConcurrency(
+			*p.Settings.IngestConcurrency, srcSettings, ingestConcurrencySettingsName,
+		)
+	default:
+		resolveConcurrency(ingestConcurrencyDefault, "", "")
+	}
+	return
+}
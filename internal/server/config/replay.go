@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// ReplayConfig describes replay protection of stamped requests.
+// Zero window disables the protection.
+type ReplayConfig struct {
+	Window    time.Duration
+	CacheSize int
+}
+
+func NewReplayConfig(p ConfigParams) (rc ReplayConfig) {
+	resolveWindow := func(value int, src, name string) {
+		if value < 0 {
+			p.ErrStream <- fmt.Errorf(
+				"replay window '%d' less zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		rc.Window = time.Second * time.Duration(value)
+	}
+
+	resolveCacheSize := func(value int, src, name string) {
+		if value <= 0 {
+			p.ErrStream <- fmt.Errorf(
+				"replay cache size '%d' should be greater zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		rc.CacheSize = value
+	}
+
+	switch {
+	case p.EnvSet.IsSet(replayWindowEnvName):
+		resolveWindow(*p.EnvValues.replayWindow, srcEnv, replayWindowEnvName)
+	case p.FlagSet.IsSet(replayWindowFlagName):
+		resolveWindow(
+			*p.FlagValues.replayWindow, srcFlag, "-"+replayWindowFlagName,
+		)
+	case p.Settings.ReplayWindow != nil:
+		resolveWindow(
+			*p.Settings.ReplayWindow, srcSettings, replayWindowSettingsName,
+		)
+	default:
+		resolveWindow(replayWindowDefault, "", "")
+	}
+
+	switch {
+	case p.EnvSet.IsSet(replayCacheEnvName):
+		resolveCacheSize(*p.EnvValues.replayCache, srcEnv, replayCacheEnvName)
+	case p.FlagSet.IsSet(replayCacheFlagName):
+		resolveCacheSize(
+			*p.FlagValues.replayCache, srcFlag, "-"+replayCacheFlagName,
+		)
+	case p.Settings.ReplayCache != nil:
+		resolveCacheSize(
+			*p.Settings.ReplayCache, srcSettings, replayCacheSettingsName,
+		)
+	default:
+		resolveCacheSize(replayCacheDefault, "", "")
+	}
+	return
+}
+
+// IsSet reports whether replayed requests are rejected.
+func (rc *ReplayConfig) IsSet() bool {
+	return rc.Window != 0
+}
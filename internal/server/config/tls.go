@@ -0,0 +1,99 @@
+package config
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/tlsconf"
+)
+
+// TLSConfig describes TLS of HTTP and gRPC listeners.
+// Listeners serve cleartext if certificate is not set.
+type TLSConfig struct {
+	CertFile, KeyFile, ClientCAFile string
+	RequireClientCert               bool
+	Config                          *tls.Config
+}
+
+func NewTLSConfig(p ConfigParams) (tc TLSConfig) {
+	tc.initCertFile(p)
+	tc.initKeyFile(p)
+	tc.initClientCAFile(p)
+	tc.initRequireClientCert(p)
+	tc.initConfig(p.ErrStream)
+	return
+}
+
+func (tc *TLSConfig) initCertFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsCertEnvName):
+		tc.CertFile = *p.EnvValues.tlsCert
+	case p.FlagSet.IsSet(tlsCertFlagName):
+		tc.CertFile = *p.FlagValues.tlsCert
+	case p.Settings.TLSCert != nil:
+		tc.CertFile = *p.Settings.TLSCert
+	}
+}
+
+func (tc *TLSConfig) initKeyFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsKeyEnvName):
+		tc.KeyFile = *p.EnvValues.tlsKey
+	case p.FlagSet.IsSet(tlsKeyFlagName):
+		tc.KeyFile = *p.FlagValues.tlsKey
+	case p.Settings.TLSKey != nil:
+		tc.KeyFile = *p.Settings.TLSKey
+	}
+}
+
+func (tc *TLSConfig) initClientCAFile(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsClientCAEnvName):
+		tc.ClientCAFile = *p.EnvValues.tlsClientCA
+	case p.FlagSet.IsSet(tlsClientCAFlagName):
+		tc.ClientCAFile = *p.FlagValues.tlsClientCA
+	case p.Settings.TLSClientCA != nil:
+		tc.ClientCAFile = *p.Settings.TLSClientCA
+	}
+}
+
+func (tc *TLSConfig) initRequireClientCert(p ConfigParams) {
+	switch {
+	case p.EnvSet.IsSet(tlsClientAuthEnvName):
+		tc.RequireClientCert = *p.EnvValues.tlsClientAuth
+	case p.FlagSet.IsSet(tlsClientAuthFlagName):
+		tc.RequireClientCert = *p.FlagValues.tlsClientAuth
+	case p.Settings.TLSClientAuth != nil:
+		tc.RequireClientCert = *p.Settings.TLSClientAuth
+	default:
+		tc.RequireClientCert = tlsClientAuthDefault
+	}
+}
+
+func (tc *TLSConfig) initConfig(errStream chan<- error) {
+	if tc.CertFile == "" && tc.KeyFile == "" {
+		if tc.ClientCAFile != "" || tc.RequireClientCert {
+			errStream <- errors.New(
+				"client certificates verification requires TLS certificate and key",
+			)
+		}
+		return
+	}
+
+	cfg, err := tlsconf.NewServer(tlsconf.ServerOpts{
+		CertFile:          tc.CertFile,
+		KeyFile:           tc.KeyFile,
+		ClientCAFile:      tc.ClientCAFile,
+		RequireClientCert: tc.RequireClientCert,
+	})
+	if err != nil {
+		errStream <- fmt.Errorf("failed to init TLS: %w", err)
+		return
+	}
+	tc.Config = cfg
+}
+
+func (tc *TLSConfig) IsSet() bool {
+	return tc.Config != nil
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/niksmo/runlytics/pkg/token"
+)
+
+// TokensConfig describes bearer tokens of API clients. Requests are not
+// authorized if tokens are not set.
+type TokensConfig struct {
+	Tokens []token.Token // hashed tokens with scopes
+	DB     bool          // api_token table of database
+}
+
+func NewTokensConfig(p ConfigParams, db DBConfig) (tc TokensConfig) {
+	resolveTokens := func(value, src, name string) {
+		tokens, err := token.ParseSpecs(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid tokens, source '%s' name '%s': %w", src, name, err,
+			)
+			return
+		}
+		tc.Tokens = tokens
+	}
+
+	switch {
+	case p.EnvSet.IsSet(tokensEnvName):
+		resolveTokens(*p.EnvValues.tokens, srcEnv, tokensEnvName)
+	case p.FlagSet.IsSet(tokensFlagName):
+		resolveTokens(*p.FlagValues.tokens, srcFlag, "-"+tokensFlagName)
+	case p.Settings.Tokens != nil:
+		resolveTokens(*p.Settings.Tokens, srcSettings, tokensSettingsName)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(tokensDBEnvName):
+		tc.DB = *p.EnvValues.tokensDB
+	case p.FlagSet.IsSet(tokensDBFlagName):
+		tc.DB = *p.FlagValues.tokensDB
+	case p.Settings.TokensDB != nil:
+		tc.DB = *p.Settings.TokensDB
+	default:
+		tc.DB = tokensDBDefault
+	}
+
+	if tc.DB && !db.IsSet() {
+		p.ErrStream <- fmt.Errorf(
+			"'-%s' requires database, set '-%s'", tokensDBFlagName, dsnFlagName,
+		)
+	}
+	if tc.DB && len(tc.Tokens) != 0 {
+		p.ErrStream <- errors.New("tokens and database are mutually exclusive")
+	}
+	return
+}
+
+// IsSet reports whether requests are authorized.
+func (tc *TokensConfig) IsSet() bool {
+	return len(tc.Tokens) != 0 || tc.DB
+}
+
+// TokenIDs returns ids of Tokens.
+func (tc TokensConfig) TokenIDs() []string {
+	ids := make([]string, len(tc.Tokens))
+	for idx, t := range tc.Tokens {
+		ids[idx] = t.ID
+	}
+	return ids
+}
@@ -2,16 +2,29 @@ package config
 
 import (
 	"fmt"
-	"net"
+
+	"github.com/niksmo/runlytics/pkg/netacl"
 )
 
+// TrustedNetConfig describes network ACL of routes. Routes without own
+// ACL use Default ACL, admin routes are denied if neither is set.
+// Proxies are peers, whose X-Real-IP and X-Forwarded-For are honored.
 type TrustedNetConfig struct {
-	IPNet *net.IPNet
+	Default *netacl.ACL
+	Routes  map[string]*netacl.ACL
+	Proxies *netacl.ACL
 }
 
 func NewTrustedNetConfig(p ConfigParams) (tc TrustedNetConfig) {
+	tc.initDefault(p)
+	tc.initRoutes(p)
+	tc.initProxies(p)
+	return
+}
+
+func (tc *TrustedNetConfig) initDefault(p ConfigParams) {
 	resolveTrustedNet := func(value, src, name string) {
-		_, IPNet, err := net.ParseCIDR(value)
+		acl, err := netacl.ParseACL(value)
 		if err != nil {
 			p.ErrStream <- fmt.Errorf(
 				"failed to resolve trusted subnet '%s', source '%s' name '%s': %w",
@@ -19,7 +32,7 @@ func NewTrustedNetConfig(p ConfigParams) (tc TrustedNetConfig) {
 			)
 			return
 		}
-		tc.IPNet = IPNet
+		tc.Default = acl
 	}
 
 	switch {
@@ -34,9 +47,96 @@ func NewTrustedNetConfig(p ConfigParams) (tc TrustedNetConfig) {
 			*p.Settings.TrustedNet, srcSettings, trustedNetSettingsName,
 		)
 	}
-	return
+}
+
+func (tc *TrustedNetConfig) initRoutes(p ConfigParams) {
+	resolveRoutes := func(value, src, name string) {
+		routes, err := netacl.ParseRoutes(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid trusted routes, source '%s' name '%s': %w",
+				src, name, err,
+			)
+			return
+		}
+		tc.Routes = routes
+	}
+
+	switch {
+	case p.EnvSet.IsSet(trustedRoutesEnvName):
+		resolveRoutes(*p.EnvValues.trustedRoutes, srcEnv, trustedRoutesEnvName)
+	case p.FlagSet.IsSet(trustedRoutesFlagName):
+		resolveRoutes(
+			*p.FlagValues.trustedRoutes, srcFlag, "-"+trustedRoutesFlagName,
+		)
+	case p.Settings.TrustedRoutes != nil:
+		resolveRoutes(
+			*p.Settings.TrustedRoutes, srcSettings, trustedRoutesSettingsName,
+		)
+	}
+}
+
+func (tc *TrustedNetConfig) initProxies(p ConfigParams) {
+	resolveProxies := func(value, src, name string) {
+		acl, err := netacl.ParseACL(value)
+		if err != nil {
+			p.ErrStream <- fmt.Errorf(
+				"invalid trusted proxies, source '%s' name '%s': %w",
+				src, name, err,
+			)
+			return
+		}
+		tc.Proxies = acl
+	}
+
+	switch {
+	case p.EnvSet.IsSet(trustedProxiesEnvName):
+		resolveProxies(*p.EnvValues.trustedProxies, srcEnv, trustedProxiesEnvName)
+	case p.FlagSet.IsSet(trustedProxiesFlagName):
+		resolveProxies(
+			*p.FlagValues.trustedProxies, srcFlag, "-"+trustedProxiesFlagName,
+		)
+	case p.Settings.TrustedProxies != nil:
+		resolveProxies(
+			*p.Settings.TrustedProxies, srcSettings, trustedProxiesSettingsName,
+		)
+	}
 }
 
 func (tc *TrustedNetConfig) IsSet() bool {
-	return tc.IPNet != nil
+	return tc.Default != nil
+}
+
+// Policy returns network policy of routes.
+func (tc TrustedNetConfig) Policy() netacl.Policy {
+	policy := netacl.Policy{
+		Default: tc.Default,
+		Routes:  make(map[string]*netacl.ACL, len(tc.Routes)+1),
+	}
+	for route, acl := range tc.Routes {
+		policy.Routes[route] = acl
+	}
+	if _, ok := policy.Routes[netacl.RouteAdmin]; !ok && tc.Default == nil {
+		policy.Routes[netacl.RouteAdmin] = new(netacl.ACL)
+	}
+	return policy
+}
+
+// RoutesString returns route ACLs for printing.
+func (tc TrustedNetConfig) RoutesString() []string {
+	var routes []string
+	for _, route := range netacl.Routes {
+		if acl, ok := tc.Routes[route]; ok {
+			routes = append(routes, route+"="+acl.String())
+		}
+	}
+	return routes
+}
+
+// aclString returns empty string for not set ACL.
+func aclString(acl *netacl.ACL) string {
+	if acl == nil {
+		return ""
+	}
+	return acl.String()
 }
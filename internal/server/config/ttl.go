@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// TTLConfig describes metrics expiration parameters.
+// Zero TTL disables expiration.
+type TTLConfig struct {
+	TTL time.Duration
+}
+
+func NewTTLConfig(p ConfigParams) (tc TTLConfig) {
+	resolveTTL := func(value int, src, name string) {
+		if value < 0 {
+			p.ErrStream <- fmt.Errorf(
+				"metrics ttl '%d' less zero, source '%s' name '%s'",
+				value, src, name,
+			)
+			return
+		}
+		tc.TTL = time.Second * time.Duration(value)
+	}
+
+	switch {
+	case p.EnvSet.IsSet(ttlEnvName):
+		resolveTTL(*p.EnvValues.ttl, srcEnv, ttlEnvName)
+	case p.FlagSet.IsSet(ttlFlagName):
+		resolveTTL(*p.FlagValues.ttl, srcFlag, "-"+ttlFlagName)
+	case p.Settings.TTL != nil:
+		resolveTTL(*p.Settings.TTL, srcSettings, ttlSettingsName)
+	default:
+		resolveTTL(ttlDefault, "", "")
+	}
+	return
+}
@@ -3,6 +3,8 @@ package server
 import "errors"
 
 var (
-	ErrNotExists = errors.New("not exists")
-	ErrInternal  = errors.New("internal server error")
+	ErrNotExists    = errors.New("not exists")
+	ErrInternal     = errors.New("internal server error")
+	ErrNotSupported = errors.New("not supported by storage")
+	ErrRangeStep    = errors.New("'step': finer than history resolution of range")
 )
@@ -2,8 +2,8 @@ package service
 
 import (
 	"context"
+	"errors"
 
-	"github.com/niksmo/runlytics/internal/server"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
 )
@@ -20,45 +20,89 @@ func NewBatchUpdateService(
 	return &BatchUpdateService{repository}
 }
 
-// BatchUpdate accept slice of metrics and returns error if occured.
+// BatchUpdate applies mixed metrics list atomically: either every metric
+// is updated or none.
 //
-// Update metrics steps:
-//  1. split metrics on two different slices: counter and gauge
-//  2. update two slices in order: gauge -> counter
-//
-// If error occur on gauge update step, returns that error immediately.
-//
-// TODO(niksmo): update gauge and counter slices in separate goroutines.
+// If some items can't be applied, [metrics.BatchErrors] are returned
+// with every failed item, otherwise storage error, if occur.
 func (s *BatchUpdateService) BatchUpdate(
 	ctx context.Context, ml metrics.MetricsList,
 ) error {
-	var gl []metrics.Metrics
-	var cl []metrics.Metrics
+	_, err := s.repository.UpdateBatch(ctx, metrics.BatchID{}, ml)
+	return err
+}
 
-	for _, m := range ml {
-		switch m.MType {
-		case metrics.MTypeGauge:
-			gl = append(gl, m)
-		case metrics.MTypeCounter:
-			cl = append(cl, m)
-		default:
-			return server.ErrInternal
+// BatchUpdateOnce updates metrics list like [BatchUpdateService.BatchUpdate],
+// unless the batch with the same id is already applied.
+// Duplicate batch returns true and nil error.
+//
+// Not identified batch is always applied.
+func (s *BatchUpdateService) BatchUpdateOnce(
+	ctx context.Context, id metrics.BatchID, ml metrics.MetricsList,
+) (bool, error) {
+	return s.repository.UpdateBatch(ctx, id, ml)
+}
+
+// BatchUpdatePartial applies valid items of metrics list, unless the batch
+// with the same id is already applied, and returns outcome of every item.
+//
+// Items not passed ops are reported as invalid. Items the storage can't
+// apply, e.g. histogram bounds mismatch, are reported as failed and the
+// rest valid items are applied atomically. If storage fails, nothing is
+// applied and the error is returned.
+func (s *BatchUpdateService) BatchUpdatePartial(
+	ctx context.Context,
+	id metrics.BatchID,
+	ml metrics.MetricsList,
+	ops ...metrics.VerifyOp,
+) (metrics.BatchResult, error) {
+	res := metrics.BatchResult{Items: make([]metrics.ItemResult, len(ml))}
+
+	var valid metrics.MetricsList
+	var validIdx []int
+	for idx, m := range ml {
+		res.Items[idx] = metrics.ItemResult{
+			Index: idx, Status: metrics.ItemApplied,
+		}
+		if err := m.Verify(ops...); err != nil {
+			res.Items[idx].Status = metrics.ItemInvalid
+			res.Items[idx].Error = err.Error()
+			continue
 		}
+		valid = append(valid, m)
+		validIdx = append(validIdx, idx)
 	}
 
-	if len(gl) != 0 {
-		err := s.repository.UpdateGaugeList(ctx, gl)
-		if err != nil {
-			return err
+	// every attempt drops at least one failed item, so the loop ends
+	for len(valid) != 0 {
+		duplicate, err := s.repository.UpdateBatch(ctx, id, valid)
+		var batchErrs metrics.BatchErrors
+		if !errors.As(err, &batchErrs) {
+			if err != nil {
+				return metrics.BatchResult{}, err
+			}
+			res.Duplicate = duplicate
+			res.Applied = len(valid)
+			break
 		}
-	}
 
-	if len(cl) != 0 {
-		err := s.repository.UpdateCounterList(ctx, cl)
-		if err != nil {
-			return err
+		failed := make(map[int]struct{}, len(batchErrs))
+		for _, e := range batchErrs {
+			item := &res.Items[validIdx[e.Index]]
+			item.Status = metrics.ItemFailed
+			item.Error = e.Err.Error()
+			failed[e.Index] = struct{}{}
 		}
-	}
 
-	return nil
+		var nextValid metrics.MetricsList
+		var nextIdx []int
+		for idx, m := range valid {
+			if _, ok := failed[idx]; !ok {
+				nextValid = append(nextValid, m)
+				nextIdx = append(nextIdx, validIdx[idx])
+			}
+		}
+		valid, validIdx = nextValid, nextIdx
+	}
+	return res, nil
 }
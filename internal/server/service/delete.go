@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// DeleteService works with repository and provides Delete method.
+type DeleteService struct {
+	repository di.IDeleteStorage
+}
+
+// NewDeleteService returns DeleteService pointer.
+func NewDeleteService(repository di.IDeleteStorage) *DeleteService {
+	return &DeleteService{repository}
+}
+
+// Delete deletes metrics by type, name and labels.
+// Returns [server.ErrNotExists] if metrics is not stored.
+func (s *DeleteService) Delete(
+	ctx context.Context, m *metrics.Metrics,
+) error {
+	if m == nil {
+		return server.ErrInternal
+	}
+
+	switch m.MType {
+	case metrics.MTypeGauge, metrics.MTypeCounter, metrics.MTypeHistogram:
+		return s.repository.DeleteByName(ctx, m.MType, m.ID, m.Labels)
+	default:
+		return server.ErrInternal
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/di"
+	"go.uber.org/zap"
+)
+
+const (
+	maxExpireInterval = time.Minute
+	minExpireInterval = time.Second
+	expireTimeout     = 30 * time.Second
+)
+
+// ExpireService periodically deletes metrics
+// not updated within TTL. Zero TTL disables expiration.
+type ExpireService struct {
+	repository di.IDeleteStorage
+	ttl        time.Duration
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewExpireService returns ExpireService pointer.
+func NewExpireService(
+	repository di.IDeleteStorage, ttl time.Duration,
+) *ExpireService {
+	return &ExpireService{
+		repository: repository,
+		ttl:        ttl,
+		stop:       make(chan struct{}),
+	}
+}
+
+func (s *ExpireService) MustRun() {
+	s.Run()
+}
+
+// Run starts expiration in background. Metrics are checked
+// every half of TTL, but at least once a minute and at most once a second.
+func (s *ExpireService) Run() {
+	if s.ttl == 0 {
+		return
+	}
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+	interval := min(max(s.ttl/2, minExpireInterval), maxExpireInterval)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case now := <-ticker.C:
+				s.Expire(now)
+			}
+		}
+	}()
+}
+
+// Stop stops expiration and waits for running one.
+func (s *ExpireService) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Expire deletes metrics not updated since now minus TTL.
+func (s *ExpireService) Expire(now time.Time) {
+	const op = "service.Expire"
+	ctx, cancel := context.WithTimeout(context.Background(), expireTimeout)
+	defer cancel()
+
+	deleted, err := s.repository.DeleteExpired(ctx, now.Add(-s.ttl))
+	if err != nil {
+		logger.Log.Error(
+			"failed to delete expired metrics",
+			zap.String("op", op), zap.Error(err),
+		)
+		return
+	}
+	if deleted != 0 {
+		logger.Log.Info(
+			"expired metrics deleted",
+			zap.String("op", op), zap.Int64("deleted", deleted),
+		)
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
 	"go.uber.org/zap"
 )
 
@@ -50,12 +51,16 @@ func NewHTMLService(repository di.IReadListStorage) *HTMLService {
 }
 
 // RenderMetricsList writes HTML page to buffer or returns error if occured.
-func (s *HTMLService) RenderMetricsList(ctx context.Context, buf *bytes.Buffer) error {
-	counter, gauge, err := s.readMetrics(ctx)
+//
+// Only metrics which labels match the filter are rendered.
+func (s *HTMLService) RenderMetricsList(
+	ctx context.Context, filter metrics.Labels, buf *bytes.Buffer,
+) error {
+	counter, gauge, histogram, err := s.readMetrics(ctx)
 	if err != nil {
 		return err
 	}
-	renderList := s.makeRenderList(counter, gauge)
+	renderList := s.makeRenderList(counter, gauge, histogram, filter)
 	return s.renderTemplate(renderList, buf)
 }
 
@@ -64,6 +69,7 @@ func (s *HTMLService) readMetrics(
 ) (
 	counter map[string]int64,
 	gauge map[string]float64,
+	histogram map[string]metrics.Histogram,
 	readErr error,
 ) {
 	var wg sync.WaitGroup
@@ -91,29 +97,68 @@ func (s *HTMLService) readMetrics(
 		}
 		counter = c
 	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h, err := s.repository.ReadHistogram(ctx)
+		if err != nil {
+			readErr = err
+			logger.Log.Error("Read all histogram metrics", zap.Error(err))
+			return
+		}
+		histogram = h
+	}()
 	wg.Wait()
 	return
 }
 
 func (s *HTMLService) makeRenderList(
-	counter map[string]int64, gauge map[string]float64,
+	counter map[string]int64,
+	gauge map[string]float64,
+	histogram map[string]metrics.Histogram,
+	filter metrics.Labels,
 ) []string {
-	render := make([]string, 0, len(gauge)+len(counter))
+	render := make([]string, 0, len(gauge)+len(counter)+len(histogram))
 
 	for k, v := range gauge {
+		if !s.matchKey(k, filter) {
+			continue
+		}
 		vs := strconv.FormatFloat(v, 'f', -1, 64)
 		render = append(render, fmt.Sprintf("%s: %s", k, vs))
 	}
 
 	for k, v := range counter {
+		if !s.matchKey(k, filter) {
+			continue
+		}
 		vs := strconv.FormatInt(v, 10)
 		render = append(render, fmt.Sprintf("%s: %s", k, vs))
 	}
 
+	for k, v := range histogram {
+		if !s.matchKey(k, filter) {
+			continue
+		}
+		render = append(render, fmt.Sprintf("%s: %s", k, v.String()))
+	}
+
 	slices.Sort(render)
 	return render
 }
 
+func (s *HTMLService) matchKey(key string, filter metrics.Labels) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	_, labels, err := metrics.ParseKey(key)
+	if err != nil {
+		return false
+	}
+	return labels.Match(filter)
+}
+
 func (s *HTMLService) renderTemplate(
 	renderList []string, buf *bytes.Buffer,
 ) error {
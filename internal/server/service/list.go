@@ -0,0 +1,73 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// ListService works with repository and provides List method.
+type ListService struct {
+	repository di.IReadListStorage
+}
+
+// NewListService returns ListService pointer.
+func NewListService(repository di.IReadListStorage) *ListService {
+	return &ListService{repository}
+}
+
+// List returns metrics which labels match the filter,
+// ordered by type and key, see [metrics.MakeKey].
+func (s *ListService) List(
+	ctx context.Context, filter metrics.Labels,
+) (metrics.MetricsList, error) {
+	gauge, err := s.repository.ReadGauge(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counter, err := s.repository.ReadCounter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	histogram, err := s.repository.ReadHistogram(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type item struct {
+		key string
+		m   metrics.Metrics
+	}
+	items := make([]item, 0, len(gauge)+len(counter)+len(histogram))
+	add := func(key string, m metrics.Metrics) {
+		id, labels, err := metrics.ParseKey(key)
+		if err != nil || !labels.Match(filter) {
+			return
+		}
+		m.ID, m.Labels = id, labels
+		items = append(items, item{key, m})
+	}
+
+	for k, v := range gauge {
+		add(k, metrics.Metrics{MType: metrics.MTypeGauge, Value: v})
+	}
+	for k, v := range counter {
+		add(k, metrics.Metrics{MType: metrics.MTypeCounter, Delta: v})
+	}
+	for k, v := range histogram {
+		add(k, metrics.Metrics{MType: metrics.MTypeHistogram, Histogram: &v})
+	}
+
+	slices.SortFunc(items, func(a, b item) int {
+		return cmp.Or(cmp.Compare(a.m.MType, b.m.MType), cmp.Compare(a.key, b.key))
+	})
+
+	ml := make(metrics.MetricsList, len(items))
+	for idx, it := range items {
+		ml[idx] = it.m
+	}
+	return ml, nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+
+	"github.com/niksmo/runlytics/pkg/di"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// RangeService works with repository and provides Range method.
+type RangeService struct {
+	repository di.IHistoryStorage
+}
+
+// NewRangeService returns RangeService pointer.
+func NewRangeService(repository di.IHistoryStorage) *RangeService {
+	return &RangeService{repository}
+}
+
+// Range reads metrics history and returns points aggregated by query func.
+//
+// Range start is aligned by step. For rate one more step before
+// range start is read, so the first point has previous value.
+func (s *RangeService) Range(
+	ctx context.Context, q metrics.RangeQuery,
+) ([]metrics.Point, error) {
+	q.From = metrics.AlignTime(q.From, q.Step)
+	from := q.From
+	if q.Func == metrics.AggRate {
+		q.From = q.From.Add(-q.Step)
+	}
+
+	buckets, err := s.repository.ReadHistory(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	points := metrics.Aggregate(buckets, q.Func)
+	for idx, p := range points {
+		if !p.Time.Before(from) {
+			return points[idx:], nil
+		}
+	}
+	return points[:0], nil
+}
@@ -31,6 +31,8 @@ func (s *UpdateService) Update(
 		return s.updateGauge(ctx, m)
 	case metrics.MTypeCounter:
 		return s.updateCounter(ctx, m)
+	case metrics.MTypeHistogram:
+		return s.updateHistogram(ctx, m)
 	default:
 		return server.ErrInternal
 	}
@@ -40,7 +42,7 @@ func (s *UpdateService) updateGauge(
 	ctx context.Context, m *metrics.Metrics,
 ) error {
 	v, err := s.repository.UpdateGaugeByName(
-		ctx, m.ID, m.Value,
+		ctx, m.ID, m.Labels, m.Value,
 	)
 	if err != nil {
 		return err
@@ -54,7 +56,7 @@ func (s *UpdateService) updateCounter(
 ) error {
 
 	d, err := s.repository.UpdateCounterByName(
-		ctx, m.ID, m.Delta,
+		ctx, m.ID, m.Labels, m.Delta,
 	)
 	if err != nil {
 		return err
@@ -62,3 +64,20 @@ func (s *UpdateService) updateCounter(
 	m.Delta = d
 	return nil
 }
+
+func (s *UpdateService) updateHistogram(
+	ctx context.Context, m *metrics.Metrics,
+) error {
+	if m.Histogram == nil {
+		return server.ErrInternal
+	}
+
+	h, err := s.repository.UpdateHistogramByName(
+		ctx, m.ID, m.Labels, *m.Histogram,
+	)
+	if err != nil {
+		return err
+	}
+	m.Histogram = &h
+	return nil
+}
@@ -31,6 +31,8 @@ func (s *ReadService) Read(
 		return s.readGauge(ctx, m)
 	case metrics.MTypeCounter:
 		return s.readCounter(ctx, m)
+	case metrics.MTypeHistogram:
+		return s.readHistogram(ctx, m)
 	default:
 		return server.ErrInternal
 	}
@@ -39,7 +41,7 @@ func (s *ReadService) Read(
 func (s *ReadService) readGauge(
 	ctx context.Context, m *metrics.Metrics,
 ) error {
-	v, err := s.repository.ReadGaugeByName(ctx, m.ID)
+	v, err := s.repository.ReadGaugeByName(ctx, m.ID, m.Labels)
 	if err != nil {
 		return err
 	}
@@ -50,10 +52,21 @@ func (s *ReadService) readGauge(
 func (s *ReadService) readCounter(
 	ctx context.Context, m *metrics.Metrics,
 ) error {
-	d, err := s.repository.ReadCounterByName(ctx, m.ID)
+	d, err := s.repository.ReadCounterByName(ctx, m.ID, m.Labels)
 	if err != nil {
 		return err
 	}
 	m.Delta = d
 	return nil
 }
+
+func (s *ReadService) readHistogram(
+	ctx context.Context, m *metrics.Metrics,
+) error {
+	h, err := s.repository.ReadHistogramByName(ctx, m.ID, m.Labels)
+	if err != nil {
+		return err
+	}
+	m.Histogram = &h
+	return nil
+}
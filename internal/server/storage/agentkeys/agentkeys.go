@@ -0,0 +1,131 @@
+// Package agentkeys provides file registry of agent public keys
+// for batch signatures verification.
+package agentkeys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"go.uber.org/zap"
+)
+
+// reloadInterval is how often the file is checked for changes.
+const reloadInterval = time.Second
+
+const revokedMark = "revoked"
+
+type agentKey struct {
+	key     ed25519.PublicKey
+	revoked bool
+}
+
+// FileRegistry is agent keys registry of text file. Every line is
+// 'agent-id public-key' or 'agent-id public-key revoked', empty lines
+// and lines started with '#' are skipped, see [sign.ParsePublicKey]
+// for public key format.
+//
+// The file is reloaded on change, so added and revoked keys
+// are applied without restart. Invalid file is not applied.
+type FileRegistry struct {
+	path    string
+	mu      sync.Mutex
+	keys    map[string]agentKey
+	modTime time.Time
+	checked time.Time
+}
+
+// NewFileRegistry returns FileRegistry pointer of loaded file.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	const op = "agentkeys.NewFileRegistry"
+	r := &FileRegistry{path: path}
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return r, nil
+}
+
+// AgentKey returns public key of agent.
+// Error wraps [sign.ErrUnknownAgent] or [sign.ErrRevokedAgent].
+func (r *FileRegistry) AgentKey(
+	_ context.Context, agentID string,
+) (ed25519.PublicKey, error) {
+	const op = "agentkeys.FileRegistry.AgentKey"
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) >= reloadInterval {
+		if err := r.load(); err != nil {
+			logger.Log.Warn(
+				"failed to reload agent keys, previous keys are used",
+				zap.String("op", op), zap.Error(err),
+			)
+		}
+	}
+
+	k, ok := r.keys[agentID]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w '%s'", op, sign.ErrUnknownAgent, agentID)
+	}
+	if k.revoked {
+		return nil, fmt.Errorf("%s: %w '%s'", op, sign.ErrRevokedAgent, agentID)
+	}
+	return k.key, nil
+}
+
+// load reads the file if it is modified since the last load.
+func (r *FileRegistry) load() error {
+	r.checked = time.Now()
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return err
+	}
+	if r.keys != nil && info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	keys, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("'%s': %w", r.path, err)
+	}
+	r.keys, r.modTime = keys, info.ModTime()
+	return nil
+}
+
+func parse(data []byte) (map[string]agentKey, error) {
+	keys := make(map[string]agentKey)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 ||
+			len(fields) == 3 && fields[2] != revokedMark {
+			return nil, fmt.Errorf("line %d: invalid format", n)
+		}
+		if _, ok := keys[fields[0]]; ok {
+			return nil, fmt.Errorf("line %d: duplicate agent '%s'", n, fields[0])
+		}
+		key, err := sign.ParsePublicKey(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		keys[fields[0]] = agentKey{key: key, revoked: len(fields) == 3}
+	}
+	return keys, scanner.Err()
+}
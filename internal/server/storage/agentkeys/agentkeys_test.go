@@ -0,0 +1,90 @@
+package agentkeys
+
+import (
+	"context"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPublicKey(t *testing.T) (ed25519.PublicKey, string) {
+	t.Helper()
+	key, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	encoded, err := sign.EncodePublicKey(key)
+	require.NoError(t, err)
+	return key, encoded
+}
+
+func writeFile(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestFileRegistry(t *testing.T) {
+	logger.Init("fatal")
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "agent_keys")
+	keyA, encodedA := newPublicKey(t)
+	keyB, encodedB := newPublicKey(t)
+	modTime := time.Now().Add(-time.Hour)
+
+	writeFile(t, path, "# agents\n\nhost-a "+encodedA+"\nhost-b "+encodedB+"\n", modTime)
+	r, err := NewFileRegistry(path)
+	require.NoError(t, err)
+
+	key, err := r.AgentKey(ctx, "host-a")
+	require.NoError(t, err)
+	assert.Equal(t, keyA, key)
+	key, err = r.AgentKey(ctx, "host-b")
+	require.NoError(t, err)
+	assert.Equal(t, keyB, key)
+	_, err = r.AgentKey(ctx, "host-c")
+	assert.ErrorIs(t, err, sign.ErrUnknownAgent)
+
+	t.Run("Should revoke only one agent on reload", func(t *testing.T) {
+		modTime = modTime.Add(time.Minute)
+		writeFile(t, path, "host-a "+encodedA+"\nhost-b "+encodedB+" revoked\n", modTime)
+		r.checked = time.Time{}
+
+		_, err := r.AgentKey(ctx, "host-b")
+		assert.ErrorIs(t, err, sign.ErrRevokedAgent)
+		key, err := r.AgentKey(ctx, "host-a")
+		require.NoError(t, err)
+		assert.Equal(t, keyA, key)
+	})
+
+	t.Run("Should keep previous keys on invalid file", func(t *testing.T) {
+		modTime = modTime.Add(time.Minute)
+		writeFile(t, path, "host-a\n", modTime)
+		r.checked = time.Time{}
+
+		key, err := r.AgentKey(ctx, "host-a")
+		require.NoError(t, err)
+		assert.Equal(t, keyA, key)
+	})
+
+	t.Run("Should fail on invalid file", func(t *testing.T) {
+		for _, data := range []string{
+			"host-a",
+			"host-a " + encodedA + " disabled",
+			"host-a not-a-key",
+			"host-a " + encodedA + "\nhost-a " + encodedB,
+		} {
+			invalid := filepath.Join(t.TempDir(), "agent_keys")
+			writeFile(t, invalid, data, modTime)
+			_, err := NewFileRegistry(invalid)
+			assert.Error(t, err, data)
+		}
+		_, err := NewFileRegistry(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
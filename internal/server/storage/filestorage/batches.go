@@ -0,0 +1,139 @@
+package filestorage
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// appliedBatches remembers batches applied within [metrics.BatchWindow]
+// by agent. Batches of one agent are applied one at a time,
+// batches of different agents do not wait for each other.
+type appliedBatches struct {
+	mu     sync.Mutex
+	agents map[string]*agentBatches
+}
+
+// agentBatches keeps applied sequences with apply time,
+// order keeps sequences in apply order for expiration.
+type agentBatches struct {
+	mu    sync.Mutex
+	seqs  map[uint64]time.Time
+	order []uint64
+}
+
+func newAppliedBatches() *appliedBatches {
+	return &appliedBatches{agents: make(map[string]*agentBatches)}
+}
+
+// apply calls fn, unless batch is already applied, and remembers
+// the batch if fn succeeds. It reports whether the batch is duplicate.
+//
+// Batch is remembered under agent lock after fn returns,
+// so concurrent duplicate waits for the first one.
+func (ab *appliedBatches) apply(
+	id metrics.BatchID, fn func() error, onApply func(metrics.BatchID, time.Time),
+) (bool, error) {
+	agent := ab.agent(id.AgentID)
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	now := time.Now()
+	agent.expire(now.Add(-metrics.BatchWindow))
+	if _, ok := agent.seqs[id.Seq]; ok {
+		return true, nil
+	}
+
+	if err := fn(); err != nil {
+		return false, err
+	}
+	agent.add(id.Seq, now)
+	if onApply != nil {
+		onApply(id, now)
+	}
+	return false, nil
+}
+
+func (ab *appliedBatches) agent(agentID string) *agentBatches {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+	agent, ok := ab.agents[agentID]
+	if !ok {
+		agent = &agentBatches{seqs: make(map[uint64]time.Time)}
+		ab.agents[agentID] = agent
+	}
+	return agent
+}
+
+// snapshot returns copy of batches applied since before.
+// Agents without such batches are forgotten.
+func (ab *appliedBatches) snapshot(before time.Time) map[string]map[uint64]time.Time {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	s := make(map[string]map[uint64]time.Time, len(ab.agents))
+	for agentID, agent := range ab.agents {
+		agent.mu.Lock()
+		agent.expire(before)
+		if len(agent.seqs) == 0 {
+			delete(ab.agents, agentID)
+		} else {
+			seqs := make(map[uint64]time.Time, len(agent.seqs))
+			for seq, at := range agent.seqs {
+				seqs[seq] = at
+			}
+			s[agentID] = seqs
+		}
+		agent.mu.Unlock()
+	}
+	return s
+}
+
+// restore replaces remembered batches, expired ones are skipped.
+func (ab *appliedBatches) restore(
+	s map[string]map[uint64]time.Time, before time.Time,
+) {
+	ab.mu.Lock()
+	defer ab.mu.Unlock()
+
+	ab.agents = make(map[string]*agentBatches, len(s))
+	for agentID, seqs := range s {
+		agent := &agentBatches{seqs: make(map[uint64]time.Time, len(seqs))}
+		for seq, at := range seqs {
+			if at.After(before) {
+				agent.add(seq, at)
+			}
+		}
+		if len(agent.seqs) != 0 {
+			agent.sortOrder()
+			ab.agents[agentID] = agent
+		}
+	}
+}
+
+func (agent *agentBatches) add(seq uint64, at time.Time) {
+	agent.seqs[seq] = at
+	agent.order = append(agent.order, seq)
+}
+
+// expire forgets sequences applied not after before.
+func (agent *agentBatches) expire(before time.Time) {
+	var n int
+	for _, seq := range agent.order {
+		if agent.seqs[seq].After(before) {
+			break
+		}
+		delete(agent.seqs, seq)
+		n++
+	}
+	agent.order = agent.order[n:]
+}
+
+// sortOrder sorts restored sequences by apply time.
+func (agent *agentBatches) sortOrder() {
+	slices.SortFunc(agent.order, func(a, b uint64) int {
+		return agent.seqs[a].Compare(agent.seqs[b])
+	})
+}
@@ -0,0 +1,52 @@
+package filestorage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppliedBatches(t *testing.T) {
+	id := metrics.BatchID{AgentID: "host-a", Seq: 1}
+	noop := func() error { return nil }
+
+	t.Run("Should not remember failed batch", func(t *testing.T) {
+		ab := newAppliedBatches()
+		_, err := ab.apply(id, func() error { return errors.New("failed") }, nil)
+		require.Error(t, err)
+
+		var calls int
+		duplicate, err := ab.apply(id, func() error { calls++; return nil }, nil)
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Should forget expired batches", func(t *testing.T) {
+		ab := newAppliedBatches()
+		now := time.Now()
+		ab.restore(map[string]map[uint64]time.Time{
+			"host-a": {
+				1: now.Add(-2 * metrics.BatchWindow),
+				2: now.Add(-time.Minute),
+			},
+			"host-b": {1: now.Add(-2 * metrics.BatchWindow)},
+		}, now.Add(-metrics.BatchWindow))
+
+		duplicate, err := ab.apply(id, noop, nil)
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+		duplicate, err = ab.apply(metrics.BatchID{AgentID: "host-a", Seq: 2}, noop, nil)
+		require.NoError(t, err)
+		assert.True(t, duplicate)
+
+		s := ab.snapshot(now.Add(-metrics.BatchWindow))
+		assert.Len(t, s, 1)
+		assert.Len(t, s["host-a"], 2)
+		assert.Empty(t, ab.snapshot(now.Add(time.Minute)))
+	})
+}
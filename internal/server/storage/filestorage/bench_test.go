@@ -0,0 +1,155 @@
+package filestorage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server/storage/memstorage"
+	"github.com/niksmo/runlytics/pkg/fileoperator"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// listStorage is storage methods under benchmark.
+type listStorage interface {
+	UpdateCounterList(context.Context, metrics.MetricsList) error
+	UpdateGaugeList(context.Context, metrics.MetricsList) error
+	ReadGauge(context.Context) (map[string]float64, error)
+}
+
+// lockedStorage is metrics map under single RWMutex, as FileStorage
+// kept metrics before sharding. It is the benchmark baseline.
+type lockedStorage struct {
+	mu      sync.RWMutex
+	counter map[string]int64
+	gauge   map[string]float64
+}
+
+func newLockedStorage() *lockedStorage {
+	return &lockedStorage{
+		counter: make(map[string]int64),
+		gauge:   make(map[string]float64),
+	}
+}
+
+func (s *lockedStorage) UpdateCounterList(
+	_ context.Context, mSlice metrics.MetricsList,
+) error {
+	for _, item := range mSlice {
+		key := metrics.MakeKey(item.ID, item.Labels)
+		s.mu.Lock()
+		s.counter[key] += item.Delta
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *lockedStorage) UpdateGaugeList(
+	_ context.Context, mSlice metrics.MetricsList,
+) error {
+	for _, item := range mSlice {
+		key := metrics.MakeKey(item.ID, item.Labels)
+		s.mu.Lock()
+		s.gauge[key] = item.Value
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+func (s *lockedStorage) ReadGauge(
+	context.Context,
+) (map[string]float64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	gauge := make(map[string]float64, len(s.gauge))
+	maps.Copy(gauge, s.gauge)
+	return gauge, nil
+}
+
+// BenchmarkUpdateList compares sharded storage against the previous
+// single RWMutex storage under concurrent batch updates and reads.
+func BenchmarkUpdateList(b *testing.B) {
+	logger.Init("fatal")
+	ctx := context.Background()
+
+	const batchSize = 100
+	gauges := make(metrics.MetricsList, batchSize)
+	counters := make(metrics.MetricsList, batchSize)
+	for idx := range batchSize {
+		gauges[idx] = metrics.Metrics{
+			ID: fmt.Sprintf("Gauge%d", idx), MType: metrics.MTypeGauge, Value: 1.5,
+		}
+		counters[idx] = metrics.Metrics{
+			ID: fmt.Sprintf("Counter%d", idx), MType: metrics.MTypeCounter, Delta: 1,
+		}
+	}
+
+	newStorage := func(b *testing.B) listStorage {
+		path := filepath.Join(b.TempDir(), "store.json")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { f.Close() })
+		// interval storage, file is not saved during benchmark
+		fs := New(
+			fileoperator.New(f, 0), time.Hour, false, "", 0,
+			memstorage.DefaultShards,
+		)
+		if err = fs.restoreData(); err != nil {
+			b.Fatal(err)
+		}
+		return fs
+	}
+
+	storages := []struct {
+		name string
+		new  func(b *testing.B) listStorage
+	}{
+		{"rwmutex", func(*testing.B) listStorage { return newLockedStorage() }},
+		{fmt.Sprintf("shards=%d", memstorage.DefaultShards), newStorage},
+	}
+
+	for _, storage := range storages {
+		b.Run(storage.name+"/update", func(b *testing.B) {
+			s := storage.new(b)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var idx int
+				for pb.Next() {
+					if idx%2 == 0 {
+						s.UpdateGaugeList(ctx, gauges)
+					} else {
+						s.UpdateCounterList(ctx, counters)
+					}
+					idx++
+				}
+			})
+		})
+
+		b.Run(storage.name+"/update-read", func(b *testing.B) {
+			s := storage.new(b)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				var idx int
+				for pb.Next() {
+					switch idx % 4 {
+					case 0:
+						s.ReadGauge(ctx)
+					case 1, 3:
+						s.UpdateCounterList(ctx, counters)
+					case 2:
+						s.UpdateGaugeList(ctx, gauges)
+					}
+					idx++
+				}
+			})
+		})
+	}
+}
@@ -7,42 +7,69 @@ import (
 	"sync"
 	"time"
 
-	"maps"
-
 	"github.com/niksmo/runlytics/internal/logger"
 	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/internal/server/storage/memstorage"
 	"github.com/niksmo/runlytics/pkg/di"
 	"github.com/niksmo/runlytics/pkg/metrics"
 	"go.uber.org/zap"
 )
 
-type data struct {
-	Counter map[string]int64   `json:"counter"`
-	Gauge   map[string]float64 `json:"gauge"`
+// snapshot is the file content: metrics and batches applied
+// within [metrics.BatchWindow] by agent.
+type snapshot struct {
+	memstorage.Data
+	Batches map[string]map[uint64]time.Time `json:"batches,omitempty"`
 }
 
 // FileStorage store metrics in underlyin map and implements [di.Storage] interface.
+//
+// Metrics are kept in [memstorage.Sharded], so updates of metrics
+// in different shards do not wait for each other.
+//
+// In WAL mode every update is appended to write-ahead log
+// instead of full file rewrite, and the log is compacted
+// into the file snapshot in background.
 type FileStorage struct {
-	mu       sync.RWMutex
+	saveMu   sync.Mutex
 	fo       di.FileOperator
-	data     data
+	data     *memstorage.Sharded
+	batches  *appliedBatches
 	restore  bool
 	interval time.Duration
 	ticker   *time.Ticker
+	walPath  string
+	walKeep  int
+	wal      *wal
+	compactC chan struct{}
+	stopC    chan struct{}
+	wg       sync.WaitGroup
 }
 
 // New returns MemoryStorage pointer.
+//
+// Not empty walPath enables WAL mode, store interval is not used then.
+// Keep is number of previous snapshots kept by fo, in WAL mode
+// the same number of compacted logs is kept for them.
+// Shards is number of independently locked metrics shards.
 func New(
-	fo di.FileOperator, interval time.Duration, restore bool,
+	fo di.FileOperator,
+	interval time.Duration,
+	restore bool,
+	walPath string,
+	keep int,
+	shards int,
 ) *FileStorage {
 	return &FileStorage{
-		data: data{
-			Counter: make(map[string]int64),
-			Gauge:   make(map[string]float64),
-		},
+		data:     memstorage.New(shards),
+		batches:  newAppliedBatches(),
 		interval: interval,
 		fo:       fo,
 		restore:  restore,
+		walPath:  walPath,
+		walKeep:  max(keep, 0),
+		compactC: make(chan struct{}, 1),
+		stopC:    make(chan struct{}),
 	}
 }
 
@@ -54,14 +81,27 @@ func (fs *FileStorage) MustRun() {
 
 // Run starts [FileStorage] and then waiting graceful shutdown.
 //
-// If MemoryStorage.restore is true, restores metrics data from file.
+// If MemoryStorage.restore is true, restores metrics data from file
+// and replays write-ahead log.
 func (fs *FileStorage) Run() error {
 	const op = "filestorage.Run"
 	var err error
+	if fs.isWAL() {
+		if fs.wal, err = openWAL(fs.walPath, fs.walKeep); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	if err = fs.restoreData(); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if fs.isWAL() {
+		fs.wg.Add(1)
+		go fs.compactWorker()
+		return nil
+	}
+
 	if !fs.isSync() {
 		fs.ticker = time.NewTicker(fs.interval)
 		go fs.intervalSave(func(saveErr error) {
@@ -86,13 +126,26 @@ func (fs *FileStorage) Stop() {
 
 	log.Info("filestorage stopping gracefully")
 
-	if !fs.isSync() {
-		fs.ticker.Stop()
-	}
+	if fs.isWAL() {
+		close(fs.stopC)
+		fs.wg.Wait()
+		if err := fs.compact(); err != nil {
+			log.Error("failed to compact log", zap.Error(err))
+			return
+		}
+		if err := fs.wal.close(); err != nil {
+			log.Error("failed to close log", zap.Error(err))
+			return
+		}
+	} else {
+		if !fs.isSync() {
+			fs.ticker.Stop()
+		}
 
-	if err := fs.save(); err != nil {
-		log.Error("failed to save", zap.Error(err))
-		return
+		if err := fs.save(); err != nil {
+			log.Error("failed to save", zap.Error(err))
+			return
+		}
 	}
 
 	if err := fs.fo.Close(); err != nil {
@@ -104,13 +157,10 @@ func (fs *FileStorage) Stop() {
 
 // UpdateCounterByName returns updated counter value and nil error.
 func (fs *FileStorage) UpdateCounterByName(
-	_ context.Context, name string, value int64,
+	_ context.Context, name string, labels metrics.Labels, value int64,
 ) (int64, error) {
-	fs.mu.Lock()
-	prev := fs.data.Counter[name]
-	current := prev + value
-	fs.data.Counter[name] = current
-	fs.mu.Unlock()
+	key := metrics.MakeKey(name, labels)
+	current := fs.data.UpdateCounter(key, value, fs.logCounter)
 
 	if fs.isSync() {
 		fs.save()
@@ -120,11 +170,10 @@ func (fs *FileStorage) UpdateCounterByName(
 
 // UpdateGaugeByName returns updated gauge value and nil error.
 func (fs *FileStorage) UpdateGaugeByName(
-	_ context.Context, name string, value float64,
+	_ context.Context, name string, labels metrics.Labels, value float64,
 ) (float64, error) {
-	fs.mu.Lock()
-	fs.data.Gauge[name] = value
-	fs.mu.Unlock()
+	key := metrics.MakeKey(name, labels)
+	fs.data.SetGauge(key, value, fs.logGauge)
 
 	if fs.isSync() {
 		fs.save()
@@ -132,50 +181,129 @@ func (fs *FileStorage) UpdateGaugeByName(
 	return value, nil
 }
 
+// UpdateHistogramByName returns merged histogram value
+// or [metrics.ErrHistogramMismatch] if bounds are not equal.
+func (fs *FileStorage) UpdateHistogramByName(
+	_ context.Context, name string, labels metrics.Labels, value metrics.Histogram,
+) (metrics.Histogram, error) {
+	key := metrics.MakeKey(name, labels)
+	current, err := fs.data.MergeHistogram(key, value, fs.logHistogram)
+	if err != nil {
+		return metrics.Histogram{}, err
+	}
+
+	if fs.isSync() {
+		fs.save()
+	}
+	return current, nil
+}
+
 // UpdateCounterList returns nil error.
 func (fs *FileStorage) UpdateCounterList(
-	ctx context.Context, mSlice metrics.MetricsList,
+	_ context.Context, mSlice metrics.MetricsList,
 ) error {
-	for _, item := range mSlice {
-		fs.UpdateCounterByName(ctx, item.ID, item.Delta)
+	fs.data.UpdateCounterList(mSlice, fs.logCounter)
+
+	if fs.isSync() {
+		fs.save()
 	}
 	return nil
 }
 
 // UpdateGaugeList returns nil error.
 func (fs *FileStorage) UpdateGaugeList(
-	ctx context.Context, mSlice metrics.MetricsList,
+	_ context.Context, mSlice metrics.MetricsList,
 ) error {
-	for _, item := range mSlice {
-		fs.UpdateGaugeByName(ctx, item.ID, item.Value)
+	fs.data.SetGaugeList(mSlice, fs.logGauge)
+
+	if fs.isSync() {
+		fs.save()
 	}
 	return nil
 }
 
+// UpdateHistogramList returns first histogram merge error, if occur.
+func (fs *FileStorage) UpdateHistogramList(
+	_ context.Context, mSlice metrics.MetricsList,
+) error {
+	err := fs.data.MergeHistogramList(mSlice, fs.logHistogram)
+
+	if fs.isSync() {
+		fs.save()
+	}
+	return err
+}
+
+// UpdateBatch applies mixed metrics list atomically, unless batch
+// is applied, and reports whether the batch is duplicate.
+// Not identified batch is always applied.
+//
+// Shards of the list are locked together, see [memstorage.Sharded.ApplyBatch].
+// If some items can't be applied, nothing is applied and
+// [metrics.BatchErrors] are returned, the batch is not remembered then.
+// In WAL mode applied batch record follows the batch updates records.
+func (fs *FileStorage) UpdateBatch(
+	_ context.Context, id metrics.BatchID, mSlice metrics.MetricsList,
+) (bool, error) {
+	apply := func() error {
+		return fs.data.ApplyBatch(mSlice, memstorage.Callbacks{
+			Counter:   fs.logCounter,
+			Gauge:     fs.logGauge,
+			Histogram: fs.logHistogram,
+		})
+	}
+
+	var duplicate bool
+	var err error
+	if id.IsZero() {
+		err = apply()
+	} else {
+		duplicate, err = fs.batches.apply(id, apply, fs.logBatch)
+	}
+
+	if err == nil && !duplicate && fs.isSync() {
+		fs.save()
+	}
+	return duplicate, err
+}
+
 // ReadCounterByName returns counter value and nil error.
 func (fs *FileStorage) ReadCounterByName(
-	_ context.Context, name string,
+	_ context.Context, name string, labels metrics.Labels,
 ) (int64, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
-	value, ok := fs.data.Counter[name]
+	key := metrics.MakeKey(name, labels)
+	value, ok := fs.data.Counter(key)
 
 	if !ok {
-		return 0, fmt.Errorf("metric '%s' is %w", name, server.ErrNotExists)
+		return 0, fmt.Errorf("metric '%s' is %w", key, server.ErrNotExists)
 	}
 	return value, nil
 }
 
 // ReadGaugeByName returns gauge value and nil error
 func (fs *FileStorage) ReadGaugeByName(
-	_ context.Context, name string,
+	_ context.Context, name string, labels metrics.Labels,
 ) (float64, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
-	value, ok := fs.data.Gauge[name]
+	key := metrics.MakeKey(name, labels)
+	value, ok := fs.data.Gauge(key)
+
+	if !ok {
+		return 0, fmt.Errorf("metric '%s' is %w", key, server.ErrNotExists)
+	}
+	return value, nil
+}
+
+// ReadHistogramByName returns histogram value and nil error.
+func (fs *FileStorage) ReadHistogramByName(
+	_ context.Context, name string, labels metrics.Labels,
+) (metrics.Histogram, error) {
+	key := metrics.MakeKey(name, labels)
+	value, ok := fs.data.Histogram(key)
 
 	if !ok {
-		return 0, fmt.Errorf("metric '%s' is %w", name, server.ErrNotExists)
+		return metrics.Histogram{}, fmt.Errorf(
+			"metric '%s' is %w", key, server.ErrNotExists,
+		)
 	}
 	return value, nil
 }
@@ -184,26 +312,59 @@ func (fs *FileStorage) ReadGaugeByName(
 func (fs *FileStorage) ReadGauge(
 	_ context.Context,
 ) (map[string]float64, error) {
-	gauge := make(map[string]float64, len(fs.data.Gauge))
-
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
-	maps.Copy(gauge, fs.data.Gauge)
-
-	return gauge, nil
+	return fs.data.Gauges(), nil
 }
 
 // ReadCounter returns counter metrics copy.
 func (fs *FileStorage) ReadCounter(
 	_ context.Context,
 ) (map[string]int64, error) {
-	counter := make(map[string]int64, len(fs.data.Counter))
+	return fs.data.Counters(), nil
+}
+
+// ReadHistogram returns histogram metrics copy.
+func (fs *FileStorage) ReadHistogram(
+	_ context.Context,
+) (map[string]metrics.Histogram, error) {
+	return fs.data.Histograms(), nil
+}
+
+// DeleteByName deletes metric or returns [server.ErrNotExists].
+func (fs *FileStorage) DeleteByName(
+	_ context.Context, mType, name string, labels metrics.Labels,
+) error {
+	key := metrics.MakeKey(name, labels)
+	if !fs.data.Delete(mType, key, fs.logDelete) {
+		return fmt.Errorf("metric '%s' is %w", key, server.ErrNotExists)
+	}
 
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
-	maps.Copy(counter, fs.data.Counter)
+	if fs.isSync() {
+		fs.save()
+	}
+	return nil
+}
+
+// DeleteExpired deletes metrics not updated since before.
+//
+// Update time is stored in the file and the log, restored metrics
+// of files saved by previous versions are treated as updated on start.
+func (fs *FileStorage) DeleteExpired(
+	_ context.Context, before time.Time,
+) (int64, error) {
+	deleted := fs.data.DeleteExpired(before, fs.logDelete)
+
+	if deleted != 0 && fs.isSync() {
+		fs.save()
+	}
+	return deleted, nil
+}
 
-	return counter, nil
+// ReadHistory returns [server.ErrNotSupported],
+// FileStorage keeps only current metrics values.
+func (fs *FileStorage) ReadHistory(
+	_ context.Context, _ metrics.RangeQuery,
+) ([]metrics.RangeBucket, error) {
+	return nil, server.ErrNotSupported
 }
 
 func (fs *FileStorage) restoreData() error {
@@ -211,36 +372,153 @@ func (fs *FileStorage) restoreData() error {
 		if err := fs.fo.Clear(); err != nil {
 			return err
 		}
+		if fs.isWAL() {
+			if err := fs.wal.clear(); err != nil {
+				return err
+			}
+		}
 	}
 
 	loaded, err := fs.fo.Load()
 	if err != nil {
 		return err
 	}
-	if len(loaded) == 0 {
-		return nil
+
+	data := snapshot{Data: memstorage.NewData()}
+	if len(loaded) != 0 {
+		err = json.Unmarshal(loaded, &data)
+		if err != nil {
+			return err
+		}
+	}
+	if data.Counter == nil {
+		data.Counter = make(map[string]int64)
+	}
+	if data.Gauge == nil {
+		data.Gauge = make(map[string]float64)
+	}
+	if data.Histogram == nil {
+		data.Histogram = make(map[string]metrics.Histogram)
+	}
+	if data.Updated == nil {
+		data.Updated = make(map[string]map[string]time.Time)
 	}
 
-	var data data
-	err = json.Unmarshal(loaded, &data)
-	if err != nil {
-		return err
+	if fs.isWAL() {
+		if err = fs.wal.replay(&data); err != nil {
+			return err
+		}
 	}
 
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
-	fs.data = data
+	fs.data.Restore(data.Data)
+	fs.batches.restore(data.Batches, time.Now().Add(-metrics.BatchWindow))
 	return nil
 }
 
 func (fs *FileStorage) isSync() bool {
-	return fs.interval == 0
+	return fs.interval == 0 && !fs.isWAL()
+}
+
+func (fs *FileStorage) isWAL() bool {
+	return fs.walPath != ""
+}
+
+func (fs *FileStorage) logCounter(key string, value int64) {
+	fs.appendLog(walRecord{MType: metrics.MTypeCounter, Key: key, Delta: value})
+}
+
+func (fs *FileStorage) logGauge(key string, value float64) {
+	fs.appendLog(walRecord{MType: metrics.MTypeGauge, Key: key, Value: value})
+}
+
+func (fs *FileStorage) logHistogram(key string, value metrics.Histogram) {
+	fs.appendLog(
+		walRecord{MType: metrics.MTypeHistogram, Key: key, Histogram: &value},
+	)
+}
+
+func (fs *FileStorage) logDelete(mType, key string) {
+	fs.appendLog(walRecord{MType: mType, Key: key, Deleted: true})
+}
+
+func (fs *FileStorage) logBatch(id metrics.BatchID, at time.Time) {
+	fs.appendLog(
+		walRecord{MType: walBatchType, Key: id.AgentID, Seq: id.Seq, At: at},
+	)
+}
+
+// appendLog writes record to write-ahead log, record without time
+// is stamped with current time. It should be called under
+// metric shard lock, so records order of each metric is the same as
+// its updates order. Records of different metrics could interleave,
+// it is harmless because records keep absolute values.
+func (fs *FileStorage) appendLog(r walRecord) {
+	const op = "filestorage.appendLog"
+	if !fs.isWAL() {
+		return
+	}
+	if r.At.IsZero() {
+		r.At = time.Now()
+	}
+	size, err := fs.wal.append(r)
+	if err != nil {
+		logger.Log.Error(
+			"failed to append log record", zap.String("op", op), zap.Error(err),
+		)
+		return
+	}
+	if size >= walCompactSize {
+		select {
+		case fs.compactC <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// compact saves snapshot and archives log records included in it.
+//
+// Log is rotated before snapshot is taken, so every rotated record
+// is already applied to the snapshot. Archived logs are kept for
+// previous snapshots, so restore from previous snapshot loses nothing.
+// Updates are never blocked for the whole data, only one shard
+// at a time while it is copied.
+func (fs *FileStorage) compact() error {
+	if err := fs.wal.rotate(); err != nil {
+		return err
+	}
+	if err := fs.save(); err != nil {
+		return err
+	}
+	return fs.wal.archiveRotated()
+}
+
+func (fs *FileStorage) compactWorker() {
+	const op = "filestorage.compactWorker"
+	defer fs.wg.Done()
+	for {
+		select {
+		case <-fs.stopC:
+			return
+		case <-fs.compactC:
+			if err := fs.compact(); err != nil {
+				logger.Log.Error(
+					"failed to compact log", zap.String("op", op), zap.Error(err),
+				)
+			}
+		}
+	}
 }
 
+// save writes data snapshot to the file. Saves are serialized,
+// so older snapshot never overwrites newer one.
 func (fs *FileStorage) save() error {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
-	data, err := json.Marshal(fs.data)
+	fs.saveMu.Lock()
+	defer fs.saveMu.Unlock()
+	// Batches go first: every remembered batch is already in metrics.
+	batches := fs.batches.snapshot(time.Now().Add(-metrics.BatchWindow))
+	data, err := json.Marshal(
+		snapshot{Data: fs.data.Snapshot(), Batches: batches},
+	)
 	if err != nil {
 		return err
 	}
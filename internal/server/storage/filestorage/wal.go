@@ -0,0 +1,319 @@
+package filestorage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	// walRotatedSuffix is suffix of log rotated for compaction,
+	// archived logs have the next suffixes ".2", ".3", ...
+	walRotatedSuffix = ".1"
+
+	// walCompactSize is log size in bytes, after which
+	// the log is compacted into snapshot.
+	walCompactSize = 4 << 20
+
+	walMaxRecordSize = 1 << 20
+
+	// walBatchType is record type of applied batch,
+	// it follows records of the batch updates.
+	walBatchType = "batch"
+)
+
+// walRecord is log record with metrics value after update and update
+// time or applied batch id and apply time.
+//
+// Records keep absolute values, not deltas, so replaying a record
+// already included in snapshot is harmless. Deleted record removes metric.
+type walRecord struct {
+	MType     string             `json:"t"`
+	Key       string             `json:"k"`
+	Delta     int64              `json:"d,omitempty"`
+	Value     float64            `json:"v,omitempty"`
+	Histogram *metrics.Histogram `json:"h,omitempty"`
+	Deleted   bool               `json:"x,omitempty"`
+	Seq       uint64             `json:"s,omitempty"`
+	At        time.Time          `json:"at,omitzero"`
+}
+
+// apply sets record value to data, deletes metric
+// or remembers applied batch, which agent id is record key.
+func (r walRecord) apply(s *snapshot) {
+	d := s.Data
+	if r.MType == walBatchType {
+		if s.Batches == nil {
+			s.Batches = make(map[string]map[uint64]time.Time)
+		}
+		if s.Batches[r.Key] == nil {
+			s.Batches[r.Key] = make(map[uint64]time.Time)
+		}
+		s.Batches[r.Key][r.Seq] = r.At
+		return
+	}
+
+	if r.Deleted {
+		delete(d.Updated[r.MType], r.Key)
+		switch r.MType {
+		case metrics.MTypeCounter:
+			delete(d.Counter, r.Key)
+		case metrics.MTypeGauge:
+			delete(d.Gauge, r.Key)
+		case metrics.MTypeHistogram:
+			delete(d.Histogram, r.Key)
+		}
+		return
+	}
+
+	if !r.At.IsZero() {
+		d.SetUpdated(r.MType, r.Key, r.At)
+	}
+	switch r.MType {
+	case metrics.MTypeCounter:
+		d.Counter[r.Key] = r.Delta
+	case metrics.MTypeGauge:
+		d.Gauge[r.Key] = r.Value
+	case metrics.MTypeHistogram:
+		if r.Histogram != nil {
+			d.Histogram[r.Key] = r.Histogram.Clone()
+		}
+	}
+}
+
+// wal is append-only write-ahead log of JSON line records.
+//
+// On compaction the current log is rotated to path with ".1" suffix,
+// which is archived after snapshot is saved. Keep archived logs are kept
+// with ".2", ".3", ... suffixes, one per previous snapshot.
+// Log methods are safe for concurrent use.
+type wal struct {
+	mu   sync.Mutex
+	path string
+	keep int
+	f    *os.File
+	w    *bufio.Writer
+	size int64
+}
+
+// openWAL opens or creates log file for append,
+// keep is number of archived logs.
+func openWAL(path string, keep int) (*wal, error) {
+	l := &wal{path: path, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *wal) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f = f
+	l.w = bufio.NewWriter(f)
+	l.size = stat.Size()
+	return nil
+}
+
+// append writes record, flushes it to the file and returns log size.
+func (l *wal) append(r walRecord) (int64, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err = l.w.Write(b); err != nil {
+		return l.size, err
+	}
+	if err = l.w.Flush(); err != nil {
+		return l.size, err
+	}
+	l.size += int64(len(b))
+	return l.size, nil
+}
+
+// rotate moves current log records to rotated log and starts empty log.
+//
+// If rotated log is left by failed compaction, current records
+// are appended to it, so no record is lost before snapshot is saved.
+func (l *wal) rotate() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+
+	rotated := l.path + walRotatedSuffix
+	_, err := os.Stat(rotated)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if err = os.Rename(l.path, rotated); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if err = appendFile(rotated, l.path); err != nil {
+			return err
+		}
+		if err = os.Truncate(l.path, 0); err != nil {
+			return err
+		}
+	}
+	return l.open()
+}
+
+// archiveRotated archives rotated log, when its records are in snapshot.
+// Archived logs are shifted, the oldest one out of keep is removed.
+func (l *wal) archiveRotated() error {
+	paths := l.rotatedPaths()
+	err := os.Remove(paths[len(paths)-1])
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for idx := len(paths) - 1; idx > 0; idx-- {
+		err = os.Rename(paths[idx-1], paths[idx])
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotatedPaths returns rotated and archived logs paths
+// from newest to oldest.
+func (l *wal) rotatedPaths() []string {
+	paths := []string{l.path + walRotatedSuffix}
+	for n := 2; n <= l.keep+1; n++ {
+		paths = append(paths, l.path+"."+strconv.Itoa(n))
+	}
+	return paths
+}
+
+// clear removes all log records.
+func (l *wal) clear() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, path := range l.rotatedPaths() {
+		err := os.Remove(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := l.f.Truncate(0); err != nil {
+		return err
+	}
+	l.w.Reset(l.f)
+	l.size = 0
+	return nil
+}
+
+// replay applies archived, rotated and current log records to data
+// in write order, so data of previous snapshot is restored as well.
+// Records of archived logs are in the newest snapshot already,
+// replaying them is harmless, because records keep absolute values.
+//
+// Replay of a log stops at first broken record, because only the last
+// record could be partially written.
+func (l *wal) replay(s *snapshot) error {
+	paths := l.rotatedPaths()
+	slices.Reverse(paths)
+	for _, path := range append(paths, l.path) {
+		if err := replayFile(path, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *wal) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+	return l.f.Close()
+}
+
+func replayFile(path string, s *snapshot) error {
+	const op = "filestorage.replayFile"
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, walMaxRecordSize)
+	var line int
+	for scanner.Scan() {
+		line++
+		var r walRecord
+		if err = json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			logger.Log.Warn(
+				"broken log record, replay stopped",
+				zap.String("op", op),
+				zap.String("path", path),
+				zap.Int("line", line),
+				zap.Error(err),
+			)
+			return nil
+		}
+		r.apply(s)
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// appendFile appends src file content to dst file.
+func appendFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,217 @@
+package filestorage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/fileoperator"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWAL(t *testing.T) {
+	logger.Init("fatal")
+	ctx := context.Background()
+	hostA := metrics.Labels{"host": "a"}
+
+	// open returns running storage over dir files, crash is simulated
+	// by opening again without Stop.
+	open := func(t *testing.T, dir string, restore bool) *FileStorage {
+		path := filepath.Join(dir, "store.json")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		require.NoError(t, err)
+		fs := New(fileoperator.New(f, 1), 0, restore, path+".wal", 1, 4)
+		require.NoError(t, fs.Run())
+		return fs
+	}
+
+	update := func(t *testing.T, fs *FileStorage) {
+		_, err := fs.UpdateCounterByName(ctx, "PollCount", nil, 5)
+		require.NoError(t, err)
+		_, err = fs.UpdateCounterByName(ctx, "PollCount", nil, 7)
+		require.NoError(t, err)
+		_, err = fs.UpdateGaugeByName(ctx, "Alloc", hostA, 1.5)
+		require.NoError(t, err)
+		h := metrics.NewHistogram(1, 2)
+		h.Observe(1.5)
+		_, err = fs.UpdateHistogramByName(ctx, "Latency", nil, h)
+		require.NoError(t, err)
+	}
+
+	// assertRestored checks values after passed number of update calls.
+	assertRestored := func(t *testing.T, fs *FileStorage, updates int) {
+		c, err := fs.ReadCounterByName(ctx, "PollCount", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(12*updates), c)
+		g, err := fs.ReadGaugeByName(ctx, "Alloc", hostA)
+		require.NoError(t, err)
+		assert.InDelta(t, 1.5, g, 0)
+		h, err := fs.ReadHistogramByName(ctx, "Latency", nil)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(updates), h.Count)
+	}
+
+	t.Run("Should replay log after crash", func(t *testing.T) {
+		dir := t.TempDir()
+		update(t, open(t, dir, true))
+
+		fs := open(t, dir, true)
+		assertRestored(t, fs, 1)
+	})
+
+	t.Run("Should replay log over snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		require.NoError(t, fs.compact())
+		update(t, fs)
+
+		_, err := os.Stat(filepath.Join(dir, "store.json.wal.1"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		fs = open(t, dir, true)
+		assertRestored(t, fs, 2)
+	})
+
+	t.Run("Should replay archived log over previous snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		require.NoError(t, fs.compact())
+		update(t, fs)
+		require.NoError(t, fs.compact())
+		update(t, fs)
+
+		_, err := os.Stat(filepath.Join(dir, "store.json.wal.2"))
+		require.NoError(t, err, "log of the newest snapshot is archived")
+		_, err = os.Stat(filepath.Join(dir, "store.json.wal.3"))
+		assert.ErrorIs(t, err, os.ErrNotExist, "only keep logs are archived")
+
+		err = os.WriteFile(filepath.Join(dir, "store.json"), []byte("{"), 0644)
+		require.NoError(t, err)
+		fs = open(t, dir, true)
+		assertRestored(t, fs, 3)
+	})
+
+	t.Run("Should keep rotated log after failed compaction", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		require.NoError(t, fs.wal.rotate())
+		update(t, fs)
+		require.NoError(t, fs.wal.rotate())
+		update(t, fs)
+
+		fs = open(t, dir, true)
+		assertRestored(t, fs, 3)
+	})
+
+	t.Run("Should skip broken tail record", func(t *testing.T) {
+		dir := t.TempDir()
+		update(t, open(t, dir, true))
+
+		f, err := os.OpenFile(
+			filepath.Join(dir, "store.json.wal"), os.O_WRONLY|os.O_APPEND, 0644,
+		)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"t":"counter","k":"PollC`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		fs := open(t, dir, true)
+		assertRestored(t, fs, 1)
+	})
+
+	t.Run("Should replay deleted metrics", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		require.NoError(t, fs.compact())
+		require.NoError(
+			t, fs.DeleteByName(ctx, metrics.MTypeGauge, "Alloc", hostA),
+		)
+		err := fs.DeleteByName(ctx, metrics.MTypeGauge, "Alloc", hostA)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+		deleted, err := fs.DeleteExpired(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+
+		fs = open(t, dir, true)
+		_, err = fs.ReadGaugeByName(ctx, "Alloc", hostA)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+		_, err = fs.ReadCounterByName(ctx, "PollCount", nil)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+		_, err = fs.ReadHistogramByName(ctx, "Latency", nil)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+	})
+
+	t.Run("Should restore update time", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		require.NoError(t, fs.compact())
+		_, err := fs.UpdateGaugeByName(ctx, "Free", nil, 2)
+		require.NoError(t, err)
+		before := time.Now()
+
+		// metrics of snapshot and log are not treated as updated on start
+		fs = open(t, dir, true)
+		deleted, err := fs.DeleteExpired(ctx, before)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), deleted)
+	})
+
+	t.Run("Should remember applied batches", func(t *testing.T) {
+		dir := t.TempDir()
+		ml := metrics.MetricsList{
+			{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1.5},
+		}
+		updateOnce := func(t *testing.T, fs *FileStorage, seq uint64) bool {
+			id := metrics.BatchID{AgentID: "host-a", Seq: seq}
+			duplicate, err := fs.UpdateBatch(ctx, id, ml)
+			require.NoError(t, err)
+			return duplicate
+		}
+
+		fs := open(t, dir, true)
+		assert.False(t, updateOnce(t, fs, 1))
+		assert.True(t, updateOnce(t, fs, 1))
+		require.NoError(t, fs.compact())
+		assert.False(t, updateOnce(t, fs, 2))
+
+		// batch 1 is restored from snapshot, batch 2 from log
+		fs = open(t, dir, true)
+		assert.True(t, updateOnce(t, fs, 1))
+		assert.True(t, updateOnce(t, fs, 2))
+		assert.False(t, updateOnce(t, fs, 3))
+		c, err := fs.ReadCounterByName(ctx, "PollCount", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(15), c)
+	})
+
+	t.Run("Should compact on stop and clear without restore", func(t *testing.T) {
+		dir := t.TempDir()
+		fs := open(t, dir, true)
+		update(t, fs)
+		fs.Stop()
+
+		info, err := os.Stat(filepath.Join(dir, "store.json.wal"))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+
+		fs = open(t, dir, true)
+		assertRestored(t, fs, 1)
+		fs.Stop()
+
+		fs = open(t, dir, false)
+		_, err = fs.ReadCounterByName(ctx, "PollCount", nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,517 @@
+// Package memstorage provides lock-striped in-memory metrics engine.
+package memstorage
+
+import (
+	"fmt"
+	"hash/maphash"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// DefaultShards is default shards number.
+const DefaultShards = 32
+
+// Data is metrics values keyed by metrics key, see [metrics.MakeKey].
+//
+// Updated is metrics last update time by metric type and key,
+// it could miss metrics of data saved by previous versions.
+type Data struct {
+	Counter   map[string]int64                `json:"counter"`
+	Gauge     map[string]float64              `json:"gauge"`
+	Histogram map[string]metrics.Histogram    `json:"histogram"`
+	Updated   map[string]map[string]time.Time `json:"updated,omitempty"`
+}
+
+// NewData returns empty Data.
+func NewData() Data {
+	return Data{
+		Counter:   make(map[string]int64),
+		Gauge:     make(map[string]float64),
+		Histogram: make(map[string]metrics.Histogram),
+		Updated:   make(map[string]map[string]time.Time),
+	}
+}
+
+// SetUpdated sets last update time of metric.
+func (d Data) SetUpdated(mType, key string, at time.Time) {
+	if d.Updated[mType] == nil {
+		d.Updated[mType] = make(map[string]time.Time)
+	}
+	d.Updated[mType][key] = at
+}
+
+// updateKey is metric type and key.
+type updateKey struct {
+	mType string
+	key   string
+}
+
+type shard struct {
+	mu   sync.RWMutex
+	data Data
+	// updated keeps metrics last update time for expiration.
+	updated map[updateKey]time.Time
+}
+
+func newShard() *shard {
+	return &shard{data: NewData(), updated: make(map[updateKey]time.Time)}
+}
+
+// Sharded keeps metrics in shards selected by metrics key hash,
+// every shard has own lock. Writers of different shards never wait
+// for each other and snapshot locks one shard at a time.
+type Sharded struct {
+	seed   maphash.Seed
+	shards []*shard
+}
+
+// New returns Sharded pointer with n shards, at least one.
+func New(n int) *Sharded {
+	n = max(n, 1)
+	s := &Sharded{seed: maphash.MakeSeed(), shards: make([]*shard, n)}
+	for idx := range s.shards {
+		s.shards[idx] = newShard()
+	}
+	return s
+}
+
+// UpdateCounter adds delta to counter and returns current value.
+// If onUpdate is not nil, it is called with current value under shard lock.
+func (s *Sharded) UpdateCounter(
+	key string, delta int64, onUpdate func(key string, value int64),
+) int64 {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.updateCounter(key, delta, onUpdate)
+}
+
+// SetGauge sets gauge value.
+// If onUpdate is not nil, it is called with value under shard lock.
+func (s *Sharded) SetGauge(
+	key string, value float64, onUpdate func(key string, value float64),
+) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.setGauge(key, value, onUpdate)
+}
+
+// MergeHistogram merges value to stored histogram and returns merged copy
+// or [metrics.ErrHistogramMismatch] if bounds are not equal.
+// If onUpdate is not nil, it is called with merged value under shard lock.
+func (s *Sharded) MergeHistogram(
+	key string,
+	value metrics.Histogram,
+	onUpdate func(key string, value metrics.Histogram),
+) (metrics.Histogram, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.mergeHistogram(key, value, onUpdate)
+}
+
+// UpdateCounterList adds list deltas to counters, every shard is locked once.
+func (s *Sharded) UpdateCounterList(
+	ml metrics.MetricsList, onUpdate func(key string, value int64),
+) {
+	s.eachShard(ml, func(sh *shard, idx int, key string) error {
+		sh.updateCounter(key, ml[idx].Delta, onUpdate)
+		return nil
+	})
+}
+
+// SetGaugeList sets list values to gauges, every shard is locked once.
+func (s *Sharded) SetGaugeList(
+	ml metrics.MetricsList, onUpdate func(key string, value float64),
+) {
+	s.eachShard(ml, func(sh *shard, idx int, key string) error {
+		sh.setGauge(key, ml[idx].Value, onUpdate)
+		return nil
+	})
+}
+
+// MergeHistogramList merges list histograms, every shard is locked once.
+// Items without histogram are skipped. Merging stops at first error.
+func (s *Sharded) MergeHistogramList(
+	ml metrics.MetricsList,
+	onUpdate func(key string, value metrics.Histogram),
+) error {
+	return s.eachShard(ml, func(sh *shard, idx int, key string) error {
+		if ml[idx].Histogram == nil {
+			return nil
+		}
+		_, err := sh.mergeHistogram(key, *ml[idx].Histogram, onUpdate)
+		return err
+	})
+}
+
+// Callbacks are called with metric value after update under shard lock.
+// Nil callback is not called.
+type Callbacks struct {
+	Counter   func(key string, value int64)
+	Gauge     func(key string, value float64)
+	Histogram func(key string, value metrics.Histogram)
+}
+
+// ApplyBatch applies mixed metrics list atomically.
+//
+// Shards of list items are locked together in shard order, then items
+// are checked and only if every item can be applied, they are applied
+// in list order. Otherwise nothing is changed and [metrics.BatchErrors]
+// are returned: invalid histogram, histogram bounds mismatch
+// and unknown type.
+func (s *Sharded) ApplyBatch(ml metrics.MetricsList, cb Callbacks) error {
+	keys := make([]string, len(ml))
+	shardOf := make([]int, len(ml))
+	locked := make([]bool, len(s.shards))
+	for idx := range ml {
+		keys[idx] = ml[idx].Key()
+		shardOf[idx] = s.shardIdx(keys[idx])
+		locked[shardOf[idx]] = true
+	}
+	for n, ok := range locked {
+		if ok {
+			s.shards[n].mu.Lock()
+		}
+	}
+	defer func() {
+		for n, ok := range locked {
+			if ok {
+				s.shards[n].mu.Unlock()
+			}
+		}
+	}()
+
+	var errs metrics.BatchErrors
+	// bounds are histogram bounds after preceding items are merged
+	bounds := make(map[string][]float64)
+	for idx, m := range ml {
+		switch m.MType {
+		case metrics.MTypeCounter, metrics.MTypeGauge:
+		case metrics.MTypeHistogram:
+			if err := metrics.VerifyHistogram(m); err != nil {
+				errs = append(errs, metrics.NewItemError(idx, m, err))
+				continue
+			}
+			current, ok := bounds[keys[idx]]
+			if !ok {
+				var stored metrics.Histogram
+				stored, ok = s.shards[shardOf[idx]].data.Histogram[keys[idx]]
+				current = stored.Bounds
+			}
+			if ok && !slices.Equal(current, m.Histogram.Bounds) {
+				errs = append(
+					errs, metrics.NewItemError(idx, m, metrics.ErrHistogramMismatch),
+				)
+				continue
+			}
+			bounds[keys[idx]] = m.Histogram.Bounds
+		default:
+			errs = append(errs, metrics.NewItemError(idx, m, metrics.ErrInvalidType))
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+
+	for idx, m := range ml {
+		sh := s.shards[shardOf[idx]]
+		switch m.MType {
+		case metrics.MTypeCounter:
+			sh.updateCounter(keys[idx], m.Delta, cb.Counter)
+		case metrics.MTypeGauge:
+			sh.setGauge(keys[idx], m.Value, cb.Gauge)
+		case metrics.MTypeHistogram:
+			sh.mergeHistogram(keys[idx], *m.Histogram, cb.Histogram)
+		}
+	}
+	return nil
+}
+
+// Counter returns counter value and existence flag.
+func (s *Sharded) Counter(key string) (int64, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	v, ok := sh.data.Counter[key]
+	return v, ok
+}
+
+// Gauge returns gauge value and existence flag.
+func (s *Sharded) Gauge(key string) (float64, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	v, ok := sh.data.Gauge[key]
+	return v, ok
+}
+
+// Histogram returns histogram copy and existence flag.
+func (s *Sharded) Histogram(key string) (metrics.Histogram, bool) {
+	sh := s.shard(key)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+	v, ok := sh.data.Histogram[key]
+	return v.Clone(), ok
+}
+
+// Snapshot returns copy of all metrics.
+//
+// Shards are copied one by one, so writers are blocked only
+// while their shard is copied. Snapshot is consistent per metric,
+// not across shards.
+func (s *Sharded) Snapshot() Data {
+	d := NewData()
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		maps.Copy(d.Counter, sh.data.Counter)
+		maps.Copy(d.Gauge, sh.data.Gauge)
+		for k, v := range sh.data.Histogram {
+			d.Histogram[k] = v.Clone()
+		}
+		for k, at := range sh.updated {
+			d.SetUpdated(k.mType, k.key, at)
+		}
+		sh.mu.RUnlock()
+	}
+	return d
+}
+
+// Counters returns counters copy, shards are copied one by one.
+func (s *Sharded) Counters() map[string]int64 {
+	counter := make(map[string]int64)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		maps.Copy(counter, sh.data.Counter)
+		sh.mu.RUnlock()
+	}
+	return counter
+}
+
+// Gauges returns gauges copy, shards are copied one by one.
+func (s *Sharded) Gauges() map[string]float64 {
+	gauge := make(map[string]float64)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		maps.Copy(gauge, sh.data.Gauge)
+		sh.mu.RUnlock()
+	}
+	return gauge
+}
+
+// Histograms returns histograms copy, shards are copied one by one.
+func (s *Sharded) Histograms() map[string]metrics.Histogram {
+	histogram := make(map[string]metrics.Histogram)
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for k, v := range sh.data.Histogram {
+			histogram[k] = v.Clone()
+		}
+		sh.mu.RUnlock()
+	}
+	return histogram
+}
+
+// Delete deletes metric of type, calls onDelete under shard lock
+// and returns false if metric does not exist.
+func (s *Sharded) Delete(
+	mType, key string, onDelete func(mType, key string),
+) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if !sh.delete(mType, key) {
+		return false
+	}
+	if onDelete != nil {
+		onDelete(mType, key)
+	}
+	return true
+}
+
+// DeleteExpired deletes metrics not updated since before
+// and returns number of deleted metrics. Shards are locked one by one,
+// onDelete is called for every deleted metric under shard lock.
+func (s *Sharded) DeleteExpired(
+	before time.Time, onDelete func(mType, key string),
+) int64 {
+	var deleted int64
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		for k, updated := range sh.updated {
+			if !updated.Before(before) {
+				continue
+			}
+			sh.delete(k.mType, k.key)
+			if onDelete != nil {
+				onDelete(k.mType, k.key)
+			}
+			deleted++
+		}
+		sh.mu.Unlock()
+	}
+	return deleted
+}
+
+// Restore replaces all metrics with data.
+// Restored metrics without update time are treated as updated now.
+func (s *Sharded) Restore(d Data) {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+	}
+	defer func() {
+		for _, sh := range s.shards {
+			sh.mu.Unlock()
+		}
+	}()
+
+	now := time.Now()
+	updated := func(mType, key string) time.Time {
+		if at, ok := d.Updated[mType][key]; ok {
+			return at
+		}
+		return now
+	}
+	for _, sh := range s.shards {
+		sh.data = NewData()
+		sh.updated = make(map[updateKey]time.Time)
+	}
+	for k, v := range d.Counter {
+		sh := s.shard(k)
+		sh.data.Counter[k] = v
+		sh.updated[updateKey{metrics.MTypeCounter, k}] = updated(
+			metrics.MTypeCounter, k,
+		)
+	}
+	for k, v := range d.Gauge {
+		sh := s.shard(k)
+		sh.data.Gauge[k] = v
+		sh.updated[updateKey{metrics.MTypeGauge, k}] = updated(
+			metrics.MTypeGauge, k,
+		)
+	}
+	for k, v := range d.Histogram {
+		sh := s.shard(k)
+		sh.data.Histogram[k] = v.Clone()
+		sh.updated[updateKey{metrics.MTypeHistogram, k}] = updated(
+			metrics.MTypeHistogram, k,
+		)
+	}
+}
+
+func (s *Sharded) shard(key string) *shard {
+	return s.shards[s.shardIdx(key)]
+}
+
+func (s *Sharded) shardIdx(key string) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+	return int(maphash.String(s.seed, key) % uint64(len(s.shards)))
+}
+
+// eachShard locks every shard of list items once and calls fn
+// for its items under the lock in list order. It stops at first fn error.
+func (s *Sharded) eachShard(
+	ml metrics.MetricsList, fn func(sh *shard, idx int, key string) error,
+) error {
+	keys := make([]string, len(ml))
+	shardOf := make([]int, len(ml))
+	count := make([]int, len(s.shards)+1)
+	for idx := range ml {
+		keys[idx] = ml[idx].Key()
+		shardOf[idx] = s.shardIdx(keys[idx])
+		count[shardOf[idx]+1]++
+	}
+
+	// order holds item indexes sorted by shard, shard items
+	// are order[count[n]:count[n+1]]
+	for n := 1; n < len(count); n++ {
+		count[n] += count[n-1]
+	}
+	order := make([]int, len(ml))
+	next := make([]int, len(s.shards))
+	copy(next, count)
+	for idx, n := range shardOf {
+		order[next[n]] = idx
+		next[n]++
+	}
+
+	for n, sh := range s.shards {
+		items := order[count[n]:count[n+1]]
+		if len(items) == 0 {
+			continue
+		}
+		sh.mu.Lock()
+		for _, idx := range items {
+			if err := fn(sh, idx, keys[idx]); err != nil {
+				sh.mu.Unlock()
+				return err
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return nil
+}
+
+func (sh *shard) updateCounter(
+	key string, delta int64, onUpdate func(string, int64),
+) int64 {
+	current := sh.data.Counter[key] + delta
+	sh.data.Counter[key] = current
+	sh.updated[updateKey{metrics.MTypeCounter, key}] = time.Now()
+	if onUpdate != nil {
+		onUpdate(key, current)
+	}
+	return current
+}
+
+func (sh *shard) setGauge(
+	key string, value float64, onUpdate func(string, float64),
+) {
+	sh.data.Gauge[key] = value
+	sh.updated[updateKey{metrics.MTypeGauge, key}] = time.Now()
+	if onUpdate != nil {
+		onUpdate(key, value)
+	}
+}
+
+func (sh *shard) mergeHistogram(
+	key string, value metrics.Histogram, onUpdate func(string, metrics.Histogram),
+) (metrics.Histogram, error) {
+	current, ok := sh.data.Histogram[key]
+	if !ok {
+		current = value.Clone()
+	} else if err := current.Merge(value); err != nil {
+		return metrics.Histogram{}, fmt.Errorf("metric '%s': %w", key, err)
+	}
+	sh.data.Histogram[key] = current
+	sh.updated[updateKey{metrics.MTypeHistogram, key}] = time.Now()
+	if onUpdate != nil {
+		onUpdate(key, current)
+	}
+	return current.Clone(), nil
+}
+
+// delete deletes metric and returns false if it does not exist.
+func (sh *shard) delete(mType, key string) bool {
+	var ok bool
+	switch mType {
+	case metrics.MTypeCounter:
+		_, ok = sh.data.Counter[key]
+		delete(sh.data.Counter, key)
+	case metrics.MTypeGauge:
+		_, ok = sh.data.Gauge[key]
+		delete(sh.data.Gauge, key)
+	case metrics.MTypeHistogram:
+		_, ok = sh.data.Histogram[key]
+		delete(sh.data.Histogram, key)
+	}
+	delete(sh.updated, updateKey{mType, key})
+	return ok
+}
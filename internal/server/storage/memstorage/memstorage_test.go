@@ -0,0 +1,200 @@
+package memstorage_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/server/storage/memstorage"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharded(t *testing.T) {
+	t.Run("Should sum concurrent counter updates", func(t *testing.T) {
+		s := memstorage.New(8)
+		var ml metrics.MetricsList
+		for idx := range 100 {
+			ml = append(ml, metrics.Metrics{
+				ID: fmt.Sprintf("c%d", idx), MType: metrics.MTypeCounter, Delta: 1,
+			})
+		}
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.UpdateCounterList(ml, nil)
+				s.UpdateCounter("total", 1, nil)
+			}()
+		}
+		wg.Wait()
+
+		snapshot := s.Snapshot()
+		assert.Len(t, snapshot.Counter, 101)
+		for _, item := range ml {
+			assert.Equal(t, int64(10), snapshot.Counter[item.Key()])
+		}
+		v, ok := s.Counter("total")
+		assert.True(t, ok)
+		assert.Equal(t, int64(10), v)
+	})
+
+	t.Run("Should call onUpdate with current value", func(t *testing.T) {
+		s := memstorage.New(4)
+		got := make(map[string]float64)
+		ml := metrics.MetricsList{
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1.5},
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 2.5},
+			{ID: "Heap", MType: metrics.MTypeGauge, Value: 3},
+		}
+		s.SetGaugeList(ml, func(key string, value float64) {
+			got[key] = value
+		})
+		assert.Equal(t, map[string]float64{"Alloc": 2.5, "Heap": 3}, got)
+
+		var counter int64
+		s.UpdateCounter("PollCount", 2, nil)
+		s.UpdateCounter("PollCount", 3, func(_ string, value int64) {
+			counter = value
+		})
+		assert.Equal(t, int64(5), counter)
+	})
+
+	t.Run("Should not merge histograms with other bounds", func(t *testing.T) {
+		s := memstorage.New(4)
+		_, err := s.MergeHistogram("Latency", metrics.NewHistogram(1, 2), nil)
+		require.NoError(t, err)
+
+		_, err = s.MergeHistogram("Latency", metrics.NewHistogram(1), nil)
+		assert.ErrorIs(t, err, metrics.ErrHistogramMismatch)
+	})
+
+	t.Run("Should apply batch atomically", func(t *testing.T) {
+		s := memstorage.New(4)
+		_, err := s.MergeHistogram("Latency", metrics.NewHistogram(1, 2), nil)
+		require.NoError(t, err)
+		other := metrics.NewHistogram(5)
+		ml := metrics.MetricsList{
+			{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+			{ID: "Latency", MType: metrics.MTypeHistogram, Histogram: &other},
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1.5},
+			{ID: "Size", MType: "unknown"},
+		}
+
+		err = s.ApplyBatch(ml, memstorage.Callbacks{})
+		var errs metrics.BatchErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 2)
+		assert.Equal(t, 1, errs[0].Index)
+		assert.ErrorIs(t, errs[0], metrics.ErrHistogramMismatch)
+		assert.Equal(t, 3, errs[1].Index)
+		assert.ErrorIs(t, errs[1], metrics.ErrInvalidType)
+		_, ok := s.Counter("PollCount")
+		assert.False(t, ok)
+		_, ok = s.Gauge("Alloc")
+		assert.False(t, ok)
+
+		var updated []string
+		cb := memstorage.Callbacks{
+			Counter: func(key string, _ int64) { updated = append(updated, key) },
+			Gauge:   func(key string, _ float64) { updated = append(updated, key) },
+		}
+		require.NoError(t, s.ApplyBatch(ml[:1], cb))
+		require.NoError(t, s.ApplyBatch(ml[2:3], cb))
+		assert.Equal(t, []string{"PollCount", "Alloc"}, updated)
+	})
+
+	t.Run("Should check histograms merged before in batch", func(t *testing.T) {
+		s := memstorage.New(4)
+		first := metrics.NewHistogram(1)
+		second := metrics.NewHistogram(2)
+		ml := metrics.MetricsList{
+			{ID: "Latency", MType: metrics.MTypeHistogram, Histogram: &first},
+			{ID: "Latency", MType: metrics.MTypeHistogram, Histogram: &second},
+		}
+		err := s.ApplyBatch(ml, memstorage.Callbacks{})
+		assert.ErrorIs(t, err, metrics.ErrHistogramMismatch)
+		_, ok := s.Histogram("Latency")
+		assert.False(t, ok)
+	})
+
+	t.Run("Should restore snapshot", func(t *testing.T) {
+		s := memstorage.New(4)
+		s.UpdateCounter("PollCount", 5, nil)
+		s.SetGauge("Alloc{host=a}", 1.5, nil)
+		h := metrics.NewHistogram(1, 2)
+		h.Observe(1.5)
+		_, err := s.MergeHistogram("Latency", h, nil)
+		require.NoError(t, err)
+		snapshot := s.Snapshot()
+
+		restored := memstorage.New(1)
+		restored.SetGauge("Stale", 1, nil)
+		restored.Restore(snapshot)
+		assert.Equal(t, snapshot, restored.Snapshot())
+		assert.Equal(t, snapshot.Counter, restored.Counters())
+		assert.Equal(t, snapshot.Gauge, restored.Gauges())
+		assert.Equal(t, snapshot.Histogram, restored.Histograms())
+
+		// snapshot is a copy
+		s.UpdateCounter("PollCount", 1, nil)
+		assert.Equal(t, int64(5), snapshot.Counter["PollCount"])
+	})
+
+	t.Run("Should restore update time", func(t *testing.T) {
+		s := memstorage.New(4)
+		s.SetGauge("Alloc", 1.5, nil)
+		s.UpdateCounter("PollCount", 5, nil)
+		before := time.Now().Add(time.Millisecond)
+		snapshot := s.Snapshot()
+		require.Len(t, snapshot.Updated, 2)
+
+		restored := memstorage.New(1)
+		restored.Restore(snapshot)
+		assert.Equal(t, int64(2), restored.DeleteExpired(before, nil))
+
+		// data without update time is treated as updated now
+		time.Sleep(2 * time.Millisecond)
+		snapshot.Updated = nil
+		restored.Restore(snapshot)
+		assert.Zero(t, restored.DeleteExpired(before, nil))
+	})
+
+	t.Run("Should delete metric", func(t *testing.T) {
+		s := memstorage.New(4)
+		s.SetGauge("Alloc", 1.5, nil)
+		s.UpdateCounter("Alloc", 1, nil)
+
+		var deleted []string
+		onDelete := func(mType, key string) {
+			deleted = append(deleted, mType+":"+key)
+		}
+		assert.True(t, s.Delete(metrics.MTypeGauge, "Alloc", onDelete))
+		assert.False(t, s.Delete(metrics.MTypeGauge, "Alloc", onDelete))
+		assert.Equal(t, []string{"gauge:Alloc"}, deleted)
+
+		_, ok := s.Gauge("Alloc")
+		assert.False(t, ok)
+		_, ok = s.Counter("Alloc")
+		assert.True(t, ok)
+	})
+
+	t.Run("Should delete expired metrics", func(t *testing.T) {
+		s := memstorage.New(4)
+		s.SetGauge("Old", 1, nil)
+		s.UpdateCounter("Old", 1, nil)
+		before := time.Now().Add(time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		s.SetGauge("New", 1, nil)
+
+		assert.Equal(t, int64(2), s.DeleteExpired(before, nil))
+		snapshot := s.Snapshot()
+		assert.Equal(t, map[string]float64{"New": 1}, snapshot.Gauge)
+		assert.Empty(t, snapshot.Counter)
+		assert.Zero(t, s.DeleteExpired(before, nil))
+	})
+}
@@ -0,0 +1,47 @@
+package psqlstorage
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/sign"
+	"go.uber.org/zap"
+)
+
+// AgentKey returns public key of agent batch signatures from agent_key
+// table. Error wraps [sign.ErrUnknownAgent] if the agent has no key and
+// [sign.ErrRevokedAgent] if revoked_at is set, otherwise it returns
+// sql driver error, if occur.
+func (ps *PSQLStorage) AgentKey(
+	ctx context.Context, agentID string,
+) (ed25519.PublicKey, error) {
+	logPrefix := "Read agent key"
+	stmt := `SELECT public_key, revoked_at IS NOT NULL FROM agent_key
+		WHERE agent_id = $1;`
+	row := ps.db.QueryRowContext(ctx, stmt, agentID)
+
+	var (
+		publicKey string
+		revoked   bool
+	)
+	err := scanRowWithRetries(ctx, row, logPrefix, &publicKey, &revoked)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("%w '%s'", sign.ErrUnknownAgent, agentID)
+	case err != nil:
+		logger.Log.Error(logPrefix+": scan row", zap.Error(err))
+		return nil, err
+	case revoked:
+		return nil, fmt.Errorf("%w '%s'", sign.ErrRevokedAgent, agentID)
+	}
+
+	key, err := sign.ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("agent '%s': %w", agentID, err)
+	}
+	return key, nil
+}
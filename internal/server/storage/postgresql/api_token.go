@@ -0,0 +1,131 @@
+package psqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/token"
+	"go.uber.org/zap"
+)
+
+// uniqueViolation is PostgreSQL error code of unique constraint violation.
+const uniqueViolation = "23505"
+
+// Token returns API token with id from api_token table. Error wraps
+// [token.ErrUnknownToken] if the token does not exist, otherwise it
+// returns sql driver error, if occur.
+func (ps *PSQLStorage) Token(ctx context.Context, id string) (token.Token, error) {
+	logPrefix := "Read token"
+	stmt := `SELECT hash, scopes, created_at, revoked_at IS NOT NULL
+		FROM api_token WHERE id = $1;`
+	row := ps.db.QueryRowContext(ctx, stmt, id)
+
+	t := token.Token{ID: id}
+	var scopes string
+	err := scanRowWithRetries(
+		ctx, row, logPrefix, &t.Hash, &scopes, &t.CreatedAt, &t.Revoked,
+	)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return token.Token{}, fmt.Errorf("%w '%s'", token.ErrUnknownToken, id)
+	case err != nil:
+		logger.Log.Error(logPrefix+": scan row", zap.Error(err))
+		return token.Token{}, err
+	}
+
+	t.Scopes, err = token.ParseScopes(scopes)
+	if err != nil {
+		return token.Token{}, fmt.Errorf("token '%s': %w", id, err)
+	}
+	return t, nil
+}
+
+// Tokens returns API tokens ordered by id or sql driver error, if occur.
+func (ps *PSQLStorage) Tokens(ctx context.Context) ([]token.Token, error) {
+	logPrefix := "Read tokens"
+	stmt := `SELECT id, hash, scopes, created_at, revoked_at IS NOT NULL
+		FROM api_token ORDER BY id;`
+	rows, err := queryWithRetries(ctx, ps.db, stmt, logPrefix)
+	if err != nil {
+		logger.Log.Error(logPrefix+": query", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []token.Token
+	for rows.Next() {
+		var (
+			t      token.Token
+			scopes string
+		)
+		err = rows.Scan(&t.ID, &t.Hash, &scopes, &t.CreatedAt, &t.Revoked)
+		if err != nil {
+			logger.Log.Error(logPrefix+": scan rows", zap.Error(err))
+			return nil, err
+		}
+		if t.Scopes, err = token.ParseScopes(scopes); err != nil {
+			return nil, fmt.Errorf("token '%s': %w", t.ID, err)
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error(logPrefix+": after rows scan iteration", zap.Error(err))
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// AddToken stores API token. Error wraps [token.ErrDuplicateID]
+// if token with the same id exists, otherwise it returns sql driver
+// error, if occur.
+func (ps *PSQLStorage) AddToken(ctx context.Context, t token.Token) error {
+	logPrefix := "Add token"
+	_, err := execWithRetries(
+		ctx,
+		ps.db,
+		`INSERT INTO api_token (id, hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4);`,
+		logger.Log.With(zap.String("op", logPrefix)),
+		t.ID, t.Hash, t.Scopes.String(), t.CreatedAt,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("%w '%s'", token.ErrDuplicateID, t.ID)
+	}
+	if err != nil {
+		logger.Log.Error(logPrefix+": exec", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// RevokeToken revokes API token. Error wraps [token.ErrUnknownToken]
+// if the token does not exist, otherwise it returns sql driver error,
+// if occur. Revoked token is kept for audit.
+func (ps *PSQLStorage) RevokeToken(ctx context.Context, id string) error {
+	logPrefix := "Revoke token"
+	result, err := execWithRetries(
+		ctx,
+		ps.db,
+		`UPDATE api_token SET revoked_at = COALESCE(revoked_at, now())
+		WHERE id = $1;`,
+		logger.Log.With(zap.String("op", logPrefix)),
+		id,
+	)
+	if err != nil {
+		logger.Log.Error(logPrefix+": exec", zap.Error(err))
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("%w '%s'", token.ErrUnknownToken, id)
+	}
+	return nil
+}
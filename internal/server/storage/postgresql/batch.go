@@ -0,0 +1,184 @@
+package psqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+// UpdateBatch applies mixed metrics list in one transaction, unless batch
+// is applied, and reports whether the batch is duplicate.
+// Not identified batch is always applied.
+//
+// If some items can't be applied, the transaction is rolled back and
+// [metrics.BatchErrors] are returned: invalid histogram, histogram bounds
+// mismatch and unknown type. Otherwise it returns sql driver error, if occur.
+//
+// Batch id is inserted in the same transaction with the updates,
+// so concurrent duplicate waits for the first one on the primary key
+// and the batch is remembered only if updates are committed.
+func (ps *PSQLStorage) UpdateBatch(
+	ctx context.Context, id metrics.BatchID, mSlice metrics.MetricsList,
+) (bool, error) {
+	logPrefix := "Update batch"
+	tx, err := beginTxWithRetries(
+		ctx, ps.db, logPrefix+": begin transaction", nil,
+	)
+	if err != nil {
+		logger.Log.Error(logPrefix+": begin transaction", zap.Error(err))
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if !id.IsZero() {
+		applied, err := insertBatch(ctx, tx, id)
+		if err != nil {
+			logger.Log.Error(logPrefix+": insert batch", zap.Error(err))
+			return false, err
+		}
+		if !applied {
+			return true, nil
+		}
+	}
+
+	var errs metrics.BatchErrors
+	var gl, cl metrics.MetricsList
+	var hIdx []int
+	for idx, m := range mSlice {
+		switch m.MType {
+		case metrics.MTypeGauge:
+			gl = append(gl, m)
+		case metrics.MTypeCounter:
+			cl = append(cl, m)
+		case metrics.MTypeHistogram:
+			if err = metrics.VerifyHistogram(m); err != nil {
+				errs = append(errs, metrics.NewItemError(idx, m, err))
+				continue
+			}
+			hIdx = append(hIdx, idx)
+		default:
+			errs = append(errs, metrics.NewItemError(idx, m, metrics.ErrInvalidType))
+		}
+	}
+
+	if len(gl) != 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			withSample(metrics.MTypeGauge, updateGaugeListStmt),
+			gaugeListArgs(gl)...,
+		)
+		if err != nil {
+			logger.Log.Error(logPrefix+": gauge", zap.Error(err))
+			return false, err
+		}
+	}
+
+	if len(cl) != 0 {
+		_, err = tx.ExecContext(
+			ctx,
+			withSample(metrics.MTypeCounter, updateCounterListStmt),
+			counterListArgs(cl)...,
+		)
+		if err != nil {
+			logger.Log.Error(logPrefix+": counter", zap.Error(err))
+			return false, err
+		}
+	}
+
+	// merge mismatch does not break transaction, so every
+	// mismatched item is found before rollback
+	for _, idx := range hIdx {
+		item := mSlice[idx]
+		_, err = mergeHistogram(ctx, tx, item.ID, item.Labels, *item.Histogram)
+		if errors.Is(err, metrics.ErrHistogramMismatch) {
+			errs = append(
+				errs, metrics.NewItemError(idx, item, metrics.ErrHistogramMismatch),
+			)
+			continue
+		}
+		if err != nil {
+			logger.Log.Error(logPrefix+": merge", zap.Error(err))
+			return false, err
+		}
+	}
+
+	if len(errs) != 0 {
+		slices.SortFunc(errs, func(a, b *metrics.ItemError) int {
+			return a.Index - b.Index
+		})
+		return false, errs
+	}
+
+	if err = commitWithRetries(ctx, tx, logPrefix+": commit"); err != nil {
+		logger.Log.Error(logPrefix+": commit", zap.Error(err))
+		return false, err
+	}
+	return false, nil
+}
+
+// insertBatch reports whether batch id is inserted,
+// false means the batch is already applied.
+func insertBatch(ctx context.Context, tx *sql.Tx, id metrics.BatchID) (bool, error) {
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO applied_batch (agent_id, seq)
+		 VALUES ($1, $2::NUMERIC)
+		 ON CONFLICT (agent_id, seq) DO NOTHING;`,
+		id.AgentID, strconv.FormatUint(id.Seq, 10),
+	)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted == 1, nil
+}
+
+// batchWorker forgets applied batches out of batch ttl until storage
+// is stopped.
+func (ps *PSQLStorage) batchWorker() {
+	defer ps.wg.Done()
+	ticker := time.NewTicker(batchExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ps.stop:
+			return
+		case now := <-ticker.C:
+			ps.expireBatchesWithTimeout(now)
+		}
+	}
+}
+
+func (ps *PSQLStorage) expireBatchesWithTimeout(now time.Time) {
+	const op = "psqlstorage.expireBatches"
+	ctx, cancel := context.WithTimeout(
+		context.Background(), batchExpireTimeout,
+	)
+	defer cancel()
+	if err := ps.expireBatches(ctx, now); err != nil {
+		logger.Log.Error(
+			"failed to expire applied batches",
+			zap.String("op", op), zap.Error(err),
+		)
+	}
+}
+
+// expireBatches forgets batches applied earlier than batch ttl before now.
+func (ps *PSQLStorage) expireBatches(ctx context.Context, now time.Time) error {
+	_, err := ps.db.ExecContext(
+		ctx,
+		`DELETE FROM applied_batch WHERE applied_at < $1;`,
+		now.Add(-ps.batchTTL),
+	)
+	return err
+}
@@ -0,0 +1,424 @@
+package psqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	historyMaintainInterval = time.Minute
+	historyMaintainTimeout  = 30 * time.Second
+
+	// rollupDelay gives in-flight update transactions time to commit
+	// samples before their bucket is rolled up.
+	rollupDelay = time.Minute
+
+	// historyLockID is advisory lock key, so only one server instance
+	// maintains history at a time.
+	historyLockID = 0x72756e6c79
+
+	partitionPrefix  = "history_p"
+	partitionDefault = "history_default"
+	partitionLayout  = "20060102"
+	partitionRange   = 24 * time.Hour
+)
+
+// A rollup describes downsampled history resolution.
+type rollup struct {
+	resolution string
+	step       time.Duration
+	retention  time.Duration
+}
+
+// rollups are ordered from fine to coarse resolution.
+// The first rollup is built from raw samples, the next from the previous one.
+var rollups = []rollup{
+	{resolution: "1m", step: time.Minute, retention: 7 * 24 * time.Hour},
+	{resolution: "1h", step: time.Hour, retention: 90 * 24 * time.Hour},
+}
+
+// withSample wraps upsert statement, so the upserted value is recorded
+// in history table as sample of mType. Statement returns upserted value.
+func withSample(mType, upsert string) string {
+	return `WITH upsert AS (` + upsert + ` RETURNING name, labels, value),
+	sample AS (
+		INSERT INTO history (mtype, name, labels, ts, value)
+		SELECT '` + mType + `', name, labels, now(), value FROM upsert
+	)
+	SELECT value FROM upsert;`
+}
+
+// historyWorker maintains history until storage is stopped.
+func (ps *PSQLStorage) historyWorker() {
+	defer ps.wg.Done()
+	ticker := time.NewTicker(historyMaintainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ps.stop:
+			return
+		case now := <-ticker.C:
+			ps.maintainHistoryWithTimeout(now)
+		}
+	}
+}
+
+func (ps *PSQLStorage) maintainHistoryWithTimeout(now time.Time) {
+	const op = "psqlstorage.maintainHistory"
+	ctx, cancel := context.WithTimeout(
+		context.Background(), historyMaintainTimeout,
+	)
+	defer cancel()
+	if err := ps.maintainHistory(ctx, now); err != nil {
+		logger.Log.Error(
+			"failed to maintain history", zap.String("op", op), zap.Error(err),
+		)
+	}
+}
+
+// maintainHistory creates upcoming partitions, rolls up completed buckets
+// and removes samples and rollups out of retention.
+//
+// If another instance holds the maintenance lock, maintainHistory does nothing.
+func (ps *PSQLStorage) maintainHistory(ctx context.Context, now time.Time) error {
+	logPrefix := "Maintain history"
+	tx, err := beginTxWithRetries(
+		ctx, ps.db, logPrefix+": begin transaction", nil,
+	)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	row := tx.QueryRowContext(
+		ctx, `SELECT pg_try_advisory_xact_lock($1);`, historyLockID,
+	)
+	if err = row.Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	now = now.UTC()
+	if err = ps.ensurePartitions(ctx, tx, now); err != nil {
+		return fmt.Errorf("ensure partitions: %w", err)
+	}
+	if err = ps.rollupHistory(ctx, tx, now); err != nil {
+		return fmt.Errorf("rollup: %w", err)
+	}
+	if err = ps.expireHistory(ctx, tx, now); err != nil {
+		return fmt.Errorf("expire: %w", err)
+	}
+
+	return commitWithRetries(ctx, tx, logPrefix+": commit")
+}
+
+// ensurePartitions creates daily partitions covering retention window
+// and the next day. Samples out of the partitions land in default
+// partition, so updates never depend on history maintenance.
+func (ps *PSQLStorage) ensurePartitions(
+	ctx context.Context, tx *sql.Tx, now time.Time,
+) error {
+	first := now.Add(-ps.retention).Truncate(partitionRange)
+	last := now.Truncate(partitionRange).Add(partitionRange)
+	for day := first; !day.After(last); day = day.Add(partitionRange) {
+		if err := ensurePartition(ctx, tx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensurePartition creates partition of day, if it does not exist.
+// Samples of the day in default partition are moved to the new
+// partition before it is attached.
+func ensurePartition(ctx context.Context, tx *sql.Tx, day time.Time) error {
+	name := partitionPrefix + day.Format(partitionLayout)
+	var exists bool
+	row := tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL;`, name)
+	if err := row.Scan(&exists); err != nil || exists {
+		return err
+	}
+
+	from := day.Format(time.RFC3339)
+	to := day.Add(partitionRange).Format(time.RFC3339)
+	stmts := []string{
+		`CREATE TABLE ` + name + ` (LIKE history INCLUDING DEFAULTS);`,
+		// samples out of partitions wait until the partition is attached
+		`LOCK TABLE ` + partitionDefault + ` IN EXCLUSIVE MODE;`,
+		`WITH moved AS (
+			DELETE FROM ` + partitionDefault + `
+			WHERE ts >= '` + from + `' AND ts < '` + to + `'
+			RETURNING mtype, name, labels, ts, value
+		)
+		INSERT INTO ` + name + ` (mtype, name, labels, ts, value)
+		SELECT mtype, name, labels, ts, value FROM moved;`,
+		`ALTER TABLE history ATTACH PARTITION ` + name +
+			` FOR VALUES FROM ('` + from + `') TO ('` + to + `');`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupHistory aggregates buckets completed since the previous run
+// for every rollup resolution.
+func (ps *PSQLStorage) rollupHistory(
+	ctx context.Context, tx *sql.Tx, now time.Time,
+) error {
+	until := now.Add(-rollupDelay)
+	for idx, r := range rollups {
+		until = until.Truncate(r.step)
+
+		var since time.Time
+		row := tx.QueryRowContext(
+			ctx,
+			`SELECT done_until FROM history_rollup_state
+			 WHERE resolution = $1;`,
+			r.resolution,
+		)
+		err := row.Scan(&since)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			since = until.Add(-ps.retention).Truncate(r.step)
+		case err != nil:
+			return err
+		}
+
+		if !until.After(since) {
+			continue
+		}
+
+		stmt := rollupRawStmt
+		args := []any{r.resolution, int64(r.step.Seconds()), since, until}
+		if idx != 0 {
+			stmt = rollupStmt
+			args = append(args, rollups[idx-1].resolution)
+		}
+		if _, err = tx.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("resolution '%s': %w", r.resolution, err)
+		}
+
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO history_rollup_state (resolution, done_until)
+			 VALUES ($1, $2)
+			 ON CONFLICT (resolution) DO UPDATE SET
+			 done_until = EXCLUDED.done_until;`,
+			r.resolution, until,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireHistory drops raw partitions and deletes samples and rollups
+// older than their retention.
+func (ps *PSQLStorage) expireHistory(
+	ctx context.Context, tx *sql.Tx, now time.Time,
+) error {
+	expired := now.Add(-ps.retention)
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT c.relname FROM pg_inherits i
+		 JOIN pg_class c ON c.oid = i.inhrelid
+		 JOIN pg_class p ON p.oid = i.inhparent
+		 WHERE p.relname = 'history';`,
+	)
+	if err != nil {
+		return err
+	}
+	var partitions []string
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		partitions = append(partitions, name)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, name := range partitions {
+		day, err := time.Parse(
+			partitionLayout, strings.TrimPrefix(name, partitionPrefix),
+		)
+		if err != nil || day.Add(partitionRange).After(expired) {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, `DROP TABLE `+name+`;`); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM history WHERE ts < $1;`, expired)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rollups {
+		_, err = tx.ExecContext(
+			ctx,
+			`DELETE FROM history_rollup
+			 WHERE resolution = $1 AND bucket < $2;`,
+			r.resolution, now.Add(-r.retention),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadHistory returns history of metric aggregated by query step
+// and sql driver error, if occur.
+//
+// Raw samples are used while query range is within raw retention,
+// otherwise the finest rollup which covers range.
+// If step is finer than the rollup, error wraps [server.ErrRangeStep].
+func (ps *PSQLStorage) ReadHistory(
+	ctx context.Context, q metrics.RangeQuery,
+) ([]metrics.RangeBucket, error) {
+	logPrefix := "Read history"
+	stepSec := int64(q.Step / time.Second)
+	args := []any{q.MType, q.ID, q.Labels.String(), stepSec, q.From, q.To}
+
+	r, ok, err := ps.rangeRollup(q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	stmt := readHistoryRawStmt
+	if ok {
+		stmt = readHistoryRollupStmt
+		args = append(args, r.resolution)
+	}
+
+	rows, err := queryWithRetries(ctx, ps.db, stmt, logPrefix, args...)
+	if err != nil {
+		logger.Log.Error(logPrefix+": query", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []metrics.RangeBucket
+	for rows.Next() {
+		var b metrics.RangeBucket
+		err = rows.Scan(&b.Time, &b.Min, &b.Max, &b.Sum, &b.Count, &b.Last)
+		if err != nil {
+			logger.Log.Error(logPrefix+": scan rows", zap.Error(err))
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error(logPrefix+": after rows scan iteration", zap.Error(err))
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// rangeRollup returns rollup for query, false means raw samples.
+//
+// It is the finest rollup covering query range, or the coarsest one.
+// Rollup buckets are re-bucketed by query step, so the step should not be
+// finer than the rollup, otherwise error wraps [server.ErrRangeStep].
+func (ps *PSQLStorage) rangeRollup(
+	q metrics.RangeQuery, now time.Time,
+) (rollup, bool, error) {
+	if !q.From.Before(now.Add(-ps.retention)) {
+		return rollup{}, false, nil
+	}
+
+	fit := rollups[len(rollups)-1]
+	for _, r := range rollups {
+		if !q.From.Before(now.Add(-r.retention)) {
+			fit = r
+			break
+		}
+	}
+	if q.Step < fit.step {
+		return rollup{}, false, fmt.Errorf(
+			"%w, at least %s", server.ErrRangeStep, fit.resolution,
+		)
+	}
+	return fit, true, nil
+}
+
+// readHistoryRawStmt aggregates raw samples in [$5, $6) into $4 seconds buckets.
+const readHistoryRawStmt = `
+SELECT to_timestamp(floor(floor(extract(epoch FROM ts)) / $4) * $4),
+	min(value), max(value), sum(value), count(*),
+	(array_agg(value ORDER BY ts DESC))[1]
+FROM history
+WHERE mtype = $1 AND name = $2 AND labels = $3 AND ts >= $5 AND ts < $6
+GROUP BY 1
+ORDER BY 1;`
+
+// readHistoryRollupStmt aggregates $7 resolution rollups in [$5, $6)
+// into $4 seconds buckets.
+const readHistoryRollupStmt = `
+SELECT to_timestamp(floor(floor(extract(epoch FROM bucket)) / $4) * $4),
+	min(min), max(max), sum(sum), sum(count)::BIGINT,
+	(array_agg(last ORDER BY bucket DESC))[1]
+FROM history_rollup
+WHERE mtype = $1 AND name = $2 AND labels = $3
+	AND bucket >= $5 AND bucket < $6 AND resolution = $7
+GROUP BY 1
+ORDER BY 1;`
+
+// rollupRawStmt aggregates raw samples in [$3, $4) into $2 seconds buckets.
+const rollupRawStmt = `
+INSERT INTO history_rollup
+	(mtype, name, labels, resolution, bucket, min, max, sum, count, last)
+SELECT mtype, name, labels, $1::TEXT,
+	to_timestamp(floor(floor(extract(epoch FROM ts)) / $2) * $2),
+	min(value), max(value), sum(value), count(*),
+	(array_agg(value ORDER BY ts DESC))[1]
+FROM history
+WHERE ts >= $3 AND ts < $4
+GROUP BY 1, 2, 3, 5
+ON CONFLICT (mtype, name, labels, resolution, bucket) DO UPDATE SET
+	min = LEAST(history_rollup.min, EXCLUDED.min),
+	max = GREATEST(history_rollup.max, EXCLUDED.max),
+	sum = history_rollup.sum + EXCLUDED.sum,
+	count = history_rollup.count + EXCLUDED.count,
+	last = EXCLUDED.last;`
+
+// rollupStmt aggregates $5 resolution rollups in [$3, $4)
+// into $2 seconds buckets.
+const rollupStmt = `
+INSERT INTO history_rollup
+	(mtype, name, labels, resolution, bucket, min, max, sum, count, last)
+SELECT mtype, name, labels, $1::TEXT,
+	to_timestamp(floor(floor(extract(epoch FROM bucket)) / $2) * $2),
+	min(min), max(max), sum(sum), sum(count),
+	(array_agg(last ORDER BY bucket DESC))[1]
+FROM history_rollup
+WHERE resolution = $5 AND bucket >= $3 AND bucket < $4
+GROUP BY 1, 2, 3, 5
+ON CONFLICT (mtype, name, labels, resolution, bucket) DO UPDATE SET
+	min = LEAST(history_rollup.min, EXCLUDED.min),
+	max = GREATEST(history_rollup.max, EXCLUDED.max),
+	sum = history_rollup.sum + EXCLUDED.sum,
+	count = history_rollup.count + EXCLUDED.count,
+	last = EXCLUDED.last;`
@@ -0,0 +1,74 @@
+package psqlstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/server"
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeRollup(t *testing.T) {
+	ps := &PSQLStorage{retention: 24 * time.Hour}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		from    time.Time
+		step    time.Duration
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Should use raw samples within retention",
+			from: now.Add(-time.Hour),
+			step: time.Second,
+		},
+		{
+			name: "Should use finest rollup covering range",
+			from: now.Add(-48 * time.Hour),
+			step: time.Minute,
+			want: "1m",
+		},
+		{
+			name: "Should re-bucket rollup of not multiple step",
+			from: now.Add(-48 * time.Hour),
+			step: 90 * time.Second,
+			want: "1m",
+		},
+		{
+			name: "Should use coarse rollup of old range",
+			from: now.Add(-30 * 24 * time.Hour),
+			step: 90 * time.Minute,
+			want: "1h",
+		},
+		{
+			name: "Should use coarsest rollup out of retention",
+			from: now.Add(-365 * 24 * time.Hour),
+			step: 24 * time.Hour,
+			want: "1h",
+		},
+		{
+			name:    "Should reject step finer than rollup",
+			from:    now.Add(-30 * 24 * time.Hour),
+			step:    time.Minute,
+			wantErr: server.ErrRangeStep,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := metrics.RangeQuery{From: test.from, Step: test.step}
+			r, ok, err := ps.rangeRollup(q, now)
+			if test.wantErr != nil {
+				assert.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want != "", ok)
+			assert.Equal(t, test.want, r.resolution)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package psqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/niksmo/runlytics/internal/logger"
+	"go.uber.org/zap"
+)
+
+// migrationLockID is advisory lock key, so concurrently starting
+// server instances apply migrations one at a time.
+const migrationLockID = 0x72756e6d67
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// ErrSchemaTooNew is returned when database schema version
+// is newer than the latest migration known to the binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than the binary")
+
+// A Migration is schema change from embedded file "NNNN_name.sql".
+type Migration struct {
+	Version int
+	Name    string
+	stmt    string
+}
+
+// A MigrationStatus describes migration state in database.
+// Unknown migration is applied to database, but is not known to the binary.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	Unknown   bool
+	AppliedAt time.Time
+}
+
+var migrations = mustLoadMigrations(migrationsFS)
+
+// Migrate applies pending migrations in version order and returns them.
+//
+// Migrations run under advisory lock, each one in its own transaction
+// together with its schema_version record. Tables created before
+// versioning are migrated in place, first migrations are idempotent.
+func (ps *PSQLStorage) Migrate(ctx context.Context) ([]Migration, error) {
+	const op = "psqlstorage.Migrate"
+	log := logger.Log.With(zap.String("op", op))
+
+	var pending []Migration
+	err := ps.withMigrationLock(ctx, func(conn *sql.Conn) error {
+		current, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if latest := migrations[len(migrations)-1].Version; current > latest {
+			return fmt.Errorf(
+				"%w: database version %d, binary version %d",
+				ErrSchemaTooNew, current, latest,
+			)
+		}
+
+		for _, m := range migrations {
+			if m.Version <= current {
+				continue
+			}
+			if err = applyMigration(ctx, conn, m); err != nil {
+				return err
+			}
+			log.Info(
+				"migration applied",
+				zap.Int("version", m.Version),
+				zap.String("name", m.Name),
+			)
+			pending = append(pending, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return pending, fmt.Errorf("%s: %w", op, err)
+	}
+	return pending, nil
+}
+
+// MigrationStatus returns known and unknown migrations state
+// in version order.
+//
+// Status is read only: it takes no migration lock and does not create
+// schema_version table, so it shows migrations committed by running
+// migration and all migrations pending for database without the table.
+func (ps *PSQLStorage) MigrationStatus(
+	ctx context.Context,
+) ([]MigrationStatus, error) {
+	const op = "psqlstorage.MigrationStatus"
+
+	applied, err := ps.appliedMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var status []MigrationStatus
+	for _, m := range migrations {
+		s, ok := applied[m.Version]
+		if !ok {
+			s = MigrationStatus{Version: m.Version, Name: m.Name}
+		}
+		delete(applied, m.Version)
+		status = append(status, s)
+	}
+	for _, s := range applied {
+		s.Unknown = true
+		status = append(status, s)
+	}
+	slices.SortFunc(status, func(a, b MigrationStatus) int {
+		return a.Version - b.Version
+	})
+	return status, nil
+}
+
+// appliedMigrations returns schema_version records by version,
+// no records if the table does not exist.
+func (ps *PSQLStorage) appliedMigrations(
+	ctx context.Context,
+) (map[int]MigrationStatus, error) {
+	applied := make(map[int]MigrationStatus)
+
+	var exists bool
+	err := ps.db.QueryRowContext(
+		ctx, `SELECT to_regclass('schema_version') IS NOT NULL;`,
+	).Scan(&exists)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := ps.db.QueryContext(
+		ctx, `SELECT version, name, applied_at FROM schema_version;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s MigrationStatus
+		if err = rows.Scan(&s.Version, &s.Name, &s.AppliedAt); err != nil {
+			return nil, err
+		}
+		s.Applied = true
+		applied[s.Version] = s
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// withMigrationLock calls fn on connection holding migration lock,
+// schema_version table is created before.
+func (ps *PSQLStorage) withMigrationLock(
+	ctx context.Context, fn func(conn *sql.Conn) error,
+) (err error) {
+	conn, err := ps.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(
+		ctx, `SELECT pg_advisory_lock($1);`, migrationLockID,
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, unlockErr := conn.ExecContext(
+			context.Background(),
+			`SELECT pg_advisory_unlock($1);`,
+			migrationLockID,
+		)
+		err = errors.Join(err, unlockErr)
+	}()
+
+	_, err = conn.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(
+		ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version;`,
+	).Scan(&version)
+	return version, err
+}
+
+func applyMigration(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, m.stmt); err != nil {
+		return fmt.Errorf("migration %d '%s': %w", m.Version, m.Name, err)
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO schema_version (version, name) VALUES ($1, $2);`,
+		m.Version, m.Name,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mustLoadMigrations returns migrations sorted by version.
+// It panics on bad file name, duplicated version or empty set,
+// so broken embedded migrations fail on start.
+func mustLoadMigrations(fsys fs.FS) []Migration {
+	files, err := fs.Glob(fsys, "migrations/*.sql")
+	if err != nil {
+		panic(err)
+	}
+
+	var ms []Migration
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || version <= 0 {
+			panic(fmt.Sprintf("migration '%s': want NNNN_name.sql", file))
+		}
+		stmt, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			panic(err)
+		}
+		ms = append(ms, Migration{Version: version, Name: name, stmt: string(stmt)})
+	}
+
+	if len(ms) == 0 {
+		panic("no migrations")
+	}
+	slices.SortFunc(ms, func(a, b Migration) int {
+		return a.Version - b.Version
+	})
+	for idx := 1; idx < len(ms); idx++ {
+		if ms[idx].Version == ms[idx-1].Version {
+			panic(fmt.Sprintf("migration version %d is duplicated", ms[idx].Version))
+		}
+	}
+	return ms
+}
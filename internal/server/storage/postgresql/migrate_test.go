@@ -0,0 +1,48 @@
+package psqlstorage
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMustLoadMigrations(t *testing.T) {
+	t.Run("Embedded migrations are ordered from one", func(t *testing.T) {
+		require.NotEmpty(t, migrations)
+		for idx, m := range migrations {
+			assert.Equal(t, idx+1, m.Version)
+			assert.NotEmpty(t, m.Name)
+			assert.NotEmpty(t, m.stmt)
+		}
+	})
+
+	t.Run("Should sort by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0010_second.sql": {Data: []byte("SELECT 2;")},
+			"migrations/0002_first.sql":  {Data: []byte("SELECT 1;")},
+		}
+		ms := mustLoadMigrations(fsys)
+		require.Len(t, ms, 2)
+		assert.Equal(t, 2, ms[0].Version)
+		assert.Equal(t, "first", ms[0].Name)
+		assert.Equal(t, 10, ms[1].Version)
+		assert.Equal(t, "SELECT 2;", ms[1].stmt)
+	})
+
+	t.Run("Should panic on bad name", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/first.sql": {Data: []byte("SELECT 1;")},
+		}
+		assert.Panics(t, func() { mustLoadMigrations(fsys) })
+	})
+
+	t.Run("Should panic on duplicated version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"migrations/0001_a.sql": {Data: []byte("SELECT 1;")},
+			"migrations/1_b.sql":    {Data: []byte("SELECT 1;")},
+		}
+		assert.Panics(t, func() { mustLoadMigrations(fsys) })
+	})
+}
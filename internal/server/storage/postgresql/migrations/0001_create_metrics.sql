@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS gauge (
+	name TEXT PRIMARY KEY,
+	value DOUBLE PRECISION NOT NULL
+);
+CREATE TABLE IF NOT EXISTS counter (
+	name TEXT PRIMARY KEY,
+	value BIGINT NOT NULL
+);
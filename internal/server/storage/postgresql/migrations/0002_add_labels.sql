@@ -0,0 +1,19 @@
+ALTER TABLE gauge ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+ALTER TABLE counter ADD COLUMN IF NOT EXISTS labels TEXT NOT NULL DEFAULT '';
+DO $$
+BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM information_schema.key_column_usage
+		WHERE table_name = 'gauge' AND column_name = 'labels'
+	) THEN
+		ALTER TABLE gauge DROP CONSTRAINT IF EXISTS gauge_pkey;
+		ALTER TABLE gauge ADD PRIMARY KEY (name, labels);
+	END IF;
+	IF NOT EXISTS (
+		SELECT 1 FROM information_schema.key_column_usage
+		WHERE table_name = 'counter' AND column_name = 'labels'
+	) THEN
+		ALTER TABLE counter DROP CONSTRAINT IF EXISTS counter_pkey;
+		ALTER TABLE counter ADD PRIMARY KEY (name, labels);
+	END IF;
+END $$;
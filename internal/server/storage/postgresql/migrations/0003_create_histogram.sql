@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS histogram (
+	name TEXT NOT NULL,
+	labels TEXT NOT NULL DEFAULT '',
+	data JSONB NOT NULL,
+	PRIMARY KEY (name, labels)
+);
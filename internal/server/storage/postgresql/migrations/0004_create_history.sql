@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS history (
+	mtype TEXT NOT NULL,
+	name TEXT NOT NULL,
+	labels TEXT NOT NULL DEFAULT '',
+	ts TIMESTAMPTZ NOT NULL,
+	value DOUBLE PRECISION NOT NULL
+) PARTITION BY RANGE (ts);
+CREATE INDEX IF NOT EXISTS history_key_ts_idx
+	ON history (mtype, name, labels, ts);
+CREATE TABLE IF NOT EXISTS history_rollup (
+	mtype TEXT NOT NULL,
+	name TEXT NOT NULL,
+	labels TEXT NOT NULL DEFAULT '',
+	resolution TEXT NOT NULL,
+	bucket TIMESTAMPTZ NOT NULL,
+	min DOUBLE PRECISION NOT NULL,
+	max DOUBLE PRECISION NOT NULL,
+	sum DOUBLE PRECISION NOT NULL,
+	count BIGINT NOT NULL,
+	last DOUBLE PRECISION NOT NULL,
+	PRIMARY KEY (mtype, name, labels, resolution, bucket)
+);
+CREATE TABLE IF NOT EXISTS history_rollup_state (
+	resolution TEXT PRIMARY KEY,
+	done_until TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+ALTER TABLE gauge ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE counter ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE histogram ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+CREATE INDEX IF NOT EXISTS gauge_updated_at_idx ON gauge (updated_at);
+CREATE INDEX IF NOT EXISTS counter_updated_at_idx ON counter (updated_at);
+CREATE INDEX IF NOT EXISTS histogram_updated_at_idx ON histogram (updated_at);
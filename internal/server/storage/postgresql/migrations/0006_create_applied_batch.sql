@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS applied_batch (
+	agent_id TEXT NOT NULL,
+	seq NUMERIC(20) NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (agent_id, seq)
+);
+CREATE INDEX IF NOT EXISTS applied_batch_applied_at_idx ON applied_batch (applied_at);
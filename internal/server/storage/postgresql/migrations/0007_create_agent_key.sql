@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS agent_key (
+	agent_id TEXT PRIMARY KEY,
+	public_key TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	revoked_at TIMESTAMPTZ
+);
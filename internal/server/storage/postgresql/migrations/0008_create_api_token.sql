@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS api_token (
+	id TEXT PRIMARY KEY,
+	hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1 @@
+CREATE TABLE IF NOT EXISTS history_default PARTITION OF history DEFAULT;
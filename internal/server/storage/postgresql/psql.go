@@ -3,8 +3,10 @@ package psqlstorage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/niksmo/runlytics/internal/logger"
@@ -15,15 +17,43 @@ import (
 
 var waitIntervals = []time.Duration{time.Second, 3 * time.Second}
 
+const (
+	batchExpireInterval = 5 * time.Minute
+	batchExpireTimeout  = 30 * time.Second
+)
+
+// metricsTables are current values tables by metrics type.
+var metricsTables = map[string]string{
+	metrics.MTypeCounter:   "counter",
+	metrics.MTypeGauge:     "gauge",
+	metrics.MTypeHistogram: "histogram",
+}
+
 // PSQLStorage wrap [*sql.DB] and implements [di.Storage] interface.
+//
+// Gauge and counter updates are also recorded as timestamped samples
+// in the history table, see [PSQLStorage.Run].
 type PSQLStorage struct {
-	db *sql.DB
+	db        *sql.DB
+	retention time.Duration
+	batchTTL  time.Duration
+	stop      chan struct{}
+	wg        sync.WaitGroup
 }
 
 // New returns PSQLStorage pointer.
-func New(dsn string) *PSQLStorage {
+//
+// Retention is raw history samples lifetime, batchTTL is how long
+// applied batch ids are remembered, it should not be less than
+// [metrics.BatchWindow].
+func New(dsn string, retention, batchTTL time.Duration) *PSQLStorage {
 	db, _ := sql.Open("pgx", dsn)
-	return &PSQLStorage{db}
+	return &PSQLStorage{
+		db:        db,
+		retention: retention,
+		batchTTL:  batchTTL,
+		stop:      make(chan struct{}),
+	}
 }
 
 func (ps *PSQLStorage) MustRun() {
@@ -32,7 +62,11 @@ func (ps *PSQLStorage) MustRun() {
 	}
 }
 
-// Creating database tables and waiting graceful shutdown
+// Migrates database schema and creates history partitions,
+// then starts background history rollups and retention and
+// expiration of applied batches.
+//
+// If database schema is newer than the binary, [ErrSchemaTooNew] is returned.
 func (ps *PSQLStorage) Run() error {
 	const op = "psqlstorage.Run"
 
@@ -41,15 +75,26 @@ func (ps *PSQLStorage) Run() error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = ps.createTables()
+	_, err = ps.Migrate(context.Background())
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
+
+	err = ps.maintainHistory(context.Background(), time.Now())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	ps.wg.Add(2)
+	go ps.historyWorker()
+	go ps.batchWorker()
 	return nil
 }
 
 func (ps *PSQLStorage) Stop() {
 	const op = "psqlstorage.Stop"
+	close(ps.stop)
+	ps.wg.Wait()
 	if err := ps.db.Close(); err != nil {
 		logger.Log.Error(
 			"failed to close storage",
@@ -64,15 +109,17 @@ func (ps *PSQLStorage) Ping(ctx context.Context) error {
 
 // UpdateCounterByName returns updated counter value and sql driver error, if occur.
 func (ps *PSQLStorage) UpdateCounterByName(
-	ctx context.Context, name string, value int64,
+	ctx context.Context, name string, labels metrics.Labels, value int64,
 ) (int64, error) {
 	logPrefix := "Update counter by name"
-	stmt := `INSERT INTO counter (name, value)
-			 VALUES ($1, $2)
-			 ON CONFLICT (name) DO UPDATE SET
-			 value = (SELECT value FROM counter WHERE name=$1) + EXCLUDED.value
-			 RETURNING value;`
-	row := ps.db.QueryRowContext(ctx, stmt, name, value)
+	stmt := withSample(
+		metrics.MTypeCounter,
+		`INSERT INTO counter (name, labels, value)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (name, labels) DO UPDATE SET
+		 value = counter.value + EXCLUDED.value, updated_at = now()`,
+	)
+	row := ps.db.QueryRowContext(ctx, stmt, name, labels.String(), value)
 
 	var retValue int64
 	err := scanRowWithRetries(ctx, row, logPrefix, &retValue)
@@ -86,15 +133,17 @@ func (ps *PSQLStorage) UpdateCounterByName(
 
 // UpdateGaugeByName returns updated gauge value and sql driver error, if occur.
 func (ps *PSQLStorage) UpdateGaugeByName(
-	ctx context.Context, name string, value float64,
+	ctx context.Context, name string, labels metrics.Labels, value float64,
 ) (float64, error) {
 	logPrefix := "Update gauge by name"
-	stmt := `INSERT INTO gauge (name, value)
-			 VALUES ($1, $2)
-			 ON CONFLICT (name) DO UPDATE SET
-			 value = EXCLUDED.value
-			 RETURNING value;`
-	row := ps.db.QueryRowContext(ctx, stmt, name, value)
+	stmt := withSample(
+		metrics.MTypeGauge,
+		`INSERT INTO gauge (name, labels, value)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (name, labels) DO UPDATE SET
+		 value = EXCLUDED.value, updated_at = now()`,
+	)
+	row := ps.db.QueryRowContext(ctx, stmt, name, labels.String(), value)
 
 	var retValue float64
 	err := scanRowWithRetries(ctx, row, "Update gauge by name", &retValue)
@@ -106,82 +155,109 @@ func (ps *PSQLStorage) UpdateGaugeByName(
 	return retValue, nil
 }
 
-// UpdateCounterList returns sql driver error, if occur.
-func (ps *PSQLStorage) UpdateCounterList(
-	ctx context.Context, mSlice metrics.MetricsList,
-) error {
-	logPrefix := "Update counter list"
+// UpdateHistogramByName returns merged histogram value and sql driver error
+// or [metrics.ErrHistogramMismatch], if occur.
+func (ps *PSQLStorage) UpdateHistogramByName(
+	ctx context.Context, name string, labels metrics.Labels, value metrics.Histogram,
+) (metrics.Histogram, error) {
+	logPrefix := "Update histogram by name"
 	tx, err := beginTxWithRetries(
 		ctx, ps.db, logPrefix+": begin transaction", nil,
 	)
 	if err != nil {
 		logger.Log.Error(logPrefix+": begin transaction", zap.Error(err))
-		return err
-	}
-	stmt, err := tx.PrepareContext(
-		ctx,
-		`INSERT INTO counter (name, value)
-		 VALUES ($1, $2)
-		 ON CONFLICT (name) DO UPDATE SET
-		 value = (SELECT value FROM counter WHERE name=$1) + EXCLUDED.value;`,
-	)
-	if err != nil {
-		logger.Log.Error(logPrefix+": prepare", zap.Error(err))
-		return err
+		return metrics.Histogram{}, err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	for _, item := range mSlice {
-		_, err = stmt.ExecContext(ctx, item.ID, item.Delta)
-		if err != nil {
-			err = rollbackWithRetries(ctx, tx, logPrefix+": rollback")
-			if err != nil {
-				logger.Log.Error(logPrefix+": rollback", zap.Error(err))
-				return err
-			}
-		}
+	merged, err := mergeHistogram(ctx, tx, name, labels, value)
+	if err != nil {
+		logger.Log.Error(logPrefix+": merge", zap.Error(err))
+		return metrics.Histogram{}, err
 	}
 
 	if err = commitWithRetries(ctx, tx, logPrefix+": commit"); err != nil {
 		logger.Log.Error(logPrefix+": commit", zap.Error(err))
+		return metrics.Histogram{}, err
+	}
+	return merged, nil
+}
+
+// UpdateCounterList returns sql driver error, if occur.
+//
+// The whole list is upserted by one statement from unnest arrays,
+// deltas of the same metric are summed.
+func (ps *PSQLStorage) UpdateCounterList(
+	ctx context.Context, mSlice metrics.MetricsList,
+) error {
+	logPrefix := "Update counter list"
+	if len(mSlice) == 0 {
+		return nil
+	}
+
+	_, err := execWithRetries(
+		ctx,
+		ps.db,
+		withSample(metrics.MTypeCounter, updateCounterListStmt),
+		logger.Log.With(zap.String("op", logPrefix)),
+		counterListArgs(mSlice)...,
+	)
+	if err != nil {
+		logger.Log.Error(logPrefix+": exec", zap.Error(err))
 		return err
 	}
 	return nil
 }
 
 // UpdateGaugeList returns sql driver error, if occur.
+//
+// The whole list is upserted by one statement from unnest arrays,
+// the last value of the same metric wins.
 func (ps *PSQLStorage) UpdateGaugeList(
 	ctx context.Context, mSlice metrics.MetricsList,
 ) error {
 	logPrefix := "Update gauge list"
-	tx, err := beginTxWithRetries(
-		ctx, ps.db, logPrefix+": begin transaction", nil,
+	if len(mSlice) == 0 {
+		return nil
+	}
+
+	_, err := execWithRetries(
+		ctx,
+		ps.db,
+		withSample(metrics.MTypeGauge, updateGaugeListStmt),
+		logger.Log.With(zap.String("op", logPrefix)),
+		gaugeListArgs(mSlice)...,
 	)
 	if err != nil {
-		logger.Log.Error(logPrefix+": begin transaction", zap.Error(err))
+		logger.Log.Error(logPrefix+": exec", zap.Error(err))
 		return err
 	}
-	stmt, err := tx.PrepareContext(
-		ctx,
-		`INSERT INTO gauge (name, value)
-	     VALUES ($1, $2)
-		 ON CONFLICT (name) DO UPDATE SET
-		 value = EXCLUDED.value;`,
+	return nil
+}
+
+// UpdateHistogramList returns sql driver error
+// or [metrics.ErrHistogramMismatch], if occur.
+func (ps *PSQLStorage) UpdateHistogramList(
+	ctx context.Context, mSlice metrics.MetricsList,
+) error {
+	logPrefix := "Update histogram list"
+	tx, err := beginTxWithRetries(
+		ctx, ps.db, logPrefix+": begin transaction", nil,
 	)
 	if err != nil {
-		logger.Log.Error(logPrefix+": prepare", zap.Error(err))
+		logger.Log.Error(logPrefix+": begin transaction", zap.Error(err))
 		return err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
 	for _, item := range mSlice {
-		_, err = stmt.ExecContext(ctx, item.ID, item.Value)
+		if item.Histogram == nil {
+			continue
+		}
+		_, err = mergeHistogram(ctx, tx, item.ID, item.Labels, *item.Histogram)
 		if err != nil {
-			err = rollbackWithRetries(ctx, tx, logPrefix+": rollback")
-			if err != nil {
-				logger.Log.Error(logPrefix+": rollback", zap.Error(err))
-				return err
-			}
+			logger.Log.Error(logPrefix+": merge", zap.Error(err))
+			return err
 		}
 	}
 
@@ -192,19 +268,86 @@ func (ps *PSQLStorage) UpdateGaugeList(
 	return nil
 }
 
+// DeleteByName deletes metric, returns [server.ErrNotExists]
+// or sql driver error, if occur. Metric history is kept.
+func (ps *PSQLStorage) DeleteByName(
+	ctx context.Context, mType, name string, labels metrics.Labels,
+) error {
+	logPrefix := "Delete by name"
+	table, ok := metricsTables[mType]
+	if !ok {
+		return server.ErrInternal
+	}
+
+	result, err := execWithRetries(
+		ctx,
+		ps.db,
+		`DELETE FROM `+table+` WHERE name = $1 AND labels = $2;`,
+		logger.Log.With(zap.String("op", logPrefix)),
+		name, labels.String(),
+	)
+	if err != nil {
+		logger.Log.Error(logPrefix+": exec", zap.Error(err))
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf(
+			"metric '%s' is %w", metrics.MakeKey(name, labels), server.ErrNotExists,
+		)
+	}
+	return nil
+}
+
+// DeleteExpired deletes metrics not updated since before
+// and returns number of deleted metrics or sql driver error, if occur.
+func (ps *PSQLStorage) DeleteExpired(
+	ctx context.Context, before time.Time,
+) (int64, error) {
+	logPrefix := "Delete expired"
+	log := logger.Log.With(zap.String("op", logPrefix))
+
+	var total int64
+	for _, table := range metricsTables {
+		result, err := execWithRetries(
+			ctx,
+			ps.db,
+			`DELETE FROM `+table+` WHERE updated_at < $1;`,
+			log,
+			before,
+		)
+		if err != nil {
+			logger.Log.Error(logPrefix+": exec", zap.Error(err))
+			return total, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+	}
+	return total, nil
+}
+
 // ReadCounterByName returns counter value and sql driver error, if occur.
 func (ps *PSQLStorage) ReadCounterByName(
-	ctx context.Context, name string,
+	ctx context.Context, name string, labels metrics.Labels,
 ) (int64, error) {
 	logPrefix := "Read counter by name"
-	stmt := `SELECT value FROM counter WHERE name = $1;`
-	row := ps.db.QueryRow(stmt, name)
+	stmt := `SELECT value FROM counter WHERE name = $1 AND labels = $2;`
+	row := ps.db.QueryRow(stmt, name, labels.String())
 
 	var value int64
 	err := scanRowWithRetries(ctx, row, logPrefix, &value)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		return 0, fmt.Errorf("metric '%s' is %w", name, server.ErrNotExists)
+		return 0, fmt.Errorf(
+			"metric '%s' is %w", metrics.MakeKey(name, labels), server.ErrNotExists,
+		)
 	case err != nil:
 		logger.Log.Error(logPrefix+": scan row", zap.Error(err))
 		return 0, err
@@ -215,17 +358,19 @@ func (ps *PSQLStorage) ReadCounterByName(
 
 // ReadGaugeByName returns gauge value and sql driver error, if occur.
 func (ps *PSQLStorage) ReadGaugeByName(
-	ctx context.Context, name string,
+	ctx context.Context, name string, labels metrics.Labels,
 ) (float64, error) {
 	logPrefix := "Read gauge by name"
-	stmt := `SELECT value FROM gauge WHERE name = $1;`
-	row := ps.db.QueryRow(stmt, name)
+	stmt := `SELECT value FROM gauge WHERE name = $1 AND labels = $2;`
+	row := ps.db.QueryRow(stmt, name, labels.String())
 
 	var value float64
 	err := scanRowWithRetries(ctx, row, logPrefix, &value)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
-		return 0, fmt.Errorf("metric '%s' is %w", name, server.ErrNotExists)
+		return 0, fmt.Errorf(
+			"metric '%s' is %w", metrics.MakeKey(name, labels), server.ErrNotExists,
+		)
 	case err != nil:
 		logger.Log.Error(logPrefix+": scan row", zap.Error(err))
 		return 0, err
@@ -234,12 +379,40 @@ func (ps *PSQLStorage) ReadGaugeByName(
 	return value, nil
 }
 
+// ReadHistogramByName returns histogram value and sql driver error, if occur.
+func (ps *PSQLStorage) ReadHistogramByName(
+	ctx context.Context, name string, labels metrics.Labels,
+) (metrics.Histogram, error) {
+	logPrefix := "Read histogram by name"
+	stmt := `SELECT data FROM histogram WHERE name = $1 AND labels = $2;`
+	row := ps.db.QueryRow(stmt, name, labels.String())
+
+	var data []byte
+	err := scanRowWithRetries(ctx, row, logPrefix, &data)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return metrics.Histogram{}, fmt.Errorf(
+			"metric '%s' is %w", metrics.MakeKey(name, labels), server.ErrNotExists,
+		)
+	case err != nil:
+		logger.Log.Error(logPrefix+": scan row", zap.Error(err))
+		return metrics.Histogram{}, err
+	}
+
+	var value metrics.Histogram
+	if err = json.Unmarshal(data, &value); err != nil {
+		logger.Log.Error(logPrefix+": unmarshal", zap.Error(err))
+		return metrics.Histogram{}, err
+	}
+	return value, nil
+}
+
 // ReadGauge returns gauge metrics and sql driver error, if occur.
 func (ps *PSQLStorage) ReadGauge(
 	ctx context.Context,
 ) (map[string]float64, error) {
 	logPrefix := "Read gauge"
-	stmt := `SELECT name, value FROM gauge;`
+	stmt := `SELECT name, labels, value FROM gauge;`
 	rows, err := queryWithRetries(ctx, ps.db, stmt, logPrefix)
 	if err != nil {
 		logger.Log.Error(logPrefix+": query", zap.Error(err))
@@ -249,15 +422,15 @@ func (ps *PSQLStorage) ReadGauge(
 
 	gaugeMap := make(map[string]float64)
 	var (
-		name  string
-		value float64
+		name, labels string
+		value        float64
 	)
 	for rows.Next() {
-		if err = rows.Scan(&name, &value); err != nil {
+		if err = rows.Scan(&name, &labels, &value); err != nil {
 			logger.Log.Error(logPrefix+": scan rows", zap.Error(err))
 			return nil, err
 		}
-		gaugeMap[name] = value
+		gaugeMap[makeKey(name, labels)] = value
 	}
 	if err = rows.Err(); err != nil {
 		logger.Log.Error(logPrefix+": after rows scan iteration", zap.Error(err))
@@ -272,7 +445,7 @@ func (ps *PSQLStorage) ReadCounter(
 	ctx context.Context,
 ) (map[string]int64, error) {
 	logPrefix := "Read counter"
-	stmt := `SELECT name, value FROM counter;`
+	stmt := `SELECT name, labels, value FROM counter;`
 	rows, err := queryWithRetries(ctx, ps.db, stmt, logPrefix)
 	if err != nil {
 		logger.Log.Error(logPrefix+": query", zap.Error(err))
@@ -282,15 +455,15 @@ func (ps *PSQLStorage) ReadCounter(
 
 	counterMap := make(map[string]int64)
 	var (
-		name  string
-		value int64
+		name, labels string
+		value        int64
 	)
 	for rows.Next() {
-		if err = rows.Scan(&name, &value); err != nil {
+		if err = rows.Scan(&name, &labels, &value); err != nil {
 			logger.Log.Error(logPrefix+": scan rows", zap.Error(err))
 			return nil, err
 		}
-		counterMap[name] = value
+		counterMap[makeKey(name, labels)] = value
 	}
 	if err = rows.Err(); err != nil {
 		logger.Log.Error(logPrefix+": after rows scan iteration", zap.Error(err))
@@ -300,30 +473,119 @@ func (ps *PSQLStorage) ReadCounter(
 	return counterMap, nil
 }
 
+// ReadHistogram returns histogram metrics and sql driver error if occurs.
+func (ps *PSQLStorage) ReadHistogram(
+	ctx context.Context,
+) (map[string]metrics.Histogram, error) {
+	logPrefix := "Read histogram"
+	stmt := `SELECT name, labels, data FROM histogram;`
+	rows, err := queryWithRetries(ctx, ps.db, stmt, logPrefix)
+	if err != nil {
+		logger.Log.Error(logPrefix+": query", zap.Error(err))
+		return nil, err
+	}
+	defer rows.Close()
+
+	histogramMap := make(map[string]metrics.Histogram)
+	var (
+		name, labels string
+		data         []byte
+	)
+	for rows.Next() {
+		if err = rows.Scan(&name, &labels, &data); err != nil {
+			logger.Log.Error(logPrefix+": scan rows", zap.Error(err))
+			return nil, err
+		}
+		var value metrics.Histogram
+		if err = json.Unmarshal(data, &value); err != nil {
+			logger.Log.Error(logPrefix+": unmarshal", zap.Error(err))
+			return nil, err
+		}
+		histogramMap[makeKey(name, labels)] = value
+	}
+	if err = rows.Err(); err != nil {
+		logger.Log.Error(logPrefix+": after rows scan iteration", zap.Error(err))
+		return nil, err
+	}
+
+	return histogramMap, nil
+}
+
 func (ps *PSQLStorage) checkDB(ctx context.Context) error {
 	return ps.db.PingContext(ctx)
 }
 
-func (ps *PSQLStorage) createTables() error {
-	const op = "psqlstorage.createTables"
+// mergeHistogram inserts histogram or merges it with stored one inside tx.
+//
+// Insert goes first, so concurrent transactions for a new metric
+// wait for each other on the primary key instead of overwriting.
+func mergeHistogram(
+	ctx context.Context,
+	tx *sql.Tx,
+	name string,
+	labels metrics.Labels,
+	value metrics.Histogram,
+) (metrics.Histogram, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return metrics.Histogram{}, err
+	}
 
-	log := logger.Log.With(zap.String("op", op))
+	res, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO histogram (name, labels, data)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (name, labels) DO NOTHING;`,
+		name, labels.String(), string(data),
+	)
+	if err != nil {
+		return metrics.Histogram{}, err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 1 {
+		return value, nil
+	}
 
-	stmt := `
-	CREATE TABLE IF NOT EXISTS gauge (
-	    name TEXT PRIMARY KEY,
-		value DOUBLE PRECISION NOT NULL
-	);
-	CREATE TABLE IF NOT EXISTS counter (
-	    name TEXT PRIMARY KEY,
-		value BIGINT NOT NULL
-	);`
+	row := tx.QueryRowContext(
+		ctx,
+		`SELECT data FROM histogram
+		 WHERE name = $1 AND labels = $2
+		 FOR UPDATE;`,
+		name, labels.String(),
+	)
+	var stored []byte
+	if err = row.Scan(&stored); err != nil {
+		return metrics.Histogram{}, err
+	}
 
-	_, err := execWithRetries(context.Background(), ps.db, stmt, log)
+	var current metrics.Histogram
+	if err = json.Unmarshal(stored, &current); err != nil {
+		return metrics.Histogram{}, err
+	}
+	if err = current.Merge(value); err != nil {
+		return metrics.Histogram{}, fmt.Errorf(
+			"metric '%s': %w", metrics.MakeKey(name, labels), err,
+		)
+	}
+
+	if data, err = json.Marshal(current); err != nil {
+		return metrics.Histogram{}, err
+	}
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE histogram SET data = $3, updated_at = now()
+		 WHERE name = $1 AND labels = $2;`,
+		name, labels.String(), string(data),
+	)
 	if err != nil {
-		return err
+		return metrics.Histogram{}, err
 	}
-	return nil
+	return current, nil
+}
+
+// makeKey returns metrics key from name and canonical labels column value.
+func makeKey(name, labels string) string {
+	l, _ := metrics.ParseLabels(labels)
+	return metrics.MakeKey(name, l)
 }
 
 const tryAfter = "tryAfter"
@@ -485,3 +747,56 @@ func commitWithRetries(
 	}
 	return err
 }
+
+// Batch upserts take names, labels and values arrays of the same length.
+// A row can be upserted once per statement, so list items
+// of the same metric are merged before upsert.
+const (
+	updateCounterListStmt = `INSERT INTO counter (name, labels, value)
+	SELECT name, labels, SUM(value)
+	FROM unnest($1::TEXT[], $2::TEXT[], $3::BIGINT[]) AS item(name, labels, value)
+	GROUP BY name, labels
+	ON CONFLICT (name, labels) DO UPDATE SET
+	value = counter.value + EXCLUDED.value, updated_at = now()`
+
+	updateGaugeListStmt = `INSERT INTO gauge (name, labels, value)
+	SELECT DISTINCT ON (name, labels) name, labels, value
+	FROM unnest($1::TEXT[], $2::TEXT[], $3::DOUBLE PRECISION[])
+		WITH ORDINALITY AS item(name, labels, value, idx)
+	ORDER BY name, labels, idx DESC
+	ON CONFLICT (name, labels) DO UPDATE SET
+	value = EXCLUDED.value, updated_at = now()`
+)
+
+// counterListArgs returns names, canonical labels and deltas arrays
+// for counter list upsert.
+func counterListArgs(mSlice metrics.MetricsList) []any {
+	names, labels := listKeys(mSlice)
+	values := make([]int64, len(mSlice))
+	for idx, item := range mSlice {
+		values[idx] = item.Delta
+	}
+	return []any{names, labels, values}
+}
+
+// gaugeListArgs returns names, canonical labels and values arrays
+// for gauge list upsert.
+func gaugeListArgs(mSlice metrics.MetricsList) []any {
+	names, labels := listKeys(mSlice)
+	values := make([]float64, len(mSlice))
+	for idx, item := range mSlice {
+		values[idx] = item.Value
+	}
+	return []any{names, labels, values}
+}
+
+// listKeys returns list names and canonical labels.
+func listKeys(mSlice metrics.MetricsList) (names, labels []string) {
+	names = make([]string, len(mSlice))
+	labels = make([]string, len(mSlice))
+	for idx, item := range mSlice {
+		names[idx] = item.ID
+		labels[idx] = item.Labels.String()
+	}
+	return names, labels
+}
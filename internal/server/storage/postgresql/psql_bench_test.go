@@ -0,0 +1,126 @@
+package psqlstorage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/niksmo/runlytics/internal/logger"
+	"github.com/niksmo/runlytics/pkg/metrics"
+)
+
+// BenchmarkUpdateList compares batch upsert by unnest arrays
+// with previous per item prepared statements inside transaction.
+func BenchmarkUpdateList(b *testing.B) {
+	logger.Init("fatal")
+	DSN := os.Getenv("RUNLYTICS_TEST_DSN")
+	storage := New(DSN, time.Hour, metrics.BatchWindow)
+	pingCtx, pingCancel := context.WithTimeout(context.Background(), time.Second)
+	defer pingCancel()
+	if err := storage.db.PingContext(pingCtx); err != nil {
+		b.Skip("Test database not connected")
+		return
+	}
+	if err := storage.Run(); err != nil {
+		b.Fatal(err)
+	}
+	defer storage.Stop()
+	ctx := context.Background()
+
+	for _, size := range []int{1000, 10000} {
+		counters := make(metrics.MetricsList, size)
+		gauges := make(metrics.MetricsList, size)
+		for idx := range size {
+			labels := metrics.Labels{"host": fmt.Sprint(idx % 10)}
+			counters[idx] = metrics.Metrics{
+				ID: fmt.Sprintf("Counter%d", idx), MType: metrics.MTypeCounter,
+				Labels: labels, Delta: 1,
+			}
+			gauges[idx] = metrics.Metrics{
+				ID: fmt.Sprintf("Gauge%d", idx), MType: metrics.MTypeGauge,
+				Labels: labels, Value: 1.5,
+			}
+		}
+
+		b.Run(fmt.Sprintf("counter/%d/unnest", size), func(b *testing.B) {
+			for range b.N {
+				if err := storage.UpdateCounterList(ctx, counters); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("counter/%d/per-item", size), func(b *testing.B) {
+			for range b.N {
+				err := updateListPerItem(ctx, storage.db, legacyCounterStmt, counters)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("gauge/%d/unnest", size), func(b *testing.B) {
+			for range b.N {
+				if err := storage.UpdateGaugeList(ctx, gauges); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("gauge/%d/per-item", size), func(b *testing.B) {
+			for range b.N {
+				err := updateListPerItem(ctx, storage.db, legacyGaugeStmt, gauges)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+var (
+	legacyCounterStmt = withSample(
+		metrics.MTypeCounter,
+		`INSERT INTO counter (name, labels, value)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (name, labels) DO UPDATE SET
+		 value = (SELECT value FROM counter WHERE name=$1 AND labels=$2) + EXCLUDED.value`,
+	)
+	legacyGaugeStmt = withSample(
+		metrics.MTypeGauge,
+		`INSERT INTO gauge (name, labels, value)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (name, labels) DO UPDATE SET
+		 value = EXCLUDED.value`,
+	)
+)
+
+// updateListPerItem is previous batch update: one exec per item.
+func updateListPerItem(
+	ctx context.Context, db *sql.DB, stmt string, mSlice metrics.MetricsList,
+) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	prepared, err := tx.PrepareContext(ctx, stmt)
+	if err != nil {
+		return err
+	}
+	defer prepared.Close()
+
+	for _, item := range mSlice {
+		var value any = item.Value
+		if item.MType == metrics.MTypeCounter {
+			value = item.Delta
+		}
+		_, err = prepared.ExecContext(ctx, item.ID, item.Labels.String(), value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -38,7 +38,7 @@ func TestPSQLStorage(t *testing.T) {
 	clearTables := func(t *testing.T) {
 		_, err := db.ExecContext(
 			context.TODO(),
-			`TRUNCATE TABLE gauge, counter;`,
+			`TRUNCATE TABLE gauge, counter, histogram, applied_batch;`,
 		)
 		require.NoError(t, err)
 	}
@@ -51,7 +51,9 @@ func TestPSQLStorage(t *testing.T) {
 		_, err := db.ExecContext(
 			ctx,
 			`DROP TABLE IF EXISTS gauge;
-		     DROP TABLE IF EXISTS counter;`,
+		     DROP TABLE IF EXISTS counter;
+		     DROP TABLE IF EXISTS applied_batch;
+		     DROP TABLE IF EXISTS schema_version;`,
 		)
 		require.NoError(t, err)
 
@@ -71,7 +73,7 @@ func TestPSQLStorage(t *testing.T) {
 		require.NoError(t, row.Scan(&count))
 		assert.Zero(t, count)
 
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 
@@ -83,9 +85,169 @@ func TestPSQLStorage(t *testing.T) {
 		assert.Equal(t, 2, count)
 	})
 
+	t.Run("Migrate schema", func(t *testing.T) {
+		ctx := context.Background()
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		defer storage.Stop()
+
+		applied, err := storage.Migrate(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+
+		status, err := storage.MigrationStatus(ctx)
+		require.NoError(t, err)
+		require.Len(t, status, len(migrations))
+		for _, s := range status {
+			assert.True(t, s.Applied)
+			assert.False(t, s.Unknown)
+		}
+
+		// status does not wait for running migration
+		conn, err := db.Conn(ctx)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.ExecContext(
+			ctx, `SELECT pg_advisory_lock($1);`, migrationLockID,
+		)
+		require.NoError(t, err)
+		lockedCtx, cancel := context.WithTimeout(ctx, time.Second)
+		_, err = storage.MigrationStatus(lockedCtx)
+		cancel()
+		require.NoError(t, err)
+		_, err = conn.ExecContext(
+			ctx, `SELECT pg_advisory_unlock($1);`, migrationLockID,
+		)
+		require.NoError(t, err)
+
+		latest := migrations[len(migrations)-1].Version
+		_, err = db.ExecContext(
+			ctx,
+			`INSERT INTO schema_version (version, name) VALUES ($1, 'future');`,
+			latest+1,
+		)
+		require.NoError(t, err)
+		defer db.ExecContext(
+			ctx, `DELETE FROM schema_version WHERE version = $1;`, latest+1,
+		)
+
+		_, err = storage.Migrate(ctx)
+		assert.ErrorIs(t, err, ErrSchemaTooNew)
+		status, err = storage.MigrationStatus(ctx)
+		require.NoError(t, err)
+		assert.True(t, status[len(status)-1].Unknown)
+	})
+
+	t.Run("Delete metrics", func(t *testing.T) {
+		clearTables(t)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		require.NoError(t, storage.Run())
+		defer storage.Stop()
+		ctx := context.Background()
+
+		_, err := storage.UpdateGaugeByName(ctx, "Alloc", nil, 1.5)
+		require.NoError(t, err)
+		_, err = storage.UpdateCounterByName(ctx, "PollCount", nil, 1)
+		require.NoError(t, err)
+
+		require.NoError(t, storage.DeleteByName(ctx, metrics.MTypeGauge, "Alloc", nil))
+		err = storage.DeleteByName(ctx, metrics.MTypeGauge, "Alloc", nil)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+
+		deleted, err := storage.DeleteExpired(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, deleted)
+		deleted, err = storage.DeleteExpired(ctx, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+	})
+
+	t.Run("Update batch once", func(t *testing.T) {
+		clearTables(t)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		require.NoError(t, storage.Run())
+		defer storage.Stop()
+		ctx := context.Background()
+
+		id := metrics.BatchID{AgentID: "test-agent", Seq: 1<<64 - 1}
+		ml := metrics.MetricsList{
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1.5},
+			{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+		}
+		for _, wantDuplicate := range []bool{false, true} {
+			duplicate, err := storage.UpdateBatch(ctx, id, ml)
+			require.NoError(t, err)
+			assert.Equal(t, wantDuplicate, duplicate)
+		}
+
+		id.Seq = 2
+		duplicate, err := storage.UpdateBatch(ctx, id, ml)
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+
+		value, err := storage.ReadCounterByName(ctx, "PollCount", nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), value)
+	})
+
+	t.Run("Forget batches out of batch ttl", func(t *testing.T) {
+		clearTables(t)
+		storage := New(DSN, time.Hour, 2*metrics.BatchWindow)
+		require.NoError(t, storage.Run())
+		defer storage.Stop()
+		ctx := context.Background()
+
+		id := metrics.BatchID{AgentID: "test-agent", Seq: 1}
+		ml := metrics.MetricsList{
+			{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+		}
+		_, err := storage.UpdateBatch(ctx, id, ml)
+		require.NoError(t, err)
+
+		now := time.Now().Add(metrics.BatchWindow + time.Minute)
+		require.NoError(t, storage.expireBatches(ctx, now))
+		duplicate, err := storage.UpdateBatch(ctx, id, ml)
+		require.NoError(t, err)
+		assert.True(t, duplicate)
+
+		now = time.Now().Add(2*metrics.BatchWindow + time.Minute)
+		require.NoError(t, storage.expireBatches(ctx, now))
+		duplicate, err = storage.UpdateBatch(ctx, id, ml)
+		require.NoError(t, err)
+		assert.False(t, duplicate)
+	})
+
+	t.Run("Update batch atomically", func(t *testing.T) {
+		clearTables(t)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		require.NoError(t, storage.Run())
+		defer storage.Stop()
+		ctx := context.Background()
+
+		_, err := storage.UpdateHistogramByName(
+			ctx, "Latency", nil, metrics.NewHistogram(1, 2),
+		)
+		require.NoError(t, err)
+		other := metrics.NewHistogram(5)
+		ml := metrics.MetricsList{
+			{ID: "Alloc", MType: metrics.MTypeGauge, Value: 1.5},
+			{ID: "Latency", MType: metrics.MTypeHistogram, Histogram: &other},
+			{ID: "PollCount", MType: metrics.MTypeCounter, Delta: 5},
+		}
+		_, err = storage.UpdateBatch(ctx, metrics.BatchID{}, ml)
+		var errs metrics.BatchErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 1)
+		assert.Equal(t, 1, errs[0].Index)
+
+		_, err = storage.ReadGaugeByName(ctx, "Alloc", nil)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+		_, err = storage.ReadCounterByName(ctx, "PollCount", nil)
+		assert.ErrorIs(t, err, server.ErrNotExists)
+	})
+
 	t.Run("Sequence update gauge by name", func(t *testing.T) {
 		clearTables(t)
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -95,7 +257,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel := context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.UpdateGaugeByName(
-				ctx, metricName, v,
+				ctx, metricName, nil, v,
 			)
 			require.NoError(t, err)
 			assert.InDelta(t, v, actualValue, 0)
@@ -104,7 +266,7 @@ func TestPSQLStorage(t *testing.T) {
 
 	t.Run("Sequence update counter by name", func(t *testing.T) {
 		clearTables(t)
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -115,7 +277,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel := context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.UpdateCounterByName(
-				ctx, metricName, v,
+				ctx, metricName, nil, v,
 			)
 			require.NoError(t, err)
 			assert.Equal(t, sum+v, actualValue)
@@ -123,10 +285,44 @@ func TestPSQLStorage(t *testing.T) {
 		}
 	})
 
+	t.Run("Update counter with different labels", func(t *testing.T) {
+		clearTables(t)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		storage.Run()
+		defer storage.Stop()
+		ctxBase := context.Background()
+		metricName := "Counter"
+		hostA := metrics.Labels{"host": "a"}
+		hostB := metrics.Labels{"host": "b"}
+
+		ctx, cancel := context.WithTimeout(ctxBase, time.Second)
+		defer cancel()
+		_, err := storage.UpdateCounterByName(ctx, metricName, hostA, 5)
+		require.NoError(t, err)
+		_, err = storage.UpdateCounterByName(ctx, metricName, hostB, 7)
+		require.NoError(t, err)
+
+		actualA, err := storage.ReadCounterByName(ctx, metricName, hostA)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), actualA)
+
+		actualB, err := storage.ReadCounterByName(ctx, metricName, hostB)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), actualB)
+
+		_, err = storage.ReadCounterByName(ctx, metricName, nil)
+		require.ErrorIs(t, err, server.ErrNotExists)
+
+		counterData, err := storage.ReadCounter(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), counterData["Counter{host=a}"])
+		assert.Equal(t, int64(7), counterData["Counter{host=b}"])
+	})
+
 	t.Run("Batch update", func(t *testing.T) {
 		t.Run("Gauge (no doubles)", func(t *testing.T) {
 			clearTables(t)
-			storage := New(DSN)
+			storage := New(DSN, time.Hour, metrics.BatchWindow)
 			storage.Run()
 			defer storage.Stop()
 
@@ -175,7 +371,7 @@ func TestPSQLStorage(t *testing.T) {
 
 		t.Run("Gauge (with doubles)", func(t *testing.T) {
 			clearTables(t)
-			storage := New(DSN)
+			storage := New(DSN, time.Hour, metrics.BatchWindow)
 			storage.Run()
 			defer storage.Stop()
 
@@ -234,7 +430,7 @@ func TestPSQLStorage(t *testing.T) {
 
 		t.Run("Counter (no doubles)", func(t *testing.T) {
 			clearTables(t)
-			storage := New(DSN)
+			storage := New(DSN, time.Hour, metrics.BatchWindow)
 			storage.Run()
 			defer storage.Stop()
 
@@ -283,7 +479,7 @@ func TestPSQLStorage(t *testing.T) {
 
 		t.Run("Counter (with doubles)", func(t *testing.T) {
 			clearTables(t)
-			storage := New(DSN)
+			storage := New(DSN, time.Hour, metrics.BatchWindow)
 			storage.Run()
 			defer storage.Stop()
 
@@ -352,7 +548,7 @@ func TestPSQLStorage(t *testing.T) {
 	})
 
 	t.Run("Read counter by name", func(t *testing.T) {
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -364,7 +560,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel := context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.ReadCounterByName(
-				ctx, metricName,
+				ctx, metricName, nil,
 			)
 			require.ErrorIs(t, err, server.ErrNotExists)
 			assert.Equal(t, expected, actualValue)
@@ -386,7 +582,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel = context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.ReadCounterByName(
-				ctx, metricName,
+				ctx, metricName, nil,
 			)
 			require.NoError(t, err)
 			assert.Equal(t, expected, actualValue)
@@ -394,7 +590,7 @@ func TestPSQLStorage(t *testing.T) {
 	})
 
 	t.Run("Read gauge by name", func(t *testing.T) {
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -406,7 +602,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel := context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.ReadGaugeByName(
-				ctx, metricName,
+				ctx, metricName, nil,
 			)
 			require.ErrorIs(t, err, server.ErrNotExists)
 			assert.InDelta(t, expected, actualValue, 0)
@@ -428,7 +624,7 @@ func TestPSQLStorage(t *testing.T) {
 			ctx, cancel = context.WithTimeout(ctxBase, time.Second)
 			defer cancel()
 			actualValue, err := storage.ReadGaugeByName(
-				ctx, metricName,
+				ctx, metricName, nil,
 			)
 			require.NoError(t, err)
 			assert.InDelta(t, expected, actualValue, 0)
@@ -436,7 +632,7 @@ func TestPSQLStorage(t *testing.T) {
 	})
 
 	t.Run("Read counter", func(t *testing.T) {
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -490,7 +686,7 @@ func TestPSQLStorage(t *testing.T) {
 	})
 
 	t.Run("Read gauge", func(t *testing.T) {
-		storage := New(DSN)
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
 		storage.Run()
 		defer storage.Stop()
 		ctxBase := context.Background()
@@ -542,4 +738,209 @@ func TestPSQLStorage(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("History", func(t *testing.T) {
+		storage := New(DSN, time.Hour, metrics.BatchWindow)
+		storage.Run()
+		defer storage.Stop()
+		ctxBase := context.Background()
+
+		clearHistory := func(t *testing.T) {
+			_, err := db.ExecContext(
+				context.TODO(),
+				`TRUNCATE TABLE history, history_rollup, history_rollup_state;`,
+			)
+			require.NoError(t, err)
+		}
+
+		t.Run("Should record samples on update", func(t *testing.T) {
+			clearTables(t)
+			clearHistory(t)
+			ctx, cancel := context.WithTimeout(ctxBase, time.Second)
+			defer cancel()
+
+			_, err := storage.UpdateGaugeByName(ctx, "Alloc", nil, 1.5)
+			require.NoError(t, err)
+			_, err = storage.UpdateCounterByName(ctx, "PollCount", nil, 2)
+			require.NoError(t, err)
+			_, err = storage.UpdateCounterByName(ctx, "PollCount", nil, 3)
+			require.NoError(t, err)
+
+			rows, err := db.QueryContext(
+				ctx,
+				`SELECT mtype, name, value FROM history ORDER BY ts, value;`,
+			)
+			require.NoError(t, err)
+			defer rows.Close()
+
+			type sample struct {
+				mType, name string
+				value       float64
+			}
+			var actual []sample
+			for rows.Next() {
+				var s sample
+				require.NoError(t, rows.Scan(&s.mType, &s.name, &s.value))
+				actual = append(actual, s)
+			}
+			require.NoError(t, rows.Err())
+
+			expected := []sample{
+				{metrics.MTypeGauge, "Alloc", 1.5},
+				{metrics.MTypeCounter, "PollCount", 2},
+				{metrics.MTypeCounter, "PollCount", 5},
+			}
+			assert.Equal(t, expected, actual)
+		})
+
+		t.Run("Should rollup completed buckets", func(t *testing.T) {
+			clearHistory(t)
+			ctx, cancel := context.WithTimeout(ctxBase, 5*time.Second)
+			defer cancel()
+
+			bucket := time.Now().UTC().Truncate(time.Minute).Add(-5 * time.Minute)
+			for idx, v := range []float64{1, 3, 2} {
+				_, err := db.ExecContext(
+					ctx,
+					`INSERT INTO history (mtype, name, labels, ts, value)
+					 VALUES ('gauge', 'Alloc', '', $1, $2);`,
+					bucket.Add(time.Duration(idx+1)*10*time.Second), v,
+				)
+				require.NoError(t, err)
+			}
+			_, err := db.ExecContext(
+				ctx,
+				`INSERT INTO history_rollup_state VALUES ('1m', $1);`,
+				bucket,
+			)
+			require.NoError(t, err)
+
+			require.NoError(t, storage.maintainHistory(ctx, time.Now()))
+
+			row := db.QueryRowContext(
+				ctx,
+				`SELECT min, max, sum, count, last FROM history_rollup
+				 WHERE resolution = '1m' AND bucket = $1;`,
+				bucket,
+			)
+			var (
+				minV, maxV, sumV, lastV float64
+				count                   int64
+			)
+			require.NoError(t, row.Scan(&minV, &maxV, &sumV, &count, &lastV))
+			assert.InDelta(t, 1, minV, 0)
+			assert.InDelta(t, 3, maxV, 0)
+			assert.InDelta(t, 6, sumV, 0)
+			assert.Equal(t, int64(3), count)
+			assert.InDelta(t, 2, lastV, 0)
+		})
+
+		t.Run("Should read history aggregated by step", func(t *testing.T) {
+			clearHistory(t)
+			ctx, cancel := context.WithTimeout(ctxBase, 5*time.Second)
+			defer cancel()
+
+			from := metrics.AlignTime(time.Now().Add(-10*time.Minute), time.Minute)
+			samples := []struct {
+				offset time.Duration
+				value  float64
+			}{
+				{10 * time.Second, 1}, {20 * time.Second, 3},
+				{70 * time.Second, 4},
+			}
+			for _, sample := range samples {
+				_, err := db.ExecContext(
+					ctx,
+					`INSERT INTO history (mtype, name, labels, ts, value)
+					 VALUES ('gauge', 'Alloc', 'host=a', $1, $2);`,
+					from.Add(sample.offset), sample.value,
+				)
+				require.NoError(t, err)
+			}
+
+			buckets, err := storage.ReadHistory(ctx, metrics.RangeQuery{
+				ID:     "Alloc",
+				MType:  metrics.MTypeGauge,
+				Labels: metrics.Labels{"host": "a"},
+				From:   from,
+				To:     from.Add(5 * time.Minute),
+				Step:   time.Minute,
+				Func:   metrics.AggAvg,
+			})
+			require.NoError(t, err)
+			require.Len(t, buckets, 2)
+			assert.True(t, from.Equal(buckets[0].Time))
+			assert.Equal(t, int64(2), buckets[0].Count)
+			assert.InDelta(t, 4, buckets[0].Sum, 0)
+			assert.InDelta(t, 3, buckets[0].Last, 0)
+			assert.True(t, from.Add(time.Minute).Equal(buckets[1].Time))
+			assert.InDelta(t, 4, buckets[1].Last, 0)
+		})
+
+		t.Run("Should move samples of default partition", func(t *testing.T) {
+			clearHistory(t)
+			ctx, cancel := context.WithTimeout(ctxBase, 5*time.Second)
+			defer cancel()
+
+			day := time.Now().UTC().Truncate(partitionRange).Add(
+				10 * partitionRange,
+			)
+			partition := partitionPrefix + day.Format(partitionLayout)
+			_, err := db.ExecContext(
+				ctx,
+				`INSERT INTO history (mtype, name, labels, ts, value)
+				 VALUES ('gauge', 'Alloc', '', $1, 1);`,
+				day.Add(time.Hour),
+			)
+			require.NoError(t, err, "sample out of partitions is recorded")
+			defer db.ExecContext(
+				context.TODO(), `DROP TABLE IF EXISTS `+partition+`;`,
+			)
+
+			tx, err := db.BeginTx(ctx, nil)
+			require.NoError(t, err)
+			defer tx.Rollback()
+			require.NoError(t, storage.ensurePartitions(ctx, tx, day))
+			require.NoError(t, tx.Commit())
+
+			var moved, left int
+			row := db.QueryRowContext(
+				ctx, `SELECT count(*) FROM `+partition+`;`,
+			)
+			require.NoError(t, row.Scan(&moved))
+			row = db.QueryRowContext(
+				ctx, `SELECT count(*) FROM `+partitionDefault+`;`,
+			)
+			require.NoError(t, row.Scan(&left))
+			assert.Equal(t, 1, moved)
+			assert.Zero(t, left)
+		})
+
+		t.Run("Should delete expired samples", func(t *testing.T) {
+			clearHistory(t)
+			ctx, cancel := context.WithTimeout(ctxBase, 5*time.Second)
+			defer cancel()
+
+			now := time.Now()
+			for _, ts := range []time.Time{now.Add(-2 * time.Hour), now} {
+				_, err := db.ExecContext(
+					ctx,
+					`INSERT INTO history (mtype, name, labels, ts, value)
+					 VALUES ('gauge', 'Alloc', '', $1, 1);`,
+					ts,
+				)
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, storage.maintainHistory(ctx, now))
+
+			row := db.QueryRowContext(
+				ctx, `SELECT count(*) FROM history WHERE ts < $1;`,
+				now.Add(-time.Hour),
+			)
+			var count int
+			require.NoError(t, row.Scan(&count))
+			assert.Zero(t, count)
+		})
+	})
 }
@@ -14,11 +14,21 @@ var ErrNotExists = errors.New("not exists")
 
 // New is a fabric method that returns storage with [di.Storage] interface.
 func New(
-	fo di.FileOperator, dsn string, saveInterval time.Duration, restore bool,
+	fo di.FileOperator,
+	dsn string,
+	saveInterval time.Duration,
+	restore bool,
+	walPath string,
+	keep int,
+	shards int,
+	historyRetention time.Duration,
+	batchTTL time.Duration,
 ) di.IStorage {
 	if dsn != "" {
-		return psqlstorage.New(dsn)
+		return psqlstorage.New(dsn, historyRetention, batchTTL)
 	}
 
-	return filestorage.New(fo, saveInterval, restore)
+	return filestorage.New(
+		fo, saveInterval, restore, walPath, keep, shards,
+	)
 }
@@ -84,11 +84,16 @@ func getMainFuncDecl(decls []ast.Decl) *ast.FuncDecl {
 	return nil
 }
 
+// findDirectCall returns "os.Exit" call of function body including
+// nested statements, calls of function literals are not direct.
 func findDirectCall(funcDecl *ast.FuncDecl) *ast.CallExpr {
 	var ret *ast.CallExpr
 	ast.Inspect(funcDecl, func(n ast.Node) bool {
 		switch e := n.(type) {
-		case *ast.FuncDecl, *ast.BlockStmt, *ast.ExprStmt:
+		case *ast.FuncDecl, *ast.BlockStmt, *ast.ExprStmt,
+			*ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.LabeledStmt,
+			*ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.CaseClause,
+			*ast.SelectStmt, *ast.CommClause, *ast.DeferStmt:
 			return true
 		case *ast.CallExpr:
 			if isOSExitCall(e) {
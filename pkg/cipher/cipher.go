@@ -1,21 +1,48 @@
 // Package cipher provides Encrypter and Decrypter over X.509 standart.
+//
+// Message is encrypted with envelope encryption: random AES-256-GCM data key
+// encrypts the message and RSA-OAEP with SHA-256 wraps the data key,
+// so the message size is not limited by RSA key size.
+//
+// Encrypted message layout:
+//
+//	version (1 byte) | wrapped key length (2 bytes, big endian) |
+//	wrapped key | GCM nonce (12 bytes) | ciphertext with GCM tag
+//
+// Version and wrapped key length are authenticated by GCM. Message of
+// exactly RSA key size has no header and is decrypted with RSA-OAEP
+// as is, it's legacy format of agents before envelope encryption.
 package cipher
 
 import (
+	"crypto/aes"
+	stdcipher "crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/binary"
 	"encoding/pem"
 	"errors"
 )
 
+// EnvelopeVersion is the version of encrypted message layout.
+const EnvelopeVersion byte = 1
+
+const (
+	dataKeySize  = 32 // AES-256
+	headerSize   = 3  // version and wrapped key length
+	gcmNonceSize = 12
+)
+
 var (
 	ErrFileLoad        = errors.New("failed to load file")
 	ErrParseCert       = errors.New("failed to parse certificate")
 	ErrParsePrivateKey = errors.New("failed to parse private key")
 	ErrPublicKeyType   = errors.New("invalid RSA PublicKey")
 	ErrEncryptMsg      = errors.New("failed to encrypt message")
+	ErrDecryptMsg      = errors.New("failed to decrypt message")
+	ErrMsgVersion      = errors.New("unsupported message version")
 )
 
 type (
@@ -36,6 +63,9 @@ type encrypterX509 struct {
 // NewEncrypter returns Encrypter pointer.
 func NewEncrypterX509(PEMData []byte) (Encrypter, error) {
 	block, _ := pem.Decode(PEMData)
+	if block == nil {
+		return nil, ErrParseCert
+	}
 
 	cert, err := x509.ParseCertificate(block.Bytes)
 	if err != nil {
@@ -49,13 +79,42 @@ func NewEncrypterX509(PEMData []byte) (Encrypter, error) {
 	return encrypter, nil
 }
 
-// EncryptMsg returns ciphered message data.
+// EncryptMsg returns ciphered message data in envelope layout.
 func (e *encrypterX509) EncryptMsg(msg []byte) ([]byte, error) {
-	data, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.publicKey, msg, nil)
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errors.Join(ErrEncryptMsg, err)
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(
+		sha256.New(), rand.Reader, e.publicKey, dataKey, nil,
+	)
 	if err != nil {
 		return nil, errors.Join(ErrEncryptMsg, err)
 	}
-	return data, nil
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, errors.Join(ErrEncryptMsg, err)
+	}
+
+	header := make([]byte, headerSize)
+	header[0] = EnvelopeVersion
+	binary.BigEndian.PutUint16(header[1:], uint16(len(wrappedKey)))
+
+	nonce := make([]byte, gcmNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Join(ErrEncryptMsg, err)
+	}
+
+	data := make(
+		[]byte, 0,
+		headerSize+len(wrappedKey)+gcmNonceSize+len(msg)+gcm.Overhead(),
+	)
+	data = append(data, header...)
+	data = append(data, wrappedKey...)
+	data = append(data, nonce...)
+	return gcm.Seal(data, nonce, msg, header), nil
 }
 
 // Decrypter provides simple message decrypting.
@@ -67,6 +126,9 @@ type decrypterX509 struct {
 // If PEMData is invalid error in occur.
 func NewDecrypterX509(PEMData []byte) (*decrypterX509, error) {
 	block, _ := pem.Decode(PEMData)
+	if block == nil {
+		return nil, ErrParsePrivateKey
+	}
 
 	rsaPrivateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
@@ -77,10 +139,62 @@ func NewDecrypterX509(PEMData []byte) (*decrypterX509, error) {
 }
 
 // DecryptMsg returns unciphered message data.
+//
+// Error wraps [ErrMsgVersion] if message layout version is unknown,
+// otherwise [ErrDecryptMsg].
 func (d *decrypterX509) DecryptMsg(msg []byte) ([]byte, error) {
-	data, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, d.privateKey, msg, nil)
+	if len(msg) == d.privateKey.Size() {
+		return d.decryptOAEP(msg)
+	}
+
+	if len(msg) < headerSize {
+		return nil, ErrDecryptMsg
+	}
+	if msg[0] != EnvelopeVersion {
+		return nil, ErrMsgVersion
+	}
+	header, body := msg[:headerSize], msg[headerSize:]
+
+	wrappedKeyLen := int(binary.BigEndian.Uint16(header[1:]))
+	if len(body) < wrappedKeyLen+gcmNonceSize {
+		return nil, ErrDecryptMsg
+	}
+	wrappedKey, body := body[:wrappedKeyLen], body[wrappedKeyLen:]
+	nonce, ciphertext := body[:gcmNonceSize], body[gcmNonceSize:]
+
+	dataKey, err := d.decryptOAEP(wrappedKey)
 	if err != nil {
 		return nil, err
 	}
+	if len(dataKey) != dataKeySize {
+		return nil, ErrDecryptMsg
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, errors.Join(ErrDecryptMsg, err)
+	}
+	data, err := gcm.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, errors.Join(ErrDecryptMsg, err)
+	}
 	return data, nil
 }
+
+func (d *decrypterX509) decryptOAEP(msg []byte) ([]byte, error) {
+	data, err := rsa.DecryptOAEP(
+		sha256.New(), rand.Reader, d.privateKey, msg, nil,
+	)
+	if err != nil {
+		return nil, errors.Join(ErrDecryptMsg, err)
+	}
+	return data, nil
+}
+
+func newGCM(key []byte) (stdcipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return stdcipher.NewGCM(block)
+}
@@ -0,0 +1,96 @@
+package cipher
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "runlytics"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	return
+}
+
+func TestCipher(t *testing.T) {
+	certPEM, keyPEM := newTestPEM(t)
+	enc, err := NewEncrypterX509(certPEM)
+	require.NoError(t, err)
+	dec, err := NewDecrypterX509(keyPEM)
+	require.NoError(t, err)
+
+	t.Run("Should decrypt message of any size", func(t *testing.T) {
+		for _, size := range []int{1, 190, 256, 4096, 1 << 20} {
+			msg := bytes.Repeat([]byte{'m'}, size)
+			data, err := enc.EncryptMsg(msg)
+			require.NoError(t, err)
+			assert.Equal(t, EnvelopeVersion, data[0])
+
+			got, err := dec.DecryptMsg(data)
+			require.NoError(t, err)
+			assert.Equal(t, msg, got)
+		}
+	})
+
+	t.Run("Should decrypt legacy message", func(t *testing.T) {
+		msg := []byte("legacy")
+		data, err := rsa.EncryptOAEP(
+			sha256.New(), rand.Reader, &dec.privateKey.PublicKey, msg, nil,
+		)
+		require.NoError(t, err)
+
+		got, err := dec.DecryptMsg(data)
+		require.NoError(t, err)
+		assert.Equal(t, msg, got)
+	})
+
+	t.Run("Should reject unknown version", func(t *testing.T) {
+		data, err := enc.EncryptMsg([]byte("message"))
+		require.NoError(t, err)
+		data[0] = EnvelopeVersion + 1
+
+		_, err = dec.DecryptMsg(data)
+		assert.ErrorIs(t, err, ErrMsgVersion)
+	})
+
+	t.Run("Should reject tampered message", func(t *testing.T) {
+		data, err := enc.EncryptMsg([]byte("message"))
+		require.NoError(t, err)
+
+		tampered := bytes.Clone(data)
+		tampered[len(tampered)-1] ^= 1
+		_, err = dec.DecryptMsg(tampered)
+		assert.ErrorIs(t, err, ErrDecryptMsg)
+
+		_, err = dec.DecryptMsg(data[:headerSize+10])
+		assert.ErrorIs(t, err, ErrDecryptMsg)
+
+		_, err = dec.DecryptMsg(data[:1])
+		assert.ErrorIs(t, err, ErrDecryptMsg)
+	})
+}
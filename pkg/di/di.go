@@ -4,8 +4,11 @@ package di
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"time"
 
 	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/niksmo/runlytics/pkg/token"
 )
 
 // MetricsGetter is the interface that wraps the GetGaugeMetrics method.
@@ -13,7 +16,8 @@ type MetricsGetter interface {
 	GetMetrics() metrics.MetricsList
 }
 
-type SendMetricsFunc func(ctx context.Context, m metrics.MetricsList, enc Encrypter, url, key, ip string) error
+// SendMetricsFunc sends metrics batch identified by id.
+type SendMetricsFunc func(ctx context.Context, id metrics.BatchID, m metrics.MetricsList, enc Encrypter, url, key, ip string) error
 
 type Runner interface {
 	Run()
@@ -79,42 +83,68 @@ type FileOperator interface {
 }
 
 // IUpdateByNameStorage is the interface that wraps the
-// UpdateCounterByName and UpdateGaugeByName methods.
+// UpdateCounterByName, UpdateGaugeByName and UpdateHistogramByName methods.
 type IUpdateByNameStorage interface {
-	UpdateCounterByName(ctx context.Context, name string, value int64) (int64, error)
-	UpdateGaugeByName(ctx context.Context, name string, value float64) (float64, error)
+	UpdateCounterByName(ctx context.Context, name string, labels metrics.Labels, value int64) (int64, error)
+	UpdateGaugeByName(ctx context.Context, name string, labels metrics.Labels, value float64) (float64, error)
+	UpdateHistogramByName(ctx context.Context, name string, labels metrics.Labels, value metrics.Histogram) (metrics.Histogram, error)
 }
 
-// IBatchUpdateStorage is the interface that wraps the
-// UpdateCounterList and UpdateGaugeList methods.
+// IBatchUpdateStorage is the interface that wraps the UpdateBatch method.
+//
+// UpdateBatch applies mixed metrics list atomically, unless batch id
+// is applied within [metrics.BatchWindow], and reports whether the batch
+// is duplicate. Zero batch id is always applied. If some items can't be
+// applied, nothing is applied and [metrics.BatchErrors] are returned.
 type IBatchUpdateStorage interface {
-	UpdateCounterList(ctx context.Context, slice metrics.MetricsList) error
-	UpdateGaugeList(ctx context.Context, slice metrics.MetricsList) error
+	UpdateBatch(ctx context.Context, id metrics.BatchID, slice metrics.MetricsList) (bool, error)
 }
 
 // IReadByNameStorage is the interface that wraps the
-// ReadCounterByName and ReadGaugeByName methods.
+// ReadCounterByName, ReadGaugeByName and ReadHistogramByName methods.
 type IReadByNameStorage interface {
-	ReadCounterByName(ctx context.Context, name string) (int64, error)
-	ReadGaugeByName(ctx context.Context, name string) (float64, error)
+	ReadCounterByName(ctx context.Context, name string, labels metrics.Labels) (int64, error)
+	ReadGaugeByName(ctx context.Context, name string, labels metrics.Labels) (float64, error)
+	ReadHistogramByName(ctx context.Context, name string, labels metrics.Labels) (metrics.Histogram, error)
 }
 
 // IReadListStorage is the interface that wraps the
-// ReadGauge and ReadCounter methods.
+// ReadGauge, ReadCounter and ReadHistogram methods.
+//
+// Returned maps are keyed by metrics key, see [metrics.MakeKey].
 type IReadListStorage interface {
 	ReadGauge(context.Context) (map[string]float64, error)
 	ReadCounter(context.Context) (map[string]int64, error)
+	ReadHistogram(context.Context) (map[string]metrics.Histogram, error)
+}
+
+// IHistoryStorage is the interface that wraps the ReadHistory method.
+//
+// Returned buckets are ordered by time and aligned by [metrics.AlignTime].
+type IHistoryStorage interface {
+	ReadHistory(ctx context.Context, q metrics.RangeQuery) ([]metrics.RangeBucket, error)
+}
+
+// IDeleteStorage is the interface that wraps the
+// DeleteByName and DeleteExpired methods.
+//
+// DeleteExpired deletes metrics not updated since before
+// and returns number of deleted metrics.
+type IDeleteStorage interface {
+	DeleteByName(ctx context.Context, mType, name string, labels metrics.Labels) error
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
 }
 
 // Storage is the interface that groups
-// the UpdateCounterByName, UpdateGaugeByName, UpdateCounterList,
-// UpdateGaugeList,ReadCounterByName, ReadGaugeByName,
-// ReadGauge, ReadCounter and Run methods.
+// the update, batch update, read by name, read list, read history, delete,
+// Run, Ping and Stop methods.
 type IStorage interface {
 	IReadByNameStorage
 	IReadListStorage
+	IHistoryStorage
 	IUpdateByNameStorage
 	IBatchUpdateStorage
+	IDeleteStorage
 	MustRunner
 	Pinger
 	Stopper
@@ -127,7 +157,7 @@ type IHealthCheckService interface {
 
 // IHTMLService is the interface that wraps the RenderMetricsList method.
 type IHTMLService interface {
-	RenderMetricsList(ctx context.Context, buf *bytes.Buffer) error
+	RenderMetricsList(ctx context.Context, filter metrics.Labels, buf *bytes.Buffer) error
 }
 
 // IReadService is the interface that wraps the Read method.
@@ -135,14 +165,53 @@ type IReadService interface {
 	Read(context.Context, *metrics.Metrics) error
 }
 
+// IListService is the interface that wraps the List method.
+//
+// List returns metrics which labels match the filter.
+type IListService interface {
+	List(ctx context.Context, filter metrics.Labels) (metrics.MetricsList, error)
+}
+
+// IRangeService is the interface that wraps the Range method.
+type IRangeService interface {
+	Range(context.Context, metrics.RangeQuery) ([]metrics.Point, error)
+}
+
 // IUpdateService is the interface that wraps the Update method.
 type IUpdateService interface {
 	Update(context.Context, *metrics.Metrics) error
 }
 
-// IBatchUpdateService is the interface that wraps the BatchUpdate method.
+// IDeleteService is the interface that wraps the Delete method.
+type IDeleteService interface {
+	Delete(context.Context, *metrics.Metrics) error
+}
+
+// IBatchUpdateService is the interface that wraps the
+// BatchUpdate, BatchUpdateOnce and BatchUpdatePartial methods.
+//
+// BatchUpdateOnce reports whether the batch is duplicate and not applied.
+// BatchUpdatePartial applies valid items only and returns every item outcome.
 type IBatchUpdateService interface {
 	BatchUpdate(context.Context, metrics.MetricsList) error
+	BatchUpdateOnce(context.Context, metrics.BatchID, metrics.MetricsList) (bool, error)
+	BatchUpdatePartial(context.Context, metrics.BatchID, metrics.MetricsList, ...metrics.VerifyOp) (metrics.BatchResult, error)
+}
+
+// IAgentKeyStorage is the interface that wraps the AgentKey method.
+//
+// AgentKey returns public key of agent batch signatures, error wraps
+// sign.ErrUnknownAgent or sign.ErrRevokedAgent if the agent is not allowed.
+type IAgentKeyStorage interface {
+	AgentKey(ctx context.Context, agentID string) (ed25519.PublicKey, error)
+}
+
+// ITokenStorage is the interface that wraps the Token method.
+//
+// Token returns stored API token, error wraps token.ErrUnknownToken
+// if the token does not exist.
+type ITokenStorage interface {
+	Token(ctx context.Context, id string) (token.Token, error)
 }
 
 // Decrypter is the interface that wraps the DecryptMsg method.
@@ -2,65 +2,139 @@
 package fileoperator
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"hash/crc32"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 )
 
-// FileOperator provides simple file usage.
-// Read and Write is buffered.
+const (
+	snapshotMagic   = "RUNLYTICS-SNAPSHOT"
+	snapshotVersion = 1
+	tmpSuffix       = ".tmp"
+)
+
+// Snapshot errors.
+var (
+	ErrInvalidSnapshot     = errors.New("invalid snapshot")
+	ErrUnsupportedSnapshot = errors.New("unsupported snapshot version")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileOperator saves data as crash-safe snapshots of underlying file.
+//
+// Snapshot has text header line with format version, payload length
+// and CRC-32C checksum, e.g. "RUNLYTICS-SNAPSHOT 1 1234 89abcdef".
+// Snapshot is written to temporary file, synced and atomically renamed
+// over the file. Previous snapshots are kept with ".1", ".2", ... suffixes.
 type FileOperator struct {
-	r *bufio.Reader
-	w *bufio.Writer
-	f *os.File
+	mu   sync.Mutex
+	f    *os.File
+	path string
+	keep int
 }
 
 // New returns FileOperator pointer.
-func New(file *os.File) *FileOperator {
-	return &FileOperator{
-		r: bufio.NewReader(file),
-		w: bufio.NewWriter(file),
-		f: file,
+//
+// Keep is number of previous snapshots kept for fallback.
+func New(file *os.File, keep int) *FileOperator {
+	fo := &FileOperator{f: file, keep: max(keep, 0)}
+	if file != nil {
+		fo.path = file.Name()
 	}
+	return fo
 }
 
-// Clear erase underlying file and move cursor to zero position.
+// Clear erase underlying file and removes previous snapshots.
 func (fo *FileOperator) Clear() (err error) {
 	const op = "FileOperator.Clear"
-	if err = fo.f.Truncate(0); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	for _, path := range append(fo.rotatedPaths(), fo.path+tmpSuffix) {
+		if err = removeIfExists(path); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
 	}
-	if _, err = fo.f.Seek(0, io.SeekStart); err != nil {
+	if err = os.Truncate(fo.path, 0); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 	return nil
 }
 
-// Load full reads underlying file.
+// Load returns payload of the newest valid snapshot.
+//
+// If the file is corrupted, previous snapshots are tried from newest to oldest.
+// File without header is loaded as is, if it is valid JSON, for compatibility
+// with plain JSON data files. Otherwise the header is considered corrupted.
+// Empty or missing file returns no data.
+// If no valid snapshot is found, error wraps every snapshot error.
 func (fo *FileOperator) Load() ([]byte, error) {
 	const op = "FileOperator.Load"
-	b, err := io.ReadAll(fo.r)
-	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	var errs []error
+	for _, path := range append([]string{fo.path}, fo.rotatedPaths()...) {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("'%s': %w", path, err))
+			continue
+		}
+		if len(b) == 0 {
+			if path == fo.path {
+				return nil, nil
+			}
+			continue
+		}
+
+		data, err := decodeSnapshot(b)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("'%s': %w", path, err))
+			continue
+		}
+		return data, nil
 	}
-	return b, nil
+
+	if len(errs) != 0 {
+		return nil, fmt.Errorf("%s: %w", op, errors.Join(errs...))
+	}
+	return nil, nil
 }
 
-// Save clear file and then write all passed data.
+// Save writes data snapshot to temporary file, syncs it, rotates
+// previous snapshots and then renames temporary file over the file.
 func (fo *FileOperator) Save(data []byte) (err error) {
 	const op = "FileOperator.Save"
-	if err = fo.Clear(); err != nil {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	tmp := fo.path + tmpSuffix
+	if err = writeSync(tmp, encodeSnapshot(data)); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
-	if _, err = fo.w.Write(data); err != nil {
+
+	if err = fo.rotate(); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	if err = fo.w.Flush(); err != nil {
+	if err = os.Rename(tmp, fo.path); err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err = syncDir(filepath.Dir(fo.path)); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
 	return nil
 }
 
@@ -72,3 +146,125 @@ func (fo *FileOperator) Close() error {
 	}
 	return nil
 }
+
+// rotate shifts previous snapshots and links current one as ".1",
+// so the file itself always exists. Empty file is not rotated.
+func (fo *FileOperator) rotate() error {
+	if fo.keep == 0 {
+		return nil
+	}
+
+	stat, err := os.Stat(fo.path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && stat.Size() == 0) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rotated := fo.rotatedPaths()
+	for idx := len(rotated) - 1; idx > 0; idx-- {
+		err := os.Rename(rotated[idx-1], rotated[idx])
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err = removeIfExists(rotated[0]); err != nil {
+		return err
+	}
+	return os.Link(fo.path, rotated[0])
+}
+
+// rotatedPaths returns previous snapshots paths from newest to oldest.
+func (fo *FileOperator) rotatedPaths() []string {
+	paths := make([]string, 0, fo.keep)
+	for n := 1; n <= fo.keep; n++ {
+		paths = append(paths, fo.path+"."+strconv.Itoa(n))
+	}
+	return paths
+}
+
+func encodeSnapshot(data []byte) []byte {
+	header := fmt.Sprintf(
+		"%s %d %d %08x\n",
+		snapshotMagic,
+		snapshotVersion,
+		len(data),
+		crc32.Checksum(data, crcTable),
+	)
+	return append([]byte(header), data...)
+}
+
+func decodeSnapshot(b []byte) ([]byte, error) {
+	magic := []byte(snapshotMagic)
+	if !bytes.HasPrefix(b, magic) && !bytes.HasPrefix(magic, b) {
+		if !json.Valid(b) {
+			return nil, fmt.Errorf(
+				"%w: missing header of not JSON data", ErrInvalidSnapshot,
+			)
+		}
+		return b, nil
+	}
+
+	header, data, ok := bytes.Cut(b, []byte("\n"))
+	if !ok {
+		return nil, fmt.Errorf("%w: missing header end", ErrInvalidSnapshot)
+	}
+
+	var (
+		version, size int
+		checksum      uint32
+	)
+	_, err := fmt.Sscanf(
+		string(header),
+		snapshotMagic+" %d %d %x",
+		&version, &size, &checksum,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %w", ErrInvalidSnapshot, err)
+	}
+	if version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedSnapshot, version)
+	}
+	if size != len(data) {
+		return nil, fmt.Errorf("%w: length mismatch", ErrInvalidSnapshot)
+	}
+	if crc32.Checksum(data, crcTable) != checksum {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	return data, nil
+}
+
+func writeSync(path string, b []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(path string) error {
+	d, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func removeIfExists(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
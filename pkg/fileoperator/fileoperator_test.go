@@ -0,0 +1,124 @@
+package fileoperator_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/niksmo/runlytics/pkg/fileoperator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileOperator(t *testing.T) {
+	open := func(t *testing.T, keep int) (*fileoperator.FileOperator, string) {
+		path := filepath.Join(t.TempDir(), "store.json")
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		require.NoError(t, err)
+		fo := fileoperator.New(f, keep)
+		t.Cleanup(func() { fo.Close() })
+		return fo, path
+	}
+
+	t.Run("Empty file", func(t *testing.T) {
+		fo, _ := open(t, 2)
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("Save and load", func(t *testing.T) {
+		fo, path := open(t, 2)
+		for _, data := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`} {
+			require.NoError(t, fo.Save([]byte(data)))
+		}
+
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Equal(t, `{"n":4}`, string(data))
+
+		_, err = os.Stat(path + ".2")
+		require.NoError(t, err)
+		_, err = os.Stat(path + ".3")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Stat(path + ".tmp")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("Fallback to previous snapshot", func(t *testing.T) {
+		fo, path := open(t, 2)
+		require.NoError(t, fo.Save([]byte(`{"n":1}`)))
+		require.NoError(t, fo.Save([]byte(`{"n":2}`)))
+		require.NoError(t, fo.Save([]byte(`{"n":3}`)))
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		b[len(b)-2] = '9'
+		require.NoError(t, os.WriteFile(path, b, 0644))
+
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Equal(t, `{"n":2}`, string(data))
+
+		require.NoError(t, os.WriteFile(path+".1", b[:10], 0644))
+		data, err = fo.Load()
+		require.NoError(t, err)
+		assert.Equal(t, `{"n":1}`, string(data))
+	})
+
+	t.Run("Fallback on corrupted header", func(t *testing.T) {
+		fo, path := open(t, 2)
+		require.NoError(t, fo.Save([]byte(`{"n":1}`)))
+		require.NoError(t, fo.Save([]byte(`{"n":2}`)))
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		b[0] = 'X'
+		require.NoError(t, os.WriteFile(path, b, 0644))
+
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Equal(t, `{"n":1}`, string(data))
+	})
+
+	t.Run("All snapshots corrupted", func(t *testing.T) {
+		fo, path := open(t, 1)
+		require.NoError(t, fo.Save([]byte(`{"n":1}`)))
+		require.NoError(t, os.WriteFile(path, []byte("RUNLYTICS-SNAPSHOT 1 7"), 0644))
+
+		_, err := fo.Load()
+		assert.ErrorIs(t, err, fileoperator.ErrInvalidSnapshot)
+	})
+
+	t.Run("Unsupported version", func(t *testing.T) {
+		fo, path := open(t, 0)
+		require.NoError(t, os.WriteFile(
+			path, []byte("RUNLYTICS-SNAPSHOT 2 2 00000000\n{}"), 0644,
+		))
+
+		_, err := fo.Load()
+		assert.ErrorIs(t, err, fileoperator.ErrUnsupportedSnapshot)
+	})
+
+	t.Run("Plain data file", func(t *testing.T) {
+		fo, path := open(t, 1)
+		require.NoError(t, os.WriteFile(path, []byte(`{"gauge":{}}`), 0644))
+
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Equal(t, `{"gauge":{}}`, string(data))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		fo, path := open(t, 1)
+		require.NoError(t, fo.Save([]byte(`{"n":1}`)))
+		require.NoError(t, fo.Save([]byte(`{"n":2}`)))
+		require.NoError(t, fo.Clear())
+
+		data, err := fo.Load()
+		require.NoError(t, err)
+		assert.Empty(t, data)
+		_, err = os.Stat(path + ".1")
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,110 @@
+// Package keyring holds rotated keys by key id.
+//
+// Active key has no expiry, retired key is accepted until its expiry,
+// so agents are moved to a new key without a flag day.
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownKey   = errors.New("unknown key id")
+	ErrExpiredKey   = errors.New("expired key")
+	ErrDuplicateKey = errors.New("duplicate key id")
+	ErrInvalidSpec  = errors.New("invalid key spec")
+)
+
+type entry[K any] struct {
+	key     K
+	expires time.Time
+}
+
+// Keyring is read-only after keys are added and is safe
+// for concurrent Get.
+type Keyring[K any] struct {
+	keys map[string]entry[K]
+	now  func() time.Time
+}
+
+// New returns empty Keyring pointer.
+func New[K any]() *Keyring[K] {
+	return &Keyring[K]{keys: make(map[string]entry[K]), now: time.Now}
+}
+
+// Add adds key with id. Zero expires is no expiry.
+// Empty id is the default key of requests without key id.
+func (r *Keyring[K]) Add(id string, key K, expires time.Time) error {
+	const op = "keyring.Add"
+	if _, ok := r.keys[id]; ok {
+		return fmt.Errorf("%s: %w '%s'", op, ErrDuplicateKey, id)
+	}
+	r.keys[id] = entry[K]{key: key, expires: expires}
+	return nil
+}
+
+// Get returns key with id.
+// Error wraps [ErrUnknownKey] or [ErrExpiredKey].
+func (r *Keyring[K]) Get(id string) (K, error) {
+	const op = "keyring.Get"
+	var zero K
+	if r == nil {
+		return zero, fmt.Errorf("%s: %w '%s'", op, ErrUnknownKey, id)
+	}
+	e, ok := r.keys[id]
+	if !ok {
+		return zero, fmt.Errorf("%s: %w '%s'", op, ErrUnknownKey, id)
+	}
+	if !e.expires.IsZero() && !r.now().Before(e.expires) {
+		return zero, fmt.Errorf("%s: %w '%s'", op, ErrExpiredKey, id)
+	}
+	return e.key, nil
+}
+
+// Len returns number of keys including expired.
+func (r *Keyring[K]) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.keys)
+}
+
+// Spec describes key in configuration.
+type Spec struct {
+	ID, Value string
+	Expires   time.Time // zero is no expiry
+}
+
+// ParseSpecs parses comma separated keys 'id=value' or
+// 'id=value@expires', expires is in RFC 3339 format,
+// e.g. 'k2=secret2,k1=secret1@2026-01-02T15:04:05Z'.
+func ParseSpecs(s string) ([]Spec, error) {
+	const op = "keyring.ParseSpecs"
+	var specs []Spec
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(item, "=")
+		if !ok || id == "" || value == "" {
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidSpec, item)
+		}
+		spec := Spec{ID: id, Value: value}
+		if idx := strings.LastIndex(value, "@"); idx != -1 {
+			expires, err := time.Parse(time.RFC3339, value[idx+1:])
+			if err != nil || idx == 0 {
+				return nil, fmt.Errorf(
+					"%s: %w '%s': expiry must be RFC 3339 time",
+					op, ErrInvalidSpec, item,
+				)
+			}
+			spec.Value, spec.Expires = value[:idx], expires
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
@@ -0,0 +1,66 @@
+package keyring
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	r := New[string]()
+	r.now = func() time.Time { return now }
+
+	require.NoError(t, r.Add("", "default", time.Time{}))
+	require.NoError(t, r.Add("k2", "active", time.Time{}))
+	require.NoError(t, r.Add("k1", "retired", now.Add(time.Hour)))
+	require.NoError(t, r.Add("k0", "expired", now))
+	assert.ErrorIs(t, r.Add("k1", "other", time.Time{}), ErrDuplicateKey)
+	assert.Equal(t, 4, r.Len())
+
+	for id, want := range map[string]string{
+		"": "default", "k2": "active", "k1": "retired",
+	} {
+		key, err := r.Get(id)
+		require.NoError(t, err)
+		assert.Equal(t, want, key)
+	}
+
+	_, err := r.Get("k0")
+	assert.ErrorIs(t, err, ErrExpiredKey)
+	_, err = r.Get("k3")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	var empty *Keyring[string]
+	assert.Equal(t, 0, empty.Len())
+	_, err = empty.Get("")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestParseSpecs(t *testing.T) {
+	t.Run("Should parse keys", func(t *testing.T) {
+		specs, err := ParseSpecs(
+			"k2=secret2, k1=se@cret1@2026-01-02T15:04:05Z,",
+		)
+		require.NoError(t, err)
+		assert.Equal(t, []Spec{
+			{ID: "k2", Value: "secret2"},
+			{
+				ID:      "k1",
+				Value:   "se@cret1",
+				Expires: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC),
+			},
+		}, specs)
+	})
+
+	t.Run("Should fail on invalid spec", func(t *testing.T) {
+		for _, s := range []string{
+			"secret", "=secret", "k1=", "k1=secret@2026-01-02", "k1=@2026-01-02T15:04:05Z",
+		} {
+			_, err := ParseSpecs(s)
+			assert.ErrorIs(t, err, ErrInvalidSpec, s)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// BatchWindow is how long server remembers applied batch sequences.
+// Agent should not resend a batch later than the window.
+const BatchWindow = time.Hour
+
+const maxAgentIDLen = 128
+
+// Batch identity errors.
+var (
+	ErrInvalidAgentID  = errors.New("'agent id': up to 128 printable characters")
+	ErrInvalidBatchSeq = errors.New("'batch seq': unsigned integer required")
+)
+
+// A BatchID identifies metrics batch sent by agent.
+//
+// Agent increments Seq for every new batch and resends failed batch
+// with the same Seq, so server applies each batch only once.
+type BatchID struct {
+	AgentID string
+	Seq     uint64
+}
+
+// ParseBatchID returns batch id from agent id and decimal sequence.
+// Empty agent id returns zero batch id, the batch is not deduplicated.
+func ParseBatchID(agentID, seq string) (BatchID, error) {
+	if agentID == "" {
+		return BatchID{}, nil
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return BatchID{}, ErrInvalidBatchSeq
+	}
+	id := BatchID{AgentID: agentID, Seq: n}
+	if err = id.Verify(); err != nil {
+		return BatchID{}, err
+	}
+	return id, nil
+}
+
+// IsZero reports whether batch is not identified.
+func (id BatchID) IsZero() bool {
+	return id.AgentID == ""
+}
+
+// Verify returns [ErrInvalidAgentID], if agent id is too long
+// or contains not printable characters.
+func (id BatchID) Verify() error {
+	if len(id.AgentID) > maxAgentIDLen {
+		return ErrInvalidAgentID
+	}
+	for _, r := range id.AgentID {
+		if !unicode.IsPrint(r) {
+			return ErrInvalidAgentID
+		}
+	}
+	return nil
+}
+
+// String returns batch id in form "agent#seq".
+func (id BatchID) String() string {
+	return id.AgentID + "#" + strconv.FormatUint(id.Seq, 10)
+}
+
+// An ItemError describes failed item of metrics list.
+type ItemError struct {
+	Index int
+	ID    string
+	MType string
+	Err   error
+}
+
+// NewItemError returns error of list item m with index idx.
+func NewItemError(idx int, m Metrics, err error) *ItemError {
+	return &ItemError{Index: idx, ID: m.ID, MType: m.MType, Err: err}
+}
+
+// Error returns item index and error, e.g. "2: 'id': required".
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns item error cause.
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchErrors are failed items of metrics list in index order.
+// List with failed items is not applied at all.
+type BatchErrors []*ItemError
+
+// Error returns items errors, e.g. "[0: 'id': required, 2: ...]".
+func (be BatchErrors) Error() string {
+	s := make([]string, len(be))
+	for idx, e := range be {
+		s[idx] = e.Error()
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
+
+// Unwrap returns items errors.
+func (be BatchErrors) Unwrap() []error {
+	errs := make([]error, len(be))
+	for idx, e := range be {
+		errs[idx] = e
+	}
+	return errs
+}
+
+// Batch item statuses of partial update.
+const (
+	ItemApplied = "applied" // item is applied
+	ItemInvalid = "invalid" // item is not valid, see [Metrics.Verify]
+	ItemFailed  = "failed"  // valid item can't be applied by storage
+)
+
+// An ItemResult describes list item outcome of partial update.
+// Error is set for invalid and failed items.
+type ItemResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// A BatchResult describes partial update outcome of every list item.
+// Items of duplicate batch are reported as applied.
+type BatchResult struct {
+	Duplicate bool         `json:"duplicate"`
+	Applied   int          `json:"applied"`
+	Items     []ItemResult `json:"items"`
+}
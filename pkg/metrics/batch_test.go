@@ -0,0 +1,39 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchID(t *testing.T) {
+	t.Run("Empty agent id", func(t *testing.T) {
+		id, err := metrics.ParseBatchID("", "")
+		require.NoError(t, err)
+		assert.True(t, id.IsZero())
+	})
+
+	t.Run("Regular", func(t *testing.T) {
+		id, err := metrics.ParseBatchID("host-a", "42")
+		require.NoError(t, err)
+		assert.Equal(t, metrics.BatchID{AgentID: "host-a", Seq: 42}, id)
+		assert.Equal(t, "host-a#42", id.String())
+	})
+
+	t.Run("Invalid seq", func(t *testing.T) {
+		for _, seq := range []string{"", "-1", "1.5", "abc"} {
+			_, err := metrics.ParseBatchID("host-a", seq)
+			assert.ErrorIs(t, err, metrics.ErrInvalidBatchSeq, seq)
+		}
+	})
+
+	t.Run("Invalid agent id", func(t *testing.T) {
+		for _, agentID := range []string{strings.Repeat("a", 129), "host\n"} {
+			_, err := metrics.ParseBatchID(agentID, "1")
+			assert.ErrorIs(t, err, metrics.ErrInvalidAgentID)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// Histogram errors.
+var (
+	ErrHistogramRequired = errors.New("'histogram': required for histogram type")
+	ErrHistogramBounds   = errors.New("'histogram': bounds should be sorted and unique")
+	ErrHistogramCounts   = errors.New("'histogram': counts length should be bounds length + 1")
+	ErrHistogramCount    = errors.New("'histogram': count should be equal to counts sum")
+	ErrHistogramMismatch = errors.New("'histogram': bounds mismatch")
+	ErrInvalidQuantile   = errors.New("quantile should be in range [0, 1]")
+)
+
+// A Histogram describes observations distribution over buckets.
+//
+// Bucket i counts observations less or equal Bounds[i]
+// and greater than Bounds[i-1]. The last bucket is +Inf bucket,
+// so Counts length is Bounds length + 1.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []uint64  `json:"counts"`
+	Sum    float64   `json:"sum"`
+	Count  uint64    `json:"count"`
+}
+
+// NewHistogram returns empty histogram with passed bucket bounds.
+// Bounds are sorted and deduplicated.
+func NewHistogram(bounds ...float64) Histogram {
+	b := slices.Clone(bounds)
+	slices.Sort(b)
+	b = slices.Compact(b)
+	return Histogram{Bounds: b, Counts: make([]uint64, len(b)+1)}
+}
+
+// Observe adds value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	idx, _ := slices.BinarySearch(h.Bounds, v)
+	h.Counts[idx]++
+	h.Sum += v
+	h.Count++
+}
+
+// Merge adds other histogram bucket counts, sum and count.
+// If bounds are not equal, [ErrHistogramMismatch] is returned.
+func (h *Histogram) Merge(other Histogram) error {
+	if !slices.Equal(h.Bounds, other.Bounds) ||
+		len(h.Counts) != len(other.Counts) {
+		return ErrHistogramMismatch
+	}
+	for idx, c := range other.Counts {
+		h.Counts[idx] += c
+	}
+	h.Sum += other.Sum
+	h.Count += other.Count
+	return nil
+}
+
+// Clone returns histogram deep copy.
+func (h Histogram) Clone() Histogram {
+	h.Bounds = slices.Clone(h.Bounds)
+	h.Counts = slices.Clone(h.Counts)
+	return h
+}
+
+// Quantile returns q-quantile estimate by linear interpolation
+// inside the bucket where the quantile is located.
+//
+// If quantile is located in the +Inf bucket, the greatest bound is returned.
+// Empty histogram returns zero.
+func (h Histogram) Quantile(q float64) (float64, error) {
+	if q < 0 || q > 1 || math.IsNaN(q) {
+		return 0, ErrInvalidQuantile
+	}
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0, nil
+	}
+
+	rank := q * float64(h.Count)
+	var cumulative uint64
+	for idx, c := range h.Counts {
+		prev := cumulative
+		cumulative += c
+		if float64(cumulative) < rank || c == 0 {
+			continue
+		}
+
+		if idx == len(h.Bounds) {
+			return h.Bounds[len(h.Bounds)-1], nil
+		}
+
+		upper := h.Bounds[idx]
+		lower := 0.0
+		switch {
+		case idx > 0:
+			lower = h.Bounds[idx-1]
+		case upper <= 0:
+			return upper, nil
+		}
+		return lower + (upper-lower)*(rank-float64(prev))/float64(c), nil
+	}
+	return h.Bounds[len(h.Bounds)-1], nil
+}
+
+// String returns histogram count and sum, e.g. "count=10 sum=12.5".
+func (h Histogram) String() string {
+	return fmt.Sprintf(
+		"count=%s sum=%s",
+		strconv.FormatUint(h.Count, 10), valueToStr(h.Sum),
+	)
+}
+
+// VerifyHistogram performs validation under Metrics.Histogram field,
+// only for histogram type:
+//   - If histogram is nil, [ErrHistogramRequired] is occur.
+//   - If bounds are not sorted or not unique, [ErrHistogramBounds] is occur.
+//   - If counts length is invalid, [ErrHistogramCounts] is occur.
+//   - If count is not equal to counts sum, [ErrHistogramCount] is occur.
+func VerifyHistogram(m Metrics) error {
+	if m.MType != MTypeHistogram {
+		return nil
+	}
+
+	h := m.Histogram
+	if h == nil {
+		return ErrHistogramRequired
+	}
+
+	for idx := 1; idx < len(h.Bounds); idx++ {
+		if h.Bounds[idx] <= h.Bounds[idx-1] {
+			return ErrHistogramBounds
+		}
+	}
+
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return ErrHistogramCounts
+	}
+
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	if count != h.Count {
+		return ErrHistogramCount
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := metrics.NewHistogram(1, 0.5, 0.1, 0.5)
+	assert.Equal(t, []float64{0.1, 0.5, 1}, h.Bounds)
+	require.Len(t, h.Counts, 4)
+
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v)
+	}
+	assert.Equal(t, []uint64{2, 1, 1, 1}, h.Counts)
+	assert.Equal(t, uint64(5), h.Count)
+	assert.InDelta(t, 3.15, h.Sum, 1e-9)
+}
+
+func TestHistogramMerge(t *testing.T) {
+	t.Run("Equal bounds", func(t *testing.T) {
+		h := metrics.NewHistogram(0.1, 1)
+		h.Observe(0.05)
+		other := metrics.NewHistogram(0.1, 1)
+		other.Observe(0.5)
+		other.Observe(5)
+
+		require.NoError(t, h.Merge(other))
+		assert.Equal(t, []uint64{1, 1, 1}, h.Counts)
+		assert.Equal(t, uint64(3), h.Count)
+		assert.InDelta(t, 5.55, h.Sum, 1e-9)
+	})
+
+	t.Run("Different bounds", func(t *testing.T) {
+		h := metrics.NewHistogram(0.1, 1)
+		other := metrics.NewHistogram(0.2, 1)
+		assert.ErrorIs(t, h.Merge(other), metrics.ErrHistogramMismatch)
+	})
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := metrics.Histogram{
+		Bounds: []float64{1, 2, 4},
+		Counts: []uint64{10, 10, 0, 0},
+		Count:  20,
+	}
+
+	median, err := h.Quantile(0.5)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, median, 1e-9)
+
+	q75, err := h.Quantile(0.75)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, q75, 1e-9)
+
+	q25, err := h.Quantile(0.25)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, q25, 1e-9)
+
+	_, err = h.Quantile(1.5)
+	require.ErrorIs(t, err, metrics.ErrInvalidQuantile)
+
+	inf := metrics.Histogram{
+		Bounds: []float64{1}, Counts: []uint64{0, 3}, Count: 3,
+	}
+	v, err := inf.Quantile(0.99)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, v, 0)
+
+	empty := metrics.NewHistogram(1)
+	v, err = empty.Quantile(0.5)
+	require.NoError(t, err)
+	assert.Zero(t, v)
+}
+
+func TestVerifyHistogram(t *testing.T) {
+	t.Run("Not histogram type", func(t *testing.T) {
+		m := metrics.Metrics{MType: metrics.MTypeGauge}
+		assert.NoError(t, m.Verify(metrics.VerifyHistogram))
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		m := metrics.Metrics{MType: metrics.MTypeHistogram}
+		assert.ErrorIs(
+			t, m.Verify(metrics.VerifyHistogram), metrics.ErrHistogramRequired,
+		)
+	})
+
+	t.Run("Unsorted bounds", func(t *testing.T) {
+		m := metrics.Metrics{
+			MType: metrics.MTypeHistogram,
+			Histogram: &metrics.Histogram{
+				Bounds: []float64{2, 1}, Counts: []uint64{0, 0, 0},
+			},
+		}
+		assert.ErrorIs(
+			t, m.Verify(metrics.VerifyHistogram), metrics.ErrHistogramBounds,
+		)
+	})
+
+	t.Run("Invalid counts length", func(t *testing.T) {
+		m := metrics.Metrics{
+			MType: metrics.MTypeHistogram,
+			Histogram: &metrics.Histogram{
+				Bounds: []float64{1, 2}, Counts: []uint64{0, 0},
+			},
+		}
+		assert.ErrorIs(
+			t, m.Verify(metrics.VerifyHistogram), metrics.ErrHistogramCounts,
+		)
+	})
+
+	t.Run("Invalid count", func(t *testing.T) {
+		m := metrics.Metrics{
+			MType: metrics.MTypeHistogram,
+			Histogram: &metrics.Histogram{
+				Bounds: []float64{1}, Counts: []uint64{1, 1}, Count: 1,
+			},
+		}
+		assert.ErrorIs(
+			t, m.Verify(metrics.VerifyHistogram), metrics.ErrHistogramCount,
+		)
+	})
+
+	t.Run("Regular", func(t *testing.T) {
+		h := metrics.NewHistogram(0.1, 1)
+		h.Observe(0.5)
+		m := metrics.Metrics{MType: metrics.MTypeHistogram, Histogram: &h}
+		assert.NoError(t, m.Verify(metrics.VerifyHistogram))
+		assert.Equal(t, "count=1 sum=0.5", m.GetValue())
+	})
+}
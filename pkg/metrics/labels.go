@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	labelsSep     = ","
+	labelValueSep = "="
+	labelsOpen    = "{"
+	labelsClose   = "}"
+)
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels validation errors.
+var (
+	ErrInvalidLabelName  = errors.New("'labels': name should match [a-zA-Z_][a-zA-Z0-9_]*")
+	ErrInvalidLabelValue = errors.New("'labels': value should not contain ',', '=', '{', '}'")
+)
+
+// Labels represents metrics dimensions, e.g. host, service or environment.
+type Labels map[string]string
+
+// ParseLabels returns labels parsed from canonical string form,
+// e.g. "env=prod,host=a". Empty string returns nil labels.
+func ParseLabels(s string) (Labels, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	pairs := strings.Split(s, labelsSep)
+	labels := make(Labels, len(pairs))
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, labelValueSep)
+		if !ok {
+			return nil, fmt.Errorf("invalid label pair '%s'", pair)
+		}
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if err := verifyLabel(name, value); err != nil {
+			return nil, err
+		}
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// String returns canonical labels form: pairs sorted by name
+// and joined by comma, e.g. "env=prod,host=a".
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	names := slices.Sorted(maps.Keys(l))
+	var b strings.Builder
+	for i, name := range names {
+		if i != 0 {
+			b.WriteString(labelsSep)
+		}
+		b.WriteString(name)
+		b.WriteString(labelValueSep)
+		b.WriteString(l[name])
+	}
+	return b.String()
+}
+
+// Match reports whether labels contains all filter pairs.
+// Empty filter matches any labels.
+func (l Labels) Match(filter Labels) bool {
+	for name, value := range filter {
+		if v, ok := l[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// MakeKey returns metrics identity built from id and labels,
+// e.g. "Alloc{env=prod,host=a}". Without labels the key equals id.
+func MakeKey(id string, labels Labels) string {
+	if len(labels) == 0 {
+		return id
+	}
+	return id + labelsOpen + labels.String() + labelsClose
+}
+
+// ParseKey splits key made by [MakeKey] on id and labels.
+func ParseKey(key string) (string, Labels, error) {
+	if !strings.HasSuffix(key, labelsClose) {
+		return key, nil, nil
+	}
+
+	idx := strings.LastIndex(key, labelsOpen)
+	if idx == -1 {
+		return key, nil, nil
+	}
+
+	labels, err := ParseLabels(key[idx+1 : len(key)-1])
+	if err != nil {
+		return "", nil, err
+	}
+	return key[:idx], labels, nil
+}
+
+// VerifyLabels performs validation under Metrics.Labels field:
+//   - If label name is invalid, [ErrInvalidLabelName] is occur.
+//   - If label value is invalid, [ErrInvalidLabelValue] is occur.
+func VerifyLabels(m Metrics) error {
+	for name, value := range m.Labels {
+		if err := verifyLabel(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyLabel(name, value string) error {
+	if !labelNameRe.MatchString(name) {
+		return ErrInvalidLabelName
+	}
+	if strings.ContainsAny(value, labelsSep+labelValueSep+labelsOpen+labelsClose) {
+		return ErrInvalidLabelValue
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabels(t *testing.T) {
+	t.Run("Empty string", func(t *testing.T) {
+		labels, err := metrics.ParseLabels("")
+		require.NoError(t, err)
+		assert.Nil(t, labels)
+	})
+
+	t.Run("Regular", func(t *testing.T) {
+		labels, err := metrics.ParseLabels("host=a, env=prod")
+		require.NoError(t, err)
+		assert.Equal(t, metrics.Labels{"host": "a", "env": "prod"}, labels)
+	})
+
+	t.Run("Missing value separator", func(t *testing.T) {
+		_, err := metrics.ParseLabels("host")
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		_, err := metrics.ParseLabels("1host=a")
+		assert.ErrorIs(t, err, metrics.ErrInvalidLabelName)
+	})
+
+	t.Run("Invalid value", func(t *testing.T) {
+		_, err := metrics.ParseLabels("host=a=b")
+		assert.ErrorIs(t, err, metrics.ErrInvalidLabelValue)
+	})
+}
+
+func TestLabelsString(t *testing.T) {
+	assert.Empty(t, metrics.Labels(nil).String())
+
+	labels := metrics.Labels{"service": "api", "host": "a", "env": "prod"}
+	assert.Equal(t, "env=prod,host=a,service=api", labels.String())
+}
+
+func TestLabelsMatch(t *testing.T) {
+	labels := metrics.Labels{"host": "a", "env": "prod"}
+	assert.True(t, labels.Match(nil))
+	assert.True(t, labels.Match(metrics.Labels{"host": "a"}))
+	assert.False(t, labels.Match(metrics.Labels{"host": "b"}))
+	assert.False(t, labels.Match(metrics.Labels{"service": "api"}))
+}
+
+func TestMetricsKey(t *testing.T) {
+	t.Run("Without labels", func(t *testing.T) {
+		m := metrics.Metrics{ID: "Alloc"}
+		assert.Equal(t, "Alloc", m.Key())
+
+		id, labels, err := metrics.ParseKey(m.Key())
+		require.NoError(t, err)
+		assert.Equal(t, "Alloc", id)
+		assert.Nil(t, labels)
+	})
+
+	t.Run("With labels", func(t *testing.T) {
+		m := metrics.Metrics{
+			ID: "Alloc", Labels: metrics.Labels{"host": "a", "env": "prod"},
+		}
+		assert.Equal(t, "Alloc{env=prod,host=a}", m.Key())
+
+		id, labels, err := metrics.ParseKey(m.Key())
+		require.NoError(t, err)
+		assert.Equal(t, "Alloc", id)
+		assert.Equal(t, m.Labels, labels)
+	})
+}
+
+func TestVerifyLabels(t *testing.T) {
+	m := metrics.Metrics{Labels: metrics.Labels{"host": "a"}}
+	require.NoError(t, m.Verify(metrics.VerifyLabels))
+
+	m.Labels["bad-name"] = "a"
+	assert.ErrorIs(t, m.Verify(metrics.VerifyLabels), metrics.ErrInvalidLabelName)
+}
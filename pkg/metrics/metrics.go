@@ -2,16 +2,15 @@
 package metrics
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
 	"strings"
 )
 
 // Metrics type constants.
 const (
-	MTypeGauge   = "gauge"
-	MTypeCounter = "counter"
+	MTypeGauge     = "gauge"
+	MTypeCounter   = "counter"
+	MTypeHistogram = "histogram"
 )
 
 // The VerifyOp type is validation check function signature.
@@ -19,10 +18,12 @@ type VerifyOp func(m Metrics) error
 
 // A Metrics describes metrics object.
 type Metrics struct {
-	ID    string  `json:"id"`
-	MType string  `json:"type"`            // use gauge or counter constants
-	Delta int64   `json:"delta,omitempty"` // for counter
-	Value float64 `json:"value,omitempty"` // for gauge
+	ID        string     `json:"id"`
+	MType     string     `json:"type"`                // use gauge, counter or histogram constants
+	Delta     int64      `json:"delta,omitempty"`     // for counter
+	Value     float64    `json:"value,omitempty"`     // for gauge
+	Histogram *Histogram `json:"histogram,omitempty"` // for histogram
+	Labels    Labels     `json:"labels,omitempty"`
 }
 
 // NewFromStrArgs constructor returns a new metrics.
@@ -73,34 +74,47 @@ func (m Metrics) Verify(ops ...VerifyOp) error {
 
 // GetValue returns a converted Delta or Value to string.
 //
+// Histogram is converted by [Histogram.String].
+//
 // Zero string returns, if:
-//   - metrics type not counter of gauge
-//   - delta or value (according by metrics type) is nil
+//   - metrics type not counter, gauge or histogram
+//   - histogram (for histogram type) is nil
 func (m Metrics) GetValue() string {
 	switch m.MType {
 	case MTypeGauge:
 		return valueToStr(m.Value)
 	case MTypeCounter:
 		return deltaToStr(m.Delta)
+	case MTypeHistogram:
+		if m.Histogram == nil {
+			return ""
+		}
+		return m.Histogram.String()
 	default:
 		return ""
 	}
 }
 
+// Key returns metrics identity built from ID and Labels, see [MakeKey].
+func (m Metrics) Key() string {
+	return MakeKey(m.ID, m.Labels)
+}
+
 // MetricsList represents slice of metrics objects.
 type MetricsList []Metrics
 
-// Verify behaves as Metrics.Verify method, but errors joins in one.
+// Verify behaves as Metrics.Verify method, but errors of items
+// are returned together as [BatchErrors].
 func (ml MetricsList) Verify(ops ...VerifyOp) error {
-	var s []string
+	var errs BatchErrors
 	for i, item := range ml {
 		if err := item.Verify(ops...); err != nil {
-			s = append(s, fmt.Sprintf("%d: %s", i, err.Error()))
+			errs = append(errs, NewItemError(i, item, err))
 		}
 	}
 
-	if len(s) != 0 {
-		return errors.New("[" + strings.Join(s, ", ") + "]")
+	if len(errs) != 0 {
+		return errs
 	}
 
 	return nil
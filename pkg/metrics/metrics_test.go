@@ -314,6 +314,28 @@ func TestMetricsListVerify(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("Returns failed items", func(t *testing.T) {
+		ml := metrics.MetricsList{
+			{ID: "Alloc", MType: metrics.MTypeGauge},
+			{ID: "", MType: metrics.MTypeGauge},
+			{ID: "Size", MType: "unknown"},
+		}
+		err := ml.Verify(metrics.VerifyID, metrics.VerifyType)
+
+		var errs metrics.BatchErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 2)
+		assert.Equal(t, 1, errs[0].Index)
+		assert.Equal(t, 2, errs[1].Index)
+		assert.Equal(t, "Size", errs[1].ID)
+		assert.ErrorIs(t, err, metrics.ErrIDRequired)
+		assert.ErrorIs(t, err, metrics.ErrInvalidType)
+		assert.Equal(
+			t, "[1: 'id': required, 2: "+metrics.ErrInvalidType.Error()+"]",
+			err.Error(),
+		)
+	})
+
 	t.Run("Regular", func(t *testing.T) {
 		m0Delta := int64(12345)
 		m1Value := 123.45
@@ -0,0 +1,184 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+)
+
+// Range aggregation functions.
+const (
+	AggAvg  = "avg"
+	AggMin  = "min"
+	AggMax  = "max"
+	AggLast = "last"
+	AggSum  = "sum"
+	AggRate = "rate" // per-second rate, only for counter type
+)
+
+// Range query defaults and limits.
+const (
+	DefaultRangeWindow = time.Hour
+	DefaultRangeStep   = time.Minute
+	DefaultRangeFunc   = AggAvg
+	MaxRangePoints     = 11000
+)
+
+var allowedAgg = map[string]struct{}{
+	AggAvg:  {},
+	AggMin:  {},
+	AggMax:  {},
+	AggLast: {},
+	AggSum:  {},
+	AggRate: {},
+}
+
+// Range query errors.
+var (
+	ErrInvalidRangeType = errors.New("'type': range is allowed only for gauge and counter types")
+	ErrInvalidAgg       = errors.New("'func': should be one of avg, min, max, last, sum, rate")
+	ErrRateType         = errors.New("'func': rate is allowed only for counter type")
+	ErrInvalidRange     = errors.New("'from': should be before 'to'")
+	ErrInvalidStep      = errors.New("'step': should be whole number of seconds, at least one")
+	ErrTooManyPoints    = errors.New("'step': too many points for range")
+)
+
+// A RangeQuery describes request for metrics history aggregated by step.
+type RangeQuery struct {
+	ID     string
+	MType  string
+	Labels Labels
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+	Func   string
+}
+
+// SetDefaults fills zero fields: range ends at now and lasts
+// [DefaultRangeWindow], step is [DefaultRangeStep] and func is [DefaultRangeFunc].
+func (q *RangeQuery) SetDefaults(now time.Time) {
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		q.From = q.To.Add(-DefaultRangeWindow)
+	}
+	if q.Step == 0 {
+		q.Step = DefaultRangeStep
+	}
+	if q.Func == "" {
+		q.Func = DefaultRangeFunc
+	}
+}
+
+// Verify performs range query validation:
+//   - ID and labels are verified as [Metrics] fields.
+//   - If type is not gauge or counter, [ErrInvalidRangeType] is occur.
+//   - If func is not allowed, [ErrInvalidAgg] is occur.
+//   - If func is rate and type is not counter, [ErrRateType] is occur.
+//   - If from is not before to, [ErrInvalidRange] is occur.
+//   - If step is not whole seconds, [ErrInvalidStep] is occur.
+//   - If range contains more than [MaxRangePoints] steps, [ErrTooManyPoints] is occur.
+func (q RangeQuery) Verify() error {
+	m := Metrics{ID: q.ID, MType: q.MType, Labels: q.Labels}
+	if err := m.Verify(VerifyID, VerifyLabels); err != nil {
+		return err
+	}
+
+	if q.MType != MTypeGauge && q.MType != MTypeCounter {
+		return ErrInvalidRangeType
+	}
+
+	if _, ok := allowedAgg[q.Func]; !ok {
+		return ErrInvalidAgg
+	}
+
+	if q.Func == AggRate && q.MType != MTypeCounter {
+		return ErrRateType
+	}
+
+	if !q.From.Before(q.To) {
+		return ErrInvalidRange
+	}
+
+	if q.Step < time.Second || q.Step%time.Second != 0 {
+		return ErrInvalidStep
+	}
+
+	if q.To.Sub(q.From)/q.Step > MaxRangePoints {
+		return ErrTooManyPoints
+	}
+	return nil
+}
+
+// AlignTime returns t rounded down to a multiple of step since Unix epoch.
+// History buckets are aligned the same way.
+func AlignTime(t time.Time, step time.Duration) time.Time {
+	sec := int64(step / time.Second)
+	if sec <= 0 {
+		return t
+	}
+	unix := t.Unix()
+	aligned := unix - unix%sec
+	if unix%sec < 0 {
+		aligned -= sec
+	}
+	return time.Unix(aligned, 0).UTC()
+}
+
+// A RangeBucket is summary of history samples within one step.
+type RangeBucket struct {
+	Time  time.Time
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count int64
+	Last  float64
+}
+
+// A Point is aggregated history value for step started at Time.
+type Point struct {
+	Time  time.Time `json:"ts"`
+	Value float64   `json:"value"`
+}
+
+// Aggregate returns points computed by fn over time ordered buckets.
+// Empty buckets are omitted.
+//
+// Rate is computed between the last values of adjacent buckets,
+// so the first bucket gives no point. Decreasing counter is treated as reset.
+func Aggregate(buckets []RangeBucket, fn string) []Point {
+	points := make([]Point, 0, len(buckets))
+	for idx, b := range buckets {
+		if b.Count == 0 {
+			continue
+		}
+
+		var v float64
+		switch fn {
+		case AggAvg:
+			v = b.Sum / float64(b.Count)
+		case AggMin:
+			v = b.Min
+		case AggMax:
+			v = b.Max
+		case AggLast:
+			v = b.Last
+		case AggSum:
+			v = b.Sum
+		case AggRate:
+			if idx == 0 {
+				continue
+			}
+			prev := buckets[idx-1]
+			increase := b.Last - prev.Last
+			if increase < 0 {
+				increase = b.Last
+			}
+			v = increase / b.Time.Sub(prev.Time).Seconds()
+		default:
+			continue
+		}
+		points = append(points, Point{Time: b.Time, Value: v})
+	}
+	return points
+}
@@ -0,0 +1,120 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeQueryVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := func() metrics.RangeQuery {
+		q := metrics.RangeQuery{ID: "PollCount", MType: metrics.MTypeCounter}
+		q.SetDefaults(now)
+		return q
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		q := valid()
+		require.NoError(t, q.Verify())
+		assert.Equal(t, now, q.To)
+		assert.Equal(t, now.Add(-metrics.DefaultRangeWindow), q.From)
+		assert.Equal(t, metrics.DefaultRangeStep, q.Step)
+		assert.Equal(t, metrics.AggAvg, q.Func)
+	})
+
+	tests := []struct {
+		name     string
+		modify   func(q *metrics.RangeQuery)
+		expected error
+	}{
+		{
+			name:     "Missing id",
+			modify:   func(q *metrics.RangeQuery) { q.ID = "" },
+			expected: metrics.ErrIDRequired,
+		},
+		{
+			name:     "Histogram type",
+			modify:   func(q *metrics.RangeQuery) { q.MType = metrics.MTypeHistogram },
+			expected: metrics.ErrInvalidRangeType,
+		},
+		{
+			name:     "Unknown func",
+			modify:   func(q *metrics.RangeQuery) { q.Func = "median" },
+			expected: metrics.ErrInvalidAgg,
+		},
+		{
+			name: "Rate for gauge",
+			modify: func(q *metrics.RangeQuery) {
+				q.MType = metrics.MTypeGauge
+				q.Func = metrics.AggRate
+			},
+			expected: metrics.ErrRateType,
+		},
+		{
+			name:     "From after to",
+			modify:   func(q *metrics.RangeQuery) { q.From = q.To.Add(time.Second) },
+			expected: metrics.ErrInvalidRange,
+		},
+		{
+			name:     "Fractional step",
+			modify:   func(q *metrics.RangeQuery) { q.Step = 1500 * time.Millisecond },
+			expected: metrics.ErrInvalidStep,
+		},
+		{
+			name: "Too many points",
+			modify: func(q *metrics.RangeQuery) {
+				q.From = q.To.Add(-24 * time.Hour)
+				q.Step = time.Second
+			},
+			expected: metrics.ErrTooManyPoints,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := valid()
+			test.modify(&q)
+			assert.ErrorIs(t, q.Verify(), test.expected)
+		})
+	}
+}
+
+func TestAlignTime(t *testing.T) {
+	ts := time.Unix(1_700_000_125, 0)
+	assert.Equal(t, time.Unix(1_700_000_100, 0).UTC(), metrics.AlignTime(ts, time.Minute))
+	assert.Equal(t, time.Unix(1_699_999_200, 0).UTC(), metrics.AlignTime(ts, time.Hour))
+}
+
+func TestAggregate(t *testing.T) {
+	base := time.Unix(1_700_000_040, 0)
+	buckets := []metrics.RangeBucket{
+		{Time: base, Min: 1, Max: 5, Sum: 9, Count: 3, Last: 5},
+		{Time: base.Add(time.Minute), Min: 6, Max: 8, Sum: 14, Count: 2, Last: 8},
+		{Time: base.Add(3 * time.Minute), Min: 2, Max: 2, Sum: 2, Count: 1, Last: 2},
+	}
+
+	values := func(points []metrics.Point) []float64 {
+		v := make([]float64, 0, len(points))
+		for _, p := range points {
+			v = append(v, p.Value)
+		}
+		return v
+	}
+
+	assert.Equal(t, []float64{3, 7, 2}, values(metrics.Aggregate(buckets, metrics.AggAvg)))
+	assert.Equal(t, []float64{1, 6, 2}, values(metrics.Aggregate(buckets, metrics.AggMin)))
+	assert.Equal(t, []float64{5, 8, 2}, values(metrics.Aggregate(buckets, metrics.AggMax)))
+	assert.Equal(t, []float64{5, 8, 2}, values(metrics.Aggregate(buckets, metrics.AggLast)))
+	assert.Equal(t, []float64{9, 14, 2}, values(metrics.Aggregate(buckets, metrics.AggSum)))
+
+	rate := metrics.Aggregate(buckets, metrics.AggRate)
+	require.Len(t, rate, 2)
+	assert.Equal(t, base.Add(time.Minute), rate[0].Time)
+	assert.InDelta(t, 3.0/60, rate[0].Value, 1e-9)
+	// counter reset: decreased value is treated as increase from zero
+	assert.InDelta(t, 2.0/120, rate[1].Value, 1e-9)
+}
@@ -7,15 +7,16 @@ import (
 
 var (
 	allowedTypes = map[string]struct{}{
-		MTypeCounter: {},
-		MTypeGauge:   {},
+		MTypeCounter:   {},
+		MTypeGauge:     {},
+		MTypeHistogram: {},
 	}
 )
 
 // Metrics field validation errors.
 var (
 	ErrIDRequired  = errors.New("'id': required")
-	ErrInvalidType = errors.New("'type': ['gauge'|'counter']")
+	ErrInvalidType = errors.New("'type': ['gauge'|'counter'|'histogram']")
 )
 
 // VerifyErrors implements error interface, used in Metrics.Verify method.
@@ -50,7 +51,7 @@ func VerifyID(m Metrics) error {
 }
 
 // VerifyType performs validation under Metrics.MType field:
-//   - If type is not counter or gauge, [ErrInvalidType] is occur.
+//   - If type is not counter, gauge or histogram, [ErrInvalidType] is occur.
 func VerifyType(m Metrics) error {
 	if _, ok := allowedTypes[m.MType]; !ok {
 		return ErrInvalidType
@@ -0,0 +1,201 @@
+// Package netacl provides network access lists of client addresses.
+//
+// Client address is the TCP peer address. Address from X-Real-IP or
+// X-Forwarded-For is used only if the peer is a trusted proxy, see
+// [ClientAddr].
+package netacl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// Route classes of [Policy], read, write and admin match token scopes.
+const (
+	RoutePing  = "ping"  // health checks
+	RouteRead  = "read"  // metrics read
+	RouteWrite = "write" // metrics update
+	RouteAdmin = "admin" // metrics delete
+)
+
+// Routes are known route classes.
+var Routes = []string{RoutePing, RouteRead, RouteWrite, RouteAdmin}
+
+// Any is route policy value allowing any address.
+const Any = "any"
+
+var (
+	ErrInvalidPrefix = errors.New("invalid network prefix")
+	ErrInvalidRoute  = errors.New("invalid route policy")
+)
+
+// ACL is list of IPv4 and IPv6 network prefixes. Nil ACL allows any
+// address, empty ACL allows none.
+type ACL struct {
+	prefixes []netip.Prefix
+}
+
+// ParseACL parses comma separated CIDRs or addresses,
+// e.g. '10.0.0.0/8,fd00::/8,192.168.1.5'.
+func ParseACL(s string) (*ACL, error) {
+	const op = "netacl.ParseACL"
+	acl := &ACL{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := parsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidPrefix, item)
+		}
+		acl.prefixes = append(acl.prefixes, prefix)
+	}
+	if len(acl.prefixes) == 0 {
+		return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidPrefix, s)
+	}
+	return acl, nil
+}
+
+// Contains reports whether addr is allowed.
+func (acl *ACL) Contains(addr netip.Addr) bool {
+	if acl == nil {
+		return true
+	}
+	addr = addr.Unmap()
+	return slices.ContainsFunc(acl.prefixes, func(p netip.Prefix) bool {
+		return p.Contains(addr)
+	})
+}
+
+// Len returns number of prefixes, nil and empty ACL have none.
+func (acl *ACL) Len() int {
+	if acl == nil {
+		return 0
+	}
+	return len(acl.prefixes)
+}
+
+func (acl *ACL) String() string {
+	if acl == nil {
+		return Any
+	}
+	items := make([]string, len(acl.prefixes))
+	for idx, p := range acl.prefixes {
+		items[idx] = p.String()
+	}
+	return strings.Join(items, ",")
+}
+
+// Policy is ACL of route classes.
+type Policy struct {
+	Default *ACL            // ACL of routes without own ACL
+	Routes  map[string]*ACL // route class ACL, nil ACL allows any address
+}
+
+// ACL returns ACL of route class.
+func (p Policy) ACL(route string) *ACL {
+	if acl, ok := p.Routes[route]; ok {
+		return acl
+	}
+	return p.Default
+}
+
+// ParseRoutes parses semicolon separated route policies 'route=ACL',
+// where ACL is [Any] or CIDRs of [ParseACL],
+// e.g. 'read=any;write=10.0.0.0/8,fd00::/8'.
+func ParseRoutes(s string) (map[string]*ACL, error) {
+	const op = "netacl.ParseRoutes"
+	routes := make(map[string]*ACL)
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(item, "=")
+		route, value = strings.TrimSpace(route), strings.TrimSpace(value)
+		if !ok || !slices.Contains(Routes, route) {
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidRoute, item)
+		}
+		if _, ok := routes[route]; ok {
+			return nil, fmt.Errorf("%s: %w: duplicate '%s'", op, ErrInvalidRoute, route)
+		}
+		if value == Any {
+			routes[route] = nil
+			continue
+		}
+		acl, err := ParseACL(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w '%s': %w", op, ErrInvalidRoute, route, err)
+		}
+		routes[route] = acl
+	}
+	return routes, nil
+}
+
+// ClientAddr returns client address of request from peer. If peer is
+// in proxies, the address is taken from realIP, or else from the last
+// forwardedFor address not in proxies. Nil proxies trust no peer.
+func ClientAddr(peer netip.Addr, proxies *ACL, realIP, forwardedFor string) netip.Addr {
+	peer = peer.Unmap()
+	if proxies == nil || !peer.IsValid() || !proxies.Contains(peer) {
+		return peer
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(realIP)); err == nil {
+		return addr.Unmap()
+	}
+
+	client := peer
+	hops := strings.Split(forwardedFor, ",")
+	for _, hop := range slices.Backward(hops) {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hop))
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !proxies.Contains(client) {
+			break
+		}
+	}
+	return client
+}
+
+// ParseAddr returns address of 'host:port' or 'host', or invalid
+// address.
+func ParseAddr(s string) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap()
+	}
+	addr, _ := netip.ParseAddr(s)
+	return addr.Unmap()
+}
+
+type clientAddrKey struct{}
+
+// NewContext returns context with client address.
+func NewContext(ctx context.Context, addr netip.Addr) context.Context {
+	return context.WithValue(ctx, clientAddrKey{}, addr)
+}
+
+// FromContext returns client address of context.
+func FromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientAddrKey{}).(netip.Addr)
+	return addr, ok
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,94 @@
+package netacl
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL(t *testing.T) {
+	acl, err := ParseACL("10.0.0.0/8, fd00::/8,192.168.1.5")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8,fd00::/8,192.168.1.5/32", acl.String())
+
+	for addr, want := range map[string]bool{
+		"10.1.2.3":        true,
+		"::ffff:10.1.2.3": true,
+		"fd00::1":         true,
+		"192.168.1.5":     true,
+		"192.168.1.6":     false,
+		"fe80::1":         false,
+	} {
+		assert.Equal(t, want, acl.Contains(netip.MustParseAddr(addr)), addr)
+	}
+
+	var open *ACL
+	assert.True(t, open.Contains(netip.MustParseAddr("1.1.1.1")))
+	assert.False(t, new(ACL).Contains(netip.MustParseAddr("1.1.1.1")))
+
+	for _, s := range []string{"", "10.0.0.0/33", "host"} {
+		_, err := ParseACL(s)
+		assert.ErrorIs(t, err, ErrInvalidPrefix, s)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	routes, err := ParseRoutes("read=any; write=10.0.0.0/8")
+	require.NoError(t, err)
+	def, err := ParseACL("192.168.1.0/24")
+	require.NoError(t, err)
+	policy := Policy{Default: def, Routes: routes}
+
+	assert.Nil(t, policy.ACL(RouteRead))
+	assert.Equal(t, "10.0.0.0/8", policy.ACL(RouteWrite).String())
+	assert.Equal(t, def, policy.ACL(RouteAdmin))
+
+	for _, s := range []string{"read", "delete=any", "read=any;read=any", "write=host"} {
+		_, err := ParseRoutes(s)
+		assert.ErrorIs(t, err, ErrInvalidRoute, s)
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	proxies, err := ParseACL("10.0.0.1,10.0.0.2")
+	require.NoError(t, err)
+	peer := netip.MustParseAddr("10.0.0.1")
+	client := netip.MustParseAddr("203.0.113.7")
+
+	tests := []struct {
+		name         string
+		peer         netip.Addr
+		proxies      *ACL
+		realIP       string
+		forwardedFor string
+		want         netip.Addr
+	}{
+		{"Should ignore headers without proxies", peer, nil, "203.0.113.7", "", peer},
+		{"Should ignore headers of not trusted peer", client, proxies, "10.0.0.9", "10.0.0.9", client},
+		{"Should use real ip of proxy", peer, proxies, "203.0.113.7", "", client},
+		{"Should skip trusted proxies", peer, proxies, "", "198.51.100.1, 203.0.113.7, 10.0.0.2", client},
+		{"Should stop on invalid hop", peer, proxies, "", "203.0.113.7, bad", peer},
+		{"Should use peer without headers", peer, proxies, "", "", peer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ClientAddr(test.peer, test.proxies, test.realIP, test.forwardedFor)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestParseAddr(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddr("127.0.0.1"), ParseAddr("127.0.0.1:8080"))
+	assert.Equal(t, netip.MustParseAddr("::1"), ParseAddr("[::1]:8080"))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.1"), ParseAddr("[::ffff:10.0.0.1]:80"))
+	assert.False(t, ParseAddr("host:80").IsValid())
+
+	ctx := NewContext(context.Background(), netip.MustParseAddr("10.0.0.1"))
+	addr, ok := FromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.1", addr.String())
+}
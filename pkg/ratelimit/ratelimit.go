@@ -0,0 +1,222 @@
+// Package ratelimit limits requests of clients by token buckets
+// and number of requests in flight.
+package ratelimit
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepInterval is minimum interval of idle clients removal.
+const sweepInterval = time.Minute
+
+// maxClients is number of tracked clients, the least recently seen
+// client is forgotten on overflow.
+const maxClients = 100_000
+
+var (
+	ErrLimited      = errors.New("rate limit exceeded")
+	ErrInvalidLimit = errors.New("invalid rate limit")
+)
+
+// LimitedError is [ErrLimited] with time after which the client
+// may retry.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLimited, e.RetryAfter)
+}
+
+func (e *LimitedError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Limit is token bucket of client, zero Rate is not limited.
+type Limit struct {
+	Rate  float64 // requests per second
+	Burst int     // bucket size
+}
+
+// ParseLimit parses 'rate' or 'rate:burst', e.g. '0.2:3' is one request
+// per 5 seconds with up to 3 requests at once. Burst is rate rounded up,
+// at least 1, if not set. Empty string and '0' are not limited.
+func ParseLimit(s string) (Limit, error) {
+	const op = "ratelimit.ParseLimit"
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+
+	rateValue, burstValue, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(rateValue, 64)
+	if err != nil || rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+		return Limit{}, fmt.Errorf("%s: %w '%s'", op, ErrInvalidLimit, s)
+	}
+	burst := max(1, int(math.Ceil(rate)))
+	if hasBurst {
+		burst, err = strconv.Atoi(burstValue)
+		if err != nil || burst < 1 {
+			return Limit{}, fmt.Errorf("%s: %w '%s'", op, ErrInvalidLimit, s)
+		}
+	}
+	if rate == 0 {
+		return Limit{}, nil
+	}
+	return Limit{Rate: rate, Burst: burst}, nil
+}
+
+func (l Limit) IsSet() bool {
+	return l.Rate > 0
+}
+
+func (l Limit) String() string {
+	if !l.IsSet() {
+		return "0"
+	}
+	return strconv.FormatFloat(l.Rate, 'f', -1, 64) + ":" + strconv.Itoa(l.Burst)
+}
+
+// Limiter limits requests of every client key by token bucket of limit
+// and by concurrency requests in flight. It is safe for concurrent use.
+//
+// The number of tracked clients is bounded, the least recently seen
+// client is forgotten on overflow and starts with full bucket.
+//
+// Nil Limiter allows any request.
+type Limiter struct {
+	limit       Limit
+	concurrency int
+	now         func() time.Time
+
+	mu        sync.Mutex
+	size      int
+	clients   map[string]*list.Element // of *client
+	recent    *list.List               // the most recently seen first
+	lastSweep time.Time
+}
+
+type client struct {
+	key      string
+	tokens   float64
+	last     time.Time
+	inFlight int
+}
+
+// New returns Limiter pointer, or nil if neither limit nor concurrency
+// is set. Zero concurrency is not limited.
+func New(limit Limit, concurrency int) *Limiter {
+	if !limit.IsSet() && concurrency <= 0 {
+		return nil
+	}
+	return &Limiter{
+		limit:       limit,
+		concurrency: max(0, concurrency),
+		now:         time.Now,
+		size:        maxClients,
+		clients:     make(map[string]*list.Element),
+		recent:      list.New(),
+	}
+}
+
+// Acquire takes request of client key and returns release func, which
+// should be called when the request is done. If the request is over
+// the limit, error is [*LimitedError].
+func (l *Limiter) Acquire(key string) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	c := l.client(key, now)
+
+	if l.concurrency != 0 && c.inFlight >= l.concurrency {
+		return nil, &LimitedError{RetryAfter: time.Second}
+	}
+
+	if l.limit.IsSet() {
+		c.refill(now, l.limit)
+		if c.tokens < 1 {
+			wait := (1 - c.tokens) / l.limit.Rate * float64(time.Second)
+			return nil, &LimitedError{RetryAfter: time.Duration(math.Ceil(wait))}
+		}
+		c.tokens--
+	}
+
+	c.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			c.inFlight--
+			l.mu.Unlock()
+		})
+	}, nil
+}
+
+// client returns client of key, new client gets full bucket.
+func (l *Limiter) client(key string, now time.Time) *client {
+	if e, ok := l.clients[key]; ok {
+		l.recent.MoveToFront(e)
+		return e.Value.(*client)
+	}
+	if l.recent.Len() >= l.size {
+		l.remove(l.recent.Back())
+	}
+	c := &client{key: key, tokens: float64(l.limit.Burst), last: now}
+	l.clients[key] = l.recent.PushFront(c)
+	return c
+}
+
+func (l *Limiter) remove(e *list.Element) {
+	delete(l.clients, e.Value.(*client).key)
+	l.recent.Remove(e)
+}
+
+// refill adds tokens of time passed since the last refill.
+func (c *client) refill(now time.Time, limit Limit) {
+	elapsed := now.Sub(c.last).Seconds()
+	if elapsed > 0 {
+		c.tokens = min(float64(limit.Burst), c.tokens+elapsed*limit.Rate)
+		c.last = now
+	}
+}
+
+// sweep removes clients without requests in flight and with full bucket,
+// such clients are equal to new ones.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for _, e := range l.clients {
+		c := e.Value.(*client)
+		if c.inFlight != 0 {
+			continue
+		}
+		if l.limit.IsSet() {
+			c.refill(now, l.limit)
+			if c.tokens < float64(l.limit.Burst) {
+				continue
+			}
+		}
+		l.remove(e)
+	}
+}
+
+// RetryAfterSeconds returns retry after in whole seconds rounded up,
+// at least 1, as Retry-After HTTP header requires.
+func RetryAfterSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
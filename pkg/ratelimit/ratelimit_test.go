@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLimiter returns limiter with clock moved by the returned func.
+func newTestLimiter(limit Limit, concurrency int) (*Limiter, func(time.Duration)) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	l := New(limit, concurrency)
+	l.now = func() time.Time { return now }
+	return l, func(d time.Duration) { now = now.Add(d) }
+}
+
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var limited *LimitedError
+	require.ErrorAs(t, err, &limited)
+	assert.ErrorIs(t, err, ErrLimited)
+	return limited.RetryAfter
+}
+
+func TestLimiterRate(t *testing.T) {
+	l, advance := newTestLimiter(Limit{Rate: 0.5, Burst: 2}, 0)
+
+	for range 2 {
+		release, err := l.Acquire("host-a")
+		require.NoError(t, err)
+		release()
+	}
+	_, err := l.Acquire("host-a")
+	assert.Equal(t, 2*time.Second, retryAfter(t, err))
+
+	_, err = l.Acquire("host-b")
+	assert.NoError(t, err, "other client has own bucket")
+
+	advance(time.Second)
+	_, err = l.Acquire("host-a")
+	assert.Equal(t, time.Second, retryAfter(t, err))
+
+	advance(time.Second)
+	_, err = l.Acquire("host-a")
+	assert.NoError(t, err)
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	l, _ := newTestLimiter(Limit{}, 1)
+
+	release, err := l.Acquire("host-a")
+	require.NoError(t, err)
+	_, err = l.Acquire("host-a")
+	assert.Equal(t, time.Second, retryAfter(t, err))
+
+	release()
+	release()
+	release, err = l.Acquire("host-a")
+	require.NoError(t, err)
+	_, err = l.Acquire("host-a")
+	assert.True(t, errors.Is(err, ErrLimited), "repeated release frees once")
+	release()
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l, advance := newTestLimiter(Limit{Rate: 1, Burst: 1}, 0)
+
+	release, err := l.Acquire("host-a")
+	require.NoError(t, err)
+	release()
+	advance(sweepInterval)
+	_, err = l.Acquire("host-b")
+	require.NoError(t, err)
+	assert.Len(t, l.clients, 1, "idle client with full bucket is removed")
+	assert.Contains(t, l.clients, "host-b")
+}
+
+func TestLimiterMaxClients(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Rate: 1, Burst: 1}, 0)
+	l.size = 2
+
+	for _, key := range []string{"host-a", "host-b"} {
+		_, err := l.Acquire(key)
+		require.NoError(t, err)
+	}
+	_, err := l.Acquire("host-a")
+	require.ErrorIs(t, err, ErrLimited, "host-a is the most recently seen")
+
+	_, err = l.Acquire("host-c")
+	require.NoError(t, err)
+	assert.Len(t, l.clients, 2, "clients are bounded")
+	assert.NotContains(t, l.clients, "host-b", "least recently seen is forgotten")
+	_, err = l.Acquire("host-a")
+	assert.ErrorIs(t, err, ErrLimited)
+}
+
+func TestNilLimiter(t *testing.T) {
+	l := New(Limit{}, 0)
+	require.Nil(t, l)
+	release, err := l.Acquire("host-a")
+	require.NoError(t, err)
+	release()
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Limit
+	}{
+		{"", Limit{}},
+		{"0", Limit{}},
+		{"5", Limit{Rate: 5, Burst: 5}},
+		{"0.2", Limit{Rate: 0.2, Burst: 1}},
+		{"0.2:3", Limit{Rate: 0.2, Burst: 3}},
+	}
+	for _, test := range tests {
+		got, err := ParseLimit(test.value)
+		require.NoError(t, err, test.value)
+		assert.Equal(t, test.want, got, test.value)
+	}
+
+	for _, value := range []string{"-1", "fast", "1:0", "1:x", "NaN"} {
+		_, err := ParseLimit(value)
+		assert.ErrorIs(t, err, ErrInvalidLimit, value)
+	}
+	assert.Equal(t, "0.2:3", Limit{Rate: 0.2, Burst: 3}.String())
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, 1, RetryAfterSeconds(0))
+	assert.Equal(t, 1, RetryAfterSeconds(time.Second))
+	assert.Equal(t, 3, RetryAfterSeconds(2100*time.Millisecond))
+}
@@ -0,0 +1,190 @@
+// Package replay provides replay protection of hashed and signed
+// agent requests.
+//
+// Agent stamps every request with the current time and a random nonce,
+// the stamp is a part of hashed and signed material, see [Stamp.Material].
+// Server accepts stamp within clock skew window once, see [Guard].
+package replay
+
+import (
+	"container/heap"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stamp headers and metadata.
+const (
+	XTimestamp = "X-Timestamp" // unix time in milliseconds
+	XNonce     = "X-Nonce"     // random request id
+)
+
+const (
+	nonceSize   = 16
+	minNonceLen = 16
+	maxNonceLen = 64
+)
+
+var (
+	ErrInvalidStamp = errors.New("missing or invalid request timestamp and nonce")
+	ErrStaleStamp   = errors.New("request timestamp is outside of allowed clock skew")
+	ErrReplayed     = errors.New("request nonce is already used")
+)
+
+// Stamp is request time and nonce. Zero Stamp is not stamped request.
+type Stamp struct {
+	Time  time.Time
+	Nonce string
+}
+
+// NewStamp returns Stamp of the current time with random nonce.
+func NewStamp() Stamp {
+	b := make([]byte, nonceSize)
+	rand.Read(b)
+	return Stamp{Time: time.Now(), Nonce: hex.EncodeToString(b)}
+}
+
+// ParseStamp returns Stamp of timestamp and nonce values. Empty values
+// are zero Stamp, otherwise error wraps [ErrInvalidStamp].
+func ParseStamp(timestamp, nonce string) (Stamp, error) {
+	const op = "replay.ParseStamp"
+	if timestamp == "" && nonce == "" {
+		return Stamp{}, nil
+	}
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || ms <= 0 || !validNonce(nonce) {
+		return Stamp{}, fmt.Errorf("%s: %w", op, ErrInvalidStamp)
+	}
+	return Stamp{Time: time.UnixMilli(ms), Nonce: nonce}, nil
+}
+
+// IsZero reports whether request is not stamped.
+func (s Stamp) IsZero() bool {
+	return s.Nonce == ""
+}
+
+// Timestamp returns [XTimestamp] value.
+func (s Stamp) Timestamp() string {
+	return strconv.FormatInt(s.Time.UnixMilli(), 10)
+}
+
+// Material returns payload prefixed with timestamp and nonce,
+// or payload as is for zero Stamp.
+func (s Stamp) Material(payload []byte) []byte {
+	if s.IsZero() {
+		return payload
+	}
+	ts := s.Timestamp()
+	msg := make([]byte, 0, len(ts)+len(s.Nonce)+len(payload)+2)
+	msg = append(msg, ts...)
+	msg = append(msg, '\n')
+	msg = append(msg, s.Nonce...)
+	msg = append(msg, '\n')
+	return append(msg, payload...)
+}
+
+func validNonce(nonce string) bool {
+	if len(nonce) < minNonceLen || len(nonce) > maxNonceLen {
+		return false
+	}
+	for _, c := range nonce {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c == '-' || c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Guard accepts every stamp once within clock skew window.
+//
+// Nonces are remembered until their timestamp leaves the window.
+// The number of nonces is bounded by cache size: if the cache is full,
+// the oldest nonce is evicted and stamps not newer than evicted one
+// are rejected, so replay is not possible after eviction.
+type Guard struct {
+	window time.Duration
+	size   int
+	mu     sync.Mutex
+	nonces map[string]struct{}
+	queue  stampQueue
+	floor  time.Time
+	now    func() time.Time
+}
+
+// NewGuard returns Guard pointer. Guard is safe for concurrent use.
+func NewGuard(window time.Duration, size int) *Guard {
+	return &Guard{
+		window: window,
+		size:   size,
+		nonces: make(map[string]struct{}, size),
+		now:    time.Now,
+	}
+}
+
+// Check accepts stamp and returns error wrapped [ErrInvalidStamp],
+// [ErrStaleStamp] or [ErrReplayed], if stamp is not accepted.
+func (g *Guard) Check(s Stamp) error {
+	const op = "replay.Guard.Check"
+	if s.IsZero() {
+		return fmt.Errorf("%s: %w", op, ErrInvalidStamp)
+	}
+
+	now := g.now()
+	if s.Time.Before(now.Add(-g.window)) || s.Time.After(now.Add(g.window)) {
+		return fmt.Errorf("%s: %w", op, ErrStaleStamp)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for len(g.queue) != 0 && g.queue[0].Time.Before(now.Add(-g.window)) {
+		delete(g.nonces, heap.Pop(&g.queue).(Stamp).Nonce)
+	}
+
+	if _, ok := g.nonces[s.Nonce]; ok {
+		return fmt.Errorf("%s: %w", op, ErrReplayed)
+	}
+
+	if len(g.queue) >= g.size {
+		if !s.Time.After(g.queue[0].Time) {
+			return fmt.Errorf("%s: %w", op, ErrStaleStamp)
+		}
+		evicted := heap.Pop(&g.queue).(Stamp)
+		delete(g.nonces, evicted.Nonce)
+		if evicted.Time.After(g.floor) {
+			g.floor = evicted.Time
+		}
+	}
+	if !s.Time.After(g.floor) {
+		return fmt.Errorf("%s: %w", op, ErrStaleStamp)
+	}
+
+	g.nonces[s.Nonce] = struct{}{}
+	heap.Push(&g.queue, s)
+	return nil
+}
+
+// stampQueue is min-heap of stamps by time.
+type stampQueue []Stamp
+
+func (q stampQueue) Len() int           { return len(q) }
+func (q stampQueue) Less(i, j int) bool { return q[i].Time.Before(q[j].Time) }
+func (q stampQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *stampQueue) Push(x any) {
+	*q = append(*q, x.(Stamp))
+}
+
+func (q *stampQueue) Pop() any {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,98 @@
+package replay
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStamp(t *testing.T) {
+	t.Run("Should parse header values", func(t *testing.T) {
+		s := NewStamp()
+		parsed, err := ParseStamp(s.Timestamp(), s.Nonce)
+		require.NoError(t, err)
+		assert.Equal(t, s.Time.UnixMilli(), parsed.Time.UnixMilli())
+		assert.Equal(t, s.Nonce, parsed.Nonce)
+
+		zero, err := ParseStamp("", "")
+		require.NoError(t, err)
+		assert.True(t, zero.IsZero())
+	})
+
+	t.Run("Should fail on invalid values", func(t *testing.T) {
+		nonce := "0123456789abcdef"
+		for _, v := range [][2]string{
+			{"", nonce},
+			{"1767312000000", ""},
+			{"-1", nonce},
+			{"1767312000000", "short"},
+			{"1767312000000", "0123456789abcdef\n"},
+		} {
+			_, err := ParseStamp(v[0], v[1])
+			assert.ErrorIs(t, err, ErrInvalidStamp, v)
+		}
+	})
+
+	t.Run("Should prefix payload", func(t *testing.T) {
+		s := Stamp{Time: time.UnixMilli(1767312000000), Nonce: "n1"}
+		assert.Equal(t,
+			[]byte("1767312000000\nn1\nbatch"), s.Material([]byte("batch")),
+		)
+		assert.Equal(t, []byte("batch"), Stamp{}.Material([]byte("batch")))
+	})
+}
+
+func TestGuard(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	newGuard := func(size int) *Guard {
+		g := NewGuard(time.Minute, size)
+		g.now = func() time.Time { return now }
+		return g
+	}
+	stamp := func(offset time.Duration, nonce string) Stamp {
+		return Stamp{Time: now.Add(offset), Nonce: nonce}
+	}
+
+	t.Run("Should accept stamp once", func(t *testing.T) {
+		g := newGuard(10)
+		require.NoError(t, g.Check(stamp(0, "n1")))
+		require.NoError(t, g.Check(stamp(-time.Second, "n2")))
+		assert.ErrorIs(t, g.Check(stamp(0, "n1")), ErrReplayed)
+		assert.ErrorIs(t, g.Check(Stamp{}), ErrInvalidStamp)
+	})
+
+	t.Run("Should reject stamp outside window", func(t *testing.T) {
+		g := newGuard(10)
+		assert.ErrorIs(t, g.Check(stamp(-2*time.Minute, "n1")), ErrStaleStamp)
+		assert.ErrorIs(t, g.Check(stamp(2*time.Minute, "n2")), ErrStaleStamp)
+	})
+
+	t.Run("Should forget nonce outside window", func(t *testing.T) {
+		g := newGuard(10)
+		require.NoError(t, g.Check(stamp(0, "n1")))
+		now = now.Add(2 * time.Minute)
+		defer func() { now = now.Add(-2 * time.Minute) }()
+		require.NoError(t, g.Check(stamp(0, "n2")))
+		assert.Equal(t, 1, len(g.nonces))
+	})
+
+	t.Run("Should reject stamp not newer than evicted", func(t *testing.T) {
+		g := newGuard(3)
+		for i := range 3 {
+			require.NoError(t, g.Check(
+				stamp(time.Duration(i)*time.Second, fmt.Sprint("n", i)),
+			))
+		}
+		require.NoError(t, g.Check(stamp(10*time.Second, "n3")))
+		assert.Equal(t, 3, len(g.nonces))
+
+		// n0 is evicted, its replay is rejected by time
+		assert.ErrorIs(t, g.Check(stamp(0, "n0")), ErrStaleStamp)
+		assert.ErrorIs(t, g.Check(stamp(-time.Second, "n4")), ErrStaleStamp)
+		assert.ErrorIs(t, g.Check(stamp(10*time.Second, "n3")), ErrReplayed)
+		require.NoError(t, g.Check(stamp(20*time.Second, "n5")))
+	})
+}
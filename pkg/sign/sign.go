@@ -0,0 +1,106 @@
+// Package sign provides per-agent Ed25519 batch signatures.
+//
+// Signed message binds payload to agent id and batch sequence,
+// so signature of one agent is not valid for another agent or batch.
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// version prefixes signed message.
+const version = "runlytics-sign-v1"
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrParseKey         = errors.New("failed to parse Ed25519 key")
+	ErrUnknownAgent     = errors.New("unknown agent")
+	ErrRevokedAgent     = errors.New("revoked agent key")
+)
+
+// Message returns signed message of agent batch payload.
+func Message(agentID string, seq uint64, payload []byte) []byte {
+	msg := make([]byte, 0, len(version)+len(agentID)+len(payload)+24)
+	msg = append(msg, version...)
+	msg = append(msg, '\n')
+	msg = append(msg, agentID...)
+	msg = append(msg, '\n')
+	msg = strconv.AppendUint(msg, seq, 10)
+	msg = append(msg, '\n')
+	return append(msg, payload...)
+}
+
+// Sign returns signature of agent batch payload.
+func Sign(
+	key ed25519.PrivateKey, agentID string, seq uint64, payload []byte,
+) []byte {
+	return ed25519.Sign(key, Message(agentID, seq, payload))
+}
+
+// Verify returns [ErrInvalidSignature], if signature of agent batch payload
+// is not valid.
+func Verify(
+	key ed25519.PublicKey,
+	agentID string,
+	seq uint64,
+	payload, signature []byte,
+) error {
+	if !ed25519.Verify(key, Message(agentID, seq, payload), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParsePrivateKey returns private key of PKCS #8 PEM data,
+// e.g. generated by 'openssl genpkey -algorithm ed25519'.
+func ParsePrivateKey(PEMData []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(PEMData)
+	if block == nil {
+		return nil, ErrParseKey
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Join(ErrParseKey, err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrParseKey
+	}
+	return edKey, nil
+}
+
+// ParsePublicKey returns public key of base64 encoded PKIX data,
+// the body of 'openssl pkey -pubout' PEM, or of raw 32 bytes key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Join(ErrParseKey, err)
+	}
+	if len(data) == ed25519.PublicKeySize {
+		return ed25519.PublicKey(data), nil
+	}
+	key, err := x509.ParsePKIXPublicKey(data)
+	if err != nil {
+		return nil, errors.Join(ErrParseKey, err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: not Ed25519 key", ErrParseKey)
+	}
+	return edKey, nil
+}
+
+// EncodePublicKey returns base64 encoded PKIX data of key.
+func EncodePublicKey(key ed25519.PublicKey) (string, error) {
+	data, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
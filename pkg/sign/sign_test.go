@@ -0,0 +1,84 @@
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	payload := []byte("batch")
+	signature := Sign(private, "host-a", 7, payload)
+
+	require.NoError(t, Verify(public, "host-a", 7, payload, signature))
+
+	for name, verify := range map[string]func() error{
+		"other agent": func() error {
+			return Verify(public, "host-b", 7, payload, signature)
+		},
+		"other seq": func() error {
+			return Verify(public, "host-a", 8, payload, signature)
+		},
+		"other payload": func() error {
+			return Verify(public, "host-a", 7, []byte("batcH"), signature)
+		},
+		"empty signature": func() error {
+			return Verify(public, "host-a", 7, payload, nil)
+		},
+	} {
+		assert.ErrorIs(t, verify(), ErrInvalidSignature, name)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert.NotEqual(t,
+		Message("host-a", 12, []byte("3")),
+		Message("host-a", 1, []byte("23")),
+	)
+	assert.Equal(t,
+		[]byte("runlytics-sign-v1\nhost-a\n12\nbatch"),
+		Message("host-a", 12, []byte("batch")),
+	)
+}
+
+func TestParseKeys(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	t.Run("Should parse PKCS #8 private key", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		require.NoError(t, err)
+		data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+		key, err := ParsePrivateKey(data)
+		require.NoError(t, err)
+		assert.Equal(t, private, key)
+
+		_, err = ParsePrivateKey([]byte("not pem"))
+		assert.ErrorIs(t, err, ErrParseKey)
+	})
+
+	t.Run("Should parse PKIX and raw public key", func(t *testing.T) {
+		encoded, err := EncodePublicKey(public)
+		require.NoError(t, err)
+		key, err := ParsePublicKey(encoded)
+		require.NoError(t, err)
+		assert.Equal(t, public, key)
+
+		key, err = ParsePublicKey(base64.StdEncoding.EncodeToString(public))
+		require.NoError(t, err)
+		assert.Equal(t, public, key)
+
+		for _, s := range []string{"not base64!", "c2hvcnQ="} {
+			_, err = ParsePublicKey(s)
+			assert.ErrorIs(t, err, ErrParseKey, s)
+		}
+	})
+}
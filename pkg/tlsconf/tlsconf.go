@@ -0,0 +1,120 @@
+// Package tlsconf builds TLS configurations of server and agent
+// from PEM files and resolves agent identity of client certificate.
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	ErrLoadKeyPair = errors.New("failed to load certificate key pair")
+	ErrLoadCA      = errors.New("failed to load CA certificates")
+	ErrRequireCA   = errors.New("client certificates required without client CA")
+)
+
+// ServerOpts describes server TLS files.
+type ServerOpts struct {
+	CertFile, KeyFile string
+	ClientCAFile      string // verifies client certificates, optional
+	RequireClientCert bool   // rejects connection without client certificate
+}
+
+// NewServer returns server TLS configuration.
+//
+// With client CA client certificate is verified if given, or required
+// with RequireClientCert.
+func NewServer(opts ServerOpts) (*tls.Config, error) {
+	const op = "tlsconf.NewServer"
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %w", op, ErrLoadKeyPair, err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if opts.ClientCAFile == "" {
+		if opts.RequireClientCert {
+			return nil, fmt.Errorf("%s: %w", op, ErrRequireCA)
+		}
+		return cfg, nil
+	}
+
+	cfg.ClientCAs, err = loadCertPool(opts.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	if opts.RequireClientCert {
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// ClientOpts describes agent TLS files.
+type ClientOpts struct {
+	CAFile            string // verifies server certificate, system roots if empty
+	CertFile, KeyFile string // client certificate, optional
+}
+
+// NewClient returns agent TLS configuration.
+func NewClient(opts ClientOpts) (*tls.Config, error) {
+	const op = "tlsconf.NewClient"
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %w", op, ErrLoadKeyPair, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Identity returns subject common name of client certificate of cfg,
+// or empty string if cfg has no client certificate.
+func Identity(cfg *tls.Config) string {
+	if cfg == nil || len(cfg.Certificates) == 0 {
+		return ""
+	}
+	leaf := cfg.Certificates[0].Leaf
+	if leaf == nil {
+		return ""
+	}
+	return leaf.Subject.CommonName
+}
+
+// PeerIdentity returns subject common name of verified peer certificate,
+// or empty string if peer certificate is not given or not verified.
+func PeerIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLoadCA, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: no certificates in '%s'", ErrLoadCA, path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,203 @@
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPKI struct {
+	dir    string
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+	serial int64
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	p := &testPKI{dir: t.TempDir()}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "runlytics CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	p.ca, err = x509.ParseCertificate(der)
+	require.NoError(t, err)
+	p.caKey, p.serial = key, 1
+	p.write(t, "ca.pem", "CERTIFICATE", der)
+	return p
+}
+
+// issue writes certificate and key signed by CA, returns their paths.
+func (p *testPKI) issue(
+	t *testing.T, name string, usage x509.ExtKeyUsage,
+) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p.serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(p.serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, p.ca, &key.PublicKey, p.caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return p.write(t, name+".pem", "CERTIFICATE", der),
+		p.write(t, name+"-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func (p *testPKI) write(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(p.dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// handshake returns peer identity seen by server and client handshake error.
+func handshake(t *testing.T, server, client *tls.Config) (string, error) {
+	t.Helper()
+	lis, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	require.NoError(t, err)
+	defer lis.Close()
+
+	identity := make(chan string, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			identity <- ""
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			identity <- ""
+			return
+		}
+		state := tlsConn.ConnectionState()
+		identity <- PeerIdentity(&state)
+		io.WriteString(conn, "ok")
+	}()
+
+	conn, err := tls.Dial("tcp", lis.Addr().String(), client)
+	if err == nil {
+		// TLS 1.3 client learns about rejected certificate on read
+		_, err = io.ReadAll(conn)
+		conn.Close()
+	}
+	return <-identity, err
+}
+
+func TestTLSConf(t *testing.T) {
+	pki := newTestPKI(t)
+	caFile := filepath.Join(pki.dir, "ca.pem")
+	serverCert, serverKey := pki.issue(t, "server", x509.ExtKeyUsageServerAuth)
+	agentCert, agentKey := pki.issue(t, "host-a", x509.ExtKeyUsageClientAuth)
+
+	t.Run("Should verify server and agent certificates", func(t *testing.T) {
+		server, err := NewServer(ServerOpts{
+			CertFile:          serverCert,
+			KeyFile:           serverKey,
+			ClientCAFile:      caFile,
+			RequireClientCert: true,
+		})
+		require.NoError(t, err)
+		client, err := NewClient(ClientOpts{
+			CAFile: caFile, CertFile: agentCert, KeyFile: agentKey,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "host-a", Identity(client))
+
+		identity, err := handshake(t, server, client)
+		require.NoError(t, err)
+		assert.Equal(t, "host-a", identity)
+	})
+
+	t.Run("Should reject agent without required certificate", func(t *testing.T) {
+		server, err := NewServer(ServerOpts{
+			CertFile:          serverCert,
+			KeyFile:           serverKey,
+			ClientCAFile:      caFile,
+			RequireClientCert: true,
+		})
+		require.NoError(t, err)
+		client, err := NewClient(ClientOpts{CAFile: caFile})
+		require.NoError(t, err)
+		assert.Empty(t, Identity(client))
+
+		_, err = handshake(t, server, client)
+		assert.Error(t, err)
+	})
+
+	t.Run("Should accept agent without optional certificate", func(t *testing.T) {
+		server, err := NewServer(ServerOpts{
+			CertFile: serverCert, KeyFile: serverKey, ClientCAFile: caFile,
+		})
+		require.NoError(t, err)
+		client, err := NewClient(ClientOpts{CAFile: caFile})
+		require.NoError(t, err)
+
+		identity, err := handshake(t, server, client)
+		require.NoError(t, err)
+		assert.Empty(t, identity)
+	})
+
+	t.Run("Should reject server of unknown CA", func(t *testing.T) {
+		server, err := NewServer(ServerOpts{
+			CertFile: serverCert, KeyFile: serverKey,
+		})
+		require.NoError(t, err)
+		other := newTestPKI(t)
+		client, err := NewClient(ClientOpts{
+			CAFile: filepath.Join(other.dir, "ca.pem"),
+		})
+		require.NoError(t, err)
+
+		_, err = handshake(t, server, client)
+		assert.Error(t, err)
+	})
+
+	t.Run("Should fail on invalid options", func(t *testing.T) {
+		_, err := NewServer(ServerOpts{
+			CertFile: serverCert, KeyFile: serverKey, RequireClientCert: true,
+		})
+		assert.ErrorIs(t, err, ErrRequireCA)
+
+		_, err = NewServer(ServerOpts{CertFile: serverCert, KeyFile: agentKey})
+		assert.ErrorIs(t, err, ErrLoadKeyPair)
+
+		_, err = NewClient(ClientOpts{CAFile: serverKey})
+		assert.ErrorIs(t, err, ErrLoadCA)
+
+		_, err = NewClient(ClientOpts{CertFile: agentCert})
+		assert.ErrorIs(t, err, ErrLoadKeyPair)
+	})
+}
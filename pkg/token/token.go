@@ -0,0 +1,241 @@
+// Package token provides scoped bearer tokens of API clients.
+//
+// Token is '<id>.<secret>', only SHA-256 hash of the token is stored,
+// so stored tokens are not usable by the store reader.
+package token
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scope grants access to API methods.
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"  // metrics read
+	ScopeWrite Scope = "write" // metrics update
+	ScopeAdmin Scope = "admin" // metrics delete, grants all scopes
+)
+
+const (
+	secretSize  = 32
+	idSize      = 8
+	maxIDLen    = 64
+	scopesSep   = "+"
+	idSecretSep = "."
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUnknownToken = errors.New("unknown token")
+	ErrRevokedToken = errors.New("revoked token")
+	ErrScope        = errors.New("insufficient token scope")
+	ErrInvalidScope = errors.New("invalid token scope")
+	ErrInvalidID    = errors.New("invalid token id")
+	ErrInvalidSpec  = errors.New("invalid token spec")
+	ErrDuplicateID  = errors.New("duplicate token id")
+)
+
+// Scopes is set of token scopes.
+type Scopes []Scope
+
+// ParseScopes parses scopes separated by '+', e.g. 'read+write'.
+func ParseScopes(s string) (Scopes, error) {
+	const op = "token.ParseScopes"
+	var scopes Scopes
+	for _, item := range strings.Split(s, scopesSep) {
+		scope := Scope(strings.TrimSpace(item))
+		switch scope {
+		case ScopeRead, ScopeWrite, ScopeAdmin:
+		default:
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidScope, item)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Allows reports whether scopes grant scope.
+func (s Scopes) Allows(scope Scope) bool {
+	return slices.Contains(s, scope) || slices.Contains(s, ScopeAdmin)
+}
+
+func (s Scopes) String() string {
+	items := make([]string, len(s))
+	for i, scope := range s {
+		items[i] = string(scope)
+	}
+	return strings.Join(items, scopesSep)
+}
+
+// Token is stored token.
+type Token struct {
+	ID        string
+	Hash      string // hex SHA-256 of token
+	Scopes    Scopes
+	CreatedAt time.Time
+	Revoked   bool
+}
+
+// New returns token with id and its stored Token.
+// Random id is generated, if id is empty.
+func New(id string, scopes Scopes) (string, Token, error) {
+	const op = "token.New"
+	if id == "" {
+		id = randomString(idSize, hex.EncodeToString)
+	}
+	if err := VerifyID(id); err != nil {
+		return "", Token{}, fmt.Errorf("%s: %w", op, err)
+	}
+	raw := id + idSecretSep + randomString(
+		secretSize, base64.RawURLEncoding.EncodeToString,
+	)
+	return raw, Token{
+		ID: id, Hash: Hash(raw), Scopes: scopes, CreatedAt: time.Now(),
+	}, nil
+}
+
+// Hash returns hex SHA-256 of token.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseID returns id of token, error wraps [ErrInvalidToken].
+func ParseID(raw string) (string, error) {
+	const op = "token.ParseID"
+	id, secret, ok := strings.Cut(raw, idSecretSep)
+	if !ok || secret == "" || VerifyID(id) != nil {
+		return "", fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	return id, nil
+}
+
+// VerifyID returns [ErrInvalidID], if id is empty, too long or contains
+// characters other than letters, digits, '-' and '_'.
+func VerifyID(id string) error {
+	if id == "" || len(id) > maxIDLen {
+		return ErrInvalidID
+	}
+	for _, c := range id {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c == '-' || c == '_':
+		default:
+			return ErrInvalidID
+		}
+	}
+	return nil
+}
+
+// Verify returns error wrapped [ErrInvalidToken], if raw is not
+// the token, [ErrRevokedToken] or [ErrScope], if the token does not
+// grant scope.
+func (t Token) Verify(raw string, scope Scope) error {
+	const op = "token.Token.Verify"
+	if subtle.ConstantTimeCompare([]byte(Hash(raw)), []byte(t.Hash)) != 1 {
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if t.Revoked {
+		return fmt.Errorf("%s: %w '%s'", op, ErrRevokedToken, t.ID)
+	}
+	if !t.Scopes.Allows(scope) {
+		return fmt.Errorf("%s: %w '%s' of '%s'", op, ErrScope, scope, t.ID)
+	}
+	return nil
+}
+
+// Spec returns token config spec 'id=hash:scopes', see [ParseSpecs].
+func (t Token) Spec() string {
+	return t.ID + "=" + t.Hash + ":" + t.Scopes.String()
+}
+
+// ParseSpecs parses comma separated tokens 'id=hash:scopes',
+// e.g. 'grafana=<hex sha256>:read,agent=<hex sha256>:read+write'.
+// Hash is case insensitive.
+func ParseSpecs(s string) ([]Token, error) {
+	const op = "token.ParseSpecs"
+	var tokens []Token
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		id, value, ok := strings.Cut(item, "=")
+		hash, scopesValue, ok2 := strings.Cut(value, ":")
+		hash = strings.ToLower(hash)
+		if !ok || !ok2 || VerifyID(id) != nil || !validHash(hash) {
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrInvalidSpec, id)
+		}
+		scopes, err := ParseScopes(scopesValue)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w '%s': %w", op, ErrInvalidSpec, id, err)
+		}
+		tokens = append(tokens, Token{ID: id, Hash: hash, Scopes: scopes})
+	}
+	return tokens, nil
+}
+
+// Registry is read-only tokens of config, it is safe for concurrent use.
+type Registry struct {
+	tokens map[string]Token
+}
+
+// NewRegistry returns Registry pointer of tokens.
+func NewRegistry(tokens []Token) (*Registry, error) {
+	const op = "token.NewRegistry"
+	r := &Registry{tokens: make(map[string]Token, len(tokens))}
+	for _, t := range tokens {
+		if _, ok := r.tokens[t.ID]; ok {
+			return nil, fmt.Errorf("%s: %w '%s'", op, ErrDuplicateID, t.ID)
+		}
+		r.tokens[t.ID] = t
+	}
+	return r, nil
+}
+
+// Token returns token with id, error wraps [ErrUnknownToken].
+func (r *Registry) Token(_ context.Context, id string) (Token, error) {
+	const op = "token.Registry.Token"
+	t, ok := r.tokens[id]
+	if !ok {
+		return Token{}, fmt.Errorf("%s: %w '%s'", op, ErrUnknownToken, id)
+	}
+	return t, nil
+}
+
+type tokenKey struct{}
+
+// NewContext returns context with verified token of the caller.
+func NewContext(ctx context.Context, t Token) context.Context {
+	return context.WithValue(ctx, tokenKey{}, t)
+}
+
+// FromContext returns verified token of the caller.
+func FromContext(ctx context.Context) (Token, bool) {
+	t, ok := ctx.Value(tokenKey{}).(Token)
+	return t, ok
+}
+
+func validHash(hash string) bool {
+	b, err := hex.DecodeString(hash)
+	return err == nil && len(b) == sha256.Size
+}
+
+func randomString(size int, encode func([]byte) string) string {
+	b := make([]byte, size)
+	rand.Read(b)
+	return encode(b)
+}
@@ -0,0 +1,112 @@
+package token
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewVerify(t *testing.T) {
+	raw, tk, err := New("ci", Scopes{ScopeRead, ScopeWrite})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(raw, "ci."))
+	assert.Equal(t, Hash(raw), tk.Hash)
+	assert.NotContains(t, tk.Spec(), raw)
+
+	id, err := ParseID(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", id)
+
+	assert.NoError(t, tk.Verify(raw, ScopeRead))
+	assert.NoError(t, tk.Verify(raw, ScopeWrite))
+	assert.ErrorIs(t, tk.Verify(raw, ScopeAdmin), ErrScope)
+	assert.ErrorIs(t, tk.Verify(raw+"x", ScopeRead), ErrInvalidToken)
+
+	tk.Revoked = true
+	assert.ErrorIs(t, tk.Verify(raw, ScopeRead), ErrRevokedToken)
+
+	t.Run("Should generate id", func(t *testing.T) {
+		raw, tk, err := New("", Scopes{ScopeAdmin})
+		require.NoError(t, err)
+		id, err := ParseID(raw)
+		require.NoError(t, err)
+		assert.Equal(t, tk.ID, id)
+		assert.NoError(t, tk.Verify(raw, ScopeWrite))
+	})
+
+	t.Run("Should reject invalid id", func(t *testing.T) {
+		_, _, err := New("c i", Scopes{ScopeRead})
+		assert.ErrorIs(t, err, ErrInvalidID)
+	})
+}
+
+func TestParseID(t *testing.T) {
+	for _, raw := range []string{"", "ci", "ci.", ".secret", "c i.secret"} {
+		_, err := ParseID(raw)
+		assert.ErrorIs(t, err, ErrInvalidToken, raw)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("read + write")
+	require.NoError(t, err)
+	assert.Equal(t, Scopes{ScopeRead, ScopeWrite}, scopes)
+	assert.Equal(t, "read+write", scopes.String())
+
+	for _, s := range []string{"", "read+", "delete"} {
+		_, err := ParseScopes(s)
+		assert.ErrorIs(t, err, ErrInvalidScope, s)
+	}
+}
+
+func TestParseSpecs(t *testing.T) {
+	_, ci, err := New("ci", Scopes{ScopeWrite})
+	require.NoError(t, err)
+	_, ops, err := New("ops", Scopes{ScopeAdmin})
+	require.NoError(t, err)
+
+	tokens, err := ParseSpecs(ci.Spec() + ", " + ops.Spec())
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, ci.Spec(), tokens[0].Spec())
+	assert.Equal(t, ops.Spec(), tokens[1].Spec())
+
+	t.Run("Should accept uppercase hash", func(t *testing.T) {
+		raw, ci, err := New("ci", Scopes{ScopeWrite})
+		require.NoError(t, err)
+		tokens, err := ParseSpecs("ci=" + strings.ToUpper(ci.Hash) + ":write")
+		require.NoError(t, err)
+		require.Len(t, tokens, 1)
+		assert.NoError(t, tokens[0].Verify(raw, ScopeWrite))
+	})
+
+	for _, s := range []string{
+		"ci",
+		"ci=" + ci.Hash,
+		"ci=abc:read",
+		"ci=" + ci.Hash + ":delete",
+	} {
+		_, err := ParseSpecs(s)
+		assert.ErrorIs(t, err, ErrInvalidSpec, s)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	_, ci, err := New("ci", Scopes{ScopeWrite})
+	require.NoError(t, err)
+
+	_, err = NewRegistry([]Token{ci, ci})
+	assert.ErrorIs(t, err, ErrDuplicateID)
+
+	r, err := NewRegistry([]Token{ci})
+	require.NoError(t, err)
+	got, err := r.Token(context.Background(), "ci")
+	require.NoError(t, err)
+	assert.Equal(t, ci, got)
+
+	_, err = r.Token(context.Background(), "ops")
+	assert.ErrorIs(t, err, ErrUnknownToken)
+}
@@ -0,0 +1,73 @@
+package proto
+
+import "github.com/niksmo/runlytics/pkg/metrics"
+
+// NewMetric returns metric message of metrics.
+func NewMetric(m metrics.Metrics) *Metric {
+	msg := &Metric{
+		Id:     m.ID,
+		Type:   m.MType,
+		Delta:  m.Delta,
+		Value:  m.Value,
+		Labels: m.Labels,
+	}
+	if m.Histogram != nil {
+		msg.Histogram = &Histogram{
+			Bounds: m.Histogram.Bounds,
+			Counts: m.Histogram.Counts,
+			Sum:    m.Histogram.Sum,
+			Count:  m.Histogram.Count,
+		}
+	}
+	return msg
+}
+
+// Metrics returns metrics of metric message.
+func (x *Metric) Metrics() metrics.Metrics {
+	m := metrics.Metrics{
+		ID:    x.GetId(),
+		MType: x.GetType(),
+		Delta: x.GetDelta(),
+		Value: x.GetValue(),
+	}
+	if len(x.GetLabels()) != 0 {
+		m.Labels = x.GetLabels()
+	}
+	if h := x.GetHistogram(); h != nil {
+		m.Histogram = &metrics.Histogram{
+			Bounds: h.GetBounds(),
+			Counts: h.GetCounts(),
+			Sum:    h.GetSum(),
+			Count:  h.GetCount(),
+		}
+	}
+	return m
+}
+
+// NewMetricsBatch returns batch message of metrics list.
+func NewMetricsBatch(ml metrics.MetricsList) *MetricsBatch {
+	return &MetricsBatch{Metrics: NewMetricList(ml)}
+}
+
+// MetricsList returns metrics list of batch message.
+func (x *MetricsBatch) MetricsList() metrics.MetricsList {
+	return MetricsListOf(x.GetMetrics())
+}
+
+// NewMetricList returns metric messages of metrics list.
+func NewMetricList(ml metrics.MetricsList) []*Metric {
+	msgs := make([]*Metric, len(ml))
+	for idx, m := range ml {
+		msgs[idx] = NewMetric(m)
+	}
+	return msgs
+}
+
+// MetricsListOf returns metrics list of metric messages.
+func MetricsListOf(msgs []*Metric) metrics.MetricsList {
+	ml := make(metrics.MetricsList, len(msgs))
+	for idx, msg := range msgs {
+		ml[idx] = msg.Metrics()
+	}
+	return ml
+}
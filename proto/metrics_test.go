@@ -0,0 +1,33 @@
+package proto_test
+
+import (
+	"testing"
+
+	"github.com/niksmo/runlytics/pkg/metrics"
+	pb "github.com/niksmo/runlytics/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMetricsBatch(t *testing.T) {
+	h := metrics.NewHistogram(0.1, 1)
+	h.Observe(0.5)
+	ml := metrics.MetricsList{
+		{ID: "Alloc", MType: metrics.MTypeGauge, Value: 123.45},
+		{
+			ID:     "PollCount",
+			MType:  metrics.MTypeCounter,
+			Delta:  -5,
+			Labels: metrics.Labels{"host": "a"},
+		},
+		{ID: "Latency", MType: metrics.MTypeHistogram, Histogram: &h},
+	}
+
+	data, err := proto.Marshal(pb.NewMetricsBatch(ml))
+	require.NoError(t, err)
+
+	var batch pb.MetricsBatch
+	require.NoError(t, proto.Unmarshal(data, &batch))
+	assert.Equal(t, ml, batch.MetricsList())
+}
@@ -9,6 +9,8 @@ package proto
 import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -21,9 +23,84 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type ItemStatus int32
+
+const (
+	ItemStatus_ITEM_STATUS_UNSPECIFIED ItemStatus = 0
+	ItemStatus_ITEM_STATUS_APPLIED     ItemStatus = 1
+	ItemStatus_ITEM_STATUS_INVALID     ItemStatus = 2
+	ItemStatus_ITEM_STATUS_FAILED      ItemStatus = 3
+)
+
+// Enum value maps for ItemStatus.
+var (
+	ItemStatus_name = map[int32]string{
+		0: "ITEM_STATUS_UNSPECIFIED",
+		1: "ITEM_STATUS_APPLIED",
+		2: "ITEM_STATUS_INVALID",
+		3: "ITEM_STATUS_FAILED",
+	}
+	ItemStatus_value = map[string]int32{
+		"ITEM_STATUS_UNSPECIFIED": 0,
+		"ITEM_STATUS_APPLIED":     1,
+		"ITEM_STATUS_INVALID":     2,
+		"ITEM_STATUS_FAILED":      3,
+	}
+)
+
+func (x ItemStatus) Enum() *ItemStatus {
+	p := new(ItemStatus)
+	*p = x
+	return p
+}
+
+func (x ItemStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ItemStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_runlytics_proto_enumTypes[0].Descriptor()
+}
+
+func (ItemStatus) Type() protoreflect.EnumType {
+	return &file_proto_runlytics_proto_enumTypes[0]
+}
+
+func (x ItemStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ItemStatus.Descriptor instead.
+func (ItemStatus) EnumDescriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{0}
+}
+
+// Batch is serialized MetricsBatch, encrypted by server public key.
+// Hash of HashSHA256 metadata is calculated over serialized batch
+// before encryption, prefixed by replay stamp if batch is stamped.
+//
+// Batch with agent_id is applied once per seq,
+// duplicate is acknowledged without update.
+// Partial batch applies valid items only, see BatchUpdateResponse items.
 type BatchUpdateRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Metrics       []byte                 `protobuf:"bytes,1,opt,name=metrics,proto3" json:"metrics,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Deprecated: gob encoded metrics list, accepted only if server
+	// legacy gob is enabled. Use batch instead.
+	//
+	// Deprecated: Marked as deprecated in proto/runlytics.proto.
+	Metrics []byte `protobuf:"bytes,1,opt,name=metrics,proto3" json:"metrics,omitempty"`
+	AgentId string `protobuf:"bytes,2,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	Seq     uint64 `protobuf:"varint,3,opt,name=seq,proto3" json:"seq,omitempty"`
+	Partial bool   `protobuf:"varint,4,opt,name=partial,proto3" json:"partial,omitempty"`
+	Batch   []byte `protobuf:"bytes,5,opt,name=batch,proto3" json:"batch,omitempty"`
+	// HashSHA256 of batch in StreamUpdates, where metadata is per stream.
+	Hash string `protobuf:"bytes,6,opt,name=hash,proto3" json:"hash,omitempty"`
+	// Ed25519 signature of agent_id, seq and batch, see package sign.
+	Signature []byte `protobuf:"bytes,7,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Replay stamp of batch in StreamUpdates, X-Timestamp and X-Nonce
+	// metadata in BatchUpdate, see package replay.
+	Timestamp     int64  `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // unix time in milliseconds
+	Nonce         string `protobuf:"bytes,9,opt,name=nonce,proto3" json:"nonce,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -58,6 +135,7 @@ func (*BatchUpdateRequest) Descriptor() ([]byte, []int) {
 	return file_proto_runlytics_proto_rawDescGZIP(), []int{0}
 }
 
+// Deprecated: Marked as deprecated in proto/runlytics.proto.
 func (x *BatchUpdateRequest) GetMetrics() []byte {
 	if x != nil {
 		return x.Metrics
@@ -65,16 +143,273 @@ func (x *BatchUpdateRequest) GetMetrics() []byte {
 	return nil
 }
 
+func (x *BatchUpdateRequest) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *BatchUpdateRequest) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *BatchUpdateRequest) GetPartial() bool {
+	if x != nil {
+		return x.Partial
+	}
+	return false
+}
+
+func (x *BatchUpdateRequest) GetBatch() []byte {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *BatchUpdateRequest) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *BatchUpdateRequest) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *BatchUpdateRequest) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *BatchUpdateRequest) GetNonce() string {
+	if x != nil {
+		return x.Nonce
+	}
+	return ""
+}
+
+type MetricsBatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricsBatch) Reset() {
+	*x = MetricsBatch{}
+	mi := &file_proto_runlytics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricsBatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricsBatch) ProtoMessage() {}
+
+func (x *MetricsBatch) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricsBatch.ProtoReflect.Descriptor instead.
+func (*MetricsBatch) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MetricsBatch) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+// Delta is set for counter, value for gauge and histogram for histogram.
+type Metric struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"` // gauge, counter or histogram
+	Delta         int64                  `protobuf:"zigzag64,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	Value         float64                `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"`
+	Histogram     *Histogram             `protobuf:"bytes,5,opt,name=histogram,proto3" json:"histogram,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Metric) Reset() {
+	*x = Metric{}
+	mi := &file_proto_runlytics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Metric) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Metric) ProtoMessage() {}
+
+func (x *Metric) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Metric.ProtoReflect.Descriptor instead.
+func (*Metric) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Metric) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Metric) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Metric) GetDelta() int64 {
+	if x != nil {
+		return x.Delta
+	}
+	return 0
+}
+
+func (x *Metric) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *Metric) GetHistogram() *Histogram {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+func (x *Metric) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+// Counts has one more bucket than bounds, the last is +Inf bucket.
+type Histogram struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        []float64              `protobuf:"fixed64,1,rep,packed,name=bounds,proto3" json:"bounds,omitempty"`
+	Counts        []uint64               `protobuf:"varint,2,rep,packed,name=counts,proto3" json:"counts,omitempty"`
+	Sum           float64                `protobuf:"fixed64,3,opt,name=sum,proto3" json:"sum,omitempty"`
+	Count         uint64                 `protobuf:"varint,4,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_proto_runlytics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Histogram) GetBounds() []float64 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Histogram) GetCounts() []uint64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Histogram) GetSum() float64 {
+	if x != nil {
+		return x.Sum
+	}
+	return 0
+}
+
+func (x *Histogram) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Items are set for partial batch only, one per request item.
 type BatchUpdateResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UpdatedCount  uint32                 `protobuf:"varint,1,opt,name=updated_count,json=updatedCount,proto3" json:"updated_count,omitempty"`
+	Duplicate     bool                   `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	Items         []*ItemResult          `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *BatchUpdateResponse) Reset() {
 	*x = BatchUpdateResponse{}
-	mi := &file_proto_runlytics_proto_msgTypes[1]
+	mi := &file_proto_runlytics_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -86,7 +421,7 @@ func (x *BatchUpdateResponse) String() string {
 func (*BatchUpdateResponse) ProtoMessage() {}
 
 func (x *BatchUpdateResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_runlytics_proto_msgTypes[1]
+	mi := &file_proto_runlytics_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -99,7 +434,7 @@ func (x *BatchUpdateResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BatchUpdateResponse.ProtoReflect.Descriptor instead.
 func (*BatchUpdateResponse) Descriptor() ([]byte, []int) {
-	return file_proto_runlytics_proto_rawDescGZIP(), []int{1}
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *BatchUpdateResponse) GetUpdatedCount() uint32 {
@@ -109,17 +444,898 @@ func (x *BatchUpdateResponse) GetUpdatedCount() uint32 {
 	return 0
 }
 
-var File_proto_runlytics_proto protoreflect.FileDescriptor
+func (x *BatchUpdateResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
 
-const file_proto_runlytics_proto_rawDesc = "" +
-	"\n" +
-	"\x15proto/runlytics.proto\x12\trunlytics\".\n" +
-	"\x12BatchUpdateRequest\x12\x18\n" +
-	"\ametrics\x18\x01 \x01(\fR\ametrics\":\n" +
-	"\x13BatchUpdateResponse\x12#\n" +
-	"\rupdated_count\x18\x01 \x01(\rR\fupdatedCount2[\n" +
+func (x *BatchUpdateResponse) GetItems() []*ItemResult {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// Ack of StreamUpdates batch. Code and message are gRPC status
+// of the batch, response is set only if code is OK.
+type BatchUpdateAck struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           uint64                 `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Code          uint32                 `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Response      *BatchUpdateResponse   `protobuf:"bytes,4,opt,name=response,proto3" json:"response,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchUpdateAck) Reset() {
+	*x = BatchUpdateAck{}
+	mi := &file_proto_runlytics_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchUpdateAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchUpdateAck) ProtoMessage() {}
+
+func (x *BatchUpdateAck) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchUpdateAck.ProtoReflect.Descriptor instead.
+func (*BatchUpdateAck) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchUpdateAck) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *BatchUpdateAck) GetCode() uint32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *BatchUpdateAck) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *BatchUpdateAck) GetResponse() *BatchUpdateResponse {
+	if x != nil {
+		return x.Response
+	}
+	return nil
+}
+
+// Error is set for invalid and failed items.
+type ItemResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Index         uint32                 `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Status        ItemStatus             `protobuf:"varint,2,opt,name=status,proto3,enum=runlytics.ItemStatus" json:"status,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ItemResult) Reset() {
+	*x = ItemResult{}
+	mi := &file_proto_runlytics_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ItemResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ItemResult) ProtoMessage() {}
+
+func (x *ItemResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ItemResult.ProtoReflect.Descriptor instead.
+func (*ItemResult) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ItemResult) GetIndex() uint32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ItemResult) GetStatus() ItemStatus {
+	if x != nil {
+		return x.Status
+	}
+	return ItemStatus_ITEM_STATUS_UNSPECIFIED
+}
+
+func (x *ItemResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type UpdateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRequest) Reset() {
+	*x = UpdateRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRequest) ProtoMessage() {}
+
+func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UpdateRequest) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+type UpdateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateResponse) Reset() {
+	*x = UpdateResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateResponse) ProtoMessage() {}
+
+func (x *UpdateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateResponse.ProtoReflect.Descriptor instead.
+func (*UpdateResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UpdateResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+type ValueRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueRequest) Reset() {
+	*x = ValueRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueRequest) ProtoMessage() {}
+
+func (x *ValueRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueRequest.ProtoReflect.Descriptor instead.
+func (*ValueRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ValueRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ValueRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ValueRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type ValueResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric,proto3" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueResponse) Reset() {
+	*x = ValueResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueResponse) ProtoMessage() {}
+
+func (x *ValueResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueResponse.ProtoReflect.Descriptor instead.
+func (*ValueResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ValueResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+// Only metrics which labels match the filter are listed.
+type ListRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Labels        map[string]string      `protobuf:"bytes,1,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListRequest) Reset() {
+	*x = ListRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListRequest) ProtoMessage() {}
+
+func (x *ListRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListRequest.ProtoReflect.Descriptor instead.
+func (*ListRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+// Metrics are ordered by type, id and labels.
+type ListResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics,proto3" json:"metrics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListResponse) Reset() {
+	*x = ListResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListResponse) ProtoMessage() {}
+
+func (x *ListResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListResponse.ProtoReflect.Descriptor instead.
+func (*ListResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+type PingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingRequest) Reset() {
+	*x = PingRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingRequest) ProtoMessage() {}
+
+func (x *PingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingRequest.ProtoReflect.Descriptor instead.
+func (*PingRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{13}
+}
+
+type PingResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingResponse) Reset() {
+	*x = PingResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingResponse) ProtoMessage() {}
+
+func (x *PingResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingResponse.ProtoReflect.Descriptor instead.
+func (*PingResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{14}
+}
+
+// Omitted from, to, step and func are set to defaults:
+// last hour range, one minute step and avg func.
+type RangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	From          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=from,proto3" json:"from,omitempty"`
+	To            *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=to,proto3" json:"to,omitempty"`
+	Step          *durationpb.Duration   `protobuf:"bytes,6,opt,name=step,proto3" json:"step,omitempty"`
+	Func          string                 `protobuf:"bytes,7,opt,name=func,proto3" json:"func,omitempty"` // avg, min, max, last, sum or rate
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RangeRequest) Reset() {
+	*x = RangeRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RangeRequest) ProtoMessage() {}
+
+func (x *RangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RangeRequest.ProtoReflect.Descriptor instead.
+func (*RangeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *RangeRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *RangeRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *RangeRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *RangeRequest) GetFrom() *timestamppb.Timestamp {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *RangeRequest) GetTo() *timestamppb.Timestamp {
+	if x != nil {
+		return x.To
+	}
+	return nil
+}
+
+func (x *RangeRequest) GetStep() *durationpb.Duration {
+	if x != nil {
+		return x.Step
+	}
+	return nil
+}
+
+func (x *RangeRequest) GetFunc() string {
+	if x != nil {
+		return x.Func
+	}
+	return ""
+}
+
+type Point struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ts            *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=ts,proto3" json:"ts,omitempty"`
+	Value         float64                `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Point) Reset() {
+	*x = Point{}
+	mi := &file_proto_runlytics_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Point) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Point) ProtoMessage() {}
+
+func (x *Point) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Point.ProtoReflect.Descriptor instead.
+func (*Point) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Point) GetTs() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Ts
+	}
+	return nil
+}
+
+func (x *Point) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type RangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Points        []*Point               `protobuf:"bytes,1,rep,name=points,proto3" json:"points,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RangeResponse) Reset() {
+	*x = RangeResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RangeResponse) ProtoMessage() {}
+
+func (x *RangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RangeResponse.ProtoReflect.Descriptor instead.
+func (*RangeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RangeResponse) GetPoints() []*Point {
+	if x != nil {
+		return x.Points
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_proto_runlytics_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeleteRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *DeleteRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+type DeleteResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteResponse) Reset() {
+	*x = DeleteResponse{}
+	mi := &file_proto_runlytics_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteResponse) ProtoMessage() {}
+
+func (x *DeleteResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_runlytics_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteResponse.ProtoReflect.Descriptor instead.
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return file_proto_runlytics_proto_rawDescGZIP(), []int{19}
+}
+
+var File_proto_runlytics_proto protoreflect.FileDescriptor
+
+const file_proto_runlytics_proto_rawDesc = "" +
+	"\n" +
+	"\x15proto/runlytics.proto\x12\trunlytics\x1a\x1egoogle/protobuf/duration.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf5\x01\n" +
+	"\x12BatchUpdateRequest\x12\x1c\n" +
+	"\ametrics\x18\x01 \x01(\fB\x02\x18\x01R\ametrics\x12\x19\n" +
+	"\bagent_id\x18\x02 \x01(\tR\aagentId\x12\x10\n" +
+	"\x03seq\x18\x03 \x01(\x04R\x03seq\x12\x18\n" +
+	"\apartial\x18\x04 \x01(\bR\apartial\x12\x14\n" +
+	"\x05batch\x18\x05 \x01(\fR\x05batch\x12\x12\n" +
+	"\x04hash\x18\x06 \x01(\tR\x04hash\x12\x1c\n" +
+	"\tsignature\x18\a \x01(\fR\tsignature\x12\x1c\n" +
+	"\ttimestamp\x18\b \x01(\x03R\ttimestamp\x12\x14\n" +
+	"\x05nonce\x18\t \x01(\tR\x05nonce\";\n" +
+	"\fMetricsBatch\x12+\n" +
+	"\ametrics\x18\x01 \x03(\v2\x11.runlytics.MetricR\ametrics\"\xfe\x01\n" +
+	"\x06Metric\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x14\n" +
+	"\x05delta\x18\x03 \x01(\x12R\x05delta\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\x01R\x05value\x122\n" +
+	"\thistogram\x18\x05 \x01(\v2\x14.runlytics.HistogramR\thistogram\x125\n" +
+	"\x06labels\x18\x06 \x03(\v2\x1d.runlytics.Metric.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"c\n" +
+	"\tHistogram\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\x01R\x06bounds\x12\x16\n" +
+	"\x06counts\x18\x02 \x03(\x04R\x06counts\x12\x10\n" +
+	"\x03sum\x18\x03 \x01(\x01R\x03sum\x12\x14\n" +
+	"\x05count\x18\x04 \x01(\x04R\x05count\"\x85\x01\n" +
+	"\x13BatchUpdateResponse\x12#\n" +
+	"\rupdated_count\x18\x01 \x01(\rR\fupdatedCount\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\x12+\n" +
+	"\x05items\x18\x03 \x03(\v2\x15.runlytics.ItemResultR\x05items\"\x8c\x01\n" +
+	"\x0eBatchUpdateAck\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x04R\x03seq\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\rR\x04code\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12:\n" +
+	"\bresponse\x18\x04 \x01(\v2\x1e.runlytics.BatchUpdateResponseR\bresponse\"g\n" +
+	"\n" +
+	"ItemResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\rR\x05index\x12-\n" +
+	"\x06status\x18\x02 \x01(\x0e2\x15.runlytics.ItemStatusR\x06status\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\":\n" +
+	"\rUpdateRequest\x12)\n" +
+	"\x06metric\x18\x01 \x01(\v2\x11.runlytics.MetricR\x06metric\";\n" +
+	"\x0eUpdateResponse\x12)\n" +
+	"\x06metric\x18\x01 \x01(\v2\x11.runlytics.MetricR\x06metric\"\xaa\x01\n" +
+	"\fValueRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12;\n" +
+	"\x06labels\x18\x03 \x03(\v2#.runlytics.ValueRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\":\n" +
+	"\rValueResponse\x12)\n" +
+	"\x06metric\x18\x01 \x01(\v2\x11.runlytics.MetricR\x06metric\"\x84\x01\n" +
+	"\vListRequest\x12:\n" +
+	"\x06labels\x18\x01 \x03(\v2\".runlytics.ListRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\";\n" +
+	"\fListResponse\x12+\n" +
+	"\ametrics\x18\x01 \x03(\v2\x11.runlytics.MetricR\ametrics\"\r\n" +
+	"\vPingRequest\"\x0e\n" +
+	"\fPingResponse\"\xc9\x02\n" +
+	"\fRangeRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12;\n" +
+	"\x06labels\x18\x03 \x03(\v2#.runlytics.RangeRequest.LabelsEntryR\x06labels\x12.\n" +
+	"\x04from\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04from\x12*\n" +
+	"\x02to\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x02to\x12-\n" +
+	"\x04step\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x04step\x12\x12\n" +
+	"\x04func\x18\a \x01(\tR\x04func\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"I\n" +
+	"\x05Point\x12*\n" +
+	"\x02ts\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x02ts\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\"9\n" +
+	"\rRangeResponse\x12(\n" +
+	"\x06points\x18\x01 \x03(\v2\x10.runlytics.PointR\x06points\"\xac\x01\n" +
+	"\rDeleteRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12<\n" +
+	"\x06labels\x18\x03 \x03(\v2$.runlytics.DeleteRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x10\n" +
+	"\x0eDeleteResponse*s\n" +
+	"\n" +
+	"ItemStatus\x12\x1b\n" +
+	"\x17ITEM_STATUS_UNSPECIFIED\x10\x00\x12\x17\n" +
+	"\x13ITEM_STATUS_APPLIED\x10\x01\x12\x17\n" +
+	"\x13ITEM_STATUS_INVALID\x10\x02\x12\x16\n" +
+	"\x12ITEM_STATUS_FAILED\x10\x032\xa0\x04\n" +
 	"\tRunlytics\x12N\n" +
-	"\vBatchUpdate\x12\x1d.runlytics.BatchUpdateRequest\x1a\x1e.runlytics.BatchUpdateResponse\"\x00B#Z!github.com/niksmo/runlytics/protob\x06proto3"
+	"\vBatchUpdate\x12\x1d.runlytics.BatchUpdateRequest\x1a\x1e.runlytics.BatchUpdateResponse\"\x00\x12O\n" +
+	"\rStreamUpdates\x12\x1d.runlytics.BatchUpdateRequest\x1a\x19.runlytics.BatchUpdateAck\"\x00(\x010\x01\x12?\n" +
+	"\x06Update\x12\x18.runlytics.UpdateRequest\x1a\x19.runlytics.UpdateResponse\"\x00\x12<\n" +
+	"\x05Value\x12\x17.runlytics.ValueRequest\x1a\x18.runlytics.ValueResponse\"\x00\x129\n" +
+	"\x04List\x12\x16.runlytics.ListRequest\x1a\x17.runlytics.ListResponse\"\x00\x129\n" +
+	"\x04Ping\x12\x16.runlytics.PingRequest\x1a\x17.runlytics.PingResponse\"\x00\x12<\n" +
+	"\x05Range\x12\x17.runlytics.RangeRequest\x1a\x18.runlytics.RangeResponse\"\x00\x12?\n" +
+	"\x06Delete\x12\x18.runlytics.DeleteRequest\x1a\x19.runlytics.DeleteResponse\"\x00B#Z!github.com/niksmo/runlytics/protob\x06proto3"
 
 var (
 	file_proto_runlytics_proto_rawDescOnce sync.Once
@@ -133,19 +1349,79 @@ func file_proto_runlytics_proto_rawDescGZIP() []byte {
 	return file_proto_runlytics_proto_rawDescData
 }
 
-var file_proto_runlytics_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_runlytics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_proto_runlytics_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_proto_runlytics_proto_goTypes = []any{
-	(*BatchUpdateRequest)(nil),  // 0: runlytics.BatchUpdateRequest
-	(*BatchUpdateResponse)(nil), // 1: runlytics.BatchUpdateResponse
+	(ItemStatus)(0),               // 0: runlytics.ItemStatus
+	(*BatchUpdateRequest)(nil),    // 1: runlytics.BatchUpdateRequest
+	(*MetricsBatch)(nil),          // 2: runlytics.MetricsBatch
+	(*Metric)(nil),                // 3: runlytics.Metric
+	(*Histogram)(nil),             // 4: runlytics.Histogram
+	(*BatchUpdateResponse)(nil),   // 5: runlytics.BatchUpdateResponse
+	(*BatchUpdateAck)(nil),        // 6: runlytics.BatchUpdateAck
+	(*ItemResult)(nil),            // 7: runlytics.ItemResult
+	(*UpdateRequest)(nil),         // 8: runlytics.UpdateRequest
+	(*UpdateResponse)(nil),        // 9: runlytics.UpdateResponse
+	(*ValueRequest)(nil),          // 10: runlytics.ValueRequest
+	(*ValueResponse)(nil),         // 11: runlytics.ValueResponse
+	(*ListRequest)(nil),           // 12: runlytics.ListRequest
+	(*ListResponse)(nil),          // 13: runlytics.ListResponse
+	(*PingRequest)(nil),           // 14: runlytics.PingRequest
+	(*PingResponse)(nil),          // 15: runlytics.PingResponse
+	(*RangeRequest)(nil),          // 16: runlytics.RangeRequest
+	(*Point)(nil),                 // 17: runlytics.Point
+	(*RangeResponse)(nil),         // 18: runlytics.RangeResponse
+	(*DeleteRequest)(nil),         // 19: runlytics.DeleteRequest
+	(*DeleteResponse)(nil),        // 20: runlytics.DeleteResponse
+	nil,                           // 21: runlytics.Metric.LabelsEntry
+	nil,                           // 22: runlytics.ValueRequest.LabelsEntry
+	nil,                           // 23: runlytics.ListRequest.LabelsEntry
+	nil,                           // 24: runlytics.RangeRequest.LabelsEntry
+	nil,                           // 25: runlytics.DeleteRequest.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 26: google.protobuf.Timestamp
+	(*durationpb.Duration)(nil),   // 27: google.protobuf.Duration
 }
 var file_proto_runlytics_proto_depIdxs = []int32{
-	0, // 0: runlytics.Runlytics.BatchUpdate:input_type -> runlytics.BatchUpdateRequest
-	1, // 1: runlytics.Runlytics.BatchUpdate:output_type -> runlytics.BatchUpdateResponse
-	1, // [1:2] is the sub-list for method output_type
-	0, // [0:1] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	3,  // 0: runlytics.MetricsBatch.metrics:type_name -> runlytics.Metric
+	4,  // 1: runlytics.Metric.histogram:type_name -> runlytics.Histogram
+	21, // 2: runlytics.Metric.labels:type_name -> runlytics.Metric.LabelsEntry
+	7,  // 3: runlytics.BatchUpdateResponse.items:type_name -> runlytics.ItemResult
+	5,  // 4: runlytics.BatchUpdateAck.response:type_name -> runlytics.BatchUpdateResponse
+	0,  // 5: runlytics.ItemResult.status:type_name -> runlytics.ItemStatus
+	3,  // 6: runlytics.UpdateRequest.metric:type_name -> runlytics.Metric
+	3,  // 7: runlytics.UpdateResponse.metric:type_name -> runlytics.Metric
+	22, // 8: runlytics.ValueRequest.labels:type_name -> runlytics.ValueRequest.LabelsEntry
+	3,  // 9: runlytics.ValueResponse.metric:type_name -> runlytics.Metric
+	23, // 10: runlytics.ListRequest.labels:type_name -> runlytics.ListRequest.LabelsEntry
+	3,  // 11: runlytics.ListResponse.metrics:type_name -> runlytics.Metric
+	24, // 12: runlytics.RangeRequest.labels:type_name -> runlytics.RangeRequest.LabelsEntry
+	26, // 13: runlytics.RangeRequest.from:type_name -> google.protobuf.Timestamp
+	26, // 14: runlytics.RangeRequest.to:type_name -> google.protobuf.Timestamp
+	27, // 15: runlytics.RangeRequest.step:type_name -> google.protobuf.Duration
+	26, // 16: runlytics.Point.ts:type_name -> google.protobuf.Timestamp
+	17, // 17: runlytics.RangeResponse.points:type_name -> runlytics.Point
+	25, // 18: runlytics.DeleteRequest.labels:type_name -> runlytics.DeleteRequest.LabelsEntry
+	1,  // 19: runlytics.Runlytics.BatchUpdate:input_type -> runlytics.BatchUpdateRequest
+	1,  // 20: runlytics.Runlytics.StreamUpdates:input_type -> runlytics.BatchUpdateRequest
+	8,  // 21: runlytics.Runlytics.Update:input_type -> runlytics.UpdateRequest
+	10, // 22: runlytics.Runlytics.Value:input_type -> runlytics.ValueRequest
+	12, // 23: runlytics.Runlytics.List:input_type -> runlytics.ListRequest
+	14, // 24: runlytics.Runlytics.Ping:input_type -> runlytics.PingRequest
+	16, // 25: runlytics.Runlytics.Range:input_type -> runlytics.RangeRequest
+	19, // 26: runlytics.Runlytics.Delete:input_type -> runlytics.DeleteRequest
+	5,  // 27: runlytics.Runlytics.BatchUpdate:output_type -> runlytics.BatchUpdateResponse
+	6,  // 28: runlytics.Runlytics.StreamUpdates:output_type -> runlytics.BatchUpdateAck
+	9,  // 29: runlytics.Runlytics.Update:output_type -> runlytics.UpdateResponse
+	11, // 30: runlytics.Runlytics.Value:output_type -> runlytics.ValueResponse
+	13, // 31: runlytics.Runlytics.List:output_type -> runlytics.ListResponse
+	15, // 32: runlytics.Runlytics.Ping:output_type -> runlytics.PingResponse
+	18, // 33: runlytics.Runlytics.Range:output_type -> runlytics.RangeResponse
+	20, // 34: runlytics.Runlytics.Delete:output_type -> runlytics.DeleteResponse
+	27, // [27:35] is the sub-list for method output_type
+	19, // [19:27] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_proto_runlytics_proto_init() }
@@ -158,13 +1434,14 @@ func file_proto_runlytics_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_runlytics_proto_rawDesc), len(file_proto_runlytics_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   2,
+			NumEnums:      1,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_proto_runlytics_proto_goTypes,
 		DependencyIndexes: file_proto_runlytics_proto_depIdxs,
+		EnumInfos:         file_proto_runlytics_proto_enumTypes,
 		MessageInfos:      file_proto_runlytics_proto_msgTypes,
 	}.Build()
 	File_proto_runlytics_proto = out.File
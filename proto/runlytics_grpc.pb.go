@@ -19,7 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Runlytics_BatchUpdate_FullMethodName = "/runlytics.Runlytics/BatchUpdate"
+	Runlytics_BatchUpdate_FullMethodName   = "/runlytics.Runlytics/BatchUpdate"
+	Runlytics_StreamUpdates_FullMethodName = "/runlytics.Runlytics/StreamUpdates"
+	Runlytics_Update_FullMethodName        = "/runlytics.Runlytics/Update"
+	Runlytics_Value_FullMethodName         = "/runlytics.Runlytics/Value"
+	Runlytics_List_FullMethodName          = "/runlytics.Runlytics/List"
+	Runlytics_Ping_FullMethodName          = "/runlytics.Runlytics/Ping"
+	Runlytics_Range_FullMethodName         = "/runlytics.Runlytics/Range"
+	Runlytics_Delete_FullMethodName        = "/runlytics.Runlytics/Delete"
 )
 
 // RunlyticsClient is the client API for Runlytics service.
@@ -27,6 +34,17 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type RunlyticsClient interface {
 	BatchUpdate(ctx context.Context, in *BatchUpdateRequest, opts ...grpc.CallOption) (*BatchUpdateResponse, error)
+	// StreamUpdates keeps one long-lived stream of batches from agent,
+	// every batch is acknowledged in the stream order.
+	StreamUpdates(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchUpdateRequest, BatchUpdateAck], error)
+	// Update returns metric value after update.
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error)
+	Value(ctx context.Context, in *ValueRequest, opts ...grpc.CallOption) (*ValueResponse, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*RangeResponse, error)
+	// Delete is allowed only from trusted subnet.
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
 }
 
 type runlyticsClient struct {
@@ -47,11 +65,95 @@ func (c *runlyticsClient) BatchUpdate(ctx context.Context, in *BatchUpdateReques
 	return out, nil
 }
 
+func (c *runlyticsClient) StreamUpdates(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[BatchUpdateRequest, BatchUpdateAck], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Runlytics_ServiceDesc.Streams[0], Runlytics_StreamUpdates_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchUpdateRequest, BatchUpdateAck]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Runlytics_StreamUpdatesClient = grpc.BidiStreamingClient[BatchUpdateRequest, BatchUpdateAck]
+
+func (c *runlyticsClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*UpdateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateResponse)
+	err := c.cc.Invoke(ctx, Runlytics_Update_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runlyticsClient) Value(ctx context.Context, in *ValueRequest, opts ...grpc.CallOption) (*ValueResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValueResponse)
+	err := c.cc.Invoke(ctx, Runlytics_Value_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runlyticsClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, Runlytics_List_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runlyticsClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PingResponse)
+	err := c.cc.Invoke(ctx, Runlytics_Ping_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runlyticsClient) Range(ctx context.Context, in *RangeRequest, opts ...grpc.CallOption) (*RangeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RangeResponse)
+	err := c.cc.Invoke(ctx, Runlytics_Range_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runlyticsClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, Runlytics_Delete_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RunlyticsServer is the server API for Runlytics service.
 // All implementations must embed UnimplementedRunlyticsServer
 // for forward compatibility.
 type RunlyticsServer interface {
 	BatchUpdate(context.Context, *BatchUpdateRequest) (*BatchUpdateResponse, error)
+	// StreamUpdates keeps one long-lived stream of batches from agent,
+	// every batch is acknowledged in the stream order.
+	StreamUpdates(grpc.BidiStreamingServer[BatchUpdateRequest, BatchUpdateAck]) error
+	// Update returns metric value after update.
+	Update(context.Context, *UpdateRequest) (*UpdateResponse, error)
+	Value(context.Context, *ValueRequest) (*ValueResponse, error)
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	Range(context.Context, *RangeRequest) (*RangeResponse, error)
+	// Delete is allowed only from trusted subnet.
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
 	mustEmbedUnimplementedRunlyticsServer()
 }
 
@@ -65,6 +167,27 @@ type UnimplementedRunlyticsServer struct{}
 func (UnimplementedRunlyticsServer) BatchUpdate(context.Context, *BatchUpdateRequest) (*BatchUpdateResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BatchUpdate not implemented")
 }
+func (UnimplementedRunlyticsServer) StreamUpdates(grpc.BidiStreamingServer[BatchUpdateRequest, BatchUpdateAck]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamUpdates not implemented")
+}
+func (UnimplementedRunlyticsServer) Update(context.Context, *UpdateRequest) (*UpdateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedRunlyticsServer) Value(context.Context, *ValueRequest) (*ValueResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Value not implemented")
+}
+func (UnimplementedRunlyticsServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedRunlyticsServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
+}
+func (UnimplementedRunlyticsServer) Range(context.Context, *RangeRequest) (*RangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Range not implemented")
+}
+func (UnimplementedRunlyticsServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
 func (UnimplementedRunlyticsServer) mustEmbedUnimplementedRunlyticsServer() {}
 func (UnimplementedRunlyticsServer) testEmbeddedByValue()                   {}
 
@@ -104,6 +227,121 @@ func _Runlytics_BatchUpdate_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Runlytics_StreamUpdates_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RunlyticsServer).StreamUpdates(&grpc.GenericServerStream[BatchUpdateRequest, BatchUpdateAck]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Runlytics_StreamUpdatesServer = grpc.BidiStreamingServer[BatchUpdateRequest, BatchUpdateAck]
+
+func _Runlytics_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runlytics_Value_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValueRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).Value(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_Value_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).Value(ctx, req.(*ValueRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runlytics_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runlytics_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_Ping_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runlytics_Range_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).Range(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_Range_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).Range(ctx, req.(*RangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Runlytics_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunlyticsServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Runlytics_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunlyticsServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Runlytics_ServiceDesc is the grpc.ServiceDesc for Runlytics service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -115,7 +353,38 @@ var Runlytics_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "BatchUpdate",
 			Handler:    _Runlytics_BatchUpdate_Handler,
 		},
+		{
+			MethodName: "Update",
+			Handler:    _Runlytics_Update_Handler,
+		},
+		{
+			MethodName: "Value",
+			Handler:    _Runlytics_Value_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _Runlytics_List_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _Runlytics_Ping_Handler,
+		},
+		{
+			MethodName: "Range",
+			Handler:    _Runlytics_Range_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Runlytics_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamUpdates",
+			Handler:       _Runlytics_StreamUpdates_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/runlytics.proto",
 }